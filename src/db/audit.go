@@ -0,0 +1,175 @@
+package db
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// audit operations
+const (
+	AuditCreate = "create"
+	AuditUpdate = "update"
+	AuditDelete = "delete"
+)
+
+// AuditRecord is an audit trail entry of a function config mutation
+type AuditRecord struct {
+	Key       string    `json:"key"`
+	Operation string    `json:"operation"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// AuditSink is a pluggable destination of audit records
+type AuditSink interface {
+	Record(record AuditRecord)
+}
+
+// LogAuditSink writes audit records as structured log entries
+type LogAuditSink struct{}
+
+// Record is an AuditSink interface method
+func (l *LogAuditSink) Record(record AuditRecord) {
+	util.LogEntry("audit", "").WithFields(log.Fields{
+		"key":       record.Key,
+		"operation": record.Operation,
+		"actor":     record.Actor,
+		"timestamp": record.Timestamp,
+	}).Infof("function config %s", record.Operation)
+}
+
+var (
+	auditSink     AuditSink = &LogAuditSink{}
+	auditSinkLock sync.RWMutex
+)
+
+// SetAuditSink replaces the audit sink
+func SetAuditSink(sink AuditSink) {
+	auditSinkLock.Lock()
+	defer auditSinkLock.Unlock()
+	auditSink = sink
+}
+
+func audit(operation, key, actor string) {
+	auditSinkLock.RLock()
+	defer auditSinkLock.RUnlock()
+	auditSink.Record(AuditRecord{
+		Key:       key,
+		Operation: operation,
+		Actor:     actor,
+		Timestamp: time.Now(),
+	})
+}
+
+// auditedDb records exactly one audit record for every successful mutation on the embedded Db
+type auditedDb struct {
+	Db
+	actor string
+}
+
+// WithAuditActor wraps a database so that mutations are audited on behalf of the actor
+func WithAuditActor(database Db, actor string) Db {
+	return &auditedDb{Db: database, actor: actor}
+}
+
+// Create creates a new document
+func (a *auditedDb) Create(functionCfg *model.FunctionConfig) (string, error) {
+	key, err := a.Db.Create(functionCfg)
+	if err == nil {
+		audit(AuditCreate, key, a.actor)
+	}
+	return key, err
+}
+
+// Update updates or creates a document
+func (a *auditedDb) Update(functionCfg *model.FunctionConfig) (string, error) {
+	key, err := a.Db.Update(functionCfg)
+	if err == nil {
+		audit(AuditUpdate, key, a.actor)
+	}
+	return key, err
+}
+
+// Clone creates a copy of a document
+func (a *auditedDb) Clone(tenant, functionName, newName string) (string, error) {
+	key, err := a.Db.Clone(tenant, functionName, newName)
+	if err == nil {
+		audit(AuditCreate, key, a.actor)
+	}
+	return key, err
+}
+
+// Touch refreshes a document UpdatedAt
+func (a *auditedDb) Touch(tenant, functionName string) (string, error) {
+	key, err := a.Db.Touch(tenant, functionName)
+	if err == nil {
+		audit(AuditUpdate, key, a.actor)
+	}
+	return key, err
+}
+
+// Delete deletes a document
+func (a *auditedDb) Delete(tenant, functionName string) (string, error) {
+	key, err := a.Db.Delete(tenant, functionName)
+	if err == nil {
+		audit(AuditDelete, key, a.actor)
+	}
+	return key, err
+}
+
+// DeleteByKey deletes a document based on key
+func (a *auditedDb) DeleteByKey(hashedTopicKey string) (string, error) {
+	key, err := a.Db.DeleteByKey(hashedTopicKey)
+	if err == nil {
+		audit(AuditDelete, key, a.actor)
+	}
+	return key, err
+}
+
+// DeleteByTenant deletes all documents of the tenant, every document no longer found afterwards is audited
+func (a *auditedDb) DeleteByTenant(tenant string) (int, error) {
+	existing, err := a.Db.LoadByTenant(tenant)
+	if err != nil {
+		return 0, err
+	}
+	deleted, err := a.Db.DeleteByTenant(tenant)
+	for _, cfg := range existing {
+		if _, gerr := a.Db.GetByKey(cfg.ID); errors.Is(gerr, ErrDocNotFound) {
+			audit(AuditDelete, cfg.ID, a.actor)
+		}
+	}
+	return deleted, err
+}
+
+// AddWebhook adds a webhook to a document
+func (a *auditedDb) AddWebhook(key string, wh model.WebhookConfig) error {
+	err := a.Db.AddWebhook(key, wh)
+	if err == nil {
+		audit(AuditUpdate, key, a.actor)
+	}
+	return err
+}
+
+// UpdateWebhook updates a webhook of a document
+func (a *auditedDb) UpdateWebhook(key string, wh model.WebhookConfig) error {
+	err := a.Db.UpdateWebhook(key, wh)
+	if err == nil {
+		audit(AuditUpdate, key, a.actor)
+	}
+	return err
+}
+
+// DeleteWebhook soft deletes a webhook of a document
+func (a *auditedDb) DeleteWebhook(key, subscription string) error {
+	err := a.Db.DeleteWebhook(key, subscription)
+	if err == nil {
+		audit(AuditUpdate, key, a.actor)
+	}
+	return err
+}
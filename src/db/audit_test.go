@@ -0,0 +1,106 @@
+package db
+
+import (
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// recordingSink keeps the audit records in memory
+type recordingSink struct {
+	records []AuditRecord
+	sync.Mutex
+}
+
+func (r *recordingSink) Record(record AuditRecord) {
+	r.Lock()
+	defer r.Unlock()
+	r.records = append(r.records, record)
+}
+
+// take returns and clears the records as operation:key pairs
+func (r *recordingSink) take() []string {
+	r.Lock()
+	defer r.Unlock()
+	taken := []string{}
+	for _, v := range r.records {
+		taken = append(taken, v.Operation+":"+v.Key)
+	}
+	r.records = nil
+	return taken
+}
+
+func TestAuditedMutations(t *testing.T) {
+	sink := &recordingSink{}
+	SetAuditSink(sink)
+	defer SetAuditSink(&LogAuditSink{})
+
+	inner, _ := NewInMemoryHandler()
+	database := WithAuditActor(inner, "tester")
+	wh := model.WebhookConfig{URL: "https://example.com/hook", Subscription: "sub", WebhookStatus: model.Activated}
+
+	steps := []struct {
+		name     string
+		mutate   func() error
+		expected []string
+	}{
+		{"create", func() error {
+			_, err := database.Create(&model.FunctionConfig{Tenant: "t1", Name: "f1"})
+			return err
+		}, []string{"create:t1f1"}},
+		{"update", func() error {
+			_, err := database.Update(&model.FunctionConfig{Tenant: "t1", Name: "f1", ID: "t1f1"})
+			return err
+		}, []string{"update:t1f1"}},
+		{"touch", func() error {
+			_, err := database.Touch("t1", "f1")
+			return err
+		}, []string{"update:t1f1"}},
+		{"clone", func() error {
+			_, err := database.Clone("t1", "f1", "f2")
+			return err
+		}, []string{"create:t1f2"}},
+		{"add webhook", func() error { return database.AddWebhook("t1f1", wh) }, []string{"update:t1f1"}},
+		{"update webhook", func() error { return database.UpdateWebhook("t1f1", wh) }, []string{"update:t1f1"}},
+		{"delete webhook", func() error { return database.DeleteWebhook("t1f1", "sub") }, []string{"update:t1f1"}},
+		{"delete by key", func() error {
+			_, err := database.DeleteByKey("t1f2")
+			return err
+		}, []string{"delete:t1f2"}},
+		{"delete by tenant", func() error {
+			if _, err := inner.Create(&model.FunctionConfig{Tenant: "t1", Name: "f3"}); err != nil {
+				return err
+			}
+			_, err := database.DeleteByTenant("t1")
+			return err
+		}, []string{"delete:t1f1", "delete:t1f3"}},
+	}
+	for _, step := range steps {
+		if err := step.mutate(); err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if records := sink.take(); !reflect.DeepEqual(records, step.expected) {
+			t.Errorf("%s: expected audit records %v, got %v", step.name, step.expected, records)
+		}
+	}
+}
+
+func TestFailedMutationIsNotAudited(t *testing.T) {
+	sink := &recordingSink{}
+	SetAuditSink(sink)
+	defer SetAuditSink(&LogAuditSink{})
+
+	inner, _ := NewInMemoryHandler()
+	database := WithAuditActor(inner, "tester")
+	if _, err := database.DeleteByKey("missing"); err == nil {
+		t.Fatal("expected a not found error")
+	}
+	if err := database.AddWebhook("missing", model.WebhookConfig{}); err == nil {
+		t.Fatal("expected a not found error")
+	}
+	if records := sink.take(); len(records) != 0 {
+		t.Fatalf("failed mutations must not be audited, got %v", records)
+	}
+}
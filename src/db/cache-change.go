@@ -0,0 +1,26 @@
+package db
+
+import "sync"
+
+// cacheHooks are the functions called after the cached documents change
+type cacheHooks struct {
+	hooks []func()
+	lock  sync.Mutex
+}
+
+// OnCacheChange registers a function called after the cached documents change.
+// The function is called synchronously so it must not block.
+func (h *cacheHooks) OnCacheChange(fn func()) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	h.hooks = append(h.hooks, fn)
+}
+
+func (h *cacheHooks) cacheChanged() {
+	h.lock.Lock()
+	hooks := append([]func(){}, h.hooks...)
+	h.lock.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+}
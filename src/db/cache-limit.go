@@ -0,0 +1,39 @@
+package db
+
+import (
+	"sync/atomic"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var cacheLimitExceeded = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "pubsub_function_db_cache_limit_exceeded_total",
+	Help: "The number of times the database cache grew past the soft limit",
+})
+
+func init() {
+	prometheus.MustRegister(cacheLimitExceeded)
+}
+
+// cacheLimit tracks whether the cache is over the soft limit
+type cacheLimit struct {
+	overLimit int32
+}
+
+// checkCacheSize reports whether the cache size exceeds the soft limit, it is counted and warned only
+// when the cache grows past the limit. The soft limit does not reject any document, it only signals memory pressure.
+func (c *cacheLimit) checkCacheSize(size int) bool {
+	cacheSoftLimit := util.ConfigInt(util.GetConfig().DbCacheSoftLimit, 10000)
+	if cacheSoftLimit <= 0 || size <= cacheSoftLimit {
+		atomic.StoreInt32(&c.overLimit, 0)
+		return false
+	}
+	if atomic.CompareAndSwapInt32(&c.overLimit, 0, 1) {
+		cacheLimitExceeded.Inc()
+		log.Warnf("database cache size %d exceeds the soft limit %d", size, cacheSoftLimit)
+	}
+	return true
+}
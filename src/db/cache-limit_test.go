@@ -0,0 +1,82 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestCheckCacheSize(t *testing.T) {
+	defer func(limit string) { util.Config.DbCacheSoftLimit = limit }(util.Config.DbCacheSoftLimit)
+	hook := test.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+
+	// the sizes are checked in turn against the same cache
+	var cache cacheLimit
+	cases := []struct {
+		limit    string
+		size     int
+		exceeded bool
+		warned   bool
+	}{
+		{"3", 3, false, false},
+		{"3", 4, true, true},
+		{"3", 5, true, false},
+		{"3", 6, true, false},
+		{"3", 3, false, false},
+		{"3", 4, true, true},
+		{"0", 100, false, false},
+		{"", 100, false, false},
+	}
+	for _, c := range cases {
+		util.Config.DbCacheSoftLimit = c.limit
+		hook.Reset()
+		before := testutil.ToFloat64(cacheLimitExceeded)
+		if exceeded := cache.checkCacheSize(c.size); exceeded != c.exceeded {
+			t.Errorf("limit %s size %d: expected exceeded %v, got %v", c.limit, c.size, c.exceeded, exceeded)
+		}
+		if counted := testutil.ToFloat64(cacheLimitExceeded) - before; counted != map[bool]float64{true: 1}[c.warned] {
+			t.Errorf("limit %s size %d: unexpected counter increment %v", c.limit, c.size, counted)
+		}
+		if warned := len(hook.AllEntries()) > 0; warned != c.warned {
+			t.Errorf("limit %s size %d: expected warning %v, got %v", c.limit, c.size, c.warned, warned)
+		}
+	}
+}
+
+func TestCacheBeyondSoftLimit(t *testing.T) {
+	defer func(limit string) { util.Config.DbCacheSoftLimit = limit }(util.Config.DbCacheSoftLimit)
+	util.Config.DbCacheSoftLimit = "2"
+
+	database, _ := NewInMemoryHandler()
+	for i := 0; i < 4; i++ {
+		if _, err := database.Create(functionOn("t1", fmt.Sprintf("f%d", i), "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgs, err := database.Load()
+	if err != nil || len(cfgs) != 4 {
+		t.Fatalf("documents beyond the soft limit must be kept, loaded %d %v", len(cfgs), err)
+	}
+	if _, err := database.GetByKey("t1f3"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the deletions bring the cache under the limit so the next growth past it is counted again
+	before := testutil.ToFloat64(cacheLimitExceeded)
+	if _, err := database.DeleteByTenant("t1"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if _, err := database.Create(functionOn("t1", fmt.Sprintf("f%d", i), "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if counted := testutil.ToFloat64(cacheLimitExceeded) - before; counted != 1 {
+		t.Errorf("expected the cache to grow past the soft limit once, counted %v", counted)
+	}
+}
@@ -0,0 +1,73 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// Clone creates a copy of the function under the new name
+func (s *PulsarHandler) Clone(tenant, functionName, newName string) (string, error) {
+	return clone(s, tenant, functionName, newName)
+}
+
+// Clone creates a copy of the function under the new name
+func (s *InMemoryHandler) Clone(tenant, functionName, newName string) (string, error) {
+	return clone(s, tenant, functionName, newName)
+}
+
+func clone(crud Crud, tenant, functionName, newName string) (string, error) {
+	if strings.TrimSpace(newName) == "" {
+		return "", fmt.Errorf("function name of the clone is missing")
+	}
+	src, err := crud.GetByKey(model.FunctionKey(tenant, functionName))
+	if err != nil {
+		return "", err
+	}
+	cfg, err := cloneConfig(src, newName)
+	if err != nil {
+		return "", err
+	}
+	return crud.Create(cfg)
+}
+
+// cloneConfig deep copies the function config under the new name. The runtime state is reset and
+// the webhook subscriptions are cleared so that they are named after the clone on creation.
+// The input subscription is regenerated so the clone neither conflicts with an exclusive subscription
+// of the source nor splits the messages of a shared one with it.
+// The clone ID is the canonical function key of the tenant and the new name rather than the GenKey hash,
+// so the clone is looked up, updated and deleted by its names like any other function.
+func cloneConfig(src *model.FunctionConfig, newName string) (*model.FunctionConfig, error) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	cfg := &model.FunctionConfig{}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return nil, err
+	}
+	cfg.Name = newName
+	cfg.ID = model.FunctionKey(cfg.Tenant, newName)
+	cfg.CreatedAt, cfg.UpdatedAt, cfg.DeletedAt = time.Time{}, time.Time{}, time.Time{}
+	cfg.IdempotencyKey, cfg.IdempotencyFingerprint = "", ""
+	if cfg.InputTopic.Subscription != "" {
+		cfg.InputTopic.Subscription = model.GenSubscriptionName()
+	}
+
+	webhooks := make([]model.WebhookConfig, 0, len(cfg.Webhooks))
+	for _, wh := range cfg.Webhooks {
+		if wh.WebhookStatus == model.Deleted {
+			continue
+		}
+		wh.Subscription = ""
+		wh.Failures = 0
+		wh.LastReply = model.WebhookReply{}
+		wh.CreatedAt, wh.UpdatedAt, wh.DeletedAt = time.Time{}, time.Time{}, time.Time{}
+		webhooks = append(webhooks, wh)
+	}
+	cfg.Webhooks = webhooks
+	return cfg, nil
+}
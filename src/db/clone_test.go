@@ -0,0 +1,103 @@
+package db
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestClone(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		src := functionOn("t1", "f1", "sub", "shared")
+		src.Webhooks = []model.WebhookConfig{
+			{URL: "http://localhost/a", SubscriptionType: "shared", Failures: 3},
+			{URL: "http://localhost/b", Subscription: "gone", SubscriptionType: "shared", WebhookStatus: model.Deleted},
+		}
+		if _, err := database.Create(src); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := database.Create(functionOn("t1", "f2", "sub", "shared")); err != nil {
+			t.Fatal(err)
+		}
+
+		cases := []struct {
+			name    string
+			source  string
+			newName string
+			err     error
+		}{
+			{"clone", "f1", "f1-copy", nil},
+			{"existing name", "f1", "f2", ErrDocAlreadyExisted},
+			{"missing source", "f9", "f9-copy", ErrDocNotFound},
+		}
+		for _, c := range cases {
+			key, err := database.Clone("t1", c.source, c.newName)
+			if !errors.Is(err, c.err) || (c.err == nil && key != model.FunctionKey("t1", c.newName)) {
+				t.Errorf("%T %s: expected key %s error %v, got %s %v", database, c.name, model.FunctionKey("t1", c.newName), c.err, key, err)
+			}
+		}
+		if _, err := database.Clone("t1", "f1", " "); err == nil {
+			t.Errorf("%T: expected the missing clone name rejected", database)
+		}
+
+		// the clone is found by its names like any other function
+		clone, err := database.GetByTopic("t1", "f1-copy")
+		if err != nil {
+			t.Fatal(err)
+		}
+		source, _ := database.GetByKey("t1f1")
+		if clone.Name != "f1-copy" || clone.InputTopic.Subscription == source.InputTopic.Subscription || clone.CreatedAt.Before(source.CreatedAt) {
+			t.Errorf("%T: unexpected clone %+v", database, clone)
+		}
+		if len(clone.Webhooks) != 1 || clone.Webhooks[0].Failures != 0 ||
+			clone.Webhooks[0].Subscription == source.Webhooks[0].Subscription {
+			t.Errorf("%T: expected the live webhook reset with its own subscription, got %+v", database, clone.Webhooks)
+		}
+
+		// the clone is independent from the source
+		clone.Webhooks[0].URL = "http://localhost/changed"
+		if source, _ = database.GetByKey("t1f1"); source.Webhooks[0].URL != "http://localhost/a" {
+			t.Errorf("%T: expected the source unchanged, got %s", database, source.Webhooks[0].URL)
+		}
+		if _, err := database.Delete("t1", "f1-copy"); err != nil {
+			t.Errorf("%T: expected the clone deleted by its names, got %v", database, err)
+		}
+		if _, err := database.GetByKey("t1f1"); err != nil {
+			t.Errorf("%T: expected the source kept, got %v", database, err)
+		}
+	}
+}
+
+func TestCloneSubscription(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		cases := []struct {
+			name        string
+			source      *model.FunctionConfig
+			regenerated bool
+		}{
+			{"exclusive", functionOn("t1", "exclusive", "sub-exclusive", ""), true},
+			{"shared", functionOn("t1", "shared", "sub-shared", "shared"), true},
+			{"no subscription", functionOn("t1", "unsubscribed", "", "exclusive"), false},
+		}
+		for _, c := range cases {
+			if _, err := database.Create(c.source); err != nil {
+				t.Fatal(err)
+			}
+			key, err := database.Clone("t1", c.source.Name, c.source.Name+"-copy")
+			if err != nil {
+				t.Errorf("%T %s: expected cloned, got %v", database, c.name, err)
+				continue
+			}
+			clone, _ := database.GetByKey(key)
+			regenerated := clone.InputTopic.Subscription != "" && clone.InputTopic.Subscription != c.source.InputTopic.Subscription
+			if regenerated != c.regenerated {
+				t.Errorf("%T %s: expected a new subscription %v, got %q", database, c.name, c.regenerated, clone.InputTopic.Subscription)
+			}
+		}
+	}
+}
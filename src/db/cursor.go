@@ -0,0 +1,69 @@
+package db
+
+import (
+	"encoding/base64"
+	"fmt"
+	"sort"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// EncodeCursor encodes the last seen ID as an opaque page cursor
+func EncodeCursor(id string) string {
+	if id == "" {
+		return ""
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// DecodeCursor decodes the last seen ID from the page cursor, an empty cursor starts from the first page
+func DecodeCursor(cursor string) (string, error) {
+	id, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return "", fmt.Errorf("invalid cursor %s", cursor)
+	}
+	return string(id), nil
+}
+
+// PaginateAfter returns the page of function configs ordered by ID after the cursor and the cursor of the next page.
+// The next cursor is empty on the last page. A zero limit returns all configs after the cursor.
+// Since the cursor is the last seen ID, configs inserted or deleted before it do not shift the next page.
+func PaginateAfter(cfgs []*model.FunctionConfig, cursor string, limit int) ([]*model.FunctionConfig, string, error) {
+	after, err := DecodeCursor(cursor)
+	if err != nil {
+		return nil, "", err
+	}
+	sort.Slice(cfgs, func(i, j int) bool { return cfgs[i].ID < cfgs[j].ID })
+	start := 0
+	if after != "" {
+		start = sort.Search(len(cfgs), func(i int) bool { return cfgs[i].ID > after })
+	}
+	end := len(cfgs)
+	if limit > 0 && start+limit < end {
+		end = start + limit
+	}
+	page := cfgs[start:end]
+	next := ""
+	if end < len(cfgs) && len(page) > 0 {
+		next = EncodeCursor(page[len(page)-1].ID)
+	}
+	return page, next, nil
+}
+
+// LoadAfter loads a page of documents after the cursor and returns the cursor of the next page
+func (s *PulsarHandler) LoadAfter(cursor string, limit int) ([]*model.FunctionConfig, string, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	return PaginateAfter(cfgs, cursor, limit)
+}
+
+// LoadAfter loads a page of documents after the cursor and returns the cursor of the next page
+func (s *InMemoryHandler) LoadAfter(cursor string, limit int) ([]*model.FunctionConfig, string, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, "", err
+	}
+	return PaginateAfter(cfgs, cursor, limit)
+}
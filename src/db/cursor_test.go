@@ -0,0 +1,78 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func pageIDs(cfgs []*model.FunctionConfig) string {
+	ids := ""
+	for _, cfg := range cfgs {
+		ids += cfg.ID + " "
+	}
+	return ids
+}
+
+func TestPaginateAfter(t *testing.T) {
+	cfgs := []*model.FunctionConfig{{ID: "c"}, {ID: "a"}, {ID: "e"}, {ID: "b"}, {ID: "d"}}
+	cases := []struct {
+		name   string
+		cursor string
+		limit  int
+		ids    string
+		next   string
+	}{
+		{"first page", "", 2, "a b ", EncodeCursor("b")},
+		{"middle page", EncodeCursor("b"), 2, "c d ", EncodeCursor("d")},
+		{"last page", EncodeCursor("d"), 2, "e ", ""},
+		{"exact last page", EncodeCursor("c"), 2, "d e ", ""},
+		{"no limit", EncodeCursor("a"), 0, "b c d e ", ""},
+		{"cursor of a removed ID", EncodeCursor("bb"), 1, "c ", EncodeCursor("c")},
+		{"after the end", EncodeCursor("z"), 2, "", ""},
+	}
+	for _, c := range cases {
+		page, next, err := PaginateAfter(cfgs, c.cursor, c.limit)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if ids := pageIDs(page); ids != c.ids || next != c.next {
+			t.Errorf("%s: expected page %q next %q, got %q %q", c.name, c.ids, c.next, ids, next)
+		}
+	}
+
+	if _, _, err := PaginateAfter(cfgs, "not base64!", 2); err == nil {
+		t.Error("expected an invalid cursor error")
+	}
+}
+
+func TestLoadAfterIsStableOnInsert(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		for _, name := range []string{"b", "d", "f", "h"} {
+			if _, err := database.Create(functionOn("t1", name, "", "shared")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		page, next, err := database.LoadAfter("", 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := pageIDs(page); ids != "t1b t1d " {
+			t.Fatalf("%T: unexpected first page %q", database, ids)
+		}
+
+		// an insert before the cursor does not shift the next page
+		if _, err := database.Create(functionOn("t1", "a", "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		page, next, err = database.LoadAfter(next, 2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := pageIDs(page); ids != "t1f t1h " || next != "" {
+			t.Errorf("%T: expected the second page \"t1f t1h \" as the last page, got %q next %q", database, ids, next)
+		}
+	}
+}
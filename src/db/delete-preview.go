@@ -0,0 +1,66 @@
+package db
+
+import (
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// DeleteImpact is the set of functions affected by deleting a function
+type DeleteImpact struct {
+	ID string `json:"id"`
+	// SharedSubscriptions are the IDs of the functions sharing the exclusive input subscription
+	SharedSubscriptions []string `json:"sharedSubscriptions"`
+	// DownstreamConsumers are the IDs of the functions consuming the output topic
+	DownstreamConsumers []string `json:"downstreamConsumers"`
+}
+
+// DeletePreview reports the functions affected by deleting the function without deleting it
+func (s *PulsarHandler) DeletePreview(tenant, functionName string) (DeleteImpact, error) {
+	return deletePreview(s, tenant, functionName)
+}
+
+// DeletePreview reports the functions affected by deleting the function without deleting it
+func (s *InMemoryHandler) DeletePreview(tenant, functionName string) (DeleteImpact, error) {
+	return deletePreview(s, tenant, functionName)
+}
+
+func deletePreview(crud Crud, tenant, functionName string) (DeleteImpact, error) {
+	target, err := crud.GetByKey(model.FunctionKey(tenant, functionName))
+	if err != nil {
+		return DeleteImpact{}, err
+	}
+	cfgs, err := crud.Load()
+	if err != nil {
+		return DeleteImpact{}, err
+	}
+	return deleteImpact(cfgs, target), nil
+}
+
+// deleteImpact computes the functions depending on the target function
+func deleteImpact(cfgs []*model.FunctionConfig, target *model.FunctionConfig) DeleteImpact {
+	impact := DeleteImpact{
+		ID:                  target.ID,
+		SharedSubscriptions: []string{},
+		DownstreamConsumers: []string{},
+	}
+	exclusive := false
+	if subType, err := model.GetSubscriptionType(target.InputTopic.SubscriptionType); err == nil {
+		exclusive = subType == pulsar.Exclusive
+	}
+	output := model.TopicKey{TopicFullName: target.OutputTopic.TopicFullName, PulsarURL: target.OutputTopic.PulsarURL}
+	for _, cfg := range cfgs {
+		if cfg.ID == target.ID || cfg.FunctionStatus == model.Deleted {
+			continue
+		}
+		if exclusive && target.InputTopic.Subscription != "" &&
+			cfg.InputTopic.Subscription == target.InputTopic.Subscription &&
+			cfg.InputTopic.PulsarURL == target.InputTopic.PulsarURL &&
+			cfg.InputTopic.TopicFullName == target.InputTopic.TopicFullName {
+			impact.SharedSubscriptions = append(impact.SharedSubscriptions, cfg.ID)
+		}
+		if matchTopicKey(cfg.InputTopic, output) {
+			impact.DownstreamConsumers = append(impact.DownstreamConsumers, cfg.ID)
+		}
+	}
+	return impact
+}
@@ -0,0 +1,77 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestDeleteImpact(t *testing.T) {
+	// f1 -> orders -> f2, f3 (pattern) and f4 shares the exclusive input subscription of f1
+	f1 := pipelineFunction("f1", "persistent://public/default/input", "", "persistent://public/default/orders")
+	f1.InputTopic.Subscription, f1.InputTopic.SubscriptionType = "sub", "exclusive"
+	f2 := pipelineFunction("f2", "persistent://public/default/orders", "", "persistent://public/default/audit")
+	f3 := pipelineFunction("f3", "", "persistent://public/default/ord.*", "")
+	f4 := pipelineFunction("f4", "persistent://public/default/input", "", "")
+	f4.InputTopic.Subscription, f4.InputTopic.SubscriptionType = "sub", "exclusive"
+	f5 := pipelineFunction("f5", "persistent://public/default/orders", "", "")
+	f5.FunctionStatus = model.Deleted
+	f6 := pipelineFunction("f6", "persistent://public/default/input", "", "")
+	f6.InputTopic.Subscription = "other"
+	cfgs := []*model.FunctionConfig{f1, f2, f3, f4, f5, f6}
+	for _, cfg := range cfgs {
+		cfg.ID = model.FunctionKey(cfg.Tenant, cfg.Name)
+	}
+
+	cases := []struct {
+		target     *model.FunctionConfig
+		shared     []string
+		downstream []string
+	}{
+		{f1, []string{"t1f4"}, []string{"t1f2", "t1f3"}},
+		{f2, []string{}, []string{}},
+		{f4, []string{"t1f1"}, []string{}},
+		// a shared subscription does not depend on the other shared consumers
+		{f6, []string{}, []string{}},
+	}
+	for _, c := range cases {
+		impact := deleteImpact(cfgs, c.target)
+		if impact.ID != c.target.ID {
+			t.Errorf("%s: unexpected impact id %s", c.target.ID, impact.ID)
+		}
+		if strings.Join(impact.SharedSubscriptions, ",") != strings.Join(c.shared, ",") {
+			t.Errorf("%s: expected shared subscriptions %v, got %v", c.target.ID, c.shared, impact.SharedSubscriptions)
+		}
+		if strings.Join(impact.DownstreamConsumers, ",") != strings.Join(c.downstream, ",") {
+			t.Errorf("%s: expected downstream consumers %v, got %v", c.target.ID, c.downstream, impact.DownstreamConsumers)
+		}
+	}
+}
+
+func TestDeletePreview(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		for _, cfg := range []*model.FunctionConfig{
+			pipelineFunction("f1", "persistent://public/default/input", "", "persistent://public/default/orders"),
+			pipelineFunction("f2", "persistent://public/default/orders", "", ""),
+		} {
+			if _, err := database.Create(cfg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		impact, err := database.DeletePreview("t1", "f1")
+		if err != nil || impact.ID != "t1f1" || strings.Join(impact.DownstreamConsumers, ",") != "t1f2" {
+			t.Errorf("%T: unexpected impact %+v %v", database, impact, err)
+		}
+		if _, err := database.DeletePreview("t1", "f9"); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("%T: expected not found, got %v", database, err)
+		}
+		// the preview deletes nothing
+		if cfgs, _ := database.Load(); len(cfgs) != 2 {
+			t.Errorf("%T: expected the functions kept, got %d", database, len(cfgs))
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// DocIdempotencyConflict means the idempotency key has been used with a different request
+var DocIdempotencyConflict = "idempotency key has been used by a different request"
+
+// the time in seconds an idempotency key is retained with the created document
+func idempotencyKeyTTL() int {
+	return util.ConfigInt(util.GetConfig().IdempotencyKeyTTL, 3600)
+}
+
+// LookupIdempotencyKey checks whether the document has been created by an earlier request with the same
+// idempotency key. The idempotency key is unique per tenant and persisted with the created document,
+// so a retry served by another replica is recognised. It returns an error if the earlier request
+// created another function or has a different fingerprint.
+func LookupIdempotencyKey(database Crud, tenant, key, idempotencyKey, fingerprint string) (bool, error) {
+	cfgs, err := database.LoadByTenant(tenant)
+	if err != nil {
+		return false, err
+	}
+	ttl := time.Duration(idempotencyKeyTTL()) * time.Second
+	for _, cfg := range cfgs {
+		if cfg.IdempotencyKey != idempotencyKey || !cfg.DeletedAt.IsZero() || time.Since(cfg.CreatedAt) > ttl {
+			continue
+		}
+		if cfg.ID != key || cfg.IdempotencyFingerprint != fingerprint {
+			return false, errors.New(DocIdempotencyConflict)
+		}
+		return true, nil
+	}
+	return false, nil
+}
+
+// IdempotentCreate creates a document at most once per idempotency key.
+// A retry with the same key and fingerprint returns the original document key with replayed set to true.
+func IdempotentCreate(database Crud, idempotencyKey, fingerprint string, functionCfg *model.FunctionConfig) (key string, replayed bool, err error) {
+	key, err = getKey(functionCfg)
+	if err != nil {
+		return key, false, err
+	}
+	if ok, err := LookupIdempotencyKey(database, functionCfg.Tenant, key, idempotencyKey, fingerprint); err != nil || ok {
+		return key, ok, err
+	}
+
+	functionCfg.IdempotencyKey, functionCfg.IdempotencyFingerprint = idempotencyKey, fingerprint
+	key, err = database.Create(functionCfg)
+	return key, false, err
+}
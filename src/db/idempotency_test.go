@@ -0,0 +1,113 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func TestIdempotentCreate(t *testing.T) {
+	database, _ := NewInMemoryHandler()
+	key, replayed, err := IdempotentCreate(database, "req-1", "fp-1", functionOn("t1", "f1", "sub", "shared"))
+	if err != nil || replayed || key != "t1f1" {
+		t.Fatalf("unexpected first creation key %s replayed %v error %v", key, replayed, err)
+	}
+	stored, _ := database.GetByKey(key)
+	if stored.IdempotencyKey != "req-1" || stored.IdempotencyFingerprint != "fp-1" {
+		t.Fatalf("the idempotency key must be persisted with the document %+v", stored)
+	}
+
+	cases := []struct {
+		name           string
+		tenant         string
+		function       string
+		idempotencyKey string
+		fingerprint    string
+		replayed       bool
+		errPrefix      string
+	}{
+		{"retry with the same request", "t1", "f1", "req-1", "fp-1", true, ""},
+		{"retry with a different request", "t1", "f1", "req-1", "fp-2", false, DocIdempotencyConflict},
+		{"retry with a different function", "t1", "f2", "req-1", "fp-1", false, DocIdempotencyConflict},
+		{"new idempotency key on an existing function", "t1", "f1", "req-2", "fp-1", false, DocAlreadyExisted},
+		{"same idempotency key of another tenant", "t2", "f1", "req-1", "fp-1", false, ""},
+	}
+	for _, c := range cases {
+		key, replayed, err := IdempotentCreate(database, c.idempotencyKey, c.fingerprint, functionOn(c.tenant, c.function, "sub", "shared"))
+		if replayed != c.replayed {
+			t.Errorf("%s: expected replayed %v", c.name, c.replayed)
+		}
+		if c.errPrefix == "" && (err != nil || key != c.tenant+c.function) {
+			t.Errorf("%s: unexpected key %s error %v", c.name, key, err)
+		}
+		if c.errPrefix != "" && (err == nil || !strings.HasPrefix(err.Error(), c.errPrefix)) {
+			t.Errorf("%s: expected %s error, got %v", c.name, c.errPrefix, err)
+		}
+	}
+}
+
+func TestIdempotencyKeyTTL(t *testing.T) {
+	defer func(ttl string) { util.Config.IdempotencyKeyTTL = ttl }(util.Config.IdempotencyKeyTTL)
+	cases := []struct {
+		ttl      string
+		replayed bool
+	}{
+		{"", true},
+		{"3600", true},
+		{"-1", false},
+	}
+	for _, c := range cases {
+		util.Config.IdempotencyKeyTTL = c.ttl
+		database, _ := NewInMemoryHandler()
+		if _, _, err := IdempotentCreate(database, "req-1", "fp-1", functionOn("t1", "f1", "sub", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		replayed, err := LookupIdempotencyKey(database, "t1", "t1f1", "req-1", "fp-1")
+		if err != nil || replayed != c.replayed {
+			t.Errorf("ttl %q: expected replayed %v, got %v %v", c.ttl, c.replayed, replayed, err)
+		}
+	}
+}
+
+func TestIdempotencyRecordIsServerManaged(t *testing.T) {
+	database, _ := NewInMemoryHandler()
+	if _, _, err := IdempotentCreate(database, "req-1", "fp-1", functionOn("t1", "f1", "sub", "shared")); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name   string
+		change func() error
+	}{
+		{"update", func() error {
+			cfg := functionOn("t1", "f1", "sub", "shared")
+			cfg.IdempotencyKey, cfg.IdempotencyFingerprint = "forged", "forged"
+			_, err := database.Update(cfg)
+			return err
+		}},
+		{"manifest import", func() error {
+			manifest, err := ExportManifest(database, "t1", "f1")
+			if err != nil {
+				return err
+			}
+			cfg, err := ParseManifest(append(manifest, "idempotencyKey: forged\nidempotencyFingerprint: forged\n"...))
+			if err != nil {
+				return err
+			}
+			_, err = ImportManifest(database, "t1", cfg)
+			return err
+		}},
+	}
+	for _, c := range cases {
+		if err := c.change(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if stored, _ := database.GetByKey("t1f1"); stored.IdempotencyKey != "req-1" || stored.IdempotencyFingerprint != "fp-1" {
+			t.Errorf("%s: expected the idempotency record kept, got %s %s", c.name, stored.IdempotencyKey, stored.IdempotencyFingerprint)
+		}
+	}
+	cfg, err := ParseManifest([]byte("name: f2\nidempotencyKey: forged\n"))
+	if err != nil || cfg.IdempotencyKey != "" {
+		t.Errorf("expected the idempotency key stripped from the manifest, got %+v %v", cfg, err)
+	}
+}
@@ -0,0 +1,55 @@
+package db
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// DocImmutableField means an update attempts to change a field that cannot change after creation
+var DocImmutableField = "immutable field cannot be changed"
+
+// DefaultImmutableFields are the immutable fields unless ImmutableFields is configured
+const DefaultImmutableFields = "Tenant,InputTopic.TopicFullName"
+
+// ImmutableFields returns the configured immutable field paths of the function config
+func ImmutableFields() []string {
+	fields := []string{}
+	for _, f := range strings.Split(util.AssignString(util.GetConfig().ImmutableFields, DefaultImmutableFields), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+// validateImmutableFields rejects the update if any immutable field differs from the existing document
+func validateImmutableFields(existing, updated *model.FunctionConfig) error {
+	for _, path := range ImmutableFields() {
+		before, ok := fieldByPath(reflect.ValueOf(*existing), path)
+		if !ok {
+			continue
+		}
+		after, _ := fieldByPath(reflect.ValueOf(*updated), path)
+		if !reflect.DeepEqual(before.Interface(), after.Interface()) {
+			return fmt.Errorf("%s: %s", DocImmutableField, path)
+		}
+	}
+	return nil
+}
+
+// fieldByPath looks up a struct field by a dot separated path of field names
+func fieldByPath(v reflect.Value, path string) (reflect.Value, bool) {
+	for _, name := range strings.Split(path, ".") {
+		if v.Kind() != reflect.Struct {
+			return v, false
+		}
+		if v = v.FieldByName(name); !v.IsValid() {
+			return v, false
+		}
+	}
+	return v, true
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func TestValidateImmutableFields(t *testing.T) {
+	defer func(fields string) { util.Config.ImmutableFields = fields }(util.Config.ImmutableFields)
+	existing := functionOn("t1", "f1", "sub", "shared")
+	cases := []struct {
+		name   string
+		fields string
+		update func(cfg *model.FunctionConfig)
+		field  string
+	}{
+		{"mutable field", "", func(cfg *model.FunctionConfig) { cfg.InputTopic.Subscription = "other" }, ""},
+		{"tenant", "", func(cfg *model.FunctionConfig) { cfg.Tenant = "t2" }, "Tenant"},
+		{"input topic", "", func(cfg *model.FunctionConfig) { cfg.InputTopic.TopicFullName = "persistent://public/default/other" }, "InputTopic.TopicFullName"},
+		{"configured fields", " InputTopic.Subscription ,", func(cfg *model.FunctionConfig) { cfg.InputTopic.Subscription = "other" }, "InputTopic.Subscription"},
+		{"default no longer applies", "Name", func(cfg *model.FunctionConfig) { cfg.Tenant = "t2" }, ""},
+		{"unknown field path", "Bogus,InputTopic.Bogus.Name", func(cfg *model.FunctionConfig) { cfg.Tenant = "t2" }, ""},
+	}
+	for _, c := range cases {
+		util.Config.ImmutableFields = c.fields
+		updated := *existing
+		c.update(&updated)
+		err := validateImmutableFields(existing, &updated)
+		if c.field == "" && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if c.field != "" && (err == nil || err.Error() != DocImmutableField+": "+c.field) {
+			t.Errorf("%s: expected the immutable field %s rejected, got %v", c.name, c.field, err)
+		}
+	}
+}
+
+func TestUpdateRejectsImmutableFields(t *testing.T) {
+	defer func(fields string) { util.Config.ImmutableFields = fields }(util.Config.ImmutableFields)
+	util.Config.ImmutableFields = ""
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		if _, err := database.Create(functionOn("t1", "f1", "sub", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		moved := functionOn("t1", "f1", "sub", "shared")
+		moved.InputTopic.TopicFullName = "persistent://public/default/other"
+		if _, err := database.Update(moved); err == nil || !strings.HasPrefix(err.Error(), DocImmutableField) {
+			t.Errorf("%T: expected the input topic change rejected, got %v", database, err)
+		}
+		if _, err := database.Update(functionOn("t1", "f1", "other-sub", "shared")); err != nil {
+			t.Errorf("%T: expected the mutable field updated, got %v", database, err)
+		}
+		cfg, _ := database.GetByKey("t1f1")
+		if cfg.InputTopic.TopicFullName != "persistent://public/default/input" {
+			t.Errorf("%T: expected the input topic unchanged, got %s", database, cfg.InputTopic.TopicFullName)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package db
 
 import (
-	"errors"
+	"fmt"
 	"time"
 
 	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -18,13 +19,18 @@ import (
 // InMemoryHandler is the in memory cache driver
 type InMemoryHandler struct {
 	functions map[string]model.FunctionConfig
-	logger    *log.Entry
+	// tombstones are the deletion times by key
+	tombstones map[string]time.Time
+	logger     *log.Entry
+	cacheHooks
+	cacheLimit
 }
 
 //Init is a Db interface method.
 func (s *InMemoryHandler) Init() error {
-	s.logger = log.WithFields(log.Fields{"app": "inmemory-db"})
+	s.logger = util.LogEntry("inmemory-db", "")
 	s.functions = make(map[string]model.FunctionConfig)
+	s.tombstones = make(map[string]time.Time)
 	return nil
 }
 
@@ -33,11 +39,31 @@ func (s *InMemoryHandler) Sync() error {
 	return nil
 }
 
+// Reload is a Db interface method.
+func (s *InMemoryHandler) Reload() error {
+	return nil
+}
+
 //Health is a Db interface method
 func (s *InMemoryHandler) Health() bool {
 	return true
 }
 
+// Ready is a Db interface method
+func (s *InMemoryHandler) Ready() bool {
+	return true
+}
+
+// HealthReport is a Db interface method
+func (s *InMemoryHandler) HealthReport() HealthReport {
+	return HealthReport{
+		Healthy:           s.Health(),
+		Ready:             s.Ready(),
+		CacheSize:         len(s.functions),
+		ProducerConnected: true,
+	}
+}
+
 // Close closes database
 func (s *InMemoryHandler) Close() error {
 	return nil
@@ -58,7 +84,20 @@ func (s *InMemoryHandler) Create(functionCfg *model.FunctionConfig) (string, err
 	}
 
 	if _, ok := s.functions[key]; ok {
-		return key, errors.New(DocAlreadyExisted)
+		return key, fmt.Errorf("%w %s", ErrDocAlreadyExisted, key)
+	}
+	if err := normalizeWebhooks(functionCfg); err != nil {
+		return key, err
+	}
+	if err := validateExclusiveSubscription(functionCfg, key, s.functions); err != nil {
+		return key, err
+	}
+	existing, err := s.LoadByTenant(functionCfg.Tenant)
+	if err != nil {
+		return key, err
+	}
+	if err := validateTenantQuota(functionCfg.Tenant, existing); err != nil {
+		return key, err
 	}
 
 	functionCfg.ID = key
@@ -67,16 +106,14 @@ func (s *InMemoryHandler) Create(functionCfg *model.FunctionConfig) (string, err
 
 	s.functions[functionCfg.ID] = *functionCfg
 	log.Infof("created a function %s database size %d", functionCfg.ID, len(s.functions))
+	s.checkCacheSize(len(s.functions))
+	s.cacheChanged()
 	return key, nil
 }
 
 // GetByTopic gets a document by the topic name and pulsar URL
 func (s *InMemoryHandler) GetByTopic(tenant, functionName string) (*model.FunctionConfig, error) {
-	key, err := model.GetKeyFromNames(tenant, functionName)
-	if err != nil {
-		return &model.FunctionConfig{}, err
-	}
-	return s.GetByKey(key)
+	return s.GetByKey(model.FunctionKey(tenant, functionName))
 }
 
 // GetByKey gets a document by the key
@@ -84,19 +121,64 @@ func (s *InMemoryHandler) GetByKey(hashedTopicKey string) (*model.FunctionConfig
 	if v, ok := s.functions[hashedTopicKey]; ok {
 		return &v, nil
 	}
-	return &model.FunctionConfig{}, errors.New(DocNotFound)
+	return &model.FunctionConfig{}, fmt.Errorf("%w %s", ErrDocNotFound, hashedTopicKey)
 }
 
-// Load loads the entire database as a list
+// GetByKeys gets documents by keys
+func (s *InMemoryHandler) GetByKeys(keys []string) (map[string]*model.FunctionConfig, []string, error) {
+	found, missing := getByKeys(s.functions, keys)
+	return found, missing, nil
+}
+
+// Load loads the entire database as a list ordered by ID
 func (s *InMemoryHandler) Load() ([]*model.FunctionConfig, error) {
-	results := []*model.FunctionConfig{}
-	for _, v := range s.functions {
-		results = append(results, &v)
-	}
+	results := loadAll(s.functions)
 	log.Infof("load database table size %d", len(results))
 	return results, nil
 }
 
+// LoadByTenant loads all the documents belong to the tenant
+func (s *InMemoryHandler) LoadByTenant(tenant string) ([]*model.FunctionConfig, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterByTenant(cfgs, tenant), nil
+}
+
+// GetByTopicKey gets the documents consuming or producing the topic
+func (s *InMemoryHandler) GetByTopicKey(tk model.TopicKey) ([]*model.FunctionConfig, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterByTopicKey(cfgs, tk), nil
+}
+
+// ProducersOf gets the documents producing to the topic
+func (s *InMemoryHandler) ProducersOf(topicFullName string) ([]*model.FunctionConfig, error) {
+	return producersOf(s.functions, topicFullName), nil
+}
+
+// LoadByStatus loads all the documents in the status
+func (s *InMemoryHandler) LoadByStatus(status model.Status) ([]*model.FunctionConfig, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterByStatus(cfgs, status), nil
+}
+
+// LoadPage loads a page of documents and returns the total number of documents
+func (s *InMemoryHandler) LoadPage(offset, limit int) ([]*model.FunctionConfig, int, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := Paginate(cfgs, offset, limit)
+	return page, total, nil
+}
+
 // Update updates or creates a topic config document
 func (s *InMemoryHandler) Update(functionCfg *model.FunctionConfig) (string, error) {
 	key, err := getKey(functionCfg)
@@ -107,34 +189,90 @@ func (s *InMemoryHandler) Update(functionCfg *model.FunctionConfig) (string, err
 	if _, ok := s.functions[key]; !ok {
 		return s.Create(functionCfg)
 	}
+	if err := normalizeWebhooks(functionCfg); err != nil {
+		return key, err
+	}
+	if err := validateExclusiveSubscription(functionCfg, key, s.functions); err != nil {
+		return key, err
+	}
 
 	v := s.functions[key]
-	v.Tenant = functionCfg.Tenant
-	v.FunctionStatus = functionCfg.FunctionStatus
-	v.UpdatedAt = time.Now()
-	// v.Webhooks = functionCfg.Webhooks
+	if err := updateDoc(&v, functionCfg); err != nil {
+		return key, err
+	}
 
 	s.logger.Infof("upsert %s", key)
-	s.functions[functionCfg.ID] = *functionCfg
+	applyDoc(s.functions, s.tombstones, *functionCfg)
+	s.cacheChanged()
 	return key, nil
 
 }
 
-// Delete deletes a document
-func (s *InMemoryHandler) Delete(tenant, functionName string) (string, error) {
-	key, err := model.GetKeyFromNames(tenant, functionName)
+// Touch refreshes the document UpdatedAt
+func (s *InMemoryHandler) Touch(tenant, functionName string) (string, error) {
+	cfg, err := s.GetByKey(model.FunctionKey(tenant, functionName))
 	if err != nil {
 		return "", err
 	}
-	return s.DeleteByKey(key)
+	cfg.UpdatedAt = time.Now()
+	s.functions[cfg.ID] = *cfg
+	s.cacheChanged()
+	return cfg.ID, nil
+}
+
+// AddWebhook adds a webhook to a function document
+func (s *InMemoryHandler) AddWebhook(key string, wh model.WebhookConfig) error {
+	return addWebhook(s, key, wh)
+}
+
+// UpdateWebhook updates a webhook identified by its subscription
+func (s *InMemoryHandler) UpdateWebhook(key string, wh model.WebhookConfig) error {
+	return updateWebhook(s, key, wh)
+}
+
+// DeleteWebhook soft deletes a webhook identified by its subscription
+func (s *InMemoryHandler) DeleteWebhook(key, subscription string) error {
+	return deleteWebhook(s, key, subscription)
+}
+
+// Validate validates a document against the existing documents
+func (s *InMemoryHandler) Validate(functionCfg *model.FunctionConfig) error {
+	key, err := getKey(functionCfg)
+	if err != nil {
+		return err
+	}
+	return validateExclusiveSubscription(functionCfg, key, s.functions)
+}
+
+// Delete deletes a document
+func (s *InMemoryHandler) Delete(tenant, functionName string) (string, error) {
+	return s.DeleteByKey(model.FunctionKey(tenant, functionName))
 }
 
 // DeleteByKey deletes a document based on key
 func (s *InMemoryHandler) DeleteByKey(hashedTopicKey string) (string, error) {
 	if _, ok := s.functions[hashedTopicKey]; !ok {
-		return "", errors.New(DocNotFound)
+		return "", fmt.Errorf("%w %s", ErrDocNotFound, hashedTopicKey)
 	}
 
 	delete(s.functions, hashedTopicKey)
+	s.checkCacheSize(len(s.functions))
+	s.cacheChanged()
 	return hashedTopicKey, nil
 }
+
+// DeleteByTenant deletes all documents of the tenant
+func (s *InMemoryHandler) DeleteByTenant(tenant string) (int, error) {
+	deleted := 0
+	for k, v := range s.functions {
+		if v.Tenant == tenant {
+			delete(s.functions, k)
+			deleted++
+		}
+	}
+	if deleted > 0 {
+		s.checkCacheSize(len(s.functions))
+		s.cacheChanged()
+	}
+	return deleted, nil
+}
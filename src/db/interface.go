@@ -2,8 +2,15 @@ package db
 
 import (
 	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
 
 	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
 
 	log "github.com/sirupsen/logrus"
 )
@@ -15,21 +22,69 @@ var dbConn Db
 type Crud interface {
 	GetByTopic(topicFullName, pulsarURL string) (*model.FunctionConfig, error)
 	GetByKey(hashedTopicKey string) (*model.FunctionConfig, error)
+	// GetByKeys returns the found documents keyed by id and the missing keys
+	GetByKeys(keys []string) (map[string]*model.FunctionConfig, []string, error)
+	// GetByTopicKey returns the functions consuming or producing the topic
+	GetByTopicKey(tk model.TopicKey) ([]*model.FunctionConfig, error)
+	// ProducersOf returns the functions not deleted whose output topic is the topic
+	ProducersOf(topicFullName string) ([]*model.FunctionConfig, error)
+	// SubscriptionsOnTopic returns the subscriptions of the functions not deleted consuming the topic
+	SubscriptionsOnTopic(topicFullName string) ([]SubscriptionInfo, error)
 	Update(topicCfg *model.FunctionConfig) (string, error)
 	Create(topicCfg *model.FunctionConfig) (string, error)
+	// Touch refreshes UpdatedAt and persists the document otherwise unchanged
+	Touch(tenant, functionName string) (string, error)
+	// Clone creates a copy of the function under the new name, it fails if the new name exists
+	Clone(tenant, functionName, newName string) (string, error)
 	Delete(topicFullName, pulsarURL string) (string, error)
 	DeleteByKey(hashedTopicKey string) (string, error)
+	// DeleteByTenant deletes the documents of the tenant and returns the number of the deleted documents
+	DeleteByTenant(tenant string) (int, error)
+	// DeletePreview reports the functions affected by deleting the function without deleting it
+	DeletePreview(tenant, functionName string) (DeleteImpact, error)
+	AddWebhook(key string, wh model.WebhookConfig) error
+	UpdateWebhook(key string, wh model.WebhookConfig) error
+	DeleteWebhook(key, subscription string) error
+
+	// Validate checks a document against existing documents without persisting it
+	Validate(topicCfg *model.FunctionConfig) error
 
 	// Load is invoked by the webhook.go to start new wekbooks and stop deleted ones
+	// The documents are ordered by ID so the order is stable between calls
 	Load() ([]*model.FunctionConfig, error)
+	LoadByTenant(tenant string) ([]*model.FunctionConfig, error)
+	LoadByStatus(status model.Status) ([]*model.FunctionConfig, error)
+	LoadPage(offset, limit int) ([]*model.FunctionConfig, int, error)
+	// LoadAfter loads a page of documents after the cursor and returns the cursor of the next page
+	LoadAfter(cursor string, limit int) ([]*model.FunctionConfig, string, error)
 }
 
 // Ops interface specifies required database access operations
 type Ops interface {
 	Init() error
 	Sync() error
+	Reload() error
+	// Verify returns the keys of the cached documents diverging from the database
+	Verify() ([]string, error)
 	Close() error
 	Health() bool
+	// Ready reports whether the cache has caught up with the database and the database is healthy
+	Ready() bool
+	HealthReport() HealthReport
+	// OnCacheChange registers a function called after the cached documents change
+	OnCacheChange(fn func())
+}
+
+// HealthReport is a summary of the database health
+type HealthReport struct {
+	Healthy           bool      `json:"healthy"`
+	Ready             bool      `json:"ready"`
+	CacheSize         int       `json:"cacheSize"`
+	LastReadAt        time.Time `json:"lastReadAt"`
+	ReaderLag         string    `json:"readerLag"`
+	ProducerConnected bool      `json:"producerConnected"`
+	// MalformedDocs is the number of database documents quarantined since startup
+	MalformedDocs int64 `json:"malformedDocs"`
 }
 
 // Db interface embeds two other database interfaces
@@ -54,6 +109,9 @@ func NewDb(reqDbType string) (Db, error) {
 	default:
 		err = errors.New("unsupported db type")
 	}
+	if err == nil {
+		registerCacheMetrics(dbConn)
+	}
 	return dbConn, err
 }
 
@@ -72,6 +130,187 @@ var DocNotFound = "no document found"
 // DocAlreadyExisted means document already existed in the database when a new creation is requested
 var DocAlreadyExisted = "document already existed"
 
+// ErrDocNotFound is returned when no document is found, it is wrapped with the document key
+var ErrDocNotFound = errors.New(DocNotFound)
+
+// ErrDocAlreadyExisted is returned when a document already exists, it is wrapped with the document key
+var ErrDocAlreadyExisted = errors.New(DocAlreadyExisted)
+
+// DocConflict means the document conflicts with another existing document in the database
+var DocConflict = "document conflict"
+
+// DocReloadInProgress means another reload of the database cache is in progress
+var DocReloadInProgress = "database reload is in progress"
+
+// DocQuotaExceeded means the tenant has reached the maximum number of documents
+var DocQuotaExceeded = "tenant function quota exceeded"
+
+// validateTenantQuota ensures a new function does not exceed the tenant's maximum number of functions
+func validateTenantQuota(tenant string, existing []*model.FunctionConfig) error {
+	quota := util.ConfigInt(util.GetConfig().MaxFunctionsPerTenant, 0)
+	if quota <= 0 {
+		return nil
+	}
+	count := 0
+	for _, v := range existing {
+		if v.FunctionStatus != model.Deleted {
+			count++
+		}
+	}
+	if count >= quota {
+		return fmt.Errorf("%s: tenant %s has %d functions, the maximum is %d", DocQuotaExceeded, tenant, count, quota)
+	}
+	return nil
+}
+
 func getKey(cfg *model.FunctionConfig) (string, error) {
-	return cfg.Tenant + cfg.Name, nil
+	return model.FunctionKey(cfg.Tenant, cfg.Name), nil
+}
+
+// normalizeWebhooks migrates the legacy webhook URLs, names the webhook subscriptions
+// by their subscription mode if missing, and validates the structured webhooks
+func normalizeWebhooks(cfg *model.FunctionConfig) error {
+	model.MigrateWebhookURLs(cfg)
+	key, _ := getKey(cfg)
+	for i := range cfg.Webhooks {
+		if cfg.Webhooks[i].Subscription != "" {
+			continue
+		}
+		name, err := model.GenWebhookSubscriptionName(cfg.Webhooks[i].SubscriptionMode, key, i)
+		if err != nil {
+			return err
+		}
+		cfg.Webhooks[i].Subscription = name
+	}
+	return model.ValidateWebhookConfig(cfg.Webhooks)
+}
+
+// validateExclusiveSubscription ensures the input topic subscription of a function config
+// does not collide with an exclusive subscription claimed by another function on the same topic.
+// The exclusive constraint applies if either side subscribes as exclusive.
+func validateExclusiveSubscription(cfg *model.FunctionConfig, key string, functions map[string]model.FunctionConfig) error {
+	in := cfg.InputTopic
+	if in.TopicFullName == "" || in.Subscription == "" {
+		return nil
+	}
+	inType, err := model.GetSubscriptionType(in.SubscriptionType)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range functions {
+		if k == key || v.InputTopic.TopicFullName != in.TopicFullName ||
+			v.InputTopic.PulsarURL != in.PulsarURL || v.InputTopic.Subscription != in.Subscription {
+			continue
+		}
+		subType, err := model.GetSubscriptionType(v.InputTopic.SubscriptionType)
+		if err != nil || subType == pulsar.Exclusive || inType == pulsar.Exclusive {
+			return fmt.Errorf("%s: exclusive subscription %s on topic %s is already claimed by function %s",
+				DocConflict, in.Subscription, in.TopicFullName, k)
+		}
+	}
+	return nil
+}
+
+// FilterByTenant returns the function configs belong to the tenant
+func FilterByTenant(cfgs []*model.FunctionConfig, tenant string) []*model.FunctionConfig {
+	results := []*model.FunctionConfig{}
+	for _, v := range cfgs {
+		if v.Tenant == tenant {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+// FilterByStatus returns the function configs in the status
+func FilterByStatus(cfgs []*model.FunctionConfig, status model.Status) []*model.FunctionConfig {
+	results := []*model.FunctionConfig{}
+	for _, v := range cfgs {
+		if v.FunctionStatus == status {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+// FilterByTopicKey returns the function configs whose input or output topic matches the topic key
+func FilterByTopicKey(cfgs []*model.FunctionConfig, tk model.TopicKey) []*model.FunctionConfig {
+	results := []*model.FunctionConfig{}
+	for _, v := range cfgs {
+		if matchTopicKey(v.InputTopic, tk) || matchTopicKey(v.OutputTopic, tk) {
+			results = append(results, v)
+		}
+	}
+	return results
+}
+
+// producersOf returns copies of the function configs not deleted whose output topic is the topic ordered by ID
+func producersOf(functions map[string]model.FunctionConfig, topicFullName string) []*model.FunctionConfig {
+	results := []*model.FunctionConfig{}
+	for _, v := range functions {
+		if v.FunctionStatus != model.Deleted && v.OutputTopic.TopicFullName == topicFullName {
+			cfg := v
+			results = append(results, &cfg)
+		}
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	return results
+}
+
+// matchTopicKey checks whether the function topic includes the topic of the same pulsar URL
+func matchTopicKey(topic model.FunctionTopic, tk model.TopicKey) bool {
+	if topic.PulsarURL != tk.PulsarURL || tk.TopicFullName == "" {
+		return false
+	}
+	if topic.TopicFullName == tk.TopicFullName || util.StrContains(topic.Topics, tk.TopicFullName) {
+		return true
+	}
+	if topic.TopicsPattern != "" {
+		if re, err := regexp.Compile(topic.TopicsPattern); err == nil {
+			return re.MatchString(tk.TopicFullName)
+		}
+	}
+	return false
+}
+
+// loadAll returns all the function configs in the functions map ordered by ID
+func loadAll(functions map[string]model.FunctionConfig) []*model.FunctionConfig {
+	results := make([]*model.FunctionConfig, 0, len(functions))
+	for _, v := range functions {
+		cfg := v
+		results = append(results, &cfg)
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].ID < results[j].ID })
+	return results
+}
+
+// Paginate returns a page of function configs ordered by ID and the total number of configs.
+// A zero limit returns all configs after the offset.
+func Paginate(cfgs []*model.FunctionConfig, offset, limit int) ([]*model.FunctionConfig, int) {
+	total := len(cfgs)
+	sort.Slice(cfgs, func(i, j int) bool { return cfgs[i].ID < cfgs[j].ID })
+	if offset >= total {
+		return []*model.FunctionConfig{}, total
+	}
+	end := total
+	if limit > 0 && offset+limit < total {
+		end = offset + limit
+	}
+	return cfgs[offset:end], total
+}
+
+// getByKeys looks up the documents by keys in the functions map
+func getByKeys(functions map[string]model.FunctionConfig, keys []string) (map[string]*model.FunctionConfig, []string) {
+	found := make(map[string]*model.FunctionConfig)
+	missing := []string{}
+	for _, k := range keys {
+		if v, ok := functions[k]; ok {
+			cfg := v
+			found[k] = &cfg
+		} else {
+			missing = append(missing, k)
+		}
+	}
+	return found, missing
 }
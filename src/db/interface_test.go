@@ -0,0 +1,424 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func functionOn(tenant, name, subscription, subType string) *model.FunctionConfig {
+	return &model.FunctionConfig{
+		Tenant: tenant,
+		Name:   name,
+		InputTopic: model.FunctionTopic{
+			TopicFullName:    "persistent://public/default/input",
+			PulsarURL:        "pulsar://localhost:6650",
+			Subscription:     subscription,
+			SubscriptionType: subType,
+		},
+	}
+}
+
+func TestValidateExclusiveSubscription(t *testing.T) {
+	existing := map[string]model.FunctionConfig{
+		"t1f1": *functionOn("t1", "f1", "sub", "exclusive"),
+		"t1f2": *functionOn("t1", "f2", "shared-sub", "shared"),
+	}
+	cases := []struct {
+		name     string
+		cfg      *model.FunctionConfig
+		key      string
+		conflict bool
+	}{
+		{"same exclusive subscription", functionOn("t2", "f3", "sub", "exclusive"), "t2f3", true},
+		{"shared on a claimed exclusive subscription", functionOn("t2", "f3", "sub", "shared"), "t2f3", true},
+		{"exclusive on a shared subscription", functionOn("t2", "f3", "shared-sub", "exclusive"), "t2f3", true},
+		{"both shared", functionOn("t2", "f3", "shared-sub", "shared"), "t2f3", false},
+		{"different subscription", functionOn("t2", "f3", "other", "exclusive"), "t2f3", false},
+		{"update of the owner", functionOn("t1", "f1", "sub", "exclusive"), "t1f1", false},
+		{"no subscription", functionOn("t2", "f3", "", "exclusive"), "t2f3", false},
+	}
+	for _, c := range cases {
+		err := validateExclusiveSubscription(c.cfg, c.key, existing)
+		if c.conflict && (err == nil || !strings.HasPrefix(err.Error(), DocConflict)) {
+			t.Errorf("%s: expected a conflict error, got %v", c.name, err)
+		}
+		if !c.conflict && err != nil {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestCreateRejectsClaimedExclusiveSubscription(t *testing.T) {
+	database, _ := NewInMemoryHandler()
+	if _, err := database.Create(functionOn("t1", "f1", "sub", "exclusive")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := database.Create(functionOn("t2", "f2", "sub", "exclusive"))
+	if err == nil || !strings.Contains(err.Error(), "already claimed by function t1f1") {
+		t.Fatalf("expected a descriptive conflict error, got %v", err)
+	}
+	if _, err := database.GetByKey("t2f2"); err == nil {
+		t.Fatal("the conflicting function must not be created")
+	}
+}
+
+func TestTenantQuota(t *testing.T) {
+	defer func(quota string) { util.Config.MaxFunctionsPerTenant = quota }(util.Config.MaxFunctionsPerTenant)
+	util.Config.MaxFunctionsPerTenant = "2"
+
+	database, _ := NewInMemoryHandler()
+	cases := []struct {
+		tenant   string
+		name     string
+		exceeded bool
+	}{
+		{"t1", "f1", false},
+		{"t1", "f2", false},
+		{"t1", "f3", true},
+		{"t2", "f1", false},
+	}
+	for _, c := range cases {
+		_, err := database.Create(functionOn(c.tenant, c.name, "", "shared"))
+		if c.exceeded && (err == nil || !strings.HasPrefix(err.Error(), DocQuotaExceeded)) {
+			t.Errorf("%s/%s: expected the quota exceeded error, got %v", c.tenant, c.name, err)
+		}
+		if !c.exceeded && err != nil {
+			t.Errorf("%s/%s: unexpected error %v", c.tenant, c.name, err)
+		}
+	}
+}
+
+func TestTypedDocErrors(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		if _, err := database.Create(functionOn("t1", "f1", "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		cases := []struct {
+			name   string
+			call   func() error
+			target error
+		}{
+			{"get by key", func() error { _, err := database.GetByKey("t1missing"); return err }, ErrDocNotFound},
+			{"get by topic", func() error { _, err := database.GetByTopic("t1", "missing"); return err }, ErrDocNotFound},
+			{"delete by key", func() error { _, err := database.DeleteByKey("t1missing"); return err }, ErrDocNotFound},
+			{"create twice", func() error { _, err := database.Create(functionOn("t1", "f1", "", "shared")); return err }, ErrDocAlreadyExisted},
+		}
+		for _, c := range cases {
+			err := c.call()
+			if !errors.Is(err, c.target) {
+				t.Errorf("%T %s: expected %v, got %v", database, c.name, c.target, err)
+			}
+			// the string constants are kept for callers matching the message
+			if err != nil && !strings.HasPrefix(err.Error(), c.target.Error()) {
+				t.Errorf("%T %s: unexpected message %v", database, c.name, err)
+			}
+		}
+	}
+}
+
+func TestGetByKeys(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		for _, name := range []string{"f1", "f2", "f3"} {
+			if _, err := database.Create(functionOn("t1", name, "", "shared")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cases := []struct {
+			keys    []string
+			found   []string
+			missing []string
+		}{
+			{[]string{"t1f1", "t1f3"}, []string{"t1f1", "t1f3"}, []string{}},
+			{[]string{"t1f1", "t1f9", "t2f1"}, []string{"t1f1"}, []string{"t1f9", "t2f1"}},
+			{[]string{"t1f9"}, []string{}, []string{"t1f9"}},
+			{[]string{}, []string{}, []string{}},
+		}
+		for _, c := range cases {
+			found, missing, err := database.GetByKeys(c.keys)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != len(c.found) {
+				t.Errorf("%T %v: expected found %v, got %v", database, c.keys, c.found, found)
+			}
+			for _, key := range c.found {
+				if cfg, ok := found[key]; !ok || cfg.ID != key {
+					t.Errorf("%T %v: expected %s to be found", database, c.keys, key)
+				}
+			}
+			if strings.Join(missing, ",") != strings.Join(c.missing, ",") {
+				t.Errorf("%T %v: expected missing %v, got %v", database, c.keys, c.missing, missing)
+			}
+		}
+	}
+}
+
+func TestLoadOrder(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		for _, name := range []string{"f3", "f1", "f10", "f2"} {
+			if _, err := database.Create(functionOn("t1", name, "", "shared")); err != nil {
+				t.Fatal(err)
+			}
+		}
+		ids := func() string {
+			cfgs, err := database.Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			keys := []string{}
+			for _, cfg := range cfgs {
+				keys = append(keys, cfg.ID)
+			}
+			return strings.Join(keys, ",")
+		}
+		first := ids()
+		if first != "t1f1,t1f10,t1f2,t1f3" {
+			t.Errorf("%T: expected documents ordered by id, got %s", database, first)
+		}
+		for i := 0; i < 10; i++ {
+			if again := ids(); again != first {
+				t.Fatalf("%T: expected the same order on every load, got %s and %s", database, first, again)
+			}
+		}
+	}
+}
+
+// pipelineFunction consumes the input topic and produces to the output topic
+func pipelineFunction(name, input, pattern, output string) *model.FunctionConfig {
+	cfg := functionOn("t1", name, "", "shared")
+	cfg.InputTopic.TopicFullName, cfg.InputTopic.TopicsPattern = input, pattern
+	if output != "" {
+		cfg.OutputTopic = model.FunctionTopic{TopicFullName: output, PulsarURL: "pulsar://localhost:6650"}
+	}
+	return cfg
+}
+
+func TestGetByTopicKey(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		for _, cfg := range []*model.FunctionConfig{
+			pipelineFunction("f1", "persistent://public/default/input", "", "persistent://public/default/orders"),
+			pipelineFunction("f2", "persistent://public/default/orders", "", "persistent://public/default/audit"),
+			pipelineFunction("f3", "", "persistent://public/default/ord.*", ""),
+			pipelineFunction("f4", "persistent://public/default/other", "", ""),
+		} {
+			if _, err := database.Create(cfg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		cases := []struct {
+			tk  model.TopicKey
+			ids []string
+		}{
+			{model.TopicKey{TopicFullName: "persistent://public/default/orders", PulsarURL: "pulsar://localhost:6650"}, []string{"t1f1", "t1f2", "t1f3"}},
+			{model.TopicKey{TopicFullName: "persistent://public/default/input", PulsarURL: "pulsar://localhost:6650"}, []string{"t1f1"}},
+			{model.TopicKey{TopicFullName: "persistent://public/default/other", PulsarURL: "pulsar://localhost:6650"}, []string{"t1f4"}},
+			// the topic of another cluster is not shared
+			{model.TopicKey{TopicFullName: "persistent://public/default/orders", PulsarURL: "pulsar://remote:6650"}, []string{}},
+			{model.TopicKey{TopicFullName: "persistent://public/default/unused", PulsarURL: "pulsar://localhost:6650"}, []string{}},
+			{model.TopicKey{PulsarURL: "pulsar://localhost:6650"}, []string{}},
+		}
+		for _, c := range cases {
+			cfgs, err := database.GetByTopicKey(c.tk)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, cfg := range cfgs {
+				ids = append(ids, cfg.ID)
+			}
+			if strings.Join(ids, ",") != strings.Join(c.ids, ",") {
+				t.Errorf("%T %s %s: expected %v, got %v", database, c.tk.TopicFullName, c.tk.PulsarURL, c.ids, ids)
+			}
+		}
+	}
+}
+
+func TestProducersOf(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		for _, cfg := range []*model.FunctionConfig{
+			pipelineFunction("f1", "persistent://public/default/input", "", "persistent://public/default/orders"),
+			pipelineFunction("f2", "persistent://public/default/orders", "", "persistent://public/default/audit"),
+			pipelineFunction("f3", "persistent://public/default/input", "", "persistent://public/default/orders"),
+			pipelineFunction("f4", "persistent://public/default/orders", "", ""),
+		} {
+			if _, err := database.Create(cfg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		// a deleted producer is excluded
+		deleted := *pipelineFunction("f5", "persistent://public/default/input", "", "persistent://public/default/orders")
+		deleted.ID, deleted.FunctionStatus = "t1f5", model.Deleted
+		switch d := database.(type) {
+		case *InMemoryHandler:
+			d.functions["t1f5"] = deleted
+		case *PulsarHandler:
+			d.topics["t1f5"] = deleted
+		}
+
+		cases := []struct {
+			topic string
+			ids   []string
+		}{
+			{"persistent://public/default/orders", []string{"t1f1", "t1f3"}},
+			{"persistent://public/default/audit", []string{"t1f2"}},
+			{"persistent://public/default/input", []string{}},
+		}
+		for _, c := range cases {
+			cfgs, err := database.ProducersOf(c.topic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ids := []string{}
+			for _, cfg := range cfgs {
+				ids = append(ids, cfg.ID)
+				// the results are copies of the cached documents
+				cfg.Name = "modified"
+			}
+			if strings.Join(ids, ",") != strings.Join(c.ids, ",") {
+				t.Errorf("%T %s: expected %v, got %v", database, c.topic, c.ids, ids)
+			}
+		}
+		if cfg, err := database.GetByKey("t1f1"); err != nil || cfg.Name != "f1" {
+			t.Errorf("%T: expected the cached document unchanged, got %v %v", database, cfg, err)
+		}
+	}
+}
+
+func TestFunctionKeyLookup(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		key, err := database.Create(functionOn("t1", "f1", "", "shared"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := database.GetByTopic("t1", "f1")
+		if err != nil || key != model.FunctionKey("t1", "f1") || cfg.ID != key {
+			t.Fatalf("%T: expected the document of key %s, got %v %v", database, key, cfg, err)
+		}
+		cases := []struct {
+			tenant, name string
+			fails        bool
+		}{
+			{"t2", "f1", true},
+			{"t1", "f1", false},
+			{"t1", "f1", true},
+		}
+		for _, c := range cases {
+			deleted, err := database.Delete(c.tenant, c.name)
+			if (err != nil) != c.fails || (!c.fails && deleted != key) {
+				t.Errorf("%T delete %s %s: expected failure %v, got %s %v", database, c.tenant, c.name, c.fails, deleted, err)
+			}
+		}
+		if _, err := database.GetByTopic("t1", "f1"); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("%T: expected the deleted document not found, got %v", database, err)
+		}
+	}
+}
+
+func TestTouch(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, producer := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		if _, err := database.Create(functionOn("t1", "f1", "sub", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		before, _ := database.GetByKey("t1f1")
+		time.Sleep(time.Millisecond)
+		key, err := database.Touch("t1", "f1")
+		if err != nil || key != "t1f1" {
+			t.Fatalf("%T: expected touched t1f1, got %s %v", database, key, err)
+		}
+		after, _ := database.GetByKey("t1f1")
+		if !after.UpdatedAt.After(before.UpdatedAt) {
+			t.Errorf("%T: expected UpdatedAt advanced from %v, got %v", database, before.UpdatedAt, after.UpdatedAt)
+		}
+		after.UpdatedAt = before.UpdatedAt
+		if !reflect.DeepEqual(before, after) {
+			t.Errorf("%T: expected the other fields unchanged, got %+v from %+v", database, after, before)
+		}
+		if _, err := database.Touch("t1", "missing"); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("%T: expected a missing document not found, got %v", database, err)
+		}
+	}
+	if sent := len(producer.sent); sent != 2 {
+		t.Errorf("expected the create and the touch sent to the database topic, got %d", sent)
+	}
+}
+
+func TestUpdateEnforcesStatusTransitions(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		if _, err := database.Create(functionOn("t1", "f1", "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		cases := []struct {
+			to    model.Status
+			legal bool
+		}{
+			{model.Activated, true},
+			{model.Deactivated, false},
+			{model.Suspended, true},
+			{model.Suspended, true},
+			{model.Activated, true},
+			{model.Deleted, true},
+		}
+		for i, c := range cases {
+			cfg := functionOn("t1", "f1", "", "shared")
+			cfg.FunctionStatus = c.to
+			_, err := database.Update(cfg)
+			if c.legal != (err == nil) {
+				t.Errorf("%T %d: update to %s unexpected error %v", database, i, c.to, err)
+			}
+		}
+		// the deleted document is removed like a tombstone from the database topic
+		if _, err := database.GetByKey("t1f1"); !errors.Is(err, ErrDocNotFound) {
+			t.Errorf("%T: expected the deleted document not found, got %v", database, err)
+		}
+	}
+}
+
+func TestInMemoryDeleteByTenant(t *testing.T) {
+	database, _ := NewInMemoryHandler()
+	for _, name := range []string{"t1/f1", "t1/f2", "t1/f3", "t2/f1"} {
+		names := strings.Split(name, "/")
+		if _, err := database.Create(functionOn(names[0], names[1], "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		tenant  string
+		deleted int
+		left    int
+	}{
+		{"t1", 3, 1},
+		{"t1", 0, 1},
+		{"t2", 1, 0},
+	}
+	for _, c := range cases {
+		deleted, err := database.DeleteByTenant(c.tenant)
+		if err != nil || deleted != c.deleted {
+			t.Errorf("%s: expected %d deleted, got %d %v", c.tenant, c.deleted, deleted, err)
+		}
+		if all, _ := database.Load(); len(all) != c.left {
+			t.Errorf("%s: expected %d functions left, got %d", c.tenant, c.left, len(all))
+		}
+	}
+}
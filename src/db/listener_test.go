@@ -0,0 +1,350 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeID is a message id of a position in the fake topic
+type fakeID int
+
+func (id fakeID) Serialize() []byte { return []byte(strconv.Itoa(int(id))) }
+
+// dbMessage is a database topic message
+type dbMessage struct {
+	pulsar.Message
+	key       string
+	payload   []byte
+	id        pulsar.MessageID
+	published time.Time
+}
+
+func (m *dbMessage) Key() string                   { return m.key }
+func (m *dbMessage) Payload() []byte               { return m.payload }
+func (m *dbMessage) ID() pulsar.MessageID          { return m.id }
+func (m *dbMessage) PublishTime() time.Time        { return m.published }
+func (m *dbMessage) Properties() map[string]string { return nil }
+
+// docMessage builds the database message of the document at the position of the topic
+func docMessage(t *testing.T, pos int, cfg model.FunctionConfig) pulsar.Message {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &dbMessage{key: cfg.ID, payload: data, id: fakeID(pos), published: time.Now()}
+}
+
+// storedDoc is a document of the function as it is stored with the id
+func storedDoc(tenant, name string) model.FunctionConfig {
+	cfg := *functionOn(tenant, name, "", "shared")
+	cfg.ID = model.FunctionKey(tenant, name)
+	cfg.UpdatedAt = time.Now()
+	return cfg
+}
+
+// fakeTopic is a database topic shared by the fake readers and consumers.
+// The live sources block for new messages once the published messages are read.
+type fakeTopic struct {
+	lock     sync.Mutex
+	messages []pulsar.Message
+	acks     []pulsar.MessageID
+	seeks    []pulsar.MessageID
+	readers  []pulsar.ReaderOptions
+	subs     []pulsar.ConsumerOptions
+	closed   int
+	notify   chan struct{}
+}
+
+func newFakeTopic(messages ...pulsar.Message) *fakeTopic {
+	return &fakeTopic{messages: messages, notify: make(chan struct{})}
+}
+
+// publish appends the message and wakes up the blocked sources
+func (f *fakeTopic) publish(msg pulsar.Message) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	f.messages = append(f.messages, msg)
+	close(f.notify)
+	f.notify = make(chan struct{})
+}
+
+// next returns the message at the position or blocks until it is published or the context is done
+func (f *fakeTopic) next(ctx context.Context, pos int) (pulsar.Message, error) {
+	for {
+		f.lock.Lock()
+		if pos < len(f.messages) {
+			msg := f.messages[pos]
+			f.lock.Unlock()
+			return msg, nil
+		}
+		notify := f.notify
+		f.lock.Unlock()
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+func (f *fakeTopic) size() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	return len(f.messages)
+}
+
+// startPos is the position following the start message id
+func startPos(id pulsar.MessageID) int {
+	if pos, ok := id.(fakeID); ok {
+		return int(pos) + 1
+	}
+	return 0
+}
+
+type fakeReader struct {
+	pulsar.Reader
+	topic *fakeTopic
+	pos   int
+}
+
+func (r *fakeReader) Next(ctx context.Context) (pulsar.Message, error) {
+	msg, err := r.topic.next(ctx, r.pos)
+	if err == nil {
+		r.pos++
+	}
+	return msg, err
+}
+
+func (r *fakeReader) HasNext() bool { return r.pos < r.topic.size() }
+
+func (r *fakeReader) Close() {
+	r.topic.lock.Lock()
+	defer r.topic.lock.Unlock()
+	r.topic.closed++
+}
+
+type fakeConsumer struct {
+	pulsar.Consumer
+	fakeReader
+}
+
+func (c *fakeConsumer) Receive(ctx context.Context) (pulsar.Message, error) { return c.Next(ctx) }
+
+func (c *fakeConsumer) Ack(msg pulsar.Message) {
+	c.topic.lock.Lock()
+	defer c.topic.lock.Unlock()
+	c.topic.acks = append(c.topic.acks, msg.ID())
+}
+
+func (c *fakeConsumer) Seek(id pulsar.MessageID) error {
+	c.topic.lock.Lock()
+	defer c.topic.lock.Unlock()
+	c.topic.seeks = append(c.topic.seeks, id)
+	c.pos = startPos(id)
+	return nil
+}
+
+func (c *fakeConsumer) Close() { c.fakeReader.Close() }
+
+// topicClient serves the readers and consumers of the fake topic
+type topicClient struct {
+	fakeClient
+	topic *fakeTopic
+}
+
+func (c *topicClient) CreateReader(opts pulsar.ReaderOptions) (pulsar.Reader, error) {
+	c.topic.lock.Lock()
+	defer c.topic.lock.Unlock()
+	c.topic.readers = append(c.topic.readers, opts)
+	return &fakeReader{topic: c.topic, pos: startPos(opts.StartMessageID)}, nil
+}
+
+func (c *topicClient) Subscribe(opts pulsar.ConsumerOptions) (pulsar.Consumer, error) {
+	c.topic.lock.Lock()
+	defer c.topic.lock.Unlock()
+	c.topic.subs = append(c.topic.subs, opts)
+	return &fakeConsumer{fakeReader: fakeReader{topic: c.topic}}, nil
+}
+
+// newListeningHandler creates a database handler on the fake topic
+func newListeningHandler(topic *fakeTopic) *PulsarHandler {
+	database, _ := newTestPulsarHandler()
+	database.client = &topicClient{topic: topic}
+	database.TopicName = "functions"
+	database.startMessageID = pulsar.EarliestMessageID()
+	database.ctx, database.cancel = context.WithCancel(context.Background())
+	database.logger = log.WithField("component", "pulsar-db-test")
+	return database
+}
+
+// listen runs the database listener until the returned function is called
+func listen(database *PulsarHandler) func() {
+	sig := make(chan *liveSignal, 1)
+	go database.dbListener(sig)
+	return func() {
+		database.cancel()
+		<-sig
+	}
+}
+
+// eventually waits for the condition to be met
+func eventually(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestListenerSource(t *testing.T) {
+	cases := []struct {
+		subscription string
+		readers      int
+		subs         int
+		acks         int
+	}{
+		{"", 1, 0, 0},
+		{"db-instance-1", 0, 1, 2},
+	}
+	for _, c := range cases {
+		topic := newFakeTopic(docMessage(t, 0, storedDoc("t1", "f1")))
+		database := newListeningHandler(topic)
+		database.SubscriptionName = c.subscription
+		database.readCompacted = true
+		stop := listen(database)
+
+		topic.publish(docMessage(t, 1, storedDoc("t1", "f2")))
+		eventually(t, "the published document to be cached", func() bool {
+			_, ok := database.cached("t1f2")
+			return ok
+		})
+		stop()
+
+		topic.lock.Lock()
+		if len(topic.readers) != c.readers || len(topic.subs) != c.subs || len(topic.acks) != c.acks || topic.closed != 1 {
+			t.Errorf("subscription %q: unexpected readers %d subscriptions %d acks %d closed %d",
+				c.subscription, len(topic.readers), len(topic.subs), len(topic.acks), topic.closed)
+		}
+		for _, opts := range topic.subs {
+			if opts.SubscriptionName != c.subscription || opts.Type != pulsar.Exclusive || !opts.ReadCompacted ||
+				len(topic.seeks) != 1 || startPos(topic.seeks[0]) != 0 {
+				t.Errorf("subscription %q: unexpected consumer options %+v seeks %v", c.subscription, opts, topic.seeks)
+			}
+		}
+		for _, opts := range topic.readers {
+			if !opts.ReadCompacted || startPos(opts.StartMessageID) != 0 {
+				t.Errorf("unexpected reader options %+v", opts)
+			}
+		}
+		topic.lock.Unlock()
+	}
+}
+
+func TestListenerStopsOnClose(t *testing.T) {
+	database := newListeningHandler(newFakeTopic())
+	sig := make(chan *liveSignal, 1)
+	result := make(chan error, 1)
+	go func() { result <- database.dbListener(sig) }()
+	// the listener blocks on the empty topic until the database is closed
+	time.Sleep(10 * time.Millisecond)
+	if err := database.Close(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("a closed database must stop the listener cleanly, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("the listener must stop when the database is closed")
+	}
+	select {
+	case <-sig:
+	default:
+		t.Error("the listener must signal its termination")
+	}
+	database.client.(*topicClient).topic.lock.Lock()
+	defer database.client.(*topicClient).topic.lock.Unlock()
+	if database.client.(*topicClient).topic.closed != 1 {
+		t.Error("the reader must be closed")
+	}
+}
+
+func TestListenerResumesAfterCancel(t *testing.T) {
+	topic := newFakeTopic(docMessage(t, 0, storedDoc("t1", "f1")))
+	database := newListeningHandler(topic)
+	sig := make(chan *liveSignal, 1)
+	go database.dbListener(sig)
+	eventually(t, "the first document", func() bool { _, ok := database.cached("t1f1"); return ok })
+
+	// canceling the reader alone, as a reload does, recreates it from the last read message
+	database.readerLock.Lock()
+	database.cancelReader()
+	database.readerLock.Unlock()
+	<-sig
+	go database.dbListener(sig)
+	topic.publish(docMessage(t, 1, storedDoc("t1", "f2")))
+	eventually(t, "the second document", func() bool { _, ok := database.cached("t1f2"); return ok })
+	database.cancel()
+	<-sig
+
+	topic.lock.Lock()
+	defer topic.lock.Unlock()
+	if len(topic.readers) != 2 || startPos(topic.readers[1].StartMessageID) != 1 {
+		t.Errorf("the reader must resume after the last read message, got %+v", topic.readers)
+	}
+}
+
+// topicProducer publishes the sent messages to the fake topic
+type topicProducer struct {
+	fakeProducer
+	topic *fakeTopic
+}
+
+func (p *topicProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	id := fakeID(p.topic.size())
+	p.topic.publish(&dbMessage{key: msg.Key, payload: msg.Payload, id: id, published: time.Now()})
+	callback(id, msg, nil)
+}
+
+func TestWaitForReady(t *testing.T) {
+	cases := []struct {
+		listening bool
+		ready     bool
+	}{
+		{true, true},
+		{false, false},
+	}
+	for _, c := range cases {
+		topic := newFakeTopic(docMessage(t, 0, storedDoc("t1", "f1")))
+		database := newListeningHandler(topic)
+		database.producer = &topicProducer{topic: topic}
+		if c.listening {
+			defer listen(database)()
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+		err := database.WaitForReady(ctx)
+		cancel()
+		if (err == nil) != c.ready || database.Ready() != c.ready {
+			t.Errorf("listening %v: expected ready %v, got %v", c.listening, c.ready, err)
+		}
+		if _, cached := database.cached("t1f1"); cached != c.ready {
+			t.Errorf("listening %v: the documents before the marker must be cached once ready", c.listening)
+		}
+		database.markersLock.Lock()
+		if len(database.markers) != 0 {
+			t.Errorf("listening %v: the marker must be removed, got %v", c.listening, database.markers)
+		}
+		database.markersLock.Unlock()
+	}
+}
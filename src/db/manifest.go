@@ -0,0 +1,252 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// manifestSecretHeaders are the webhook headers stripped from a manifest unless their values reference a variable
+var manifestSecretHeaders = []string{"authorization", "proxy-authorization", "cookie", "x-api-key"}
+
+// ExportManifest exports the function config as a YAML manifest. The server managed fields, the tokens,
+// and the secrets are stripped so the manifest can be kept in source control and imported to any tenant.
+// Secrets referencing a variable, such as ${WEBHOOK_SECRET}, are retained.
+func ExportManifest(database Crud, tenant, functionName string) ([]byte, error) {
+	cfg, err := database.GetByKey(model.FunctionKey(tenant, functionName))
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(manifestOf(cfg))
+	if err != nil {
+		return nil, err
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	// the keys are sorted so the manifest is stable between exports
+	return yaml.Marshal(pruneManifest(doc))
+}
+
+// ParseManifest parses a YAML or JSON manifest into a function config
+func ParseManifest(manifest []byte) (*model.FunctionConfig, error) {
+	cfg := &model.FunctionConfig{}
+	if err := yaml.Unmarshal(manifest, cfg); err != nil {
+		return nil, fmt.Errorf("malformed function manifest %v", err)
+	}
+	if strings.TrimSpace(cfg.Name) == "" {
+		return nil, fmt.Errorf("function name is missing in the manifest")
+	}
+	// the idempotency record is server managed
+	cfg.IdempotencyKey, cfg.IdempotencyFingerprint = "", ""
+	return cfg, nil
+}
+
+// ImportManifest creates or updates the tenant function from the manifest config. An existing function
+// keeps its server managed fields, and its tokens and secrets unless the manifest specifies them.
+func ImportManifest(database Crud, tenant string, cfg *model.FunctionConfig) (string, error) {
+	cfg.Tenant = tenant
+	cfg.ID = model.FunctionKey(tenant, cfg.Name)
+	existing, err := database.GetByKey(cfg.ID)
+	if err != nil {
+		return database.Create(cfg)
+	}
+	cfg.FunctionStatus = existing.FunctionStatus
+	cfg.WebhookURLs = existing.WebhookURLs
+	cfg.CreatedAt = existing.CreatedAt
+	cfg.InputTopic.Token = keepSecret(cfg.InputTopic.Token, existing.InputTopic.Token)
+	cfg.OutputTopic.Token = keepSecret(cfg.OutputTopic.Token, existing.OutputTopic.Token)
+	cfg.LogTopic.Token = keepSecret(cfg.LogTopic.Token, existing.LogTopic.Token)
+	for i := range cfg.Webhooks {
+		wh := &cfg.Webhooks[i]
+		if j := findWebhook(existing.Webhooks, wh.Subscription); j >= 0 && wh.Subscription != "" {
+			prev := existing.Webhooks[j]
+			wh.Secret = keepSecret(wh.Secret, prev.Secret)
+			wh.BasicAuthPass = keepSecret(wh.BasicAuthPass, prev.BasicAuthPass)
+			wh.CreatedAt = prev.CreatedAt
+			wh.Headers = mergeSecretHeaders(wh.Headers, prev.Headers)
+		}
+	}
+	return database.Update(cfg)
+}
+
+// manifestOf copies the portable fields of the function config
+func manifestOf(cfg *model.FunctionConfig) model.FunctionConfig {
+	m := model.FunctionConfig{
+		Name:                    cfg.Name,
+		FunctionFilePath:        cfg.FunctionFilePath,
+		LanguagePack:            cfg.LanguagePack,
+		UserConfig:              cfg.UserConfig,
+		SecretRefs:              cfg.SecretRefs,
+		Parallelism:             cfg.Parallelism,
+		MaxConcurrentDeliveries: cfg.MaxConcurrentDeliveries,
+		InputTopic:              manifestTopic(cfg.InputTopic),
+		OutputTopic:             manifestTopic(cfg.OutputTopic),
+		OutputKeyMode:           cfg.OutputKeyMode,
+		PropagateProperties:     cfg.PropagateProperties,
+		DeduplicationEnabled:    cfg.DeduplicationEnabled,
+		SequenceIDStrategy:      cfg.SequenceIDStrategy,
+		MessageRoutingMode:      cfg.MessageRoutingMode,
+		LogTopic:                manifestTopic(cfg.LogTopic),
+		TriggerType:             cfg.TriggerType,
+		Cron:                    cfg.Cron,
+		Webhooks:                []model.WebhookConfig{},
+	}
+	for _, wh := range cfg.Webhooks {
+		if wh.WebhookStatus == model.Deleted {
+			continue
+		}
+		m.Webhooks = append(m.Webhooks, model.WebhookConfig{
+			URL:                 wh.URL,
+			Headers:             manifestHeaders(wh.Headers),
+			Subscription:        wh.Subscription,
+			SubscriptionType:    wh.SubscriptionType,
+			SubscriptionMode:    wh.SubscriptionMode,
+			InitialPosition:     wh.InitialPosition,
+			Filter:              wh.Filter,
+			PayloadMode:         wh.PayloadMode,
+			DeliveryGuarantee:   wh.DeliveryGuarantee,
+			PropagateProperties: wh.PropagateProperties,
+			TimeoutMs:           wh.TimeoutMs,
+			MaxPayloadBytes:     wh.MaxPayloadBytes,
+			BatchSize:           wh.BatchSize,
+			BatchTimeoutMs:      wh.BatchTimeoutMs,
+			Signed:              wh.Signed,
+			Secret:              manifestSecret(wh.Secret),
+			BasicAuthUser:       wh.BasicAuthUser,
+			BasicAuthPass:       manifestSecret(wh.BasicAuthPass),
+			ClientCertPath:      wh.ClientCertPath,
+			ClientKeyPath:       wh.ClientKeyPath,
+			WebhookStatus:       wh.WebhookStatus,
+		})
+	}
+	return m
+}
+
+// manifestServerFields are the server managed fields omitted from a manifest
+var manifestServerFields = map[string]bool{
+	"id": true, "tenant": true, "functionStatus": true, "webhookURLs": true, "token": true,
+	"failures": true, "lastReply": true, "createdAt": true, "updatedAt": true, "deletedAt": true,
+}
+
+// pruneManifest removes the server managed fields and the empty values
+func pruneManifest(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, field := range value {
+			field = pruneManifest(field)
+			if manifestServerFields[k] || isEmptyManifestValue(field) {
+				delete(value, k)
+			} else {
+				value[k] = field
+			}
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = pruneManifest(value[i])
+		}
+		return value
+	}
+	return v
+}
+
+func isEmptyManifestValue(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case bool:
+		return !value
+	case float64:
+		return value == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
+
+func manifestTopic(topic model.FunctionTopic) model.FunctionTopic {
+	topic.Token = ""
+	topic.Tenant = ""
+	return topic
+}
+
+// manifestSecret strips the secret unless it references a variable
+func manifestSecret(secret string) string {
+	if strings.Contains(secret, "${") {
+		return secret
+	}
+	return ""
+}
+
+func manifestHeaders(headers []string) []string {
+	results := []string{}
+	for _, h := range headers {
+		if !isSecretHeader(h) || strings.Contains(h, "${") {
+			results = append(results, h)
+		}
+	}
+	return results
+}
+
+func headerName(h string) string {
+	return strings.ToLower(strings.TrimSpace(strings.SplitN(h, ":", 2)[0]))
+}
+
+func isMaskedHeader(h string) bool {
+	kv := strings.SplitN(h, ":", 2)
+	return len(kv) == 2 && strings.TrimSpace(kv[1]) == model.Masked
+}
+
+func isSecretHeader(h string) bool {
+	name := headerName(h)
+	for _, secret := range manifestSecretHeaders {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeSecretHeaders adds the existing secret headers stripped from the manifest,
+// and restores the existing values of the masked headers
+func mergeSecretHeaders(headers, existing []string) []string {
+	names := make(map[string]bool)
+	kept := []string{}
+	for _, h := range headers {
+		if isMaskedHeader(h) {
+			for _, prev := range existing {
+				if headerName(prev) == headerName(h) {
+					kept = append(kept, prev)
+					names[headerName(h)] = true
+				}
+			}
+			continue
+		}
+		kept = append(kept, h)
+		names[headerName(h)] = true
+	}
+	headers = kept
+	for _, h := range existing {
+		if isSecretHeader(h) && !strings.Contains(h, "${") && !names[headerName(h)] {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+// keepSecret keeps the existing secret unless a new one is specified, a masked secret is not specified
+func keepSecret(secret, existing string) string {
+	if secret == "" || secret == model.Masked {
+		return existing
+	}
+	return secret
+}
@@ -0,0 +1,115 @@
+package db
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// manifestFunction is a function with tokens, secrets and server managed fields
+func manifestFunction(tenant string) *model.FunctionConfig {
+	cfg := functionOn(tenant, "f1", "sub", "shared")
+	cfg.LanguagePack = "nodejs"
+	cfg.FunctionFilePath = "https://example.com/f1.js"
+	cfg.Parallelism = 2
+	cfg.UserConfig = map[string]string{"region": "us"}
+	cfg.InputTopic.Token = "input-token"
+	cfg.OutputTopic = model.FunctionTopic{TopicFullName: "persistent://public/default/output", Token: "output-token"}
+	cfg.Webhooks = []model.WebhookConfig{{
+		URL:              "http://localhost/hook",
+		Subscription:     "hook-sub",
+		SubscriptionType: "shared",
+		Headers:          []string{"Authorization: Bearer header-secret", "X-Trace: on", "X-Api-Key: ${PUBSUBFN_SECRET_API_KEY}"},
+		Signed:           true,
+		Secret:           "${PUBSUBFN_SECRET_SIGNING}",
+		Failures:         2,
+	}}
+	return cfg
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	database, _ := NewInMemoryHandler()
+	if _, err := database.Create(manifestFunction("t1")); err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := ExportManifest(database, "t1", "f1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := ExportManifest(database, "t1", "f1")
+	if string(again) != string(manifest) {
+		t.Errorf("expected a stable manifest, got\n%s\nand\n%s", manifest, again)
+	}
+
+	cases := []struct {
+		text     string
+		retained bool
+	}{
+		{"t1f1", false},
+		{"tenant", false},
+		{"createdAt", false},
+		{"functionStatus", false},
+		{"failures", false},
+		{"input-token", false},
+		{"output-token", false},
+		{"secret: ${PUBSUBFN_SECRET_SIGNING}", true},
+		{"header-secret", false},
+		{"X-Api-Key: ${PUBSUBFN_SECRET_API_KEY}", true},
+		{"X-Trace: on", true},
+		{"region: us", true},
+		{"hook-sub", true},
+	}
+	for _, c := range cases {
+		if strings.Contains(string(manifest), c.text) != c.retained {
+			t.Errorf("%s: expected retained %v in the manifest\n%s", c.text, c.retained, manifest)
+		}
+	}
+
+	// a new function in another tenant reproduces the functional fields
+	cfg, err := ParseManifest(manifest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := ImportManifest(database, "t2", cfg)
+	if err != nil || key != "t2f1" {
+		t.Fatalf("expected the function t2f1 imported, got %s %v", key, err)
+	}
+	source, _ := database.GetByKey("t1f1")
+	imported, _ := database.GetByKey("t2f1")
+	if !reflect.DeepEqual(manifestOf(imported), manifestOf(source)) {
+		t.Errorf("expected the functional fields reproduced\n%+v\ngot\n%+v", manifestOf(source), manifestOf(imported))
+	}
+
+	// reimporting over the existing function keeps its tokens and secrets
+	cfg, _ = ParseManifest(manifest)
+	if _, err := ImportManifest(database, "t1", cfg); err != nil {
+		t.Fatal(err)
+	}
+	updated, _ := database.GetByKey("t1f1")
+	if updated.InputTopic.Token != "input-token" || updated.Webhooks[0].Secret != "${PUBSUBFN_SECRET_SIGNING}" ||
+		!reflect.DeepEqual(updated.Webhooks[0].Headers, []string{"X-Trace: on", "X-Api-Key: ${PUBSUBFN_SECRET_API_KEY}", "Authorization: Bearer header-secret"}) ||
+		!updated.CreatedAt.Equal(source.CreatedAt) {
+		t.Errorf("expected the existing secrets kept, got %+v", updated)
+	}
+}
+
+func TestParseManifest(t *testing.T) {
+	cases := []struct {
+		manifest string
+		name     string
+		valid    bool
+	}{
+		{"name: f1\nparallelism: 2\n", "f1", true},
+		{`{"name": "f1", "parallelism": 2}`, "f1", true},
+		{"parallelism: 2\n", "", false},
+		{"name: [f1\n", "", false},
+	}
+	for _, c := range cases {
+		cfg, err := ParseManifest([]byte(c.manifest))
+		if (err == nil) != c.valid || (c.valid && (cfg.Name != c.name || cfg.Parallelism != 2)) {
+			t.Errorf("%q: expected valid %v name %s, got %+v %v", c.manifest, c.valid, c.name, cfg, err)
+		}
+	}
+}
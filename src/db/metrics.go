@@ -0,0 +1,70 @@
+package db
+
+import (
+	"sync"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	cachedFunctionsDesc = prometheus.NewDesc(
+		"pubsub_function_cached_functions",
+		"The number of non-deleted function configs in the database cache",
+		nil, nil,
+	)
+	cachedFunctionsByStatusDesc = prometheus.NewDesc(
+		"pubsub_function_cached_functions_by_status",
+		"The number of function configs in the database cache by function status",
+		[]string{"status"}, nil,
+	)
+)
+
+// cacheCollector reports the cached function counts from the database on every scrape,
+// so the gauges always reflect the cache without being updated on every change
+type cacheCollector struct {
+	database Crud
+}
+
+// Describe implements prometheus.Collector
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- cachedFunctionsDesc
+	ch <- cachedFunctionsByStatusDesc
+}
+
+// Collect implements prometheus.Collector
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	cfgs, err := c.database.Load()
+	if err != nil {
+		log.Errorf("failed to load database for metrics %v", err)
+		return
+	}
+	counts := make(map[model.Status]int)
+	total := 0
+	for _, cfg := range cfgs {
+		counts[cfg.FunctionStatus]++
+		if cfg.FunctionStatus != model.Deleted {
+			total++
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(cachedFunctionsDesc, prometheus.GaugeValue, float64(total))
+	for i := range model.StatusNames {
+		status := model.Status(i)
+		ch <- prometheus.MustNewConstMetric(cachedFunctionsByStatusDesc, prometheus.GaugeValue,
+			float64(counts[status]), status.String())
+	}
+}
+
+var cacheMetricsOnce sync.Once
+
+// registerCacheMetrics registers the cached function gauges of the first database created,
+// a second registration would be rejected by the registry as a duplicate
+func registerCacheMetrics(database Crud) {
+	cacheMetricsOnce.Do(func() {
+		if err := prometheus.Register(&cacheCollector{database: database}); err != nil {
+			log.Errorf("failed to register database cache metrics %v", err)
+		}
+	})
+}
@@ -0,0 +1,62 @@
+package db
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestRegisterCacheMetricsOnce(t *testing.T) {
+	hook := test.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+
+	database, _ := NewInMemoryHandler()
+	for _, name := range []string{"f1", "f2"} {
+		if _, err := database.Create(functionOn("t1", name, "", "shared")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, _ := NewInMemoryHandler()
+	registerCacheMetrics(database)
+	registerCacheMetrics(other)
+	registerCacheMetrics(database)
+	for _, entry := range hook.AllEntries() {
+		if entry.Level == log.ErrorLevel {
+			t.Errorf("unexpected registration error %s", entry.Message)
+		}
+	}
+
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name    string
+		metrics int
+		total   float64
+	}{
+		{"pubsub_function_cached_functions", 1, 2},
+		{"pubsub_function_cached_functions_by_status", 0, 2},
+	}
+	for _, c := range cases {
+		found := false
+		for _, family := range families {
+			if family.GetName() != c.name {
+				continue
+			}
+			found = true
+			total := 0.0
+			for _, m := range family.GetMetric() {
+				total += m.GetGauge().GetValue()
+			}
+			if (c.metrics > 0 && len(family.GetMetric()) != c.metrics) || total != c.total {
+				t.Errorf("%s: expected %v functions of the first database, got %v in %d metrics", c.name, c.total, total, len(family.GetMetric()))
+			}
+		}
+		if !found {
+			t.Errorf("%s is not registered", c.name)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package db
+
+import (
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// docTime is the time of the last change of the document, a deleted document is changed at its deletion
+func docTime(doc *model.FunctionConfig) time.Time {
+	if doc.DeletedAt.After(doc.UpdatedAt) {
+		return doc.DeletedAt
+	}
+	return doc.UpdatedAt
+}
+
+// isStaleDoc checks whether the document is older than the cached document or the tombstone of the same key.
+// The documents written before the change times were recorded are never stale.
+func isStaleDoc(topics map[string]model.FunctionConfig, tombstones map[string]time.Time, doc *model.FunctionConfig) bool {
+	t := docTime(doc)
+	if t.IsZero() {
+		return false
+	}
+	if cached, ok := topics[doc.ID]; ok && t.Before(docTime(&cached)) {
+		return true
+	}
+	deletedAt, ok := tombstones[doc.ID]
+	return ok && t.Before(deletedAt)
+}
+
+// applyDoc applies the document to the cache unless it is stale, and returns whether it is applied.
+// The time of the deleted documents is kept as a tombstone so an older document cannot bring it back.
+func applyDoc(topics map[string]model.FunctionConfig, tombstones map[string]time.Time, doc model.FunctionConfig) bool {
+	if isStaleDoc(topics, tombstones, &doc) {
+		return false
+	}
+	if doc.FunctionStatus == model.Deleted {
+		delete(topics, doc.ID)
+		if t := docTime(&doc); !t.IsZero() {
+			tombstones[doc.ID] = t
+		}
+		return true
+	}
+	delete(tombstones, doc.ID)
+	topics[doc.ID] = doc
+	return true
+}
+
+// updateDoc turns the updated document into the next version of the cached document. The immutable fields
+// and the status transition are validated, and the server stamps the change time over the client's one.
+// The idempotency record of the creation is kept.
+func updateDoc(cached, updated *model.FunctionConfig) error {
+	if err := validateImmutableFields(cached, updated); err != nil {
+		return err
+	}
+	if err := model.Transition(cached, updated.FunctionStatus); err != nil {
+		return err
+	}
+	updated.ID = cached.ID
+	updated.CreatedAt = cached.CreatedAt
+	updated.DeletedAt = cached.DeletedAt
+	updated.IdempotencyKey, updated.IdempotencyFingerprint = cached.IdempotencyKey, cached.IdempotencyFingerprint
+	updated.UpdatedAt = time.Now()
+	return nil
+}
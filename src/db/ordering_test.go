@@ -0,0 +1,130 @@
+package db
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestApplyDoc(t *testing.T) {
+	now := time.Now()
+	doc := func(updatedAt, deletedAt time.Time, status model.Status) model.FunctionConfig {
+		cfg := storedDoc("t1", "f1")
+		cfg.UpdatedAt, cfg.DeletedAt, cfg.FunctionStatus = updatedAt, deletedAt, status
+		return cfg
+	}
+	cases := []struct {
+		name      string
+		cached    []model.FunctionConfig
+		incoming  model.FunctionConfig
+		applied   bool
+		updatedAt time.Time
+		deleted   bool
+	}{
+		{"new document", nil, doc(now, time.Time{}, model.Activated), true, now, false},
+		{"newer document", []model.FunctionConfig{doc(now, time.Time{}, model.Activated)},
+			doc(now.Add(time.Second), time.Time{}, model.Suspended), true, now.Add(time.Second), false},
+		{"out of order older document", []model.FunctionConfig{doc(now, time.Time{}, model.Suspended)},
+			doc(now.Add(-time.Second), time.Time{}, model.Activated), false, now, false},
+		{"undated legacy document", []model.FunctionConfig{doc(now, time.Time{}, model.Suspended)},
+			doc(time.Time{}, time.Time{}, model.Activated), true, time.Time{}, false},
+		{"tombstone", []model.FunctionConfig{doc(now, time.Time{}, model.Activated)},
+			doc(now, now.Add(time.Second), model.Deleted), true, time.Time{}, true},
+		{"older document after the tombstone", []model.FunctionConfig{doc(now, now.Add(time.Second), model.Deleted)},
+			doc(now, time.Time{}, model.Activated), false, time.Time{}, true},
+		{"recreated after the tombstone", []model.FunctionConfig{doc(now, now.Add(time.Second), model.Deleted)},
+			doc(now.Add(2*time.Second), time.Time{}, model.Activated), true, now.Add(2 * time.Second), false},
+	}
+	for _, c := range cases {
+		topics, tombstones := map[string]model.FunctionConfig{}, map[string]time.Time{}
+		for _, cached := range c.cached {
+			applyDoc(topics, tombstones, cached)
+		}
+		if applied := applyDoc(topics, tombstones, c.incoming); applied != c.applied {
+			t.Errorf("%s: expected applied %v", c.name, c.applied)
+		}
+		cached, ok := topics["t1f1"]
+		if ok == c.deleted || (ok && !cached.UpdatedAt.Equal(c.updatedAt)) {
+			t.Errorf("%s: unexpected cached document %v %+v", c.name, ok, cached)
+		}
+	}
+}
+
+func TestUpdateStampsServerTime(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, producer := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		if _, err := database.Create(functionOn("t1", "f1", "sub", "shared")); err != nil {
+			t.Fatal(err)
+		}
+		created, _ := database.GetByKey("t1f1")
+		time.Sleep(time.Millisecond)
+
+		// the client sends an update without the id and with its own times
+		cfg := functionOn("t1", "f1", "sub", "shared")
+		cfg.Parallelism = 3
+		cfg.FunctionStatus = model.Activated
+		cfg.UpdatedAt = created.UpdatedAt.Add(-time.Hour)
+		if _, err := database.Update(cfg); err != nil {
+			t.Fatalf("%T: %v", database, err)
+		}
+		updated, err := database.GetByKey("t1f1")
+		if err != nil {
+			t.Fatalf("%T: %v", database, err)
+		}
+		if updated.Parallelism != 3 || updated.FunctionStatus != model.Activated {
+			t.Errorf("%T: expected the update persisted, got %+v", database, updated)
+		}
+		if !updated.CreatedAt.Equal(created.CreatedAt) || !updated.UpdatedAt.After(created.UpdatedAt) {
+			t.Errorf("%T: expected CreatedAt %v kept and UpdatedAt after %v, got %v %v",
+				database, created.CreatedAt, created.UpdatedAt, updated.CreatedAt, updated.UpdatedAt)
+		}
+		if _, err := database.GetByKey(""); err == nil {
+			t.Errorf("%T: expected no document stored without a key", database)
+		}
+	}
+	last := producer.sent[len(producer.sent)-1]
+	if last.Key != "t1f1" {
+		t.Errorf("expected the update sent under the function key, got %q", last.Key)
+	}
+}
+
+func TestReplicasConvergeOnUpdate(t *testing.T) {
+	topic := newFakeTopic()
+	replicas := []*PulsarHandler{newListeningHandler(topic), newListeningHandler(topic)}
+	for _, replica := range replicas {
+		replica.producer = &topicProducer{topic: topic}
+		defer listen(replica)()
+	}
+	if _, err := replicas[0].Create(functionOn("t1", "f1", "sub", "shared")); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the create replicated", func() bool {
+		_, err := replicas[1].GetByKey("t1f1")
+		return err == nil
+	})
+	stale, _ := replicas[1].GetByKey("t1f1")
+	time.Sleep(time.Millisecond)
+	if _, err := replicas[0].Touch("t1", "f1"); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the touch replicated", func() bool {
+		cfg, _ := replicas[1].GetByKey("t1f1")
+		return cfg.UpdatedAt.After(stale.UpdatedAt)
+	})
+
+	// the update is built from the copy read before the touch
+	stale.FunctionStatus = model.Activated
+	if _, err := replicas[1].Update(stale); err != nil {
+		t.Fatal(err)
+	}
+	eventually(t, "the update replicated", func() bool {
+		for _, replica := range replicas {
+			if cfg, _ := replica.GetByKey("t1f1"); cfg.FunctionStatus != model.Activated {
+				return false
+			}
+		}
+		return true
+	})
+}
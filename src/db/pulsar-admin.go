@@ -0,0 +1,145 @@
+package db
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// compaction status of the Pulsar admin API
+const (
+	CompactionNotRun  = "NOT_RUN"
+	CompactionRunning = "RUNNING"
+	CompactionSuccess = "SUCCESS"
+	CompactionError   = "ERROR"
+)
+
+// CompactionAdmin triggers and queries the topic compaction
+type CompactionAdmin interface {
+	TriggerCompaction(topic string) error
+	CompactionStatus(topic string) (string, error)
+}
+
+// RestAdmin is a Pulsar admin REST API client
+type RestAdmin struct {
+	URL    string
+	Token  string
+	Client *http.Client
+}
+
+// NewRestAdmin creates a Pulsar admin REST API client
+func NewRestAdmin(adminURL, token string) *RestAdmin {
+	return &RestAdmin{
+		URL:    strings.TrimSuffix(adminURL, "/"),
+		Token:  token,
+		Client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// topicPath converts a topic name to the admin REST API path
+// a short topic name is in the public tenant and default namespace
+func topicPath(topic string) string {
+	domain := "persistent"
+	if parts := strings.SplitN(topic, "://", 2); len(parts) == 2 {
+		domain, topic = parts[0], parts[1]
+	}
+	if !strings.Contains(topic, "/") {
+		topic = "public/default/" + topic
+	}
+	return domain + "/" + topic
+}
+
+func (a *RestAdmin) do(method, topic, resource string) ([]byte, error) {
+	url := fmt.Sprintf("%s/admin/v2/%s/%s", a.URL, topicPath(topic), resource)
+	req, err := http.NewRequest(method, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if a.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+a.Token)
+	}
+	res, err := a.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		return nil, fmt.Errorf("pulsar admin %s %s replied status code %d %s", method, url, res.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// TriggerCompaction triggers the topic compaction
+func (a *RestAdmin) TriggerCompaction(topic string) error {
+	_, err := a.do(http.MethodPut, topic, "compaction")
+	return err
+}
+
+// CompactionStatus returns the status of the last compaction of the topic
+func (a *RestAdmin) CompactionStatus(topic string) (string, error) {
+	body, err := a.do(http.MethodGet, topic, "compaction")
+	if err != nil {
+		return "", err
+	}
+	status := struct {
+		Status    string `json:"status"`
+		LastError string `json:"lastError"`
+	}{}
+	if err := json.Unmarshal(body, &status); err != nil {
+		return "", err
+	}
+	if status.LastError != "" {
+		return status.Status, fmt.Errorf("topic %s compaction error %s", topic, status.LastError)
+	}
+	return status.Status, nil
+}
+
+// SubscriptionBacklog returns the number of messages in the subscription backlog of the topic
+func (a *RestAdmin) SubscriptionBacklog(topic, subscription string) (int64, error) {
+	body, err := a.do(http.MethodGet, topic, "stats")
+	if err != nil {
+		return 0, err
+	}
+	stats := struct {
+		Subscriptions map[string]struct {
+			MsgBacklog int64 `json:"msgBacklog"`
+		} `json:"subscriptions"`
+	}{}
+	if err := json.Unmarshal(body, &stats); err != nil {
+		return 0, err
+	}
+	sub, ok := stats.Subscriptions[subscription]
+	if !ok {
+		return 0, fmt.Errorf("subscription %s does not exist on topic %s", subscription, topic)
+	}
+	return sub.MsgBacklog, nil
+}
+
+// compactPeriodically triggers the topic compaction on every tick until stopped.
+// A tick is skipped if the previous compaction is still running.
+func compactPeriodically(admin CompactionAdmin, topic string, tick <-chan time.Time, stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-tick:
+			if status, err := admin.CompactionStatus(topic); err == nil && status == CompactionRunning {
+				log.Infof("skip topic %s compaction since the last compaction is running", topic)
+				continue
+			}
+			if err := admin.TriggerCompaction(topic); err != nil {
+				log.Errorf("failed to trigger topic %s compaction %v", topic, err)
+			}
+		}
+	}
+}
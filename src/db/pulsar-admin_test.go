@@ -0,0 +1,194 @@
+package db
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+// fakeAdmin records the compaction triggers and replies a fixed compaction status
+type fakeAdmin struct {
+	lock      sync.Mutex
+	status    string
+	statusErr error
+	triggered int
+}
+
+func (a *fakeAdmin) TriggerCompaction(topic string) error {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.triggered++
+	return nil
+}
+
+func (a *fakeAdmin) CompactionStatus(topic string) (string, error) {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.status, a.statusErr
+}
+
+func (a *fakeAdmin) triggers() int {
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	return a.triggered
+}
+
+func TestConfigureCompaction(t *testing.T) {
+	defer func(readCompacted, adminURL string) {
+		util.Config.DbReadCompacted, util.Config.PulsarAdminURL = readCompacted, adminURL
+	}(util.Config.DbReadCompacted, util.Config.PulsarAdminURL)
+
+	cases := []struct {
+		readCompacted string
+		adminURL      string
+		wantCompacted bool
+		wantAdmin     bool
+	}{
+		{"", "", true, false},
+		{"false", "", false, false},
+		{"true", "http://localhost:8080/", true, true},
+	}
+	for _, c := range cases {
+		util.Config.DbReadCompacted, util.Config.PulsarAdminURL = c.readCompacted, c.adminURL
+		handler := &PulsarHandler{PulsarToken: "token"}
+		handler.configureCompaction()
+		if handler.readCompacted != c.wantCompacted || (handler.admin != nil) != c.wantAdmin {
+			t.Errorf("%q %q: unexpected read compacted %v admin %v", c.readCompacted, c.adminURL, handler.readCompacted, handler.admin)
+		}
+		if admin, ok := handler.admin.(*RestAdmin); ok && (admin.URL != "http://localhost:8080" || admin.Token != "token") {
+			t.Errorf("unexpected admin client %+v", admin)
+		}
+	}
+}
+
+func TestVerifyCompaction(t *testing.T) {
+	cases := []struct {
+		name      string
+		admin     *fakeAdmin
+		trigger   bool
+		triggered int
+		warned    bool
+	}{
+		{"no admin", nil, true, 0, true},
+		{"not compacted", &fakeAdmin{status: CompactionNotRun}, false, 0, true},
+		{"compacted", &fakeAdmin{status: CompactionSuccess}, false, 0, false},
+		{"trigger on startup", &fakeAdmin{status: CompactionRunning}, true, 1, false},
+		{"status failure", &fakeAdmin{statusErr: errors.New("unreachable")}, false, 0, true},
+	}
+	for _, c := range cases {
+		logger, hook := test.NewNullLogger()
+		handler := &PulsarHandler{TopicName: "functions", logger: log.NewEntry(logger)}
+		if c.admin != nil {
+			handler.admin = c.admin
+		}
+		handler.verifyCompaction(c.trigger)
+		if c.admin != nil && c.admin.triggers() != c.triggered {
+			t.Errorf("%s: expected %d triggers, got %d", c.name, c.triggered, c.admin.triggers())
+		}
+		warned := false
+		for _, entry := range hook.AllEntries() {
+			warned = warned || entry.Level == log.WarnLevel
+		}
+		if warned != c.warned {
+			t.Errorf("%s: expected warning %v, got %v", c.name, c.warned, warned)
+		}
+	}
+}
+
+func TestRestAdminCompaction(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+r.Header.Get("Authorization"))
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"status":"SUCCESS"}`))
+		}
+	}))
+	defer server.Close()
+
+	admin := NewRestAdmin(server.URL+"/", "token")
+	if err := admin.TriggerCompaction("functions"); err != nil {
+		t.Fatal(err)
+	}
+	status, err := admin.CompactionStatus("persistent://t/ns/functions")
+	if err != nil || status != CompactionSuccess {
+		t.Fatalf("unexpected status %s %v", status, err)
+	}
+	expected := []string{
+		"PUT /admin/v2/persistent/public/default/functions/compaction Bearer token",
+		"GET /admin/v2/persistent/t/ns/functions/compaction Bearer token",
+	}
+	if strings.Join(requests, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected requests %v", requests)
+	}
+}
+
+func TestCompactPeriodically(t *testing.T) {
+	cases := []struct {
+		status    string
+		ticks     int
+		triggered int
+	}{
+		{CompactionSuccess, 3, 3},
+		{CompactionNotRun, 1, 1},
+		{CompactionRunning, 3, 0},
+	}
+	for _, c := range cases {
+		admin := &fakeAdmin{status: c.status}
+		tick := make(chan time.Time)
+		stop := make(chan struct{})
+		done := make(chan struct{})
+		go func() {
+			compactPeriodically(admin, "functions", tick, stop)
+			close(done)
+		}()
+		for i := 0; i < c.ticks; i++ {
+			tick <- time.Now()
+		}
+		close(stop)
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("%s: compaction routine did not stop", c.status)
+		}
+		if admin.triggers() != c.triggered {
+			t.Errorf("%s: expected %d triggers after %d ticks, got %d", c.status, c.triggered, c.ticks, admin.triggers())
+		}
+	}
+}
+
+func TestRestAdminSubscriptionBacklog(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/admin/v2/persistent/t/ns/in/stats" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"subscriptions":{"sub":{"msgBacklog":42},"idle":{"msgBacklog":0}}}`))
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		topic, subscription string
+		backlog             int64
+		fails               bool
+	}{
+		{"persistent://t/ns/in", "sub", 42, false},
+		{"persistent://t/ns/in", "idle", 0, false},
+		{"persistent://t/ns/in", "missing", 0, true},
+		{"persistent://t/ns/other", "sub", 0, true},
+	}
+	admin := NewRestAdmin(server.URL, "")
+	for _, c := range cases {
+		backlog, err := admin.SubscriptionBacklog(c.topic, c.subscription)
+		if (err != nil) != c.fails || backlog != c.backlog {
+			t.Errorf("%s %s: expected backlog %d fails %v, got %d %v", c.topic, c.subscription, c.backlog, c.fails, backlog, err)
+		}
+	}
+}
@@ -4,11 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"sort"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/icrypto"
 	"github.com/kafkaesque-io/pubsub-function/src/model"
 	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
 	"github.com/kafkaesque-io/pubsub-function/src/util"
@@ -25,6 +29,21 @@ import (
  * A topic prefix for the webhook configuration database
 **/
 
+// the maximum time in seconds to replay the database topic on reload
+func reloadTimeout() int {
+	return util.ConfigInt(util.GetConfig().DbReloadTimeout, 300)
+}
+
+// the maximum time in milliseconds to wait for a document to be sent to the database topic
+func sendTimeout() int {
+	return util.ConfigInt(util.GetConfig().DbSendTimeoutMs, 30000)
+}
+
+// the maximum time in seconds to flush the buffered documents to the database topic
+func flushTimeout() int {
+	return util.ConfigInt(util.GetConfig().DbFlushTimeout, 10)
+}
+
 // the signal to track if the liveness of the reader process
 type liveSignal struct{}
 
@@ -36,17 +55,60 @@ type PulsarHandler struct {
 	PulsarURL   string
 	PulsarToken string
 	TopicName   string
-	topicsLock  sync.RWMutex
-	client      pulsar.Client
-	producer    pulsar.Producer
-	topics      map[string]model.FunctionConfig
-	logger      *log.Entry
+	// SubscriptionName runs the listener as a durable consumer instead of a reader if it is specified
+	SubscriptionName string
+	topicsLock       sync.RWMutex
+	client           pulsar.Client
+	producer         pulsar.Producer
+	topics           map[string]model.FunctionConfig
+	// tombstones are the deletion times of the deleted documents protected by the topics lock
+	tombstones map[string]time.Time
+	logger     *log.Entry
+
+	// reader statistics for health report
+	statsLock  sync.RWMutex
+	lastReadAt time.Time
+	readerLag  time.Duration
+	// malformedDocs is the number of database messages failed to unmarshal
+	malformedDocs int64
+
+	// readerLock protects the live reader cancellation and the message ID it resumes from
+	readerLock     sync.Mutex
+	cancelReader   context.CancelFunc
+	startMessageID pulsar.MessageID
+	reloading      int32
+
+	// readCompacted reads the compacted database topic
+	readCompacted bool
+	// admin manages the database topic compaction, it is nil without the admin URL
+	admin CompactionAdmin
+	// ctx is canceled when the database is closed to stop the reader loop and the background routines
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// producer probe statistics for health check
+	heartbeatInterval time.Duration
+	lastSendAt        time.Time
+	lastSendErr       error
+
+	// markers are the ready markers awaited to be read by the listener
+	markers     map[string]chan struct{}
+	markersLock sync.Mutex
+	// cacheReady is set once a ready marker is read by the listener
+	cacheReady int32
+
+	// cacheHooks are called after the listener applies a document or the cache is reloaded
+	cacheHooks
+	cacheLimit
 }
 
 //Init is a Db interface method.
 func (s *PulsarHandler) Init() error {
-	s.logger = log.WithFields(log.Fields{"app": "pulsardb"})
+	s.logger = util.LogEntry("pulsardb", "")
 	s.topics = make(map[string]model.FunctionConfig)
+	s.tombstones = make(map[string]time.Time)
+	s.startMessageID = pulsar.EarliestMessageID()
+	s.ctx, s.cancel = context.WithCancel(context.Background())
 
 	s.logger.Infof("database pulsar URL: %s", s.PulsarURL)
 	if log.GetLevel() == log.DebugLevel {
@@ -54,7 +116,9 @@ func (s *PulsarHandler) Init() error {
 	}
 
 	var err error
-	s.client, err = pulsardriver.NewPulsarClient(s.PulsarURL, s.PulsarToken)
+	// the client is shared with the function topics on the same cluster
+	connectTimeout, operationTimeout := clientTimeouts()
+	s.client, err = pulsardriver.GetPulsarClientWithTimeouts(s.PulsarURL, s.PulsarToken, connectTimeout, operationTimeout)
 	if err != nil {
 		// this would be a serious problem so that we return with error
 		return err
@@ -67,13 +131,44 @@ func (s *PulsarHandler) Init() error {
 		return err
 	}
 
-	// a loop to receive and recover from failure
+	if s.readCompacted {
+		s.verifyCompaction(util.StringToBool(util.GetConfig().DbCompactOnStartup))
+	}
+
+	if interval := util.ConfigInt(util.GetConfig().DbCompactionInterval, 0); interval > 0 && s.admin != nil {
+		ticker := time.NewTicker(time.Duration(interval) * time.Second)
+		go func() {
+			defer ticker.Stop()
+			compactPeriodically(s.admin, s.TopicName, ticker.C, s.ctx.Done())
+		}()
+	}
+
+	if interval := util.ConfigInt(util.GetConfig().DbVerifyInterval, 0); interval > 0 {
+		go s.verifyPeriodically(time.Duration(interval) * time.Second)
+	}
+
+	s.heartbeatInterval = time.Duration(util.ConfigInt(util.GetConfig().DbHeartbeatInterval, 60)) * time.Second
+	if s.heartbeatInterval > 0 {
+		go s.heartbeat()
+	}
+	// the readiness is reported once the cache catches up
+	go func() {
+		if err := s.WaitForReady(s.ctx); err != nil {
+			s.logger.Warnf("database readiness %v", err)
+		}
+	}()
+
+	// a loop to receive and recover from failure until the database is closed
 	go func() {
 		sig := make(chan *liveSignal)
 		go s.dbListener(sig)
 		for {
 			select {
 			case <-sig:
+				if s.ctx.Err() != nil {
+					s.logger.Infof("database listener stopped")
+					return
+				}
 				go s.dbListener(sig)
 			}
 		}
@@ -82,81 +177,395 @@ func (s *PulsarHandler) Init() error {
 	return nil
 }
 
+// configureCompaction sets up the compacted read and the compaction admin from the config
+func (s *PulsarHandler) configureCompaction() {
+	s.readCompacted = util.StringToBool(util.AssignString(util.GetConfig().DbReadCompacted, "true"))
+	s.admin = nil
+	if util.GetConfig().PulsarAdminURL != "" {
+		s.admin = NewRestAdmin(util.GetConfig().PulsarAdminURL, s.PulsarToken)
+	}
+}
+
+// verifyCompaction optionally triggers the database topic compaction and
+// warns if the compacted read is requested but the topic has not been compacted
+func (s *PulsarHandler) verifyCompaction(trigger bool) {
+	if s.admin == nil {
+		s.logger.Warnf("compacted read of database topic %s cannot be verified without the Pulsar admin URL", s.TopicName)
+		return
+	}
+	if trigger {
+		if err := s.admin.TriggerCompaction(s.TopicName); err != nil {
+			s.logger.Errorf("failed to trigger database topic %s compaction %v", s.TopicName, err)
+		}
+	}
+	status, err := s.admin.CompactionStatus(s.TopicName)
+	if err != nil {
+		s.logger.Warnf("failed to verify database topic %s compaction %v", s.TopicName, err)
+		return
+	}
+	if status == CompactionNotRun {
+		s.logger.Warnf("compacted read is requested but database topic %s has not been compacted, "+
+			"the reader replays the uncompacted history", s.TopicName)
+	}
+}
+
+// dbSource is the source of database messages for the listener
+type dbSource interface {
+	Next(ctx context.Context) (pulsar.Message, error)
+	Ack(msg pulsar.Message)
+	Close()
+}
+
+// readerSource reads the database topic without a subscription
+type readerSource struct {
+	pulsar.Reader
+}
+
+// Ack is a no-op since a reader has no subscription
+func (r readerSource) Ack(msg pulsar.Message) {}
+
+// consumerSource consumes the database topic with a durable subscription so the backlog can be monitored
+type consumerSource struct {
+	pulsar.Consumer
+}
+
+// Next receives the next message
+func (c consumerSource) Next(ctx context.Context) (pulsar.Message, error) {
+	return c.Receive(ctx)
+}
+
+// createSource creates a reader by default or a durable consumer if the subscription name is configured.
+// The consumer seeks to the start message ID since the cache is rebuilt from the topic on every start.
+// The subscription name must be unique per server instance.
+func (s *PulsarHandler) createSource() (dbSource, error) {
+	if s.SubscriptionName == "" {
+		reader, err := s.client.CreateReader(pulsar.ReaderOptions{
+			Topic:          s.TopicName,
+			StartMessageID: s.startMessageID,
+			ReadCompacted:  s.readCompacted,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return readerSource{reader}, nil
+	}
+
+	consumer, err := s.client.Subscribe(pulsar.ConsumerOptions{
+		Topic:                       s.TopicName,
+		SubscriptionName:            s.SubscriptionName,
+		Type:                        pulsar.Exclusive,
+		SubscriptionInitialPosition: pulsar.SubscriptionPositionEarliest,
+		ReadCompacted:               s.readCompacted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := consumer.Seek(s.startMessageID); err != nil {
+		consumer.Close()
+		return nil, err
+	}
+	return consumerSource{consumer}, nil
+}
+
 //DbListener listens db updates
 func (s *PulsarHandler) dbListener(sig chan *liveSignal) error {
 	defer func(termination chan *liveSignal) {
-		s.logger.Errorf("tenant db listener terminated")
+		if s.ctx.Err() == nil {
+			s.logger.Errorf("tenant db listener terminated")
+		}
 		termination <- &liveSignal{}
 	}(sig)
 	s.logger.Infof("listens to pulsar wh database changes")
-	reader, err := s.client.CreateReader(pulsar.ReaderOptions{
-		Topic:          s.TopicName,
-		StartMessageID: pulsar.EarliestMessageID(),
-		ReadCompacted:  true,
-	})
+	ctx, cancel := context.WithCancel(s.ctx)
+	defer cancel()
+	s.readerLock.Lock()
+	source, err := s.createSource()
+	s.cancelReader = cancel
+	s.readerLock.Unlock()
 
 	if err != nil {
 		log.Errorf("dbListener failed to create reader, error %v", err)
 		return err
 	}
-	defer reader.Close()
+	defer source.Close()
 
-	ctx := context.Background()
 	// infinite loop to receive messages
 	for {
-		data, err := reader.Next(ctx)
+		data, err := source.Next(ctx)
 		if err != nil {
+			if s.ctx.Err() != nil {
+				// the database is closed
+				return nil
+			}
 			log.Errorf("dbListener reader.Next() error %v", err)
 			return err
 		}
-		doc := model.FunctionConfig{}
-		if err = json.Unmarshal(data.Payload(), &doc); err != nil {
-			s.logger.Errorf("dblistener reader unmarshal error %v", err)
-			// ignore error and move on
-		} else {
-			s.topicsLock.Lock()
-			defer s.topicsLock.Unlock()
-			if doc.FunctionStatus != model.Deleted {
-				s.logger.Infof("add topic configuration %s", doc.ID)
-				s.topics[doc.ID] = doc
-			} else {
-				delete(s.topics, doc.ID)
-			}
+		s.topicsLock.Lock()
+		err = s.updateCache(s.topics, s.tombstones, data)
+		s.topicsLock.Unlock()
+		if err != nil {
+			// only the live listener quarantines so a replayed malformed document is not counted again
+			s.quarantine(data, err)
+		} else if data.Key() != HeartbeatKey {
+			s.cacheChanged()
 		}
+		source.Ack(data)
+		if data.Key() == HeartbeatKey {
+			s.markerRead(data)
+		}
+
+		s.readerLock.Lock()
+		s.startMessageID = data.ID()
+		s.readerLock.Unlock()
+
+		s.statsLock.Lock()
+		s.lastReadAt = time.Now()
+		s.readerLag = s.lastReadAt.Sub(data.PublishTime())
+		s.statsLock.Unlock()
 	}
 }
 
+// updateCache applies a database message to the cache, a message older than the cached document
+// or the deletion of the same key is ignored in case it is read out of order.
+// A malformed document is returned as an error and the cache keeps the last good document.
+func (s *PulsarHandler) updateCache(topics map[string]model.FunctionConfig, tombstones map[string]time.Time, data pulsar.Message) error {
+	if data.Key() == HeartbeatKey {
+		return nil
+	}
+	doc := model.FunctionConfig{}
+	if err := json.Unmarshal(data.Payload(), &doc); err != nil {
+		return err
+	}
+	model.MigrateWebhookURLs(&doc)
+	if !applyDoc(topics, tombstones, doc) {
+		s.logger.Warnf("ignore stale topic configuration %s changed at %v", doc.ID, docTime(&doc))
+		return nil
+	}
+	if doc.FunctionStatus != model.Deleted {
+		s.logger.Infof("add topic configuration %s", doc.ID)
+	}
+	s.checkCacheSize(len(topics))
+	return nil
+}
+
+// Reload rebuilds the cache by replaying the compacted topic from the earliest message.
+// The live reader is torn down during the replay and resumes after the last replayed message.
+func (s *PulsarHandler) Reload() error {
+	if !atomic.CompareAndSwapInt32(&s.reloading, 0, 1) {
+		return errors.New(DocReloadInProgress)
+	}
+	defer atomic.StoreInt32(&s.reloading, 0)
+
+	// holding the reader lock prevents the live reader from being recreated until the replay completes
+	s.readerLock.Lock()
+	defer s.readerLock.Unlock()
+	if s.cancelReader != nil {
+		s.cancelReader()
+		s.cancelReader = nil
+	}
+
+	topics, tombstones, lastMessageID, err := s.replay()
+	if err != nil {
+		return err
+	}
+
+	s.topicsLock.Lock()
+	s.topics = topics
+	s.tombstones = tombstones
+	s.topicsLock.Unlock()
+	s.startMessageID = lastMessageID
+	s.logger.Infof("reloaded database cache size %d", len(topics))
+	s.cacheChanged()
+	return nil
+}
+
+// replay reads the database topic from the earliest message into a new map of documents
+// and returns the map and the tombstones with the last read message ID
+func (s *PulsarHandler) replay() (map[string]model.FunctionConfig, map[string]time.Time, pulsar.MessageID, error) {
+	reader, err := s.client.CreateReader(pulsar.ReaderOptions{
+		Topic:          s.TopicName,
+		StartMessageID: pulsar.EarliestMessageID(),
+		ReadCompacted:  s.readCompacted,
+	})
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer reader.Close()
+
+	topics := make(map[string]model.FunctionConfig)
+	tombstones := make(map[string]time.Time)
+	lastMessageID := pulsar.EarliestMessageID()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(reloadTimeout())*time.Second)
+	defer cancel()
+	for reader.HasNext() {
+		data, err := reader.Next(ctx)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+		if err := s.updateCache(topics, tombstones, data); err != nil {
+			s.logger.Warnf("skip malformed database document key %s during replay %v", data.Key(), err)
+		}
+		lastMessageID = data.ID()
+	}
+	return topics, tombstones, lastMessageID, nil
+}
+
+// clientTimeouts returns the configured connection and operation timeouts of the database Pulsar client
+func clientTimeouts() (time.Duration, time.Duration) {
+	return time.Duration(util.ConfigInt(util.GetConfig().DbConnectTimeoutMs, 10000)) * time.Millisecond,
+		time.Duration(util.ConfigInt(util.GetConfig().DbOperationTimeoutMs, 30000)) * time.Millisecond
+}
+
 func (s *PulsarHandler) createProducer() error {
-	var err error
+	compression, err := model.GetCompressionType(util.GetConfig().DbCompression)
+	if err != nil {
+		return err
+	}
 	s.producer, err = s.client.CreateProducer(pulsar.ProducerOptions{
 		Topic:           s.TopicName,
 		DisableBatching: true,
+		CompressionType: compression,
 	})
 	return err
 }
 
 //Sync is a Db interface method.
+// it flushes the buffered documents to the database topic within the flush timeout
 func (s *PulsarHandler) Sync() error {
-	return errors.New("Unsupported since this is automatically sync-ed")
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(flushTimeout())*time.Second)
+	defer cancel()
+	return s.flush(ctx)
+}
+
+// send sends a document to the database topic bounded by the send timeout.
+// The Pulsar client in use has no producer send timeout option and ignores the context of Send,
+// so the send is awaited asynchronously. A timed out document may still be persisted later,
+// which is safe to retry since documents are keyed by the id and compaction keeps the latest one.
+func (s *PulsarHandler) send(msg *pulsar.ProducerMessage) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sendTimeout())*time.Millisecond)
+	defer cancel()
+	done := make(chan error, 1)
+	s.producer.SendAsync(ctx, msg, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+		done <- err
+	})
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("database send of document %s timed out after %d ms", msg.Key, sendTimeout())
+	}
+}
+
+// flush flushes the producer bounded by the context
+func (s *PulsarHandler) flush(ctx context.Context) error {
+	if s.producer == nil {
+		return nil
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- s.producer.Flush()
+	}()
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return fmt.Errorf("database producer flush error %v", ctx.Err())
+	}
 }
 
 //Health is a Db interface method
+// it is unhealthy if the last producer heartbeat failed or no heartbeat succeeded within two intervals
 func (s *PulsarHandler) Health() bool {
-	return true
+	if s.heartbeatInterval <= 0 {
+		return true
+	}
+	s.statsLock.RLock()
+	defer s.statsLock.RUnlock()
+	return s.lastSendErr == nil && time.Since(s.lastSendAt) < 2*s.heartbeatInterval
+}
+
+// HeartbeatKey is the message key of the producer heartbeats, compaction collapses them into the latest one
+const HeartbeatKey = "__pubsub_function_heartbeat__"
+
+// heartbeat periodically sends a heartbeat message to verify the producer can send
+func (s *PulsarHandler) heartbeat() {
+	// the first heartbeat is sent immediately so the health reflects the producer on startup
+	s.sendHeartbeat()
+	ticker := time.NewTicker(s.heartbeatInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendHeartbeat()
+		}
+	}
+}
+
+func (s *PulsarHandler) sendHeartbeat() {
+	now := time.Now()
+	data, _ := json.Marshal(map[string]time.Time{"heartbeat": now})
+	err := s.send(&pulsar.ProducerMessage{
+		Payload: data,
+		Key:     HeartbeatKey,
+	})
+	if err != nil {
+		s.logger.Errorf("database producer heartbeat error %v", err)
+	}
+	s.statsLock.Lock()
+	defer s.statsLock.Unlock()
+	s.lastSendErr = err
+	if err == nil {
+		s.lastSendAt = now
+	}
+}
+
+// Ready is a Db interface method
+// it is ready once the cache has caught up with the database topic and the database is healthy
+func (s *PulsarHandler) Ready() bool {
+	return atomic.LoadInt32(&s.cacheReady) == 1 && s.Health()
+}
+
+// HealthReport is a Db interface method
+func (s *PulsarHandler) HealthReport() HealthReport {
+	s.topicsLock.RLock()
+	cacheSize := len(s.topics)
+	s.topicsLock.RUnlock()
+
+	healthy := s.Health()
+	ready := atomic.LoadInt32(&s.cacheReady) == 1 && healthy
+	s.statsLock.RLock()
+	defer s.statsLock.RUnlock()
+	return HealthReport{
+		Healthy:           healthy,
+		Ready:             ready,
+		CacheSize:         cacheSize,
+		LastReadAt:        s.lastReadAt,
+		ReaderLag:         s.readerLag.String(),
+		ProducerConnected: s.producer != nil && s.lastSendErr == nil,
+		MalformedDocs:     atomic.LoadInt64(&s.malformedDocs),
+	}
 }
 
 // Close closes database
+// buffered documents are flushed before the producer is closed
 func (s *PulsarHandler) Close() error {
+	err := s.Sync()
+	if err != nil {
+		s.logger.Errorf("failed to flush database producer before close %v", err)
+	}
+	s.cancel()
 	s.producer.Close()
 	// s.client.Close()
 	// Here is a Client object leak
-	return nil
+	return err
 }
 
 //NewPulsarHandler initialize a Pulsar Db
 func NewPulsarHandler() (*PulsarHandler, error) {
 	handler := PulsarHandler{
-		logger: log.WithFields(log.Fields{"app": "pulsardb"}),
+		logger: util.LogEntry("pulsardb", ""),
 	}
 	handler.PulsarURL = util.GetConfig().PulsarBrokerURL
 	if strings.HasPrefix(util.GetConfig().DbConnectionStr, "pulsar") {
@@ -164,10 +573,77 @@ func NewPulsarHandler() (*PulsarHandler, error) {
 	}
 	handler.TopicName = util.GetConfig().DbName
 	handler.PulsarToken = util.GetConfig().DbPassword
+	handler.SubscriptionName = util.GetConfig().DbSubscriptionName
+	handler.configureCompaction()
+	if timeout := util.ConfigInt(util.GetConfig().DbReadyTimeout, 0); timeout > 0 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+		defer cancel()
+		err := handler.InitWithReady(ctx)
+		return &handler, err
+	}
 	err := handler.Init()
 	return &handler, err
 }
 
+// InitWithReady initializes the database and blocks until the cache has caught up
+// with the database topic or the context expires
+func (s *PulsarHandler) InitWithReady(ctx context.Context) error {
+	if err := s.Init(); err != nil {
+		return err
+	}
+	return s.WaitForReady(ctx)
+}
+
+// WaitForReady sends a ready marker to the database topic and waits for the listener to read it,
+// which proves the cache has caught up with the topic tail at the time of the call
+func (s *PulsarHandler) WaitForReady(ctx context.Context) error {
+	token := icrypto.GenUUID()
+	seen := make(chan struct{})
+	s.markersLock.Lock()
+	if s.markers == nil {
+		s.markers = make(map[string]chan struct{})
+	}
+	s.markers[token] = seen
+	s.markersLock.Unlock()
+	defer func() {
+		s.markersLock.Lock()
+		delete(s.markers, token)
+		s.markersLock.Unlock()
+	}()
+
+	data, _ := json.Marshal(map[string]string{"marker": token})
+	if err := s.send(&pulsar.ProducerMessage{
+		Payload: data,
+		Key:     HeartbeatKey,
+	}); err != nil {
+		return err
+	}
+	select {
+	case <-seen:
+		s.logger.Infof("database cache has caught up with the topic %s", s.TopicName)
+		atomic.StoreInt32(&s.cacheReady, 1)
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("database cache is not ready %v", ctx.Err())
+	}
+}
+
+// markerRead notifies the waiter of the ready marker in the heartbeat message
+func (s *PulsarHandler) markerRead(data pulsar.Message) {
+	marker := struct {
+		Marker string `json:"marker"`
+	}{}
+	if err := json.Unmarshal(data.Payload(), &marker); err != nil || marker.Marker == "" {
+		return
+	}
+	s.markersLock.Lock()
+	defer s.markersLock.Unlock()
+	if seen, ok := s.markers[marker.Marker]; ok {
+		close(seen)
+		delete(s.markers, marker.Marker)
+	}
+}
+
 // Create creates a new document
 func (s *PulsarHandler) Create(functionCfg *model.FunctionConfig) (string, error) {
 	key, err := getKey(functionCfg)
@@ -175,8 +651,21 @@ func (s *PulsarHandler) Create(functionCfg *model.FunctionConfig) (string, error
 		return key, err
 	}
 
-	if _, ok := s.topics[key]; ok {
-		return key, errors.New(DocAlreadyExisted)
+	if _, ok := s.cached(key); ok {
+		return key, fmt.Errorf("%w %s", ErrDocAlreadyExisted, key)
+	}
+	if err := normalizeWebhooks(functionCfg); err != nil {
+		return key, err
+	}
+	if err := s.validateSubscription(functionCfg, key); err != nil {
+		return key, err
+	}
+	existing, err := s.LoadByTenant(functionCfg.Tenant)
+	if err != nil {
+		return key, err
+	}
+	if err := validateTenantQuota(functionCfg.Tenant, existing); err != nil {
+		return key, err
 	}
 
 	functionCfg.ID = key
@@ -188,7 +677,6 @@ func (s *PulsarHandler) Create(functionCfg *model.FunctionConfig) (string, error
 
 func (s *PulsarHandler) updateCacheAndPulsar(functionCfg *model.FunctionConfig) (string, error) {
 
-	ctx := context.Background()
 	data, err := json.Marshal(*functionCfg)
 	if err != nil {
 		return "", err
@@ -198,41 +686,106 @@ func (s *PulsarHandler) updateCacheAndPulsar(functionCfg *model.FunctionConfig)
 		Key:     functionCfg.ID,
 	}
 
-	if _, err = s.producer.Send(ctx, &msg); err != nil {
+	if err = s.send(&msg); err != nil {
 		return "", err
 	}
 	// s.producer.Flush() do not use it's a blocking call
 
 	s.logger.Infof("send to Pulsar %s", functionCfg.ID)
 
-	s.topics[functionCfg.ID] = *functionCfg
+	s.topicsLock.Lock()
+	applyDoc(s.topics, s.tombstones, *functionCfg)
+	size := len(s.topics)
+	s.topicsLock.Unlock()
+	s.checkCacheSize(size)
 	return functionCfg.ID, nil
 }
 
+// cached gets a copy of the cached document
+func (s *PulsarHandler) cached(key string) (model.FunctionConfig, bool) {
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	v, ok := s.topics[key]
+	return v, ok
+}
+
+// validateSubscription validates the exclusive subscription of the document against the cached documents
+func (s *PulsarHandler) validateSubscription(functionCfg *model.FunctionConfig, key string) error {
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	return validateExclusiveSubscription(functionCfg, key, s.topics)
+}
+
 // GetByTopic gets a document by the topic name and pulsar URL
 func (s *PulsarHandler) GetByTopic(tenant, functionName string) (*model.FunctionConfig, error) {
-	key, err := model.GetKeyFromNames(tenant, functionName)
-	if err != nil {
-		return &model.FunctionConfig{}, err
-	}
-	return s.GetByKey(key)
+	return s.GetByKey(model.FunctionKey(tenant, functionName))
 }
 
 // GetByKey gets a document by the key
 func (s *PulsarHandler) GetByKey(hashedTopicKey string) (*model.FunctionConfig, error) {
-	if v, ok := s.topics[hashedTopicKey]; ok {
+	if v, ok := s.cached(hashedTopicKey); ok {
 		return &v, nil
 	}
-	return &model.FunctionConfig{}, errors.New(DocNotFound)
+	return &model.FunctionConfig{}, fmt.Errorf("%w %s", ErrDocNotFound, hashedTopicKey)
+}
+
+// GetByKeys gets documents by keys with a single read lock
+func (s *PulsarHandler) GetByKeys(keys []string) (map[string]*model.FunctionConfig, []string, error) {
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	found, missing := getByKeys(s.topics, keys)
+	return found, missing, nil
 }
 
-// Load loads the entire database into memory
+// Load loads the entire database into memory ordered by ID
 func (s *PulsarHandler) Load() ([]*model.FunctionConfig, error) {
-	results := []*model.FunctionConfig{}
-	for _, v := range s.topics {
-		results = append(results, &v)
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	return loadAll(s.topics), nil
+}
+
+// LoadByTenant loads all the documents belong to the tenant
+func (s *PulsarHandler) LoadByTenant(tenant string) ([]*model.FunctionConfig, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, err
 	}
-	return results, nil
+	return FilterByTenant(cfgs, tenant), nil
+}
+
+// GetByTopicKey gets the documents consuming or producing the topic
+func (s *PulsarHandler) GetByTopicKey(tk model.TopicKey) ([]*model.FunctionConfig, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterByTopicKey(cfgs, tk), nil
+}
+
+// ProducersOf gets the documents producing to the topic with a single read lock
+func (s *PulsarHandler) ProducersOf(topicFullName string) ([]*model.FunctionConfig, error) {
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	return producersOf(s.topics, topicFullName), nil
+}
+
+// LoadByStatus loads all the documents in the status
+func (s *PulsarHandler) LoadByStatus(status model.Status) ([]*model.FunctionConfig, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, err
+	}
+	return FilterByStatus(cfgs, status), nil
+}
+
+// LoadPage loads a page of documents and returns the total number of documents
+func (s *PulsarHandler) LoadPage(offset, limit int) ([]*model.FunctionConfig, int, error) {
+	cfgs, err := s.Load()
+	if err != nil {
+		return nil, 0, err
+	}
+	page, total := Paginate(cfgs, offset, limit)
+	return page, total, nil
 }
 
 // Update updates or creates a topic config document
@@ -242,53 +795,180 @@ func (s *PulsarHandler) Update(functionCfg *model.FunctionConfig) (string, error
 		return key, err
 	}
 
-	if _, ok := s.topics[key]; !ok {
+	v, ok := s.cached(key)
+	if !ok {
 		return s.Create(functionCfg)
 	}
+	if err := normalizeWebhooks(functionCfg); err != nil {
+		return key, err
+	}
+	if err := s.validateSubscription(functionCfg, key); err != nil {
+		return key, err
+	}
 
-	v := s.topics[key]
-	v.Tenant = functionCfg.Tenant
-	v.FunctionStatus = functionCfg.FunctionStatus
-	v.UpdatedAt = time.Now()
+	if err := updateDoc(&v, functionCfg); err != nil {
+		return key, err
+	}
 
 	s.logger.Infof("upsert %s", key)
 	return s.updateCacheAndPulsar(functionCfg)
 
 }
 
-// Delete deletes a document
-func (s *PulsarHandler) Delete(tenant, functionName string) (string, error) {
-	key, err := model.GetKeyFromNames(tenant, functionName)
+// Touch refreshes the document UpdatedAt and sends it to the database topic so a topic event is produced
+func (s *PulsarHandler) Touch(tenant, functionName string) (string, error) {
+	cfg, err := s.GetByKey(model.FunctionKey(tenant, functionName))
 	if err != nil {
 		return "", err
 	}
-	return s.DeleteByKey(key)
+	cfg.UpdatedAt = time.Now()
+	return s.updateCacheAndPulsar(cfg)
+}
+
+// AddWebhook adds a webhook to a function document
+func (s *PulsarHandler) AddWebhook(key string, wh model.WebhookConfig) error {
+	return addWebhook(s, key, wh)
+}
+
+// UpdateWebhook updates a webhook identified by its subscription
+func (s *PulsarHandler) UpdateWebhook(key string, wh model.WebhookConfig) error {
+	return updateWebhook(s, key, wh)
+}
+
+// DeleteWebhook soft deletes a webhook identified by its subscription
+func (s *PulsarHandler) DeleteWebhook(key, subscription string) error {
+	return deleteWebhook(s, key, subscription)
+}
+
+// Validate validates a document against the existing documents
+func (s *PulsarHandler) Validate(functionCfg *model.FunctionConfig) error {
+	key, err := getKey(functionCfg)
+	if err != nil {
+		return err
+	}
+	return s.validateSubscription(functionCfg, key)
+}
+
+// Delete deletes a document
+func (s *PulsarHandler) Delete(tenant, functionName string) (string, error) {
+	return s.DeleteByKey(model.FunctionKey(tenant, functionName))
 }
 
 // DeleteByKey deletes a document based on key
 func (s *PulsarHandler) DeleteByKey(hashedTopicKey string) (string, error) {
-	if _, ok := s.topics[hashedTopicKey]; !ok {
-		return "", errors.New(DocNotFound)
+	v, ok := s.cached(hashedTopicKey)
+	if !ok {
+		return "", fmt.Errorf("%w %s", ErrDocNotFound, hashedTopicKey)
 	}
 
-	v := s.topics[hashedTopicKey]
-	v.FunctionStatus = model.Deleted
-
-	ctx := context.Background()
-	data, err := json.Marshal(v)
+	msg, err := tombstone(&v)
 	if err != nil {
 		return "", err
 	}
+	if err = s.send(msg); err != nil {
+		return "", err
+	}
 
-	msg := pulsar.ProducerMessage{
+	s.topicsLock.Lock()
+	applyDoc(s.topics, s.tombstones, v)
+	size := len(s.topics)
+	s.topicsLock.Unlock()
+	s.checkCacheSize(size)
+	return hashedTopicKey, nil
+}
+
+// tombstone marks the document deleted and builds its tombstone message
+func tombstone(v *model.FunctionConfig) (*pulsar.ProducerMessage, error) {
+	v.FunctionStatus = model.Deleted
+	v.DeletedAt = time.Now()
+
+	data, err := json.Marshal(*v)
+	if err != nil {
+		return nil, err
+	}
+	return &pulsar.ProducerMessage{
 		Payload: data,
 		Key:     v.ID,
+	}, nil
+}
+
+// DeleteByTenant tombstones all documents of the tenant with asynchronous sends awaited together.
+// Documents failed to be tombstoned remain in the cache and the errors are reported.
+// The sends are bounded by the database send timeout so a stuck send cannot block the deletion.
+func (s *PulsarHandler) DeleteByTenant(tenant string) (int, error) {
+	s.topicsLock.RLock()
+	docs := []model.FunctionConfig{}
+	for _, v := range s.topics {
+		if v.Tenant == tenant {
+			docs = append(docs, v)
+		}
+	}
+	s.topicsLock.RUnlock()
+
+	// the sends share one deadline since they are in flight together
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(sendTimeout())*time.Millisecond)
+	defer cancel()
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	pending := map[string]bool{}
+	deleted := []model.FunctionConfig{}
+	failures := []string{}
+	for _, v := range docs {
+		msg, err := tombstone(&v)
+		lock.Lock()
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", v.ID, err))
+			lock.Unlock()
+			continue
+		}
+		pending[v.ID] = true
+		lock.Unlock()
+		wg.Add(1)
+		doc := v
+		s.producer.SendAsync(ctx, msg, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+			defer wg.Done()
+			lock.Lock()
+			defer lock.Unlock()
+			delete(pending, doc.ID)
+			if err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", doc.ID, err))
+				return
+			}
+			deleted = append(deleted, doc)
+		})
+	}
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
 	}
 
-	if _, err = s.producer.Send(ctx, &msg); err != nil {
-		return "", err
+	// the late callbacks of the timed out sends are not applied
+	lock.Lock()
+	applied := append([]model.FunctionConfig{}, deleted...)
+	failed := append([]string{}, failures...)
+	for id := range pending {
+		failed = append(failed, fmt.Sprintf("%s: send timed out after %d ms", id, sendTimeout()))
 	}
+	lock.Unlock()
 
-	delete(s.topics, v.ID)
-	return hashedTopicKey, nil
+	s.topicsLock.Lock()
+	for _, doc := range applied {
+		applyDoc(s.topics, s.tombstones, doc)
+	}
+	size := len(s.topics)
+	s.topicsLock.Unlock()
+	s.checkCacheSize(size)
+
+	s.logger.Infof("deleted %d functions of tenant %s", len(applied), tenant)
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		return len(applied), fmt.Errorf("failed to delete %d functions of tenant %s: %s",
+			len(failed), tenant, strings.Join(failed, "; "))
+	}
+	return len(applied), nil
 }
@@ -0,0 +1,367 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+	log "github.com/sirupsen/logrus"
+)
+
+// fakeProducer records the sent documents and fails the keys in failKeys,
+// the sends are acknowledged after the send delay
+type fakeProducer struct {
+	pulsar.Producer
+	lock       sync.Mutex
+	sent       []*pulsar.ProducerMessage
+	failKeys   map[string]bool
+	stuckKeys  map[string]bool
+	flushDelay time.Duration
+	sendDelay  time.Duration
+	flushed    int
+	closed     bool
+}
+
+func (p *fakeProducer) Flush() error {
+	time.Sleep(p.flushDelay)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.flushed++
+	return nil
+}
+
+func (p *fakeProducer) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.closed = true
+}
+
+func (p *fakeProducer) SendAsync(_ context.Context, msg *pulsar.ProducerMessage, callback func(pulsar.MessageID, *pulsar.ProducerMessage, error)) {
+	p.lock.Lock()
+	p.sent = append(p.sent, msg)
+	fail, stuck := p.failKeys[msg.Key], p.stuckKeys[msg.Key]
+	p.lock.Unlock()
+	if stuck {
+		return
+	}
+	if p.sendDelay > 0 {
+		go func() {
+			time.Sleep(p.sendDelay)
+			callback(nil, msg, nil)
+		}()
+		return
+	}
+	if fail {
+		callback(nil, msg, errors.New("send failure"))
+		return
+	}
+	callback(nil, msg, nil)
+}
+
+// fakeClient records the producer options and creates fake producers
+type fakeClient struct {
+	pulsar.Client
+	producerOpts []pulsar.ProducerOptions
+}
+
+func (c *fakeClient) CreateProducer(opts pulsar.ProducerOptions) (pulsar.Producer, error) {
+	c.producerOpts = append(c.producerOpts, opts)
+	return &fakeProducer{failKeys: map[string]bool{}}, nil
+}
+
+func newTestPulsarHandler() (*PulsarHandler, *fakeProducer) {
+	producer := &fakeProducer{failKeys: map[string]bool{}, stuckKeys: map[string]bool{}}
+	return &PulsarHandler{
+		producer:   producer,
+		topics:     make(map[string]model.FunctionConfig),
+		tombstones: make(map[string]time.Time),
+		logger:     log.WithField("component", "pulsar-db-test"),
+	}, producer
+}
+
+func TestPulsarHandlerConcurrentAccess(t *testing.T) {
+	database, _ := newTestPulsarHandler()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			name := fmt.Sprintf("f%d", i)
+			for j := 0; j < 50; j++ {
+				if _, err := database.Update(functionOn("t1", name, "", "shared")); err != nil {
+					t.Error(err)
+					return
+				}
+				database.GetByKey("t1" + name)
+				database.Load()
+				database.Validate(functionOn("t2", name, "sub", "exclusive"))
+				if j%10 == 9 {
+					if _, err := database.DeleteByKey("t1" + name); err != nil {
+						t.Error(err)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestPulsarHandlerFlush(t *testing.T) {
+	database, producer := newTestPulsarHandler()
+	database.ctx, database.cancel = context.WithCancel(context.Background())
+	if err := database.Close(); err != nil || producer.flushed != 1 || !producer.closed {
+		t.Fatalf("close must flush then close the producer, flushed %d closed %v error %v", producer.flushed, producer.closed, err)
+	}
+	if database.ctx.Err() == nil {
+		t.Fatal("close must cancel the background routines")
+	}
+
+	producer.flushDelay = 100 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := database.flush(ctx); err == nil {
+		t.Fatal("a flush exceeding the timeout must fail")
+	}
+}
+
+func TestPulsarHandlerDeleteByTenant(t *testing.T) {
+	defer func(timeout string) { util.Config.DbSendTimeoutMs = timeout }(util.Config.DbSendTimeoutMs)
+	util.Config.DbSendTimeoutMs = "50"
+	database, producer := newTestPulsarHandler()
+	for _, cfg := range []*model.FunctionConfig{
+		functionOn("t1", "f1", "", "shared"),
+		functionOn("t1", "f2", "", "shared"),
+		functionOn("t1", "f3", "", "shared"),
+		functionOn("t1", "f4", "", "shared"),
+		functionOn("t2", "f1", "", "shared"),
+	} {
+		if _, err := database.Create(cfg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale, _ := database.cached("t1f1")
+	producer.sent = nil
+	producer.failKeys["t1f2"] = true
+	// a stuck send is bounded by the send timeout
+	producer.stuckKeys["t1f4"] = true
+
+	deleted, err := database.DeleteByTenant("t1")
+	if err == nil || !strings.Contains(err.Error(), "t1f2: send failure") || !strings.Contains(err.Error(), "t1f4: send timed out") {
+		t.Errorf("expected the failure of t1f2 and the timeout of t1f4 to be reported, got %v", err)
+	}
+	if deleted != 2 {
+		t.Errorf("expected 2 deleted functions, got %d", deleted)
+	}
+
+	cases := []struct {
+		key       string
+		cached    bool
+		tombstone bool
+	}{
+		{"t1f1", false, true},
+		{"t1f2", true, false},
+		{"t1f3", false, true},
+		{"t1f4", true, false},
+		{"t2f1", true, false},
+	}
+	for _, c := range cases {
+		_, cached := database.cached(c.key)
+		_, tombstone := database.tombstones[c.key]
+		if cached != c.cached || tombstone != c.tombstone {
+			t.Errorf("%s: expected cached %v tombstone %v, got %v %v", c.key, c.cached, c.tombstone, cached, tombstone)
+		}
+	}
+
+	for _, msg := range producer.sent {
+		var doc model.FunctionConfig
+		if err := json.Unmarshal(msg.Payload, &doc); err != nil {
+			t.Fatal(err)
+		}
+		if doc.FunctionStatus != model.Deleted || doc.DeletedAt.IsZero() {
+			t.Errorf("%s: the tombstone must be deleted with the deletion time, got %v %v", msg.Key, doc.FunctionStatus, doc.DeletedAt)
+		}
+	}
+
+	// the tombstone keeps a stale document from bringing the function back
+	if applyDoc(database.topics, database.tombstones, stale) {
+		t.Error("a document older than the tombstone must not be applied")
+	}
+}
+
+func TestPulsarHandlerSendTimeout(t *testing.T) {
+	defer func(timeout string) { util.Config.DbSendTimeoutMs = timeout }(util.Config.DbSendTimeoutMs)
+
+	cases := []struct {
+		timeout string
+		delay   time.Duration
+		fails   bool
+	}{
+		{"20", 200 * time.Millisecond, true},
+		{"1000", 10 * time.Millisecond, false},
+		{"", 0, false},
+	}
+	for _, c := range cases {
+		util.Config.DbSendTimeoutMs = c.timeout
+		database, producer := newTestPulsarHandler()
+		producer.sendDelay = c.delay
+		_, err := database.Create(functionOn("t1", "f1", "", "shared"))
+		if (err != nil) != c.fails {
+			t.Errorf("timeout %q delay %v: unexpected error %v", c.timeout, c.delay, err)
+		}
+		// a document failed to be sent is not cached
+		if _, cached := database.cached("t1f1"); cached == c.fails {
+			t.Errorf("timeout %q delay %v: unexpected cached %v", c.timeout, c.delay, cached)
+		}
+	}
+}
+
+func TestCreateProducerCompression(t *testing.T) {
+	defer func(compression string) { util.Config.DbCompression = compression }(util.Config.DbCompression)
+
+	cases := []struct {
+		compression string
+		want        pulsar.CompressionType
+		valid       bool
+	}{
+		{"", pulsar.LZ4, true},
+		{"zstd", pulsar.ZSTD, true},
+		{"none", pulsar.NoCompression, true},
+		{"snappy", 0, false},
+	}
+	for _, c := range cases {
+		util.Config.DbCompression = c.compression
+		client := &fakeClient{}
+		database := &PulsarHandler{client: client, TopicName: "functions"}
+		err := database.createProducer()
+		if (err == nil) != c.valid {
+			t.Errorf("%q: unexpected error %v", c.compression, err)
+			continue
+		}
+		if !c.valid {
+			if len(client.producerOpts) != 0 {
+				t.Errorf("%q: no producer must be created with an invalid compression", c.compression)
+			}
+			continue
+		}
+		if opts := client.producerOpts[0]; opts.CompressionType != c.want || opts.Topic != "functions" || !opts.DisableBatching {
+			t.Errorf("%q: unexpected producer options %+v", c.compression, opts)
+		}
+	}
+}
+
+func TestPulsarHandlerHeartbeatHealth(t *testing.T) {
+	cases := []struct {
+		name     string
+		interval time.Duration
+		fail     bool
+		age      time.Duration
+		healthy  bool
+	}{
+		{"heartbeat disabled", 0, true, 0, true},
+		{"heartbeat sent", time.Minute, false, 0, true},
+		{"heartbeat failed", time.Minute, true, 0, false},
+		{"no heartbeat within two intervals", time.Minute, false, 3 * time.Minute, false},
+	}
+	for _, c := range cases {
+		database, producer := newTestPulsarHandler()
+		database.heartbeatInterval = c.interval
+		producer.failKeys[HeartbeatKey] = c.fail
+		database.sendHeartbeat()
+		database.lastSendAt = database.lastSendAt.Add(-c.age)
+		if healthy := database.Health(); healthy != c.healthy {
+			t.Errorf("%s: expected healthy %v", c.name, c.healthy)
+		}
+		if report := database.HealthReport(); report.Healthy != c.healthy || report.ProducerConnected == c.fail {
+			t.Errorf("%s: unexpected health report %+v", c.name, report)
+		}
+	}
+}
+
+func TestPulsarHandlerHeartbeatLoop(t *testing.T) {
+	database, producer := newTestPulsarHandler()
+	database.heartbeatInterval = 5 * time.Millisecond
+	database.ctx, database.cancel = context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		database.heartbeat()
+		close(done)
+	}()
+	time.Sleep(30 * time.Millisecond)
+	database.cancel()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("the heartbeat must stop when the database is closed")
+	}
+
+	producer.lock.Lock()
+	defer producer.lock.Unlock()
+	if len(producer.sent) < 2 {
+		t.Fatalf("expected periodic heartbeats, got %d", len(producer.sent))
+	}
+	// heartbeats are not documents
+	for _, msg := range producer.sent {
+		database.updateCache(database.topics, database.tombstones, &dbMessage{key: msg.Key, payload: msg.Payload})
+	}
+	if len(database.topics) != 0 || atomic.LoadInt64(&database.malformedDocs) != 0 {
+		t.Errorf("heartbeats must not change the cache, got %d documents", len(database.topics))
+	}
+}
+
+func TestClientTimeouts(t *testing.T) {
+	defer func(connect, operation string) {
+		util.Config.DbConnectTimeoutMs, util.Config.DbOperationTimeoutMs = connect, operation
+	}(util.Config.DbConnectTimeoutMs, util.Config.DbOperationTimeoutMs)
+
+	cases := []struct {
+		connect, operation string
+		connectTimeout     time.Duration
+		operationTimeout   time.Duration
+	}{
+		{"", "", 10 * time.Second, 30 * time.Second},
+		{"500", "2000", 500 * time.Millisecond, 2 * time.Second},
+		{"invalid", "1500", 10 * time.Second, 1500 * time.Millisecond},
+	}
+	for _, c := range cases {
+		util.Config.DbConnectTimeoutMs, util.Config.DbOperationTimeoutMs = c.connect, c.operation
+		connectTimeout, operationTimeout := clientTimeouts()
+		if connectTimeout != c.connectTimeout || operationTimeout != c.operationTimeout {
+			t.Errorf("%q %q: expected %v %v, got %v %v", c.connect, c.operation, c.connectTimeout, c.operationTimeout, connectTimeout, operationTimeout)
+		}
+	}
+}
+
+func TestReadyDuringOutage(t *testing.T) {
+	cases := []struct {
+		name       string
+		cacheReady int32
+		sendErr    error
+		sentAgo    time.Duration
+		ready      bool
+	}{
+		{"caught up", 1, nil, 0, true},
+		{"cache warming", 0, nil, 0, false},
+		{"send failure", 1, errors.New("broker unreachable"), 0, false},
+		{"no recent heartbeat", 1, nil, time.Minute, false},
+	}
+	for _, c := range cases {
+		database, _ := newTestPulsarHandler()
+		database.heartbeatInterval = time.Second
+		database.cacheReady = c.cacheReady
+		database.lastSendErr = c.sendErr
+		database.lastSendAt = time.Now().Add(-c.sentAgo)
+		if database.Ready() != c.ready || database.HealthReport().Ready != c.ready {
+			t.Errorf("%s: expected ready %v", c.name, c.ready)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"context"
+	"encoding/base64"
+	"sync/atomic"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var malformedDocs = prometheus.NewCounter(prometheus.CounterOpts{
+	Name: "pubsub_function_db_malformed_docs_total",
+	Help: "The number of database documents failed to unmarshal",
+})
+
+func init() {
+	prometheus.MustRegister(malformedDocs)
+}
+
+// properties of the quarantined document
+const (
+	QuarantineMessageIDProperty = "MessageId"
+	QuarantineKeyProperty       = "Key"
+	QuarantineErrorProperty     = "Error"
+)
+
+// quarantine records the malformed database message and forwards its raw payload to the quarantine topic
+func (s *PulsarHandler) quarantine(data pulsar.Message, err error) {
+	atomic.AddInt64(&s.malformedDocs, 1)
+	malformedDocs.Inc()
+	msgID := base64.StdEncoding.EncodeToString(data.ID().Serialize())
+	s.logger.Errorf("quarantine malformed database document key %s message id %s error %v payload %q",
+		data.Key(), msgID, err, data.Payload())
+
+	topic := util.GetConfig().DbQuarantineTopic
+	if topic == "" {
+		return
+	}
+	p, perr := pulsardriver.GetPulsarProducer(s.PulsarURL, s.PulsarToken, topic)
+	if perr != nil {
+		s.logger.Errorf("failed to create database quarantine producer %v", perr)
+		return
+	}
+	// the listener is not blocked by the quarantine topic
+	p.SendAsync(context.Background(), &pulsar.ProducerMessage{
+		Payload: data.Payload(),
+		Properties: map[string]string{
+			QuarantineMessageIDProperty: msgID,
+			QuarantineKeyProperty:       data.Key(),
+			QuarantineErrorProperty:     err.Error(),
+		},
+	}, func(_ pulsar.MessageID, _ *pulsar.ProducerMessage, err error) {
+		if err != nil {
+			s.logger.Errorf("failed to send malformed document %s to quarantine topic %s %v", msgID, topic, err)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package db
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestQuarantineMalformedDocs(t *testing.T) {
+	before := testutil.ToFloat64(malformedDocs)
+	topic := newFakeTopic(
+		docMessage(t, 0, storedDoc("t1", "f1")),
+		&dbMessage{key: "t1f2", payload: []byte("{not json"), id: fakeID(1), published: time.Now()},
+		docMessage(t, 2, storedDoc("t1", "f3")),
+	)
+	database := newListeningHandler(topic)
+	stop := listen(database)
+	eventually(t, "the document after the malformed one", func() bool { _, ok := database.cached("t1f3"); return ok })
+	stop()
+
+	cases := []struct {
+		name    string
+		operate func() error
+	}{
+		{"listener", func() error { return nil }},
+		{"reload", database.Reload},
+		{"verify", func() error { _, err := database.Verify(); return err }},
+		{"reload again", database.Reload},
+	}
+	for _, c := range cases {
+		if err := c.operate(); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		// a replayed malformed document is not quarantined again
+		if count := atomic.LoadInt64(&database.malformedDocs); count != 1 {
+			t.Errorf("%s: expected 1 malformed document, got %d", c.name, count)
+		}
+		if delta := testutil.ToFloat64(malformedDocs) - before; delta != 1 {
+			t.Errorf("%s: expected the malformed counter increased by 1, got %v", c.name, delta)
+		}
+		_, ok1 := database.cached("t1f1")
+		_, ok2 := database.cached("t1f2")
+		_, ok3 := database.cached("t1f3")
+		if !ok1 || ok2 || !ok3 {
+			t.Errorf("%s: expected only the good documents cached, got t1f1 %v t1f2 %v t1f3 %v", c.name, ok1, ok2, ok3)
+		}
+	}
+	if report := database.HealthReport(); report.MalformedDocs != 1 {
+		t.Errorf("expected the health report of 1 malformed document, got %d", report.MalformedDocs)
+	}
+}
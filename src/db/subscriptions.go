@@ -0,0 +1,83 @@
+package db
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// SubscriptionInfo is a subscription name and type configured on a topic
+type SubscriptionInfo struct {
+	Subscription     string `json:"subscription"`
+	SubscriptionType string `json:"subscriptionType"`
+	// Functions are the IDs of the functions using the subscription
+	Functions []string `json:"functions"`
+}
+
+// SubscriptionsOnTopic gets the subscriptions configured on the topic with a single read lock
+func (s *PulsarHandler) SubscriptionsOnTopic(topicFullName string) ([]SubscriptionInfo, error) {
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	return subscriptionsOn(s.topics, topicFullName), nil
+}
+
+// SubscriptionsOnTopic gets the subscriptions configured on the topic
+func (s *InMemoryHandler) SubscriptionsOnTopic(topicFullName string) ([]SubscriptionInfo, error) {
+	return subscriptionsOn(s.functions, topicFullName), nil
+}
+
+// subscriptionsOn aggregates the input topic and webhook subscriptions of the functions not deleted
+// consuming the topic, deleted webhooks are excluded. The results are ordered by subscription and type.
+func subscriptionsOn(functions map[string]model.FunctionConfig, topicFullName string) []SubscriptionInfo {
+	byKey := make(map[string]*SubscriptionInfo)
+	add := func(id, name, subType string) {
+		if name == "" {
+			return
+		}
+		subType = subscriptionTypeName(subType)
+		key := name + "|" + subType
+		info, ok := byKey[key]
+		if !ok {
+			info = &SubscriptionInfo{Subscription: name, SubscriptionType: subType, Functions: []string{}}
+			byKey[key] = info
+		}
+		if len(info.Functions) == 0 || info.Functions[len(info.Functions)-1] != id {
+			info.Functions = append(info.Functions, id)
+		}
+	}
+
+	for _, cfg := range loadAll(functions) {
+		in := cfg.InputTopic
+		if cfg.FunctionStatus == model.Deleted ||
+			!matchTopicKey(in, model.TopicKey{PulsarURL: in.PulsarURL, TopicFullName: topicFullName}) {
+			continue
+		}
+		add(cfg.ID, in.Subscription, in.SubscriptionType)
+		for _, wh := range cfg.Webhooks {
+			if wh.WebhookStatus != model.Deleted {
+				add(cfg.ID, wh.Subscription, wh.SubscriptionType)
+			}
+		}
+	}
+
+	results := make([]SubscriptionInfo, 0, len(byKey))
+	for _, info := range byKey {
+		results = append(results, *info)
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Subscription != results[j].Subscription {
+			return results[i].Subscription < results[j].Subscription
+		}
+		return results[i].SubscriptionType < results[j].SubscriptionType
+	})
+	return results
+}
+
+// subscriptionTypeName normalizes the subscription type, an empty type is the default exclusive subscription
+func subscriptionTypeName(subType string) string {
+	if subType == "" {
+		return "exclusive"
+	}
+	return strings.ToLower(subType)
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestSubscriptionsOnTopic(t *testing.T) {
+	inMemory, _ := NewInMemoryHandler()
+	pulsarDb, _ := newTestPulsarHandler()
+	for _, database := range []Db{inMemory, pulsarDb} {
+		f1 := functionOn("t1", "f1", "shared-sub", "shared")
+		f1.Webhooks = []model.WebhookConfig{
+			{URL: "http://localhost/a", Subscription: "hook-sub", SubscriptionType: "Exclusive"},
+			{URL: "http://localhost/b", Subscription: "gone-sub", SubscriptionType: "shared", WebhookStatus: model.Deleted},
+		}
+		f3 := functionOn("t2", "f3", "", "shared")
+		f3.InputTopic.TopicFullName, f3.InputTopic.TopicsPattern = "", "persistent://public/default/in.*"
+		f3.InputTopic.Subscription = "pattern-sub"
+		for _, cfg := range []*model.FunctionConfig{
+			f1,
+			functionOn("t1", "f2", "shared-sub", "shared"),
+			f3,
+			functionOn("t1", "f4", "failover-sub", "failover"),
+		} {
+			if _, err := database.Create(cfg); err != nil {
+				t.Fatal(err)
+			}
+		}
+		other := functionOn("t1", "f5", "other-sub", "shared")
+		other.InputTopic.TopicFullName = "persistent://public/default/other"
+		if _, err := database.Create(other); err != nil {
+			t.Fatal(err)
+		}
+		// a deleted function is excluded
+		deleted := *functionOn("t1", "f6", "deleted-sub", "shared")
+		deleted.ID, deleted.FunctionStatus = "t1f6", model.Deleted
+		switch d := database.(type) {
+		case *InMemoryHandler:
+			d.functions["t1f6"] = deleted
+		case *PulsarHandler:
+			d.topics["t1f6"] = deleted
+		}
+
+		cases := []struct {
+			topic    string
+			expected []SubscriptionInfo
+		}{
+			{"persistent://public/default/input", []SubscriptionInfo{
+				{"failover-sub", "failover", []string{"t1f4"}},
+				{"hook-sub", "exclusive", []string{"t1f1"}},
+				{"pattern-sub", "shared", []string{"t2f3"}},
+				{"shared-sub", "shared", []string{"t1f1", "t1f2"}},
+			}},
+			{"persistent://public/default/other", []SubscriptionInfo{{"other-sub", "shared", []string{"t1f5"}}}},
+			{"persistent://public/default/none", []SubscriptionInfo{}},
+		}
+		for _, c := range cases {
+			subs, err := database.SubscriptionsOnTopic(c.topic)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(subs, c.expected) {
+				t.Errorf("%T %s: expected %v, got %v", database, c.topic, c.expected, subs)
+			}
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package db
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// Verify replays the database topic into a temporary map and returns the keys of the documents
+// diverging from the cache. A document being written during the replay may be reported as well.
+func (s *PulsarHandler) Verify() ([]string, error) {
+	replayed, _, _, err := s.replay()
+	if err != nil {
+		return nil, err
+	}
+	s.topicsLock.RLock()
+	defer s.topicsLock.RUnlock()
+	return divergentKeys(s.topics, replayed), nil
+}
+
+// verifyPeriodically logs the divergence between the cache and the database topic until the database is closed
+func (s *PulsarHandler) verifyPeriodically(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			keys, err := s.Verify()
+			if err != nil {
+				s.logger.Errorf("failed to verify database cache %v", err)
+			} else if len(keys) > 0 {
+				s.logger.Errorf("database cache diverges from topic %s on keys %v", s.TopicName, keys)
+			}
+		}
+	}
+}
+
+// Verify always succeeds since the in-memory database has no backing topic
+func (s *InMemoryHandler) Verify() ([]string, error) {
+	return []string{}, nil
+}
+
+// divergentKeys returns the sorted keys missing from either map or whose documents differ.
+// The documents are compared by their JSON encoding as they are persisted.
+func divergentKeys(cache, replayed map[string]model.FunctionConfig) []string {
+	keys := []string{}
+	for k, v := range cache {
+		r, ok := replayed[k]
+		if !ok || !sameDocument(v, r) {
+			keys = append(keys, k)
+		}
+	}
+	for k := range replayed {
+		if _, ok := cache[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func sameDocument(a, b model.FunctionConfig) bool {
+	aJSON, aErr := json.Marshal(a)
+	bJSON, bErr := json.Marshal(b)
+	return aErr == nil && bErr == nil && string(aJSON) == string(bJSON)
+}
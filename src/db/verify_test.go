@@ -0,0 +1,56 @@
+package db
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestVerify(t *testing.T) {
+	topic := newFakeTopic()
+	for i, name := range []string{"f1", "f2", "f3"} {
+		topic.publish(docMessage(t, i, storedDoc("t1", name)))
+	}
+	database := newListeningHandler(topic)
+
+	cases := []struct {
+		name    string
+		diverge func(cache map[string]model.FunctionConfig)
+		keys    []string
+	}{
+		{"consistent", func(cache map[string]model.FunctionConfig) {}, []string{}},
+		{"modified document", func(cache map[string]model.FunctionConfig) {
+			cfg := cache["t1f1"]
+			cfg.Parallelism = 9
+			cache["t1f1"] = cfg
+		}, []string{"t1f1"}},
+		{"document missing from the cache", func(cache map[string]model.FunctionConfig) { delete(cache, "t1f2") }, []string{"t1f2"}},
+		{"document missing from the topic", func(cache map[string]model.FunctionConfig) { cache["t1f9"] = storedDoc("t1", "f9") }, []string{"t1f9"}},
+		{"several divergences", func(cache map[string]model.FunctionConfig) {
+			delete(cache, "t1f3")
+			cache["t2f1"] = storedDoc("t2", "f1")
+		}, []string{"t1f3", "t2f1"}},
+	}
+	for _, c := range cases {
+		if err := database.Reload(); err != nil {
+			t.Fatal(err)
+		}
+		database.topicsLock.Lock()
+		c.diverge(database.topics)
+		database.topicsLock.Unlock()
+
+		keys, err := database.Verify()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(keys, ",") != strings.Join(c.keys, ",") {
+			t.Errorf("%s: expected divergent keys %v, got %v", c.name, c.keys, keys)
+		}
+	}
+
+	inMemory, _ := NewInMemoryHandler()
+	if keys, err := inMemory.Verify(); err != nil || len(keys) != 0 {
+		t.Errorf("expected the in-memory database to be consistent, got %v %v", keys, err)
+	}
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// addWebhook adds a webhook to the function document
+func addWebhook(database Crud, key string, wh model.WebhookConfig) error {
+	cfg, err := database.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	wh.CreatedAt = time.Now()
+	wh.UpdatedAt = wh.CreatedAt
+	webhooks := append(append([]model.WebhookConfig{}, cfg.Webhooks...), wh)
+	if err := model.ValidateWebhookConfig(webhooks); err != nil {
+		return err
+	}
+	cfg.Webhooks = webhooks
+	_, err = database.Update(cfg)
+	return err
+}
+
+// updateWebhook replaces the webhook identified by its subscription in the function document
+func updateWebhook(database Crud, key string, wh model.WebhookConfig) error {
+	cfg, err := database.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	i := findWebhook(cfg.Webhooks, wh.Subscription)
+	if i < 0 {
+		return fmt.Errorf("%w webhook subscription %s", ErrDocNotFound, wh.Subscription)
+	}
+	wh.CreatedAt = cfg.Webhooks[i].CreatedAt
+	wh.UpdatedAt = time.Now()
+	webhooks := append([]model.WebhookConfig{}, cfg.Webhooks...)
+	webhooks[i] = wh
+	if err := model.ValidateWebhookConfig(webhooks); err != nil {
+		return err
+	}
+	cfg.Webhooks = webhooks
+	_, err = database.Update(cfg)
+	return err
+}
+
+// deleteWebhook soft deletes the webhook so that it is retained in the function document
+func deleteWebhook(database Crud, key, subscription string) error {
+	cfg, err := database.GetByKey(key)
+	if err != nil {
+		return err
+	}
+	i := findWebhook(cfg.Webhooks, subscription)
+	if i < 0 {
+		return fmt.Errorf("%w webhook subscription %s", ErrDocNotFound, subscription)
+	}
+	// the webhooks are copied since the slice may be shared with the cached document
+	webhooks := append([]model.WebhookConfig{}, cfg.Webhooks...)
+	now := time.Now()
+	webhooks[i].WebhookStatus = model.Deleted
+	webhooks[i].DeletedAt = now
+	webhooks[i].UpdatedAt = now
+	cfg.Webhooks = webhooks
+	_, err = database.Update(cfg)
+	return err
+}
+
+// findWebhook returns the index of the webhook not deleted with the subscription, -1 if not found
+func findWebhook(webhooks []model.WebhookConfig, subscription string) int {
+	for i, v := range webhooks {
+		if v.Subscription == subscription && v.WebhookStatus != model.Deleted {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,78 @@
+package db
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func webhookOn(url, subscription string) model.WebhookConfig {
+	return model.WebhookConfig{URL: url, Subscription: subscription, WebhookStatus: model.Activated}
+}
+
+func TestWebhookLifecycle(t *testing.T) {
+	database, _ := NewInMemoryHandler()
+	if _, err := database.Create(functionOn("t1", "f1", "", "shared")); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []struct {
+		name     string
+		apply    func() error
+		notFound bool
+		webhooks int
+		deleted  int
+	}{
+		{"add", func() error { return database.AddWebhook("t1f1", webhookOn("https://example.com/a", "sub-a")) }, false, 1, 0},
+		{"add another", func() error { return database.AddWebhook("t1f1", webhookOn("https://example.com/b", "sub-b")) }, false, 2, 0},
+		{"update", func() error { return database.UpdateWebhook("t1f1", webhookOn("https://example.com/a2", "sub-a")) }, false, 2, 0},
+		{"update unknown", func() error { return database.UpdateWebhook("t1f1", webhookOn("https://example.com/c", "sub-c")) }, true, 2, 0},
+		{"soft delete", func() error { return database.DeleteWebhook("t1f1", "sub-a") }, false, 2, 1},
+		{"delete again", func() error { return database.DeleteWebhook("t1f1", "sub-a") }, true, 2, 1},
+		{"update deleted", func() error { return database.UpdateWebhook("t1f1", webhookOn("https://example.com/a3", "sub-a")) }, true, 2, 1},
+		{"unknown function", func() error { return database.AddWebhook("t1f9", webhookOn("https://example.com/a", "sub-a")) }, true, 2, 1},
+	}
+	for _, step := range steps {
+		err := step.apply()
+		if step.notFound != errors.Is(err, ErrDocNotFound) || (!step.notFound && err != nil) {
+			t.Errorf("%s: unexpected error %v", step.name, err)
+		}
+		cfg, _ := database.GetByKey("t1f1")
+		deleted := 0
+		for _, wh := range cfg.Webhooks {
+			if wh.WebhookStatus == model.Deleted {
+				deleted++
+				if wh.DeletedAt.IsZero() {
+					t.Errorf("%s: the soft deleted webhook has no deletion time", step.name)
+				}
+			}
+		}
+		if len(cfg.Webhooks) != step.webhooks || deleted != step.deleted {
+			t.Errorf("%s: expected %d webhooks %d deleted, got %+v", step.name, step.webhooks, step.deleted, cfg.Webhooks)
+		}
+	}
+
+	cfg, _ := database.GetByKey("t1f1")
+	if cfg.Webhooks[0].URL != "https://example.com/a2" || cfg.Webhooks[0].CreatedAt.IsZero() {
+		t.Errorf("the update must replace the webhook and keep its creation time %+v", cfg.Webhooks[0])
+	}
+}
+
+func TestNormalizeWebhooksNamesSubscriptions(t *testing.T) {
+	cfg := functionOn("t1", "f1", "", "shared")
+	cfg.Webhooks = []model.WebhookConfig{
+		{URL: "https://example.com/a", SubscriptionMode: model.SubscriptionResumable, WebhookStatus: model.Activated},
+		{URL: "https://example.com/b", WebhookStatus: model.Activated},
+		{URL: "https://example.com/c", Subscription: "named", WebhookStatus: model.Activated},
+	}
+	if err := normalizeWebhooks(cfg); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Webhooks[0].Subscription != "t1f1-webhook-0" ||
+		!strings.HasPrefix(cfg.Webhooks[1].Subscription, model.NonResumable) ||
+		cfg.Webhooks[2].Subscription != "named" {
+		t.Fatalf("unexpected subscription names %+v", cfg.Webhooks)
+	}
+}
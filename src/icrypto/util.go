@@ -2,7 +2,12 @@ package icrypto
 
 // encryption and decryption utility functions
 import (
+	"crypto/hmac"
+	crand "crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
+	"fmt"
 	"math/rand"
 
 	log "github.com/sirupsen/logrus"
@@ -57,3 +62,33 @@ func RandKey(n int) string {
 func GenTopicKey() string {
 	return RandKey(24)
 }
+
+// GenUUID generates a random version 4 UUID
+func GenUUID() string {
+	b := make([]byte, 16)
+	if _, err := crand.Read(b); err != nil {
+		// fall back to the pseudo random source if the system entropy is unavailable
+		rand.Read(b)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
+// SignHMACSHA256 computes HMAC-SHA256 of the data and returns it hex encoded
+func SignHMACSHA256(key, data []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyHMACSHA256 verifies a hex encoded HMAC-SHA256 signature in constant time
+func VerifyHMACSHA256(key, data []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,51 @@
+package icrypto
+
+import (
+	"regexp"
+	"testing"
+)
+
+// RFC 4231 test case 2
+const (
+	hmacKey       = "Jefe"
+	hmacData      = "what do ya want for nothing?"
+	hmacSignature = "5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843"
+)
+
+func TestSignHMACSHA256(t *testing.T) {
+	if sig := SignHMACSHA256([]byte(hmacKey), []byte(hmacData)); sig != hmacSignature {
+		t.Fatalf("expected signature %s, got %s", hmacSignature, sig)
+	}
+}
+
+func TestVerifyHMACSHA256(t *testing.T) {
+	cases := []struct {
+		key, data, signature string
+		valid                bool
+	}{
+		{hmacKey, hmacData, hmacSignature, true},
+		{"other", hmacData, hmacSignature, false},
+		{hmacKey, hmacData + ".", hmacSignature, false},
+		{hmacKey, hmacData, "not hex", false},
+	}
+	for _, c := range cases {
+		if VerifyHMACSHA256([]byte(c.key), []byte(c.data), c.signature) != c.valid {
+			t.Errorf("expected %v for key %s data %s signature %s", c.valid, c.key, c.data, c.signature)
+		}
+	}
+}
+
+func TestGenUUID(t *testing.T) {
+	pattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		id := GenUUID()
+		if !pattern.MatchString(id) {
+			t.Fatalf("%s is not a version 4 UUID", id)
+		}
+		if seen[id] {
+			t.Fatalf("duplicated UUID %s after %d generations", id, i)
+		}
+		seen[id] = true
+	}
+}
@@ -0,0 +1,105 @@
+package lambda
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/prometheus/client_golang/prometheus"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// BacklogSource reports the backlog of a subscription such as the Pulsar admin API
+type BacklogSource interface {
+	SubscriptionBacklog(topic, subscription string) (int64, error)
+}
+
+var subscriptionBacklog = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Name: "pubsub_function_subscription_backlog",
+	Help: "The number of messages in the backlog of the function input topic subscriptions",
+}, []string{"function", "subscription"})
+
+func init() {
+	prometheus.MustRegister(subscriptionBacklog)
+}
+
+var (
+	// backlogs are the last collected subscription backlogs by function ID
+	backlogs     = make(map[string]map[string]int64)
+	backlogsLock sync.RWMutex
+)
+
+// Backlog returns the last collected backlog of the function subscriptions
+func Backlog(functionID string) map[string]int64 {
+	backlogsLock.RLock()
+	defer backlogsLock.RUnlock()
+	results := make(map[string]int64, len(backlogs[functionID]))
+	for sub, backlog := range backlogs[functionID] {
+		results[sub] = backlog
+	}
+	return results
+}
+
+// CollectBacklog updates the backlog gauges of the input topic subscription and the webhook subscriptions
+// of the functions. Gauges of functions no longer present are removed.
+func CollectBacklog(cfgs []*model.FunctionConfig, source BacklogSource) {
+	subscriptionBacklog.Reset()
+	collected := make(map[string]map[string]int64)
+	defer func() {
+		backlogsLock.Lock()
+		backlogs = collected
+		backlogsLock.Unlock()
+	}()
+	for _, cfg := range cfgs {
+		topic := cfg.InputTopic.TopicFullName
+		if topic == "" || cfg.FunctionStatus == model.Deleted {
+			continue
+		}
+		subs := []string{}
+		if cfg.InputTopic.Subscription != "" {
+			subs = append(subs, cfg.InputTopic.Subscription)
+		}
+		for _, wh := range model.ActiveWebhooks(cfg.Webhooks) {
+			if wh.Subscription != cfg.InputTopic.Subscription {
+				subs = append(subs, wh.Subscription)
+			}
+		}
+		for _, sub := range subs {
+			backlog, err := source.SubscriptionBacklog(topic, sub)
+			if err != nil {
+				log.Warnf("failed to collect function %s subscription %s backlog %v", cfg.ID, sub, err)
+				continue
+			}
+			subscriptionBacklog.WithLabelValues(cfg.ID, sub).Set(float64(backlog))
+			if collected[cfg.ID] == nil {
+				collected[cfg.ID] = make(map[string]int64)
+			}
+			collected[cfg.ID][sub] = backlog
+		}
+	}
+}
+
+// StartBacklogMonitor collects the backlog of all functions on every interval until the returned stop is called
+func StartBacklogMonitor(database db.Crud, source BacklogSource, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				cfgs, err := database.Load()
+				if err != nil {
+					log.Errorf("failed to load functions for backlog collection %v", err)
+					continue
+				}
+				CollectBacklog(cfgs, source)
+			}
+		}
+	}()
+	return func() { close(done) }
+}
@@ -0,0 +1,124 @@
+package lambda
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeBacklogSource reports the backlog by topic and subscription
+type fakeBacklogSource struct {
+	lock     sync.Mutex
+	backlogs map[string]int64
+	calls    int
+}
+
+func (s *fakeBacklogSource) SubscriptionBacklog(topic, subscription string) (int64, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.calls++
+	backlog, ok := s.backlogs[topic+"/"+subscription]
+	if !ok {
+		return 0, errors.New("subscription not found")
+	}
+	return backlog, nil
+}
+
+func backlogFunction(id, sub string, status model.Status, webhookSubs ...string) *model.FunctionConfig {
+	cfg := &model.FunctionConfig{
+		ID:             id,
+		FunctionStatus: status,
+		InputTopic:     model.FunctionTopic{TopicFullName: "persistent://t/ns/" + id, Subscription: sub},
+	}
+	for _, s := range webhookSubs {
+		cfg.Webhooks = append(cfg.Webhooks, model.WebhookConfig{Subscription: s, WebhookStatus: model.Activated})
+	}
+	return cfg
+}
+
+func TestCollectBacklog(t *testing.T) {
+	source := &fakeBacklogSource{backlogs: map[string]int64{
+		"persistent://t/ns/f1/sub":  5,
+		"persistent://t/ns/f1/wh":   7,
+		"persistent://t/ns/f2/sub":  0,
+		"persistent://t/ns/f3/sub":  9,
+		"persistent://t/ns/gone/s1": 3,
+	}}
+	cases := []struct {
+		name     string
+		cfg      *model.FunctionConfig
+		expected map[string]int64
+	}{
+		{"input and webhook subscriptions", backlogFunction("f1", "sub", model.Activated, "wh", "sub"), map[string]int64{"sub": 5, "wh": 7}},
+		{"empty backlog", backlogFunction("f2", "sub", model.Suspended), map[string]int64{"sub": 0}},
+		{"deleted function", backlogFunction("f3", "sub", model.Deleted), map[string]int64{}},
+		{"failed collection", backlogFunction("f4", "sub", model.Activated), map[string]int64{}},
+		{"no input topic", &model.FunctionConfig{ID: "f5"}, map[string]int64{}},
+	}
+	// the gauge of a function removed since the last collection is reset
+	CollectBacklog([]*model.FunctionConfig{backlogFunction("gone", "s1", model.Activated)}, source)
+	cfgs := []*model.FunctionConfig{}
+	for _, c := range cases {
+		cfgs = append(cfgs, c.cfg)
+	}
+	CollectBacklog(cfgs, source)
+
+	for _, c := range cases {
+		if backlog := Backlog(c.cfg.ID); !reflect.DeepEqual(backlog, c.expected) {
+			t.Errorf("%s: expected backlog %v, got %v", c.name, c.expected, backlog)
+		}
+		for sub, backlog := range c.expected {
+			if gauge := testutil.ToFloat64(subscriptionBacklog.WithLabelValues(c.cfg.ID, sub)); gauge != float64(backlog) {
+				t.Errorf("%s: expected gauge %d of %s, got %v", c.name, backlog, sub, gauge)
+			}
+		}
+	}
+	if backlog := Backlog("gone"); len(backlog) != 0 {
+		t.Errorf("expected the backlog of the removed function to be reset, got %v", backlog)
+	}
+	if n := testutil.CollectAndCount(subscriptionBacklog); n != 3 {
+		t.Errorf("expected 3 backlog gauges, got %d", n)
+	}
+}
+
+func TestStartBacklogMonitor(t *testing.T) {
+	database, err := db.NewInMemoryHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := backlogFunction("", "sub", model.Activated)
+	cfg.Tenant, cfg.Name = "t", "monitor"
+	cfg.InputTopic.TopicFullName = "persistent://t/ns/monitor"
+	id, err := database.Create(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	source := &fakeBacklogSource{backlogs: map[string]int64{"persistent://t/ns/monitor/sub": 11}}
+
+	stop := StartBacklogMonitor(database, source, 5*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for Backlog(id)["sub"] != 11 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	stop()
+	if backlog := Backlog(id); backlog["sub"] != 11 {
+		t.Fatalf("expected the monitor to collect the backlog, got %v", backlog)
+	}
+
+	// no collection after stop
+	source.lock.Lock()
+	calls := source.calls
+	source.lock.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	source.lock.Lock()
+	defer source.lock.Unlock()
+	if source.calls > calls+1 {
+		t.Errorf("expected no collection after stop, got %d more", source.calls-calls)
+	}
+}
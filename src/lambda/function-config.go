@@ -2,9 +2,15 @@ package lambda
 
 import (
 	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strings"
 
+	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
 )
 
 const (
@@ -18,19 +24,187 @@ const (
 	CronTrigger = "cron"
 )
 
-// ValidateFunctionConfig validates function config
-func ValidateFunctionConfig(cfg *model.FunctionTopic) error {
+// supported language packs
+const (
+	GoPack     = "go"
+	PythonPack = "python"
+	JavaPack   = "java"
+	NodejsPack = "nodejs"
+)
+
+// SupportedLanguagePacks is the list of supported language packs
+var SupportedLanguagePacks = []string{GoPack, PythonPack, JavaPack, NodejsPack}
+
+// languagePackAliases maps accepted language pack names to the supported language pack
+var languagePackAliases = map[string]string{
+	"js":         NodejsPack,
+	"javascript": NodejsPack,
+	"node":       NodejsPack,
+	"golang":     GoPack,
+}
+
+// GetLanguagePack validates and normalizes the language pack.
+// An empty language pack means a webhook only function.
+func GetLanguagePack(pack string) (string, error) {
+	p := strings.ToLower(strings.TrimSpace(pack))
+	if alias, ok := languagePackAliases[p]; ok {
+		p = alias
+	}
+	if p == "" || util.StrContains(SupportedLanguagePacks, p) {
+		return p, nil
+	}
+	return "", fmt.Errorf("unsupported language pack %s, supported language packs are %s",
+		pack, strings.Join(SupportedLanguagePacks, ", "))
+}
+
+// ValidateFunctionConfig validates function config, all the problems are reported in a model.ValidationError
+func ValidateFunctionConfig(cfg *model.FunctionConfig) error {
+	verr := &model.ValidationError{}
+	pack, err := GetLanguagePack(cfg.LanguagePack)
+	if err != nil {
+		verr.Add("languagePack", err)
+	}
+	if pack != "" {
+		verr.Add("functionFilePath", ValidateFunctionFilePath(cfg.FunctionFilePath))
+	}
+	verr.Add("userConfig", ValidateUserConfig(cfg))
+	if cfg.TriggerType == PulsarTrigger {
+		verr.Add("inputTopics", ValidateFunctionTopic(&cfg.InputTopic))
+		verr.Add("parallelism", ValidateParallelism(cfg.Parallelism, cfg.InputTopic.SubscriptionType))
+		verr.Add("outputTopics.topicFullName", ValidateTopicLoop(&cfg.InputTopic, &cfg.OutputTopic))
+	}
+	if cfg.MaxConcurrentDeliveries < 0 {
+		verr.Addf("maxConcurrentDeliveries", "maximum concurrent deliveries must not be negative")
+	}
+	verr.Add("outputKeyMode", model.ValidateOutputKeyMode(cfg.OutputKeyMode))
+	if _, err := model.GetSchemaType(cfg.OutputTopic.SchemaType, cfg.OutputTopic.SchemaDefinition); err != nil {
+		verr.Add("outputTopics.schemaType", err)
+	}
+	verr.Add("deduplicationEnabled", ValidateDeduplication(cfg))
+	verr.Add("messageRoutingMode", ValidateMessageRoutingMode(cfg))
+	verr.Add("", model.ValidateWebhookConfig(cfg.Webhooks))
+	return verr.Err()
+}
+
+// ValidateTopicLoop rejects the output topic consumed by the function itself, which would loop forever.
+// The output topic without a Pulsar URL is on the same cluster as the input topic.
+func ValidateTopicLoop(input, output *model.FunctionTopic) error {
+	out := output.TopicFullName
+	if out == "" || (output.PulsarURL != "" && output.PulsarURL != input.PulsarURL) {
+		return nil
+	}
+	if out == input.TopicFullName || util.StrContains(input.Topics, out) {
+		return fmt.Errorf("output topic %s must not be the input topic of the same function", out)
+	}
+	if input.TopicsPattern != "" {
+		if re, err := regexp.Compile(input.TopicsPattern); err == nil && re.MatchString(out) {
+			return fmt.Errorf("output topic %s must not match the input topics pattern %s", out, input.TopicsPattern)
+		}
+	}
+	return nil
+}
+
+// ValidateMessageRoutingMode validates the output message routing mode, the custom partition routing requires an output key
+func ValidateMessageRoutingMode(cfg *model.FunctionConfig) error {
+	mode, err := model.GetMessageRoutingMode(cfg.MessageRoutingMode)
+	if err != nil {
+		return err
+	}
+	if mode == model.CustomPartition && (cfg.OutputKeyMode == "" || cfg.OutputKeyMode == model.OutputKeyNone) {
+		return fmt.Errorf("custom partition routing requires an output key mode")
+	}
+	return nil
+}
+
+// ValidateDeduplication validates the output deduplication. The sequence ids must increase per producer
+// so the input messages of a partition must be consumed in order by a single consumer.
+func ValidateDeduplication(cfg *model.FunctionConfig) error {
+	if _, err := model.GetSequenceIDStrategy(cfg.SequenceIDStrategy); err != nil {
+		return err
+	}
+	if !cfg.DeduplicationEnabled {
+		return nil
+	}
+	if cfg.OutputTopic.TopicFullName == "" {
+		return fmt.Errorf("deduplication requires an output topic")
+	}
+	subType, err := model.GetSubscriptionType(cfg.InputTopic.SubscriptionType)
+	if err != nil {
+		return err
+	}
+	if subType != pulsar.Exclusive && subType != pulsar.Failover {
+		return fmt.Errorf("deduplication requires an exclusive or failover input subscription")
+	}
+	return nil
+}
+
+// supported URL schemes of function file path
+var functionFileSchemes = []string{"http", "https", "pulsar"}
+
+// ValidateFunctionFilePath validates the function file path is either a URL or an absolute local path.
+// The presence of a local file is only checked if FunctionFileCheck is enabled,
+// since a control plane only deployment does not have the function files co-located.
+func ValidateFunctionFilePath(path string) error {
+	if strings.TrimSpace(path) == "" {
+		return fmt.Errorf("function file path is missing")
+	}
+	if u, err := url.Parse(path); err == nil && u.Scheme != "" {
+		if !util.StrContains(functionFileSchemes, strings.ToLower(u.Scheme)) || u.Host == "" {
+			return fmt.Errorf("function file path %s must be a %s URL", path, strings.Join(functionFileSchemes, ", "))
+		}
+		return nil
+	}
+	if !filepath.IsAbs(path) || strings.ContainsRune(path, 0) {
+		return fmt.Errorf("function file path %s must be an absolute path", path)
+	}
+	if util.StringToBool(util.GetConfig().FunctionFileCheck) {
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			return fmt.Errorf("function file %s does not exist", path)
+		}
+	}
+	return nil
+}
+
+// ValidateFunctionTopic validates function topic config, all the problems are reported in a model.ValidationError
+func ValidateFunctionTopic(cfg *model.FunctionTopic) error {
+	verr := &model.ValidationError{}
 	if !model.IsURL(cfg.PulsarURL) {
-		return fmt.Errorf("not a URL %s", cfg.PulsarURL)
+		verr.Addf("pulsarURL", "not a URL %s", cfg.PulsarURL)
+	}
+	exclusive := 0
+	for _, set := range []bool{cfg.TopicFullName != "", cfg.TopicsPattern != "", len(cfg.Topics) > 0} {
+		if set {
+			exclusive++
+		}
+	}
+	if exclusive > 1 {
+		verr.Addf("topicFullName", "topic full name, topics pattern, and topics are mutually exclusive")
+	}
+	if cfg.TopicsPattern != "" {
+		if _, err := regexp.Compile(cfg.TopicsPattern); err != nil {
+			verr.Addf("topicsPattern", "invalid topics pattern %s error %v", cfg.TopicsPattern, err)
+		}
+	}
+	for i, topic := range cfg.Topics {
+		verr.Add(fmt.Sprintf("topics[%d]", i), model.ValidateTopicName(topic))
 	}
 	if strings.TrimSpace(cfg.Subscription) == "" {
-		return fmt.Errorf("subscription name is missing")
+		verr.Addf("subscription", "subscription name is missing")
 	}
 	if _, err := model.GetSubscriptionType(cfg.SubscriptionType); err != nil {
-		return err
+		verr.Add("subscriptionType", err)
 	}
 	if _, err := model.GetInitialPosition(cfg.InitialPosition); err != nil {
-		return err
+		verr.Add("initialPosition", err)
 	}
-	return nil
+	if err := model.ValidateKeySharedPolicy(cfg.KeySharedPolicy); err != nil {
+		verr.Add("keySharedPolicy", err)
+	}
+	if _, err := model.GetAckMode(cfg.AckMode, cfg.SubscriptionType); err != nil {
+		verr.Add("ackMode", err)
+	}
+	if _, err := model.GetSchemaType(cfg.SchemaType, cfg.SchemaDefinition); err != nil {
+		verr.Add("schemaType", err)
+	}
+	return verr.Err()
 }
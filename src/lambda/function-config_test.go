@@ -0,0 +1,225 @@
+package lambda
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func TestGetLanguagePack(t *testing.T) {
+	cases := []struct {
+		pack    string
+		want    string
+		invalid bool
+	}{
+		{"", "", false},
+		{"JavaScript", NodejsPack, false},
+		{" node ", NodejsPack, false},
+		{"golang", GoPack, false},
+		{PythonPack, PythonPack, false},
+		{JavaPack, JavaPack, false},
+		{"cobol", "", true},
+	}
+	for _, c := range cases {
+		pack, err := GetLanguagePack(c.pack)
+		if c.invalid != (err != nil) || pack != c.want {
+			t.Errorf("%q: expected %q, got %q error %v", c.pack, c.want, pack, err)
+		}
+	}
+}
+
+func TestValidateFunctionFilePath(t *testing.T) {
+	file, err := ioutil.TempFile("", "function-*.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+	defer func(check string) { util.Config.FunctionFileCheck = check }(util.Config.FunctionFileCheck)
+
+	cases := []struct {
+		path      string
+		fileCheck string
+		valid     bool
+	}{
+		{"", "", false},
+		{"https://example.com/f.js", "", true},
+		{"ftp://example.com/f.js", "", false},
+		{"https:///f.js", "", false},
+		{"relative/f.js", "", false},
+		{"/pulsar/functions/missing.js", "", true},
+		{"/pulsar/functions/missing.js", "true", false},
+		{file.Name(), "true", true},
+		{os.TempDir(), "true", false},
+	}
+	for _, c := range cases {
+		util.Config.FunctionFileCheck = c.fileCheck
+		if err := ValidateFunctionFilePath(c.path); c.valid != (err == nil) {
+			t.Errorf("%q with file check %q: unexpected error %v", c.path, c.fileCheck, err)
+		}
+	}
+}
+
+// validTopic is a valid input topic subscribed by a topics pattern, a list of topics, or a topic full name
+func validTopic(topicFullName, pattern string, topics ...string) *model.FunctionTopic {
+	return &model.FunctionTopic{
+		PulsarURL:     "pulsar://localhost:6650",
+		TopicFullName: topicFullName,
+		TopicsPattern: pattern,
+		Topics:        topics,
+		Subscription:  "sub",
+	}
+}
+
+// fieldsOf returns the fields of the validation problems
+func fieldsOf(err error) []string {
+	fields := []string{}
+	if verr, ok := err.(*model.ValidationError); ok {
+		for _, fe := range verr.Errors {
+			fields = append(fields, fe.Field)
+		}
+	}
+	return fields
+}
+
+func TestValidateTopicsPattern(t *testing.T) {
+	cases := []struct {
+		name   string
+		topic  *model.FunctionTopic
+		fields []string
+	}{
+		{"pattern", validTopic("", "persistent://t/ns/events-.*"), []string{}},
+		{"invalid pattern", validTopic("", "persistent://t/ns/events-(.*"), []string{"topicsPattern"}},
+		{"pattern and topic full name", validTopic("persistent://t/ns/a", "persistent://t/ns/events-.*"), []string{"topicFullName"}},
+	}
+	for _, c := range cases {
+		if fields := fieldsOf(ValidateFunctionTopic(c.topic)); strings.Join(fields, ",") != strings.Join(c.fields, ",") {
+			t.Errorf("%s: expected problems of %v, got %v", c.name, c.fields, fields)
+		}
+	}
+}
+
+func TestValidateTopicLoopPattern(t *testing.T) {
+	input := validTopic("", "persistent://t/ns/events-.*")
+	cases := []struct {
+		output string
+		loop   bool
+	}{
+		{"persistent://t/ns/events-out", true},
+		{"persistent://t/ns/results", false},
+	}
+	for _, c := range cases {
+		if err := ValidateTopicLoop(input, &model.FunctionTopic{TopicFullName: c.output}); (err != nil) != c.loop {
+			t.Errorf("%s: expected loop %v, got %v", c.output, c.loop, err)
+		}
+	}
+}
+
+func TestValidateTopicsList(t *testing.T) {
+	cases := []struct {
+		name   string
+		topic  *model.FunctionTopic
+		fields []string
+	}{
+		{"list", validTopic("", "", "persistent://t/ns/a", "non-persistent://t/ns/b"), []string{}},
+		{"malformed topic", validTopic("", "", "persistent://t/ns/a", "t/ns/b"), []string{"topics[1]"}},
+		{"missing namespace", validTopic("", "", "persistent://t/a"), []string{"topics[0]"}},
+		{"list and topic full name", validTopic("persistent://t/ns/a", "", "persistent://t/ns/b"), []string{"topicFullName"}},
+		{"list and pattern", validTopic("", "persistent://t/ns/.*", "persistent://t/ns/b"), []string{"topicFullName"}},
+	}
+	for _, c := range cases {
+		if fields := fieldsOf(ValidateFunctionTopic(c.topic)); strings.Join(fields, ",") != strings.Join(c.fields, ",") {
+			t.Errorf("%s: expected problems of %v, got %v", c.name, c.fields, fields)
+		}
+	}
+
+	input := validTopic("", "", "persistent://t/ns/a", "persistent://t/ns/b")
+	if err := ValidateTopicLoop(input, &model.FunctionTopic{TopicFullName: "persistent://t/ns/b"}); err == nil {
+		t.Error("an output topic in the input topics must be rejected")
+	}
+}
+
+func TestValidateFunctionConfig(t *testing.T) {
+	valid := func() *model.FunctionConfig {
+		return &model.FunctionConfig{
+			TriggerType: PulsarTrigger,
+			Parallelism: 1,
+			InputTopic:  *validTopic("persistent://t/ns/in", ""),
+			OutputTopic: model.FunctionTopic{TopicFullName: "persistent://t/ns/out"},
+		}
+	}
+	cases := []struct {
+		name   string
+		update func(cfg *model.FunctionConfig)
+		fields []string
+	}{
+		{"valid", func(cfg *model.FunctionConfig) {}, []string{}},
+		{"three problems", func(cfg *model.FunctionConfig) {
+			cfg.LanguagePack = "cobol"
+			cfg.InputTopic.Subscription = " "
+			cfg.MaxConcurrentDeliveries = -1
+		}, []string{"languagePack", "inputTopics.subscription", "maxConcurrentDeliveries"}},
+		{"nested topic and webhook problems", func(cfg *model.FunctionConfig) {
+			cfg.InputTopic.PulsarURL = "localhost"
+			cfg.Parallelism = 0
+			cfg.Webhooks = []model.WebhookConfig{{URL: "not a url", Subscription: "sub"}}
+		}, []string{"inputTopics.pulsarURL", "parallelism", "webhooks[0].url"}},
+		{"topic loop", func(cfg *model.FunctionConfig) {
+			cfg.OutputTopic.TopicFullName = "persistent://t/ns/in"
+		}, []string{"outputTopics.topicFullName"}},
+	}
+	for _, c := range cases {
+		cfg := valid()
+		c.update(cfg)
+		if fields := fieldsOf(ValidateFunctionConfig(cfg)); strings.Join(fields, ",") != strings.Join(c.fields, ",") {
+			t.Errorf("%s: expected problems of %v, got %v", c.name, c.fields, fields)
+		}
+	}
+}
+
+func TestValidateTopicLoop(t *testing.T) {
+	input := validTopic("persistent://t/ns/in", "")
+	cases := []struct {
+		name   string
+		output model.FunctionTopic
+		loop   bool
+	}{
+		{"identical topic", model.FunctionTopic{TopicFullName: "persistent://t/ns/in"}, true},
+		{"identical topic on the same cluster", model.FunctionTopic{TopicFullName: "persistent://t/ns/in", PulsarURL: input.PulsarURL}, true},
+		{"identical topic on another cluster", model.FunctionTopic{TopicFullName: "persistent://t/ns/in", PulsarURL: "pulsar://other:6650"}, false},
+		{"distinct topic", model.FunctionTopic{TopicFullName: "persistent://t/ns/out"}, false},
+		{"no output topic", model.FunctionTopic{}, false},
+	}
+	for _, c := range cases {
+		if err := ValidateTopicLoop(input, &c.output); (err != nil) != c.loop {
+			t.Errorf("%s: expected loop %v, got %v", c.name, c.loop, err)
+		}
+	}
+}
+
+func TestValidateMessageRoutingMode(t *testing.T) {
+	cases := []struct {
+		mode    string
+		keyMode string
+		valid   bool
+	}{
+		{"", "", true},
+		{"roundrobin", model.OutputKeyNone, true},
+		{"singlepartition", "", true},
+		{"custompartition", model.OutputKeyInherit, true},
+		{"custompartition", "customer", true},
+		{"custompartition", "", false},
+		{"custompartition", model.OutputKeyNone, false},
+		{"hash", model.OutputKeyInherit, false},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{MessageRoutingMode: c.mode, OutputKeyMode: c.keyMode}
+		if err := ValidateMessageRoutingMode(cfg); (err == nil) != c.valid {
+			t.Errorf("%q with output key mode %q: expected valid %v, got %v", c.mode, c.keyMode, c.valid, err)
+		}
+	}
+}
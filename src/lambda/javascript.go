@@ -8,7 +8,6 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/kafkaesque-io/pubsub-function/src/model"
@@ -39,8 +38,12 @@ var port = 2999
 // CreateFnInstance creates function instance
 func CreateFnInstance(cfg model.FunctionConfig) (string, error) {
 	// if "linux" != runtime.GOOS {
-	switch strings.ToLower(cfg.LanguagePack) {
-	case "js", "javascript", "node", "nodejs":
+	pack, err := GetLanguagePack(cfg.LanguagePack)
+	if err != nil {
+		return "", err
+	}
+	switch pack {
+	case NodejsPack:
 		return StartNodeInstance(cfg)
 	default:
 		return "", fmt.Errorf("unsupported function language pack %s", cfg.LanguagePack)
@@ -54,8 +57,15 @@ func StartNodeInstance(cfg model.FunctionConfig) (url string, err error) {
 		return "", err
 	}
 
+	env, err := FunctionEnv(&cfg)
+	if err != nil {
+		return "", err
+	}
+
 	log.Infof("file path %s port %d", cfg.FunctionFilePath, port)
 	cmd := exec.Command("node", "../function-pack/js/loader.js", strconv.Itoa(port), cfg.FunctionFilePath)
+	// the user config overrides the server environment inherited by the instance
+	cmd.Env = append(os.Environ(), env...)
 	if err := cmd.Start(); err != nil {
 		return "", err
 	}
@@ -0,0 +1,103 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// NewOutputMessage builds the message produced to the function output topic from the input message
+// The input message properties are copied to the output message if the property propagation is enabled.
+// The sequence id is derived from the input message if the deduplication is enabled.
+func NewOutputMessage(cfg *model.FunctionConfig, input pulsar.Message, payload []byte) (*pulsar.ProducerMessage, error) {
+	msg := &pulsar.ProducerMessage{
+		Payload: payload,
+		Key:     model.OutputKey(cfg.OutputKeyMode, input.Key(), input.Properties()),
+	}
+	if cfg.PropagateProperties && len(input.Properties()) > 0 {
+		msg.Properties = make(map[string]string, len(input.Properties()))
+		for k, v := range input.Properties() {
+			msg.Properties[k] = v
+		}
+	}
+	if cfg.DeduplicationEnabled {
+		seqID, err := outputSequenceID(cfg, input)
+		if err != nil {
+			return nil, err
+		}
+		msg.SequenceID = &seqID
+	}
+	return msg, nil
+}
+
+// outputSequenceID derives the output message sequence id from the input message by the sequence id strategy
+func outputSequenceID(cfg *model.FunctionConfig, input pulsar.Message) (int64, error) {
+	strategy, err := model.GetSequenceIDStrategy(cfg.SequenceIDStrategy)
+	if err != nil {
+		return 0, err
+	}
+	if strategy == model.SequenceIDPublishTime {
+		return input.PublishTime().UnixNano() / 1e6, nil
+	}
+	id, err := pulsardriver.ParseMessageID(input.ID())
+	if err != nil {
+		return 0, err
+	}
+	return id.Offset(), nil
+}
+
+// outputProducerName is unique per input partition since the sequence ids only increase within a partition
+func outputProducerName(cfg *model.FunctionConfig, input pulsar.Message) (string, error) {
+	id, err := pulsardriver.ParseMessageID(input.ID())
+	if err != nil {
+		return "", err
+	}
+	if id.Partition < 0 {
+		return cfg.ID, nil
+	}
+	return fmt.Sprintf("%s-%d", cfg.ID, id.Partition), nil
+}
+
+// SendOutput produces the function result of the input message to the output topic. With the deduplication
+// enabled, a reprocessed input message carries the same sequence id so the broker drops the duplicate.
+func SendOutput(ctx context.Context, cfg *model.FunctionConfig, input pulsar.Message, payload []byte) error {
+	out := cfg.OutputTopic
+	msg, err := NewOutputMessage(cfg, input, payload)
+	if err != nil {
+		return err
+	}
+	routing, err := model.GetMessageRoutingMode(cfg.MessageRoutingMode)
+	if err != nil {
+		return err
+	}
+	name := ""
+	if cfg.DeduplicationEnabled {
+		if name, err = outputProducerName(cfg, input); err != nil {
+			return err
+		}
+	}
+	producer, err := pulsardriver.GetOutputProducer(out.PulsarURL, out.Token, out.TopicFullName, name, routing)
+	if err != nil {
+		return err
+	}
+	_, err = producer.Send(ctx, msg)
+	return err
+}
+
+// OutputSender produces the function replies to the output topic, it is nil if the function has no output topic
+func OutputSender(cfg *model.FunctionConfig) func(input pulsar.Message, reply []byte) error {
+	if cfg.OutputTopic.TopicFullName == "" {
+		return nil
+	}
+	timeout := time.Duration(util.ConfigInt(util.GetConfig().OutputSendTimeoutMs, 30000)) * time.Millisecond
+	return func(input pulsar.Message, reply []byte) error {
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		return SendOutput(ctx, cfg, input, reply)
+	}
+}
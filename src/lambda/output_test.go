@@ -0,0 +1,196 @@
+package lambda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// fakeMessage is an input message with a key, properties and an id
+type fakeMessage struct {
+	pulsar.Message
+	key         string
+	properties  map[string]string
+	id          pulsar.MessageID
+	publishTime time.Time
+}
+
+func (m *fakeMessage) Key() string                   { return m.key }
+func (m *fakeMessage) Properties() map[string]string { return m.properties }
+func (m *fakeMessage) ID() pulsar.MessageID          { return m.id }
+func (m *fakeMessage) PublishTime() time.Time        { return m.publishTime }
+
+func TestOutputKeyMode(t *testing.T) {
+	input := &fakeMessage{key: "input-key", properties: map[string]string{"customer": "c1"}}
+	cases := []struct {
+		mode  string
+		valid bool
+		key   string
+	}{
+		{"", true, ""},
+		{model.OutputKeyNone, true, ""},
+		{model.OutputKeyInherit, true, "input-key"},
+		{"customer", true, "c1"},
+		{"missing", true, ""},
+		{"two words", false, ""},
+		{" customer", false, ""},
+	}
+	for _, c := range cases {
+		if err := model.ValidateOutputKeyMode(c.mode); (err == nil) != c.valid {
+			t.Errorf("%q: expected valid %v, got %v", c.mode, c.valid, err)
+		}
+		if !c.valid {
+			continue
+		}
+		msg, err := NewOutputMessage(&model.FunctionConfig{OutputKeyMode: c.mode}, input, []byte("result"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if msg.Key != c.key || string(msg.Payload) != "result" {
+			t.Errorf("%q: expected key %q, got %q", c.mode, c.key, msg.Key)
+		}
+	}
+}
+
+func TestOutputPropagatesProperties(t *testing.T) {
+	input := &fakeMessage{properties: map[string]string{"trace": "t1"}}
+	cases := []struct {
+		propagate  bool
+		properties int
+	}{
+		{true, 1},
+		{false, 0},
+	}
+	for _, c := range cases {
+		msg, err := NewOutputMessage(&model.FunctionConfig{PropagateProperties: c.propagate}, input, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msg.Properties) != c.properties || (c.propagate && msg.Properties["trace"] != "t1") {
+			t.Errorf("propagate %v: unexpected properties %v", c.propagate, msg.Properties)
+		}
+	}
+	// the output properties are a copy of the input properties
+	msg, _ := NewOutputMessage(&model.FunctionConfig{PropagateProperties: true}, input, nil)
+	msg.Properties["trace"] = "changed"
+	if input.properties["trace"] != "t1" {
+		t.Error("the input properties must not be shared with the output message")
+	}
+}
+
+// inputMessage is an input message at the ledger and entry of the partition, published at the time
+func inputMessage(t *testing.T, ledger, entry, partition byte, publishTime time.Time) *fakeMessage {
+	data := []byte{0x08, ledger, 0x10, entry}
+	if partition > 0 {
+		data = append(data, 0x18, partition)
+	}
+	id, err := pulsar.DeserializeMessageID(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &fakeMessage{id: id, publishTime: publishTime}
+}
+
+func TestOutputDeduplication(t *testing.T) {
+	now := time.Now()
+	inputs := []*fakeMessage{
+		inputMessage(t, 5, 1, 0, now),
+		inputMessage(t, 5, 2, 0, now.Add(time.Millisecond)),
+		// the reprocessed input after a crash
+		inputMessage(t, 5, 2, 0, now.Add(time.Millisecond)),
+		inputMessage(t, 6, 0, 0, now.Add(2*time.Millisecond)),
+		inputMessage(t, 5, 1, 0, now),
+		inputMessage(t, 5, 9, 1, now),
+	}
+	cases := []struct {
+		strategy string
+		sent     int
+	}{
+		{"", 4},
+		{model.SequenceIDMessageID, 4},
+		{"PublishTime", 4},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{ID: "t1f1", DeduplicationEnabled: true, SequenceIDStrategy: c.strategy}
+		// the broker drops a message whose sequence id is not above the last one of the producer
+		last := map[string]int64{}
+		sent := 0
+		for _, input := range inputs {
+			msg, err := NewOutputMessage(cfg, input, []byte("result"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			name, err := outputProducerName(cfg, input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if msg.SequenceID == nil {
+				t.Fatalf("%q: expected a sequence id", c.strategy)
+			}
+			if seqID, ok := last[name]; !ok || *msg.SequenceID > seqID {
+				last[name] = *msg.SequenceID
+				sent++
+			}
+		}
+		if sent != c.sent || len(last) != 2 {
+			t.Errorf("%q: expected %d messages sent by 2 producers, got %d by %v", c.strategy, c.sent, sent, last)
+		}
+	}
+
+	msg, err := NewOutputMessage(&model.FunctionConfig{ID: "t1f1"}, inputs[0], nil)
+	if err != nil || msg.SequenceID != nil {
+		t.Errorf("expected no sequence id without the deduplication, got %v %v", msg, err)
+	}
+	if _, err := NewOutputMessage(&model.FunctionConfig{DeduplicationEnabled: true, SequenceIDStrategy: "random"}, inputs[0], nil); err == nil {
+		t.Error("expected an unsupported sequence id strategy error")
+	}
+}
+
+func TestValidateDeduplication(t *testing.T) {
+	cases := []struct {
+		name     string
+		enabled  bool
+		strategy string
+		output   string
+		subType  string
+		valid    bool
+	}{
+		{"disabled", false, "", "", "shared", true},
+		{"exclusive", true, "", "persistent://t/ns/out", "exclusive", true},
+		{"failover publish time", true, "publishtime", "persistent://t/ns/out", "failover", true},
+		{"shared", true, "", "persistent://t/ns/out", "shared", false},
+		{"key shared", true, "", "persistent://t/ns/out", "keyshared", false},
+		{"no output topic", true, "", "", "exclusive", false},
+		{"unsupported strategy", false, "random", "", "exclusive", false},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{
+			DeduplicationEnabled: c.enabled,
+			SequenceIDStrategy:   c.strategy,
+			InputTopic:           model.FunctionTopic{SubscriptionType: c.subType},
+			OutputTopic:          model.FunctionTopic{TopicFullName: c.output},
+		}
+		if err := ValidateDeduplication(cfg); (err == nil) != c.valid {
+			t.Errorf("%s: expected valid %v, got %v", c.name, c.valid, err)
+		}
+	}
+}
+
+func TestOutputSender(t *testing.T) {
+	cases := []struct {
+		name   string
+		output model.FunctionTopic
+		sender bool
+	}{
+		{"output topic", model.FunctionTopic{TopicFullName: "persistent://public/default/output", PulsarURL: "pulsar://localhost:6650"}, true},
+		{"no output topic", model.FunctionTopic{}, false},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{ID: "t1f1", OutputTopic: c.output}
+		if sender := OutputSender(cfg); (sender != nil) != c.sender {
+			t.Errorf("%s: expected an output sender %v", c.name, c.sender)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package lambda
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/webhook"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RunnerFactory creates the runner of the function config
+type RunnerFactory func(cfg model.FunctionConfig) (*FunctionRunner, error)
+
+// Reconciler keeps the function runners in line with the stored function configs.
+// Activated functions are started, suspended functions are paused with their consumers connected,
+// and the runners of the functions deactivated or deleted are stopped.
+type Reconciler struct {
+	database  db.Crud
+	newRunner RunnerFactory
+	runners   map[string]*FunctionRunner
+	// triggered requests a reconciliation from the reconciler loop
+	triggered chan struct{}
+	sync.Mutex
+}
+
+// NewReconciler creates a reconciler of the functions in the database
+func NewReconciler(database db.Crud, factory RunnerFactory) *Reconciler {
+	return &Reconciler{
+		database:  database,
+		newRunner: factory,
+		runners:   make(map[string]*FunctionRunner),
+		triggered: make(chan struct{}, 1),
+	}
+}
+
+// Trigger requests a reconciliation without waiting for the next interval, such as on a database change.
+// It does not block and the triggers are coalesced while a reconciliation is pending.
+func (rc *Reconciler) Trigger() {
+	select {
+	case rc.triggered <- struct{}{}:
+	default:
+	}
+}
+
+// Reconcile loads the function configs and starts, pauses, or stops the runners accordingly.
+// It is idempotent so it can be called on every database change or periodically.
+func (rc *Reconciler) Reconcile() error {
+	cfgs, err := rc.database.Load()
+	if err != nil {
+		return err
+	}
+	rc.Lock()
+	defer rc.Unlock()
+
+	desired := make(map[string]model.FunctionConfig)
+	for _, cfg := range cfgs {
+		if cfg.TriggerType == PulsarTrigger &&
+			(cfg.FunctionStatus == model.Activated || cfg.FunctionStatus == model.Suspended) {
+			desired[cfg.ID] = *cfg
+		}
+	}
+
+	for id, r := range rc.runners {
+		cfg, ok := desired[id]
+		// the runner is recreated on any config change so the consumers and the webhooks are up to date
+		if !ok || configChanged(r.Config, cfg) {
+			r.Stop()
+			delete(rc.runners, id)
+			log.Infof("reconciler stopped function %s", id)
+		}
+	}
+	for id, cfg := range desired {
+		r, ok := rc.runners[id]
+		if !ok {
+			if r, err = rc.newRunner(cfg); err != nil {
+				log.Errorf("reconciler failed to create function %s runner %v", id, err)
+				continue
+			}
+			if err = r.Start(); err != nil {
+				r.Stop()
+				log.Errorf("reconciler failed to start function %s %v", id, err)
+				continue
+			}
+			rc.runners[id] = r
+			log.Infof("reconciler started function %s", id)
+		}
+		r.SetStatus(cfg.FunctionStatus)
+	}
+	return nil
+}
+
+// configChanged compares the configs except the status applied by pausing or resuming the runner
+// and the update time changed along with the status
+func configChanged(running, desired model.FunctionConfig) bool {
+	running.FunctionStatus, running.UpdatedAt = desired.FunctionStatus, desired.UpdatedAt
+	return !reflect.DeepEqual(running, desired)
+}
+
+// Running returns the IDs of the functions delivering messages, the paused functions are excluded
+func (rc *Reconciler) Running() []string {
+	rc.Lock()
+	defer rc.Unlock()
+	ids := []string{}
+	for id, r := range rc.runners {
+		if !r.Paused() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Runner returns the runner of the function if it is started
+func (rc *Reconciler) Runner(id string) (*FunctionRunner, bool) {
+	rc.Lock()
+	defer rc.Unlock()
+	r, ok := rc.runners[id]
+	return r, ok
+}
+
+// StopAll stops all the runners
+func (rc *Reconciler) StopAll() {
+	rc.Lock()
+	defer rc.Unlock()
+	for id, r := range rc.runners {
+		r.Stop()
+		delete(rc.runners, id)
+	}
+}
+
+// StartReconciler reconciles the functions on every interval and on every trigger until the returned stop
+// is called, the runners are stopped on stop
+func StartReconciler(rc *Reconciler, interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	ticker := time.NewTicker(interval)
+	go func() {
+		defer close(stopped)
+		defer ticker.Stop()
+		for {
+			if err := rc.Reconcile(); err != nil {
+				log.Errorf("failed to load functions for reconciliation %v", err)
+			}
+			select {
+			case <-done:
+				rc.StopAll()
+				return
+			case <-ticker.C:
+			case <-rc.triggered:
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// RunFunctions starts the reconciler of the functions in the database delivering to the function webhooks.
+// The functions are reconciled on every database cache change and on every interval.
+func RunFunctions(database db.Db, interval time.Duration) (rc *Reconciler, stop func()) {
+	return runFunctions(database, NewWebhookRunnerFactory(database), interval)
+}
+
+func runFunctions(database db.Db, factory RunnerFactory, interval time.Duration) (*Reconciler, func()) {
+	rc := NewReconciler(database, factory)
+	database.OnCacheChange(rc.Trigger)
+	return rc, StartReconciler(rc, interval)
+}
+
+// NewWebhookRunnerFactory creates the runners delivering the function input messages to the function webhooks,
+// the webhook status transitions are persisted to the database and the webhook replies are produced to the
+// function output topic
+func NewWebhookRunnerFactory(database db.Crud) RunnerFactory {
+	return func(cfg model.FunctionConfig) (*FunctionRunner, error) {
+		delivery := webhook.NewFunctionDelivery(&cfg, webhook.NewDbStatusReporter(database, cfg.ID))
+		if output := OutputSender(&cfg); output != nil {
+			delivery.SetOutput(output)
+		}
+		r, err := NewFunctionRunner(cfg, delivery.Handle)
+		if err != nil {
+			delivery.Close()
+			return nil, err
+		}
+		r.OnStop(delivery.Close)
+		return r, nil
+	}
+}
@@ -0,0 +1,218 @@
+package lambda
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// fakeRunners creates the runners on fake consumers
+type fakeRunners struct {
+	consumers fakeConsumers
+	created   int
+}
+
+func (f *fakeRunners) factory(cfg model.FunctionConfig) (*FunctionRunner, error) {
+	f.created++
+	return NewFunctionRunnerWithFactory(cfg, func(pulsar.Consumer, pulsar.Message) {}, f.consumers.factory)
+}
+
+// reconciledFunction is a pulsar triggered function of the status
+func reconciledFunction(name string, status model.Status) *model.FunctionConfig {
+	return &model.FunctionConfig{
+		Tenant:         "t1",
+		Name:           name,
+		TriggerType:    PulsarTrigger,
+		Parallelism:    2,
+		FunctionStatus: status,
+		InputTopic: model.FunctionTopic{
+			TopicFullName:    "persistent://public/default/input",
+			PulsarURL:        "pulsar://localhost:6650",
+			Subscription:     name,
+			SubscriptionType: "shared",
+		},
+	}
+}
+
+// eventually waits for the condition to be met
+func eventually(t *testing.T, what string, condition func() bool) {
+	deadline := time.Now().Add(2 * time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestReconcilerLifecycle(t *testing.T) {
+	database, _ := db.NewInMemoryHandler()
+	runners := &fakeRunners{}
+	// the interval is long so the reconciliations are triggered by the database changes
+	rc, stop := runFunctions(database, runners.factory, time.Hour)
+	defer stop()
+
+	setStatus := func(status model.Status) func() error {
+		return func() error {
+			cfg, err := database.GetByKey("t1f1")
+			if err != nil {
+				return err
+			}
+			cfg.FunctionStatus = status
+			_, err = database.Update(cfg)
+			return err
+		}
+	}
+	cases := []struct {
+		step      string
+		change    func() error
+		running   string
+		started   bool
+		paused    bool
+		consumers int
+	}{
+		{"create", func() error { _, err := database.Create(reconciledFunction("f1", model.Deactivated)); return err }, "", false, false, 0},
+		{"activate", setStatus(model.Activated), "t1f1", true, false, 2},
+		{"suspend", setStatus(model.Suspended), "", true, true, 2},
+		{"resume", setStatus(model.Activated), "t1f1", true, false, 2},
+		{"suspend again", setStatus(model.Suspended), "", true, true, 2},
+		{"delete", func() error { _, err := database.Delete("t1", "f1"); return err }, "", false, false, 0},
+	}
+	for _, c := range cases {
+		if err := c.change(); err != nil {
+			t.Fatalf("%s: %v", c.step, err)
+		}
+		eventually(t, c.step+" reconciled", func() bool {
+			r, started := rc.Runner("t1f1")
+			return strings.Join(rc.Running(), ",") == c.running && started == c.started &&
+				(!started || r.Paused() == c.paused) && runners.consumers.open() == c.consumers
+		})
+	}
+	// pausing and resuming keep the runner and its consumers
+	if runners.created != 1 {
+		t.Errorf("expected a single runner through the status changes, got %d", runners.created)
+	}
+}
+
+func TestReconcilerRestartsOnConfigChange(t *testing.T) {
+	database, _ := db.NewInMemoryHandler()
+	if _, err := database.Create(reconciledFunction("f1", model.Activated)); err != nil {
+		t.Fatal(err)
+	}
+	runners := &fakeRunners{}
+	rc := NewReconciler(database, runners.factory)
+	defer rc.StopAll()
+
+	cases := []struct {
+		name      string
+		update    func(cfg *model.FunctionConfig)
+		restarted bool
+		consumers int
+	}{
+		{"unchanged", func(cfg *model.FunctionConfig) {}, false, 2},
+		{"status", func(cfg *model.FunctionConfig) { cfg.FunctionStatus = model.Suspended }, false, 2},
+		{"parallelism", func(cfg *model.FunctionConfig) { cfg.Parallelism = 3 }, true, 3},
+		{"webhook", func(cfg *model.FunctionConfig) {
+			cfg.Webhooks = []model.WebhookConfig{{URL: "http://localhost/hook", Subscription: "hook", SubscriptionType: "shared"}}
+		}, true, 3},
+		{"user config", func(cfg *model.FunctionConfig) { cfg.UserConfig = map[string]string{"k": "v"} }, true, 3},
+	}
+	if err := rc.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range cases {
+		before, _ := rc.Runner("t1f1")
+		cfg, _ := database.GetByKey("t1f1")
+		c.update(cfg)
+		if _, err := database.Update(cfg); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if err := rc.Reconcile(); err != nil {
+			t.Fatal(err)
+		}
+		after, ok := rc.Runner("t1f1")
+		if !ok || (after != before) != c.restarted {
+			t.Errorf("%s: expected restarted %v", c.name, c.restarted)
+		}
+		if open := runners.consumers.open(); open != c.consumers {
+			t.Errorf("%s: expected %d open consumers, got %d", c.name, c.consumers, open)
+		}
+	}
+}
+
+func TestReconcilerTrigger(t *testing.T) {
+	rc := NewReconciler(nil, nil)
+	// the triggers are coalesced without blocking
+	for i := 0; i < 3; i++ {
+		rc.Trigger()
+	}
+	if len(rc.triggered) != 1 {
+		t.Errorf("expected one pending trigger, got %d", len(rc.triggered))
+	}
+}
+
+func TestWebhookRunnerFactory(t *testing.T) {
+	database, _ := db.NewInMemoryHandler()
+	cfg := *reconciledFunction("f1", model.Activated)
+	cfg.InputTopic.SubscriptionType = "exclusive"
+	if _, err := NewWebhookRunnerFactory(database)(cfg); err == nil {
+		t.Error("expected an error on parallel consumers of an exclusive subscription")
+	}
+}
+
+func TestRuntimeStatus(t *testing.T) {
+	database, _ := db.NewInMemoryHandler()
+	if _, err := database.Create(reconciledFunction("f1", model.Activated)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := database.Create(reconciledFunction("f2", model.Suspended)); err != nil {
+		t.Fatal(err)
+	}
+	runners := &fakeRunners{}
+	rc := NewReconciler(database, runners.factory)
+	defer rc.StopAll()
+	if err := rc.Reconcile(); err != nil {
+		t.Fatal(err)
+	}
+
+	repliedAt := time.Now()
+	webhooks := []model.WebhookConfig{
+		{Failures: 1, LastReply: model.WebhookReply{StatusCode: 500, RepliedAt: repliedAt.Add(-time.Minute)}},
+		{Failures: 2, LastReply: model.WebhookReply{StatusCode: 502, RepliedAt: repliedAt}},
+		{Failures: 4, WebhookStatus: model.Deleted, LastReply: model.WebhookReply{StatusCode: 503, RepliedAt: repliedAt.Add(time.Minute)}},
+	}
+	cases := []struct {
+		name      string
+		function  string
+		webhooks  []model.WebhookConfig
+		connected bool
+		paused    bool
+		consumers int
+		errors    int
+		lastCode  int
+	}{
+		{"running", "t1f1", nil, true, false, 2, 0, 0},
+		{"suspended", "t1f2", nil, true, true, 2, 0, 0},
+		{"deliveries", "t1f1", webhooks, true, false, 2, 3, 502},
+		{"unknown", "t1f3", nil, false, false, 0, 0, 0},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{ID: c.function, Webhooks: c.webhooks}
+		status := rc.RuntimeStatus(cfg)
+		if status.ID != c.function || status.Connected != c.connected || status.Paused != c.paused ||
+			status.Consumers != c.consumers || status.Errors != c.errors {
+			t.Errorf("%s: unexpected runtime status %+v", c.name, status)
+		}
+		lastCode := 0
+		if status.LastDelivery != nil {
+			lastCode = status.LastDelivery.StatusCode
+		}
+		if lastCode != c.lastCode {
+			t.Errorf("%s: expected the last delivery status %d, got %d", c.name, c.lastCode, lastCode)
+		}
+	}
+}
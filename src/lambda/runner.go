@@ -0,0 +1,206 @@
+package lambda
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// MessageHandler processes a message received by one of the function consumers.
+// The handler is responsible for acknowledging the message.
+type MessageHandler func(consumer pulsar.Consumer, msg pulsar.Message)
+
+// ConsumerFactory creates a consumer on the function input topic
+type ConsumerFactory func(ft model.FunctionTopic) (pulsar.Consumer, error)
+
+// FunctionRunner processes the function input topic with Parallelism number of consumers
+type FunctionRunner struct {
+	Config      model.FunctionConfig
+	handler     MessageHandler
+	newConsumer ConsumerFactory
+	consumers   []pulsar.Consumer
+	cancel      context.CancelFunc
+	wg          sync.WaitGroup
+	sync.Mutex
+
+	// resumed is closed to resume the delivery, it is nil unless the delivery is paused
+	resumed   chan struct{}
+	pauseLock sync.Mutex
+
+	// onStop releases the resources of the message handler once the runner is stopped
+	onStop func()
+}
+
+// NewFunctionRunner creates a function runner consuming from Pulsar
+func NewFunctionRunner(cfg model.FunctionConfig, handler MessageHandler) (*FunctionRunner, error) {
+	return NewFunctionRunnerWithFactory(cfg, handler, newPulsarConsumer)
+}
+
+// NewFunctionRunnerWithFactory creates a function runner with a custom consumer factory
+func NewFunctionRunnerWithFactory(cfg model.FunctionConfig, handler MessageHandler, factory ConsumerFactory) (*FunctionRunner, error) {
+	if err := ValidateParallelism(cfg.Parallelism, cfg.InputTopic.SubscriptionType); err != nil {
+		return nil, err
+	}
+	r := &FunctionRunner{
+		Config:      cfg,
+		handler:     handler,
+		newConsumer: factory,
+	}
+	r.SetStatus(cfg.FunctionStatus)
+	return r, nil
+}
+
+// ValidateParallelism validates the subscription type allows the number of consumers
+func ValidateParallelism(parallelism int, subscriptionType string) error {
+	if parallelism < 1 {
+		return fmt.Errorf("parallelism must be greater than 0")
+	}
+	subType, err := model.GetSubscriptionType(subscriptionType)
+	if err != nil {
+		return err
+	}
+	if subType == pulsar.Exclusive && parallelism > 1 {
+		return fmt.Errorf("exclusive subscription does not support parallelism %d, use shared or keyshared subscription", parallelism)
+	}
+	return nil
+}
+
+// Start creates the consumers and processes messages concurrently
+func (r *FunctionRunner) Start() error {
+	r.Lock()
+	defer r.Unlock()
+	if r.cancel != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	for i := 0; i < r.Config.Parallelism; i++ {
+		c, err := r.newConsumer(r.Config.InputTopic)
+		if err != nil {
+			cancel()
+			r.closeConsumers()
+			return err
+		}
+		r.consumers = append(r.consumers, c)
+		r.wg.Add(1)
+		go r.receive(ctx, c)
+	}
+	r.cancel = cancel
+	log.Infof("function %s started %d consumers on topic %s", r.Config.ID, len(r.consumers), r.Config.InputTopic.TopicFullName)
+	return nil
+}
+
+// Stop stops processing and closes all consumers
+func (r *FunctionRunner) Stop() {
+	r.Lock()
+	defer r.Unlock()
+	if r.cancel != nil {
+		r.cancel()
+		r.wg.Wait()
+		r.closeConsumers()
+		r.cancel = nil
+	}
+	if r.onStop != nil {
+		r.onStop()
+		r.onStop = nil
+	}
+}
+
+// OnStop sets the function called once the runner is stopped, after its consumers are closed
+func (r *FunctionRunner) OnStop(fn func()) {
+	r.Lock()
+	defer r.Unlock()
+	r.onStop = fn
+}
+
+// Consumers returns the number of running consumers
+func (r *FunctionRunner) Consumers() int {
+	r.Lock()
+	defer r.Unlock()
+	return len(r.consumers)
+}
+
+// SetStatus pauses the delivery when the function is suspended and resumes it when the function is activated.
+// The consumers stay connected while paused so the subscription keeps its position and the backlog accumulates.
+func (r *FunctionRunner) SetStatus(status model.Status) {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	switch status {
+	case model.Suspended:
+		if r.resumed == nil {
+			r.resumed = make(chan struct{})
+			log.Infof("function %s delivery paused", r.Config.ID)
+		}
+	case model.Activated:
+		if r.resumed != nil {
+			close(r.resumed)
+			r.resumed = nil
+			log.Infof("function %s delivery resumed", r.Config.ID)
+		}
+	}
+}
+
+// Paused returns whether the delivery is paused
+func (r *FunctionRunner) Paused() bool {
+	r.pauseLock.Lock()
+	defer r.pauseLock.Unlock()
+	return r.resumed != nil
+}
+
+// waitResumed blocks while the delivery is paused, it returns false if the context is done first
+func (r *FunctionRunner) waitResumed(ctx context.Context) bool {
+	r.pauseLock.Lock()
+	resumed := r.resumed
+	r.pauseLock.Unlock()
+	if resumed == nil {
+		return true
+	}
+	select {
+	case <-resumed:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+func (r *FunctionRunner) receive(ctx context.Context, c pulsar.Consumer) {
+	defer r.wg.Done()
+	for {
+		if !r.waitResumed(ctx) {
+			return
+		}
+		msg, err := c.Receive(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Errorf("function %s consumer receive error %v", r.Config.ID, err)
+			}
+			return
+		}
+		// a message received before the pause is held unacknowledged until resumed
+		if !r.waitResumed(ctx) {
+			return
+		}
+		r.handler(c, msg)
+	}
+}
+
+func (r *FunctionRunner) closeConsumers() {
+	for _, c := range r.consumers {
+		c.Close()
+	}
+	r.consumers = nil
+}
+
+func newPulsarConsumer(ft model.FunctionTopic) (pulsar.Consumer, error) {
+	client, err := pulsardriver.GetTopicClient(ft)
+	if err != nil {
+		return nil, err
+	}
+	return pulsardriver.NewConsumer(client, ft)
+}
@@ -0,0 +1,203 @@
+package lambda
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// fakeConsumer blocks in Receive until the context is done
+type fakeConsumer struct {
+	pulsar.Consumer
+	lock   sync.Mutex
+	closed bool
+}
+
+func (c *fakeConsumer) Receive(ctx context.Context) (pulsar.Message, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (c *fakeConsumer) Close() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+}
+
+// fakeConsumers creates fake consumers and keeps them for inspection
+type fakeConsumers struct {
+	lock      sync.Mutex
+	consumers []*fakeConsumer
+}
+
+func (f *fakeConsumers) factory(ft model.FunctionTopic) (pulsar.Consumer, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	c := &fakeConsumer{}
+	f.consumers = append(f.consumers, c)
+	return c, nil
+}
+
+func (f *fakeConsumers) open() int {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	open := 0
+	for _, c := range f.consumers {
+		c.lock.Lock()
+		if !c.closed {
+			open++
+		}
+		c.lock.Unlock()
+	}
+	return open
+}
+
+func TestValidateParallelism(t *testing.T) {
+	cases := []struct {
+		parallelism int
+		subType     string
+		valid       bool
+	}{
+		{1, "exclusive", true},
+		{2, "exclusive", false},
+		{4, "shared", true},
+		{4, "keyshared", true},
+		{0, "shared", false},
+		{1, "bogus", false},
+	}
+	for _, c := range cases {
+		err := ValidateParallelism(c.parallelism, c.subType)
+		if c.valid != (err == nil) {
+			t.Errorf("parallelism %d on %s: unexpected error %v", c.parallelism, c.subType, err)
+		}
+	}
+}
+
+func TestFunctionRunnerParallelism(t *testing.T) {
+	for _, parallelism := range []int{1, 3} {
+		consumers := &fakeConsumers{}
+		cfg := model.FunctionConfig{ID: "t1f1", Parallelism: parallelism, InputTopic: model.FunctionTopic{SubscriptionType: "shared"}}
+		r, err := NewFunctionRunnerWithFactory(cfg, func(pulsar.Consumer, pulsar.Message) {}, consumers.factory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Start(); err != nil {
+			t.Fatal(err)
+		}
+		r.Start()
+		if r.Consumers() != parallelism || consumers.open() != parallelism {
+			t.Errorf("expected %d consumers, got %d", parallelism, r.Consumers())
+		}
+		r.Stop()
+		if r.Consumers() != 0 || consumers.open() != 0 {
+			t.Errorf("the consumers must be closed on stop, %d open", consumers.open())
+		}
+	}
+}
+
+// queueConsumer receives the queued messages
+type queueConsumer struct {
+	pulsar.Consumer
+	messages chan pulsar.Message
+}
+
+func (c *queueConsumer) Receive(ctx context.Context) (pulsar.Message, error) {
+	select {
+	case msg := <-c.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *queueConsumer) Close() {}
+
+func TestFunctionRunnerPause(t *testing.T) {
+	consumer := &queueConsumer{messages: make(chan pulsar.Message, 10)}
+	handled := make(chan pulsar.Message, 10)
+	cfg := model.FunctionConfig{ID: "t1f1", Parallelism: 1, FunctionStatus: model.Suspended}
+	r, err := NewFunctionRunnerWithFactory(cfg, func(c pulsar.Consumer, msg pulsar.Message) { handled <- msg },
+		func(model.FunctionTopic) (pulsar.Consumer, error) { return consumer, nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer r.Stop()
+
+	cases := []struct {
+		status  model.Status
+		paused  bool
+		handled bool
+	}{
+		// a function created suspended does not deliver
+		{model.Suspended, true, false},
+		{model.Activated, false, true},
+		{model.Suspended, true, false},
+		// an unrelated status keeps the delivery state
+		{model.Deactivated, true, false},
+		{model.Activated, false, true},
+	}
+	for i, c := range cases {
+		r.SetStatus(c.status)
+		if r.Paused() != c.paused {
+			t.Errorf("%d %s: expected paused %v", i, c.status, c.paused)
+		}
+		consumer.messages <- &fakeMessage{key: c.status.String()}
+		select {
+		case <-handled:
+			if !c.handled {
+				t.Errorf("%d %s: expected no delivery while paused", i, c.status)
+			}
+		case <-time.After(50 * time.Millisecond):
+			if c.handled {
+				t.Errorf("%d %s: expected the delivery", i, c.status)
+			}
+			// the consumer stays connected while paused so the held message is delivered on resume
+			if r.Consumers() != 1 {
+				t.Errorf("%d %s: expected the consumer kept, got %d", i, c.status, r.Consumers())
+			}
+			r.SetStatus(model.Activated)
+			<-handled
+			r.SetStatus(c.status)
+		}
+	}
+}
+
+func TestFunctionRunnerOnStop(t *testing.T) {
+	cases := []struct {
+		name    string
+		started bool
+	}{
+		{"started", true},
+		{"never started", false},
+	}
+	for _, c := range cases {
+		consumers := &fakeConsumers{}
+		cfg := model.FunctionConfig{ID: "t1f1", Parallelism: 1}
+		r, err := NewFunctionRunnerWithFactory(cfg, func(pulsar.Consumer, pulsar.Message) {}, consumers.factory)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stopped, open := 0, -1
+		r.OnStop(func() {
+			stopped++
+			open = consumers.open()
+		})
+		if c.started {
+			if err := r.Start(); err != nil {
+				t.Fatal(err)
+			}
+		}
+		r.Stop()
+		r.Stop()
+		if stopped != 1 || open != 0 {
+			t.Errorf("%s: expected the stop hook called once after the consumers closed, got %d with %d open", c.name, stopped, open)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package lambda
+
+import (
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// RuntimeStatus is the live state of a function in this server, separate from its stored config
+type RuntimeStatus struct {
+	ID     string       `json:"id"`
+	Status model.Status `json:"status"`
+	// Connected is true if the function consumers are connected to the input topic
+	Connected bool `json:"connected"`
+	Paused    bool `json:"paused"`
+	Consumers int  `json:"consumers"`
+	// Backlog is the last collected backlog by subscription
+	Backlog map[string]int64 `json:"backlog"`
+	// LastDelivery is the most recent webhook reply
+	LastDelivery *model.WebhookReply `json:"lastDelivery,omitempty"`
+	// Errors is the number of consecutive failed deliveries summed over the webhooks
+	Errors int `json:"errors"`
+}
+
+// RuntimeStatus assembles the live state of the function from its runner, the backlog collection,
+// and the webhook delivery results
+func (rc *Reconciler) RuntimeStatus(cfg *model.FunctionConfig) RuntimeStatus {
+	status := RuntimeStatus{
+		ID:      cfg.ID,
+		Status:  cfg.FunctionStatus,
+		Backlog: Backlog(cfg.ID),
+	}
+	if r, ok := rc.Runner(cfg.ID); ok {
+		status.Consumers = r.Consumers()
+		status.Connected = status.Consumers > 0
+		status.Paused = r.Paused()
+	}
+	for i, wh := range cfg.Webhooks {
+		if wh.WebhookStatus == model.Deleted {
+			continue
+		}
+		status.Errors += wh.Failures
+		if !wh.LastReply.RepliedAt.IsZero() &&
+			(status.LastDelivery == nil || wh.LastReply.RepliedAt.After(status.LastDelivery.RepliedAt)) {
+			status.LastDelivery = &cfg.Webhooks[i].LastReply
+		}
+	}
+	return status
+}
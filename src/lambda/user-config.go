@@ -0,0 +1,83 @@
+package lambda
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// SecretLookup resolves the server variable referenced by a function secret ref, the secret env variables by default
+var SecretLookup = util.LookupSecretEnv
+
+// envNamePattern is a portable environment variable name
+var envNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// MaxUserConfigBytes is the maximum total size of the user config keys and values
+const MaxUserConfigBytes = 64 << 10
+
+// ValidateUserConfig validates the user config and the secret refs of the function
+func ValidateUserConfig(cfg *model.FunctionConfig) error {
+	size := 0
+	for k, v := range cfg.UserConfig {
+		if err := validateEnvName(k); err != nil {
+			return err
+		}
+		if strings.ContainsRune(v, 0) {
+			return fmt.Errorf("user config %s value must not contain a NUL character", k)
+		}
+		size += len(k) + len(v)
+	}
+	if size > MaxUserConfigBytes {
+		return fmt.Errorf("user config size %d exceeds the maximum %d bytes", size, MaxUserConfigBytes)
+	}
+	for k, ref := range cfg.SecretRefs {
+		if err := validateEnvName(k); err != nil {
+			return err
+		}
+		if _, ok := cfg.UserConfig[k]; ok {
+			return fmt.Errorf("%s is specified in both user config and secret refs", k)
+		}
+		if !envNamePattern.MatchString(ref) {
+			return fmt.Errorf("secret ref %s references an invalid variable name %q", k, ref)
+		}
+		if !util.IsSecretEnvName(ref) {
+			return fmt.Errorf("secret ref %s references variable %s not prefixed with %s", k, ref, util.SecretEnvPrefix)
+		}
+	}
+	return nil
+}
+
+func validateEnvName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("user config key is empty")
+	}
+	if !envNamePattern.MatchString(name) {
+		return fmt.Errorf("user config key %s must be letters, digits, and underscores not starting with a digit", name)
+	}
+	return nil
+}
+
+// FunctionEnv returns the environment variables of the function instance, the user config and
+// the resolved secret refs in the KEY=value form ordered by key
+func FunctionEnv(cfg *model.FunctionConfig) ([]string, error) {
+	env := make([]string, 0, len(cfg.UserConfig)+len(cfg.SecretRefs))
+	for k, v := range cfg.UserConfig {
+		env = append(env, k+"="+v)
+	}
+	for k, ref := range cfg.SecretRefs {
+		if !util.IsSecretEnvName(ref) {
+			return nil, fmt.Errorf("function %s secret ref %s references variable %s not prefixed with %s", cfg.ID, k, ref, util.SecretEnvPrefix)
+		}
+		v, ok := SecretLookup(ref)
+		if !ok {
+			return nil, fmt.Errorf("missing variable %s referenced by function %s secret ref %s", ref, cfg.ID, k)
+		}
+		env = append(env, k+"="+v)
+	}
+	sort.Strings(env)
+	return env, nil
+}
@@ -0,0 +1,66 @@
+package lambda
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestValidateUserConfig(t *testing.T) {
+	cases := []struct {
+		name       string
+		userConfig map[string]string
+		secretRefs map[string]string
+		valid      bool
+	}{
+		{"empty", nil, nil, true},
+		{"user config and secret", map[string]string{"LOG_LEVEL": "debug"}, map[string]string{"API_KEY": "PUBSUBFN_SECRET_API_KEY"}, true},
+		{"empty key", map[string]string{"": "v"}, nil, false},
+		{"invalid key", map[string]string{"1KEY": "v"}, nil, false},
+		{"NUL value", map[string]string{"KEY": "a\x00b"}, nil, false},
+		{"oversized", map[string]string{"KEY": strings.Repeat("v", MaxUserConfigBytes)}, nil, false},
+		{"key in both", map[string]string{"API_KEY": "v"}, map[string]string{"API_KEY": "PUBSUBFN_SECRET_API_KEY"}, false},
+		{"invalid variable", nil, map[string]string{"API_KEY": "PUBSUBFN_SECRET_API-KEY"}, false},
+		{"server variable", nil, map[string]string{"DB": "DbPassword"}, false},
+		{"bare secret prefix", nil, map[string]string{"DB": "PUBSUBFN_SECRET_"}, false},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{UserConfig: c.userConfig, SecretRefs: c.secretRefs}
+		if err := ValidateUserConfig(cfg); (err == nil) != c.valid {
+			t.Errorf("%s: expected valid %v, got %v", c.name, c.valid, err)
+		}
+	}
+}
+
+func TestFunctionEnv(t *testing.T) {
+	os.Setenv("PUBSUBFN_SECRET_API_KEY", "secret")
+	os.Setenv("DbPassword", "server-secret")
+	defer os.Unsetenv("PUBSUBFN_SECRET_API_KEY")
+	defer os.Unsetenv("DbPassword")
+
+	cases := []struct {
+		name       string
+		secretRefs map[string]string
+		env        string
+	}{
+		{"user config only", nil, "LOG_LEVEL=debug"},
+		{"secret", map[string]string{"API_KEY": "PUBSUBFN_SECRET_API_KEY"}, "API_KEY=secret,LOG_LEVEL=debug"},
+		{"missing secret", map[string]string{"API_KEY": "PUBSUBFN_SECRET_MISSING"}, ""},
+		{"server variable", map[string]string{"DB": "DbPassword"}, ""},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{ID: "t1f1", UserConfig: map[string]string{"LOG_LEVEL": "debug"}, SecretRefs: c.secretRefs}
+		env, err := FunctionEnv(cfg)
+		if c.env == "" {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", c.name, env)
+			}
+			continue
+		}
+		if err != nil || strings.Join(env, ",") != c.env {
+			t.Errorf("%s: expected %s, got %v %v", c.name, c.env, env, err)
+		}
+	}
+}
@@ -3,28 +3,39 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/kafkaesque-io/pubsub-function/src/broker"
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/lambda"
 	"github.com/kafkaesque-io/pubsub-function/src/route"
 	"github.com/kafkaesque-io/pubsub-function/src/util"
 	"github.com/rs/cors"
 	log "github.com/sirupsen/logrus"
 )
 
-var mode = util.AssignString(os.Getenv("ProcessMode"), *flag.String("mode", "hybrid", "server running mode"))
+var modeFlag = flag.String("mode", "hybrid", "server running mode")
 
 func main() {
 	exit := make(chan bool)
 	util.Init()
+	if err := util.ValidateConfig(); err != nil {
+		log.Fatal(err)
+	}
 
 	flag.Parse()
+	mode := util.AssignString(os.Getenv("ProcessMode"), *modeFlag)
 	log.Warnf("start server mode %s", mode)
 	if !util.IsValidMode(&mode) {
-		log.Panic("Unsupported server mode")
+		log.Fatalf("unsupported server mode %s, supported modes are %s", mode, strings.Join(util.Modes, ", "))
 	}
 
 	if util.IsBrokerRequired(&mode) {
 		broker.Init()
+		interval := util.ConfigInt(util.GetConfig().ReconcileInterval, 60)
+		reconciler, _ := lambda.RunFunctions(db.NewDbWithPanic(util.GetConfig().PbDbType), time.Duration(interval)*time.Second)
+		route.SetRuntime(reconciler)
 	}
 
 	if util.IsHTTPRouterRequired(&mode) {
@@ -36,7 +47,10 @@ func main() {
 			AllowedHeaders:   []string{"Authorization", "PulsarTopicUrl"},
 		})
 
-		router := route.NewRouter(&mode)
+		router, err := route.NewRouter(&mode)
+		if err != nil {
+			log.Fatal(err)
+		}
 
 		handler := c.Handler(router)
 		config := util.GetConfig()
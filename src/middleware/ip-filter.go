@@ -0,0 +1,157 @@
+package middleware
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// IPFilter allows or denies requests by the client IP against lists of CIDRs
+type IPFilter struct {
+	allow      []*net.IPNet
+	deny       []*net.IPNet
+	trustProxy bool
+}
+
+// NewIPFilter creates an IP filter from comma separated lists of CIDRs or IP addresses.
+// An empty allow list allows all addresses not in the deny list.
+// The client IP is taken from X-Forwarded-For only if the proxy is trusted.
+func NewIPFilter(allow, deny string, trustProxy bool) (*IPFilter, error) {
+	allowNets, err := parseCIDRs(allow)
+	if err != nil {
+		return nil, err
+	}
+	denyNets, err := parseCIDRs(deny)
+	if err != nil {
+		return nil, err
+	}
+	return &IPFilter{
+		allow:      allowNets,
+		deny:       denyNets,
+		trustProxy: trustProxy,
+	}, nil
+}
+
+func parseCIDRs(list string) ([]*net.IPNet, error) {
+	nets := []*net.IPNet{}
+	for _, v := range strings.Split(list, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		if !strings.Contains(v, "/") {
+			ip := net.ParseIP(v)
+			if ip == nil {
+				return nil, fmt.Errorf("invalid IP address %s", v)
+			}
+			if ip.To4() != nil {
+				v = v + "/32"
+			} else {
+				v = v + "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(v)
+		if err != nil {
+			return nil, err
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// Allowed evaluates whether the IP is allowed, the deny list takes precedence over the allow list
+func (f *IPFilter) Allowed(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range f.deny {
+		if n.Contains(ip) {
+			return false
+		}
+	}
+	if len(f.allow) == 0 {
+		return true
+	}
+	for _, n := range f.allow {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the request client IP
+func (f *IPFilter) ClientIP(r *http.Request) net.IP {
+	if f.trustProxy {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			// the left most address is the original client
+			return net.ParseIP(strings.TrimSpace(strings.Split(forwarded, ",")[0]))
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// Handler replies 403 Forbidden to requests from a disallowed client IP
+func (f *IPFilter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip := f.ClientIP(r); !f.Allowed(ip) {
+			log.Warnf("reject request from client IP %v", ip)
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var (
+	receiverFilter     *IPFilter
+	receiverFilterErr  error
+	receiverFilterInit bool
+	receiverFilterLock sync.Mutex
+)
+
+func init() {
+	// the receiver filter is rebuilt from the reloaded CIDRs on the next request
+	util.OnConfigReload(func() {
+		receiverFilterLock.Lock()
+		receiverFilterInit = false
+		receiverFilterLock.Unlock()
+	})
+}
+
+func getReceiverFilter() (*IPFilter, error) {
+	receiverFilterLock.Lock()
+	defer receiverFilterLock.Unlock()
+	if !receiverFilterInit {
+		cfg := util.GetConfig()
+		receiverFilter, receiverFilterErr = NewIPFilter(cfg.ReceiverAllowedCIDRs, cfg.ReceiverDeniedCIDRs,
+			util.StringToBool(cfg.TrustedProxy))
+		if receiverFilterErr != nil {
+			log.Errorf("invalid receiver IP filter configuration %v", receiverFilterErr)
+		}
+		receiverFilterInit = true
+	}
+	return receiverFilter, receiverFilterErr
+}
+
+// ReceiverIPFilter filters the receiver requests by the configured allowed and denied CIDRs
+func ReceiverIPFilter(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		filter, err := getReceiverFilter()
+		if err != nil {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		filter.Handler(next).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestIPFilter(t *testing.T) {
+	cases := []struct {
+		name       string
+		allow      string
+		deny       string
+		trustProxy bool
+		remoteAddr string
+		forwarded  string
+		status     int
+	}{
+		{"no lists", "", "", false, "203.0.113.7:1234", "", http.StatusOK},
+		{"allowed CIDR", "10.0.0.0/8", "", false, "10.1.2.3:1234", "", http.StatusOK},
+		{"not in the allow list", "10.0.0.0/8", "", false, "203.0.113.7:1234", "", http.StatusForbidden},
+		{"single allowed IP", "203.0.113.7", "", false, "203.0.113.7:1234", "", http.StatusOK},
+		{"deny takes precedence", "10.0.0.0/8", "10.1.0.0/16", false, "10.1.2.3:1234", "", http.StatusForbidden},
+		{"denied IPv6", "", "2001:db8::/32", false, "[2001:db8::1]:1234", "", http.StatusForbidden},
+		{"untrusted forwarded header", "10.0.0.0/8", "", false, "203.0.113.7:1234", "10.1.2.3", http.StatusForbidden},
+		{"trusted forwarded header", "10.0.0.0/8", "", true, "203.0.113.7:1234", "10.1.2.3, 203.0.113.7", http.StatusOK},
+		{"malformed forwarded header", "", "", true, "10.1.2.3:1234", "unknown", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		f, err := NewIPFilter(c.allow, c.deny, c.trustProxy)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		r := httptest.NewRequest(http.MethodPost, "/", nil)
+		r.RemoteAddr = c.remoteAddr
+		if c.forwarded != "" {
+			r.Header.Set("X-Forwarded-For", c.forwarded)
+		}
+		rr := httptest.NewRecorder()
+		f.Handler(okHandler).ServeHTTP(rr, r)
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, rr.Code)
+		}
+	}
+}
+
+func TestNewIPFilterInvalid(t *testing.T) {
+	for _, list := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.0/8, bogus"} {
+		if _, err := NewIPFilter(list, "", false); err == nil {
+			t.Errorf("%s: expected an error", list)
+		}
+	}
+}
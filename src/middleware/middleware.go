@@ -2,8 +2,11 @@ package middleware
 
 //middleware includes auth, rate limit, and etc.
 import (
+	"mime"
 	"net/http"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/kafkaesque-io/pubsub-function/src/util"
 
@@ -14,7 +17,10 @@ var (
 	// Rate is the default global rate limit
 	// This rate only limits the rate hitting on endpoint
 	// It does not limit the underline resource access
-	Rate = NewSema(200)
+	// The in-process semaphore can be replaced by a distributed limiter to enforce a cluster wide limit
+	Rate RateLimiter = &defaultSema
+
+	defaultSema = NewSema(200)
 )
 
 // AuthFunc is a function type to allow pluggable authentication middleware
@@ -79,3 +85,64 @@ func LimitRate(next http.Handler) http.Handler {
 		Rate.Release()
 	})
 }
+
+// RequireJSON rejects write requests with a body that is not application/json
+// so handlers do not fail with an opaque unmarshal error
+func RequireJSON(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost, http.MethodPut, http.MethodPatch:
+			if r.ContentLength == 0 {
+				// no body to unmarshal
+				break
+			}
+			mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+			if err != nil || mediaType != "application/json" {
+				http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Timeout replies with 503 Service Unavailable when the handler does not complete within the duration.
+// A non-positive duration disables the timeout.
+func Timeout(d time.Duration) func(next http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if d <= 0 {
+			return next
+		}
+		return http.TimeoutHandler(next, d, "Request timed out")
+	}
+}
+
+// ConfigTimeout applies the HTTPRequestTimeout configuration in seconds.
+// The timeout handler is built once and rebuilt on a configuration reload
+// so that a reloaded timeout takes effect without a restart.
+func ConfigTimeout(next http.Handler) http.Handler {
+	t := &configTimeout{next: next}
+	t.reload()
+	util.OnConfigReload(t.reload)
+	return t
+}
+
+// configTimeout serves the requests with the timeout handler of the current configuration
+type configTimeout struct {
+	next    http.Handler
+	handler atomic.Value
+}
+
+// timeoutHandler keeps the type stored in the atomic value the same whether the timeout is disabled or not
+type timeoutHandler struct {
+	http.Handler
+}
+
+func (t *configTimeout) reload() {
+	d := time.Duration(util.ConfigInt(util.GetConfig().HTTPRequestTimeout, 60)) * time.Second
+	t.handler.Store(timeoutHandler{Timeout(d)(t.next)})
+}
+
+func (t *configTimeout) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	t.handler.Load().(timeoutHandler).ServeHTTP(w, r)
+}
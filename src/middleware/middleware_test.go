@@ -0,0 +1,83 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+func TestRequireJSON(t *testing.T) {
+	cases := []struct {
+		method      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{http.MethodPost, "application/json", `{}`, http.StatusOK},
+		{http.MethodPut, "application/json; charset=utf-8", `{}`, http.StatusOK},
+		{http.MethodPatch, "text/plain", `{}`, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "", `{}`, http.StatusUnsupportedMediaType},
+		{http.MethodPost, "", "", http.StatusOK},
+		{http.MethodGet, "text/plain", `{}`, http.StatusOK},
+		{http.MethodDelete, "", `{}`, http.StatusOK},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(c.method, "/", strings.NewReader(c.body))
+		if c.contentType != "" {
+			r.Header.Set("Content-Type", c.contentType)
+		}
+		rr := httptest.NewRecorder()
+		RequireJSON(okHandler).ServeHTTP(rr, r)
+		if rr.Code != c.status {
+			t.Errorf("%s %q with body %q: expected status %d, got %d", c.method, c.contentType, c.body, c.status, rr.Code)
+		}
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	slowHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	})
+	cases := []struct {
+		name    string
+		timeout time.Duration
+		status  int
+	}{
+		{"slow handler times out", 10 * time.Millisecond, http.StatusServiceUnavailable},
+		{"within the timeout", time.Second, http.StatusOK},
+		{"disabled timeout", 0, http.StatusOK},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		Timeout(c.timeout)(slowHandler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.name, c.status, rr.Code)
+		}
+	}
+}
+
+func TestConfigTimeoutReload(t *testing.T) {
+	defer func(timeout string) { util.Config.HTTPRequestTimeout = timeout }(util.Config.HTTPRequestTimeout)
+	util.Config.HTTPRequestTimeout = "0"
+	handler := ConfigTimeout(okHandler).(*configTimeout)
+	if _, ok := handler.handler.Load().(timeoutHandler).Handler.(http.HandlerFunc); !ok {
+		t.Fatal("a disabled timeout must serve the handler directly")
+	}
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d", rr.Code)
+	}
+
+	util.Config.HTTPRequestTimeout = "5"
+	handler.reload()
+	if _, ok := handler.handler.Load().(timeoutHandler).Handler.(http.HandlerFunc); ok {
+		t.Fatal("the reloaded timeout must wrap the handler with a timeout handler")
+	}
+}
@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RateLimiter is a pluggable limiter used by the LimitRate middleware
+type RateLimiter interface {
+	Acquire() error
+	Release() error
+}
+
+// SharedStore is a counter store shared across all replicas of the server
+type SharedStore interface {
+	// Incr increments the counter of the key and returns the new value.
+	// The counter expires after the ttl since its first increment.
+	Incr(key string, ttl time.Duration) (int64, error)
+}
+
+// DistributedLimiter enforces a cluster wide limit of requests per fixed time window
+// with the counters kept in a shared store
+type DistributedLimiter struct {
+	Store  SharedStore
+	Name   string
+	Limit  int64
+	Window time.Duration
+}
+
+// NewDistributedLimiter creates a limiter allowing limit requests per window across all replicas
+func NewDistributedLimiter(store SharedStore, name string, limit int64, window time.Duration) *DistributedLimiter {
+	return &DistributedLimiter{
+		Store:  store,
+		Name:   name,
+		Limit:  limit,
+		Window: window,
+	}
+}
+
+// Acquire counts a request against the current window
+func (l *DistributedLimiter) Acquire() error {
+	window := time.Now().UnixNano() / int64(l.Window)
+	count, err := l.Store.Incr(fmt.Sprintf("%s-%d", l.Name, window), l.Window)
+	if err != nil {
+		return err
+	}
+	if count > l.Limit {
+		return errors.New("rate limit exceeded")
+	}
+	return nil
+}
+
+// Release is a no-op since requests are counted per time window
+func (l *DistributedLimiter) Release() error {
+	return nil
+}
+
+// InMemoryStore is a SharedStore within a single process
+type InMemoryStore struct {
+	counters map[string]int64
+	expiries map[string]time.Time
+	sync.Mutex
+}
+
+// NewInMemoryStore creates an in memory shared store
+func NewInMemoryStore() *InMemoryStore {
+	return &InMemoryStore{
+		counters: make(map[string]int64),
+		expiries: make(map[string]time.Time),
+	}
+}
+
+// Incr increments the counter of the key
+func (s *InMemoryStore) Incr(key string, ttl time.Duration) (int64, error) {
+	s.Lock()
+	defer s.Unlock()
+	now := time.Now()
+	for k, expiry := range s.expiries {
+		if now.After(expiry) {
+			delete(s.counters, k)
+			delete(s.expiries, k)
+		}
+	}
+	if _, ok := s.expiries[key]; !ok {
+		s.expiries[key] = now.Add(ttl)
+	}
+	s.counters[key]++
+	return s.counters[key], nil
+}
+
+// SetRateLimiter replaces the limiter used by the LimitRate middleware
+func SetRateLimiter(limiter RateLimiter) {
+	Rate = limiter
+}
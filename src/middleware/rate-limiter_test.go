@@ -0,0 +1,62 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDistributedLimiterGlobalCap(t *testing.T) {
+	cases := []struct {
+		limit    int64
+		requests int
+		allowed  int
+	}{
+		{1, 4, 1},
+		{5, 4, 4},
+		{5, 12, 5},
+	}
+	for _, c := range cases {
+		// two replicas share the same store and limiter name
+		store := NewInMemoryStore()
+		replicas := []*DistributedLimiter{
+			NewDistributedLimiter(store, "receiver", c.limit, time.Hour),
+			NewDistributedLimiter(store, "receiver", c.limit, time.Hour),
+		}
+		allowed := 0
+		for i := 0; i < c.requests; i++ {
+			if replicas[i%2].Acquire() == nil {
+				allowed++
+			}
+		}
+		if allowed != c.allowed {
+			t.Errorf("limit %d: expected %d allowed requests, got %d", c.limit, c.allowed, allowed)
+		}
+	}
+}
+
+func TestInMemoryStoreExpiry(t *testing.T) {
+	store := NewInMemoryStore()
+	if n, _ := store.Incr("k", time.Millisecond); n != 1 {
+		t.Fatalf("expected 1, got %d", n)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if n, _ := store.Incr("k", time.Millisecond); n != 1 {
+		t.Errorf("expected the counter to expire, got %d", n)
+	}
+}
+
+func TestLimitRateWithSharedLimiter(t *testing.T) {
+	defer func(limiter RateLimiter) { Rate = limiter }(Rate)
+	SetRateLimiter(NewDistributedLimiter(NewInMemoryStore(), "api", 1, time.Hour))
+
+	expected := []int{http.StatusOK, http.StatusTooManyRequests}
+	for i, status := range expected {
+		rr := httptest.NewRecorder()
+		LimitRate(okHandler).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != status {
+			t.Errorf("request %d: expected status %d, got %d", i, status, rr.Code)
+		}
+	}
+}
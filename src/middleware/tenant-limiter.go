@@ -0,0 +1,172 @@
+package middleware
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// idleBucketTTL is how long an unused tenant bucket is kept before eviction
+const idleBucketTTL = 10 * time.Minute
+
+// tokenBucket refills rate tokens per second up to the burst of one second worth of tokens
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+// burst is at least one token so a rate below one request per second can still be served
+func (b *tokenBucket) burst() float64 {
+	return math.Max(1, b.rate)
+}
+
+func (b *tokenBucket) take(now time.Time) bool {
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if burst := b.burst(); b.tokens > burst {
+		b.tokens = burst
+	}
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// TenantLimiter limits the request rate of each tenant independently
+type TenantLimiter struct {
+	defaultRate float64
+	overrides   map[string]float64
+	buckets     map[string]*tokenBucket
+	lastEvict   time.Time
+	sync.Mutex
+}
+
+// NewTenantLimiter creates a tenant limiter with the default requests per second of a tenant
+// and per tenant overrides. A non-positive rate means unlimited.
+func NewTenantLimiter(defaultRate float64, overrides map[string]float64) *TenantLimiter {
+	if overrides == nil {
+		overrides = make(map[string]float64)
+	}
+	return &TenantLimiter{
+		defaultRate: defaultRate,
+		overrides:   overrides,
+		buckets:     make(map[string]*tokenBucket),
+		lastEvict:   time.Now(),
+	}
+}
+
+// ParseTenantRates parses comma separated tenant:rate pairs
+func ParseTenantRates(str string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	for _, v := range strings.Split(str, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		kv := strings.SplitN(v, ":", 2)
+		if len(kv) != 2 {
+			return nil, fmt.Errorf("malformed tenant rate %s", v)
+		}
+		rate, err := strconv.ParseFloat(strings.TrimSpace(kv[1]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed tenant rate %s", v)
+		}
+		rates[strings.TrimSpace(kv[0])] = rate
+	}
+	return rates, nil
+}
+
+// Allow takes a token from the tenant bucket
+func (l *TenantLimiter) Allow(tenant string) bool {
+	rate, ok := l.overrides[tenant]
+	if !ok {
+		rate = l.defaultRate
+	}
+	if rate <= 0 {
+		return true
+	}
+
+	l.Lock()
+	defer l.Unlock()
+	now := time.Now()
+	l.evict(now)
+	b, ok := l.buckets[tenant]
+	if !ok {
+		b = &tokenBucket{rate: rate, last: now}
+		b.tokens = b.burst()
+		l.buckets[tenant] = b
+	}
+	return b.take(now)
+}
+
+// evict removes the buckets idle beyond idleBucketTTL.
+// It must be called with the lock held.
+func (l *TenantLimiter) evict(now time.Time) {
+	if now.Sub(l.lastEvict) < idleBucketTTL {
+		return
+	}
+	for tenant, b := range l.buckets {
+		if now.Sub(b.last) >= idleBucketTTL {
+			delete(l.buckets, tenant)
+		}
+	}
+	l.lastEvict = now
+}
+
+// Handler limits the request rate by the tenant in the route path or the authenticated subject.
+// It must be applied after the authentication middleware.
+func (l *TenantLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := util.AssignString(mux.Vars(r)["tenant"], r.Header.Get("injectedSubs"))
+		if tenant != "" && !l.Allow(tenant) {
+			http.Error(w, "Too many requests", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+var (
+	tenantLimiter     *TenantLimiter
+	tenantLimiterLock sync.Mutex
+)
+
+func init() {
+	// the tenant limiter is rebuilt from the reloaded rates on the next request
+	util.OnConfigReload(func() {
+		tenantLimiterLock.Lock()
+		tenantLimiter = nil
+		tenantLimiterLock.Unlock()
+	})
+}
+
+func getTenantLimiter() *TenantLimiter {
+	tenantLimiterLock.Lock()
+	defer tenantLimiterLock.Unlock()
+	if tenantLimiter == nil {
+		overrides, err := ParseTenantRates(util.GetConfig().TenantRateLimitOverrides)
+		if err != nil {
+			log.Errorf("ignore invalid tenant rate limit overrides %v", err)
+		}
+		tenantLimiter = NewTenantLimiter(float64(util.ConfigInt(util.GetConfig().TenantRateLimit, 0)), overrides)
+	}
+	return tenantLimiter
+}
+
+// LimitTenantRate limits the request rate per tenant by the configured tenant rates
+func LimitTenantRate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		getTenantLimiter().Handler(next).ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,101 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestTokenBucket(t *testing.T) {
+	start := time.Now()
+	cases := []struct {
+		name  string
+		rate  float64
+		takes []time.Duration
+		want  []bool
+	}{
+		{"burst of the rate", 2, []time.Duration{0, 0, 0}, []bool{true, true, false}},
+		{"refill", 2, []time.Duration{0, 0, 0, 500 * time.Millisecond}, []bool{true, true, false, true}},
+		{"rate below one", 0.5, []time.Duration{0, 0, time.Second, 2 * time.Second}, []bool{true, false, false, true}},
+		{"burst capped after idle", 1, []time.Duration{10 * time.Second, 10 * time.Second}, []bool{true, false}},
+	}
+	for _, c := range cases {
+		b := &tokenBucket{rate: c.rate, last: start}
+		b.tokens = b.burst()
+		for i, offset := range c.takes {
+			if got := b.take(start.Add(offset)); got != c.want[i] {
+				t.Errorf("%s: take %d expected %v, got %v", c.name, i, c.want[i], got)
+			}
+		}
+	}
+}
+
+func TestTenantLimiterIsolation(t *testing.T) {
+	limiter := NewTenantLimiter(1, map[string]float64{"unlimited": 0})
+	handler := limiter.Handler(okHandler)
+	router := mux.NewRouter()
+	router.Handle("/{tenant}", handler)
+
+	cases := []struct {
+		tenant string
+		status int
+	}{
+		{"a", http.StatusOK},
+		{"a", http.StatusTooManyRequests},
+		{"b", http.StatusOK},
+		{"b", http.StatusTooManyRequests},
+		{"unlimited", http.StatusOK},
+		{"unlimited", http.StatusOK},
+	}
+	for i, c := range cases {
+		rr := httptest.NewRecorder()
+		router.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/"+c.tenant, nil))
+		if rr.Code != c.status {
+			t.Errorf("request %d of tenant %s: expected status %d, got %d", i, c.tenant, c.status, rr.Code)
+		}
+	}
+}
+
+func TestTenantLimiterEvictsIdleBuckets(t *testing.T) {
+	limiter := NewTenantLimiter(1, nil)
+	limiter.Allow("a")
+	limiter.Allow("b")
+	limiter.buckets["a"].last = time.Now().Add(-2 * idleBucketTTL)
+	limiter.lastEvict = time.Now().Add(-2 * idleBucketTTL)
+
+	limiter.Allow("b")
+	if _, ok := limiter.buckets["a"]; ok {
+		t.Error("expected the idle bucket of tenant a to be evicted")
+	}
+	if _, ok := limiter.buckets["b"]; !ok {
+		t.Error("expected the bucket of tenant b to be kept")
+	}
+}
+
+func TestParseTenantRates(t *testing.T) {
+	cases := []struct {
+		str   string
+		rates map[string]float64
+		err   bool
+	}{
+		{"", map[string]float64{}, false},
+		{"a:1, b: 0.5", map[string]float64{"a": 1, "b": 0.5}, false},
+		{"a", nil, true},
+		{"a:x", nil, true},
+	}
+	for _, c := range cases {
+		rates, err := ParseTenantRates(c.str)
+		if (err != nil) != c.err {
+			t.Errorf("%q: unexpected error %v", c.str, err)
+			continue
+		}
+		for k, v := range c.rates {
+			if rates[k] != v {
+				t.Errorf("%q: expected rate %v of %s, got %v", c.str, v, k, rates[k])
+			}
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package model
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// key shared subscription policies
+const (
+	// KeySharedAutoSplit distributes the hash range across consumers automatically
+	KeySharedAutoSplit = "autosplit"
+	// KeySharedSticky assigns fixed hash ranges to consumers
+	KeySharedSticky = "sticky"
+)
+
+// the hash range of key shared subscription
+const (
+	MinKeySharedHash = 0
+	MaxKeySharedHash = 65535
+)
+
+// HashRange is an inclusive range of key hashes
+type HashRange struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// KeySharedPolicy is the key shared subscription policy with sticky hash ranges
+type KeySharedPolicy struct {
+	Mode   string      `json:"mode"`
+	Ranges []HashRange `json:"ranges"`
+}
+
+// ParseKeySharedPolicy parses the key shared policy configuration.
+// The syntax is either `autosplit` or `sticky:<start>-<end>[,<start>-<end>...]`,
+// the sticky hash ranges must be within [0, 65535] and must not overlap.
+func ParseKeySharedPolicy(policy string) (KeySharedPolicy, error) {
+	parts := strings.SplitN(strings.TrimSpace(policy), ":", 2)
+	switch strings.ToLower(parts[0]) {
+	case KeySharedAutoSplit, "":
+		if len(parts) > 1 {
+			return KeySharedPolicy{}, fmt.Errorf("autosplit key shared policy does not take hash ranges")
+		}
+		return KeySharedPolicy{Mode: KeySharedAutoSplit}, nil
+	case KeySharedSticky:
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) == "" {
+			return KeySharedPolicy{}, fmt.Errorf("sticky key shared policy requires hash ranges")
+		}
+		ranges, err := parseHashRanges(parts[1])
+		if err != nil {
+			return KeySharedPolicy{}, err
+		}
+		return KeySharedPolicy{Mode: KeySharedSticky, Ranges: ranges}, nil
+	default:
+		return KeySharedPolicy{}, fmt.Errorf("invalid key shared policy %s", policy)
+	}
+}
+
+// ValidateKeySharedPolicy validates the key shared policy can be applied to a consumer.
+// The sticky hash ranges are parsed but rejected since the current Pulsar client cannot apply them.
+func ValidateKeySharedPolicy(policy string) error {
+	p, err := ParseKeySharedPolicy(policy)
+	if err != nil {
+		return err
+	}
+	if p.Mode != KeySharedAutoSplit {
+		return fmt.Errorf("%s key shared policy is not supported by the Pulsar client, use %s", p.Mode, KeySharedAutoSplit)
+	}
+	return nil
+}
+
+func parseHashRanges(str string) ([]HashRange, error) {
+	ranges := []HashRange{}
+	for _, v := range strings.Split(str, ",") {
+		bounds := strings.Split(strings.TrimSpace(v), "-")
+		if len(bounds) != 2 {
+			return nil, fmt.Errorf("invalid hash range %s", v)
+		}
+		start, err := strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash range %s", v)
+		}
+		end, err := strconv.Atoi(strings.TrimSpace(bounds[1]))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hash range %s", v)
+		}
+		if start < MinKeySharedHash || end > MaxKeySharedHash || start > end {
+			return nil, fmt.Errorf("hash range %s must be within [%d, %d]", v, MinKeySharedHash, MaxKeySharedHash)
+		}
+		ranges = append(ranges, HashRange{Start: start, End: end})
+	}
+
+	sorted := make([]HashRange, len(ranges))
+	copy(sorted, ranges)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Start < sorted[j].Start })
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Start <= sorted[i-1].End {
+			return nil, fmt.Errorf("hash range %d-%d overlaps with %d-%d",
+				sorted[i].Start, sorted[i].End, sorted[i-1].Start, sorted[i-1].End)
+		}
+	}
+	return ranges, nil
+}
@@ -0,0 +1,62 @@
+package model
+
+import "testing"
+
+func TestParseKeySharedPolicy(t *testing.T) {
+	cases := []struct {
+		policy  string
+		mode    string
+		ranges  []HashRange
+		invalid bool
+	}{
+		{"", KeySharedAutoSplit, nil, false},
+		{"AutoSplit", KeySharedAutoSplit, nil, false},
+		{"autosplit:0-10", "", nil, true},
+		{"sticky:0-100,200-65535", KeySharedSticky, []HashRange{{0, 100}, {200, 65535}}, false},
+		{"sticky: 200-300 , 0-10", KeySharedSticky, []HashRange{{200, 300}, {0, 10}}, false},
+		{"sticky", "", nil, true},
+		{"sticky:", "", nil, true},
+		{"sticky:0-100,100-200", "", nil, true},
+		{"sticky:10-5", "", nil, true},
+		{"sticky:0-65536", "", nil, true},
+		{"sticky:-1-10", "", nil, true},
+		{"sticky:a-b", "", nil, true},
+		{"roundrobin", "", nil, true},
+	}
+	for _, c := range cases {
+		policy, err := ParseKeySharedPolicy(c.policy)
+		if c.invalid != (err != nil) {
+			t.Errorf("%q: unexpected error %v", c.policy, err)
+			continue
+		}
+		if c.invalid {
+			continue
+		}
+		if policy.Mode != c.mode || len(policy.Ranges) != len(c.ranges) {
+			t.Errorf("%q: unexpected policy %+v", c.policy, policy)
+			continue
+		}
+		for i, r := range c.ranges {
+			if policy.Ranges[i] != r {
+				t.Errorf("%q: expected range %+v, got %+v", c.policy, r, policy.Ranges[i])
+			}
+		}
+	}
+}
+
+func TestValidateKeySharedPolicy(t *testing.T) {
+	cases := []struct {
+		policy string
+		valid  bool
+	}{
+		{"", true},
+		{"autosplit", true},
+		{"sticky:0-100", false},
+		{"bogus", false},
+	}
+	for _, c := range cases {
+		if err := ValidateKeySharedPolicy(c.policy); c.valid != (err == nil) {
+			t.Errorf("%q: unexpected error %v", c.policy, err)
+		}
+	}
+}
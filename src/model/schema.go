@@ -0,0 +1,88 @@
+package model
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+// JSON Schema draft used by the generated schema
+const jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+
+// schemaRequired lists the required properties per json path, matching the function config validation
+var schemaRequired = map[string][]string{
+	"":            {"name", "tenant"},
+	"inputTopics": {"topicFullName", "pulsarURL", "subscription"},
+}
+
+var (
+	statusType = reflect.TypeOf(Status(0))
+	timeType   = reflect.TypeOf(time.Time{})
+)
+
+// FunctionConfigSchema generates JSON Schema of FunctionConfig from the struct
+// so that the schema is always in sync with the model.
+func FunctionConfigSchema() map[string]interface{} {
+	schema := typeSchema(reflect.TypeOf(FunctionConfig{}), "")
+	schema["$schema"] = jsonSchemaDraft
+	schema["title"] = "FunctionConfig"
+	return schema
+}
+
+func typeSchema(t reflect.Type, path string) map[string]interface{} {
+	switch {
+	case t == statusType:
+		enum := make([]interface{}, len(StatusNames))
+		for i, v := range StatusNames {
+			enum[i] = v
+		}
+		return map[string]interface{}{"type": "string", "enum": enum}
+	case t == timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem(), path)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem(), path)}
+	case reflect.Ptr:
+		return typeSchema(t.Elem(), path)
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if field.PkgPath != "" {
+				continue // unexported
+			}
+			name := jsonFieldName(field)
+			if name == "-" {
+				continue
+			}
+			properties[name] = typeSchema(field.Type, strings.TrimPrefix(path+"."+name, "."))
+		}
+		schema := map[string]interface{}{"type": "object", "properties": properties}
+		if required, ok := schemaRequired[path]; ok {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		return map[string]interface{}{}
+	}
+}
+
+func jsonFieldName(field reflect.StructField) string {
+	if tag := strings.Split(field.Tag.Get("json"), ",")[0]; tag != "" {
+		return tag
+	}
+	return field.Name
+}
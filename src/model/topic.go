@@ -2,7 +2,9 @@ package model
 
 import (
 	"crypto/sha1"
+	"crypto/tls"
 	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"net/url"
 	"strings"
@@ -10,6 +12,7 @@ import (
 
 	"github.com/apache/pulsar-client-go/pulsar"
 	"github.com/kafkaesque-io/pubsub-function/src/icrypto"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
 )
 
 // Status can be used for webhook status
@@ -28,19 +31,55 @@ const (
 )
 
 // WebhookConfig - a configuration for webhook
+// The headers and the basic auth password can reference a secret env variable prefixed with
+// PUBSUBFN_SECRET_ as ${VAR}, it is resolved at delivery time.
+// The client certificate and key paths enable mutual TLS to the webhook endpoint.
+// A zero TimeoutMs delivers with the default webhook timeout of the configuration.
 type WebhookConfig struct {
-	URL              string    `json:"url"`
-	Headers          []string  `json:"headers"`
-	Subscription     string    `json:"subscription"`
-	SubscriptionType string    `json:"subscriptionType"`
-	InitialPosition  string    `json:"initialPosition"`
-	WebhookStatus    Status    `json:"webhookStatus"`
-	CreatedAt        time.Time `json:"createdAt"`
-	UpdatedAt        time.Time `json:"updatedAt"`
-	DeletedAt        time.Time `json:"deletedAt"`
+	URL                 string       `json:"url"`
+	Headers             []string     `json:"headers"`
+	Subscription        string       `json:"subscription"`
+	SubscriptionType    string       `json:"subscriptionType"`
+	SubscriptionMode    string       `json:"subscriptionMode"`
+	InitialPosition     string       `json:"initialPosition"`
+	Filter              Filter       `json:"filter"`
+	PayloadMode         string       `json:"payloadMode"`
+	DeliveryGuarantee   string       `json:"deliveryGuarantee"`
+	PropagateProperties bool         `json:"propagateProperties"`
+	TimeoutMs           int          `json:"timeoutMs"`
+	MaxPayloadBytes     int          `json:"maxPayloadBytes"`
+	BatchSize           int          `json:"batchSize"`
+	BatchTimeoutMs      int          `json:"batchTimeoutMs"`
+	Signed              bool         `json:"signed"`
+	Secret              string       `json:"secret"`
+	BasicAuthUser       string       `json:"basicAuthUser"`
+	BasicAuthPass       string       `json:"basicAuthPass"`
+	ClientCertPath      string       `json:"clientCertPath"`
+	ClientKeyPath       string       `json:"clientKeyPath"`
+	WebhookStatus       Status       `json:"webhookStatus"`
+	Failures            int          `json:"failures"`
+	LastReply           WebhookReply `json:"lastReply"`
+	CreatedAt           time.Time    `json:"createdAt"`
+	UpdatedAt           time.Time    `json:"updatedAt"`
+	DeletedAt           time.Time    `json:"deletedAt"`
 }
 
-//TODO add state of Webhook replies
+// Filter selects messages to deliver by key prefix and/or a property key value pair.
+// An empty filter matches all messages.
+type Filter struct {
+	KeyPrefix     string `json:"keyPrefix"`
+	PropertyKey   string `json:"propertyKey"`
+	PropertyValue string `json:"propertyValue"`
+}
+
+// WebhookReply is the state of the last webhook delivery
+type WebhookReply struct {
+	StatusCode int       `json:"statusCode"`
+	Attempts   int       `json:"attempts"`
+	Error      string    `json:"error"`
+	RepliedAt  time.Time `json:"repliedAt"`
+	Breaker    string    `json:"breaker"`
+}
 
 // TopicConfig - a configuraion for topic and its webhook configuration.
 type TopicConfig struct {
@@ -57,36 +96,55 @@ type TopicConfig struct {
 }
 
 // FunctionConfig is the function configuration
+// The user config is passed to the function instance as environment variables. The secret refs map
+// an instance environment variable to the server variable holding the secret, which is resolved
+// when the instance starts so the secret is never stored.
 type FunctionConfig struct {
-	Name             string        `json:"name"`
-	ID               string        `json:"id"`
-	Tenant           string        `json:"tenant"`
-	FunctionStatus   Status        `json:"functionStatus"`
-	FunctionFilePath string        `json:"functionFilePath"`
-	LanguagePack     string        `json:"languagePack"`
-	Parallelism      int           `json:"parallelism"`
-	WebhookURLs      []string      `json:"webhookURLs"`
-	InputTopic       FunctionTopic `json:"inputTopics"`
-	OutputTopic      FunctionTopic `json:"outputTopics"`
-	LogTopic         FunctionTopic `json:"logTopic"`
-	TriggerType      string        `json:"triggerType"`
-	Cron             string        `json:"cron"`
-	CreatedAt        time.Time     `json:"createdAt"`
-	UpdatedAt        time.Time     `json:"updatedAt"`
-	DeletedAt        time.Time     `json:"deletedAt"`
+	Name                    string            `json:"name"`
+	ID                      string            `json:"id"`
+	Tenant                  string            `json:"tenant"`
+	FunctionStatus          Status            `json:"functionStatus"`
+	FunctionFilePath        string            `json:"functionFilePath"`
+	LanguagePack            string            `json:"languagePack"`
+	UserConfig              map[string]string `json:"userConfig"`
+	SecretRefs              map[string]string `json:"secretRefs"`
+	Parallelism             int               `json:"parallelism"`
+	MaxConcurrentDeliveries int               `json:"maxConcurrentDeliveries"`
+	WebhookURLs             []string          `json:"webhookURLs"`
+	Webhooks                []WebhookConfig   `json:"webhooks"`
+	InputTopic              FunctionTopic     `json:"inputTopics"`
+	OutputTopic             FunctionTopic     `json:"outputTopics"`
+	OutputKeyMode           string            `json:"outputKeyMode"`
+	PropagateProperties     bool              `json:"propagateProperties"`
+	DeduplicationEnabled    bool              `json:"deduplicationEnabled"`
+	SequenceIDStrategy      string            `json:"sequenceIdStrategy"`
+	MessageRoutingMode      string            `json:"messageRoutingMode"`
+	LogTopic                FunctionTopic     `json:"logTopic"`
+	TriggerType             string            `json:"triggerType"`
+	Cron                    string            `json:"cron"`
+	CreatedAt               time.Time         `json:"createdAt"`
+	UpdatedAt               time.Time         `json:"updatedAt"`
+	DeletedAt               time.Time         `json:"deletedAt"`
+	IdempotencyKey          string            `json:"idempotencyKey,omitempty"`
+	IdempotencyFingerprint  string            `json:"idempotencyFingerprint,omitempty"`
 }
 
 // FunctionTopic is the topic configurtion for function
 type FunctionTopic struct {
-	TopicFullName    string `json:"topicFullName"`
-	PulsarURL        string `json:"pulsarURL"`
-	Token            string `json:"token"`
-	Tenant           string `json:"tenant"`
-	Key              string `json:"key"`
-	Subscription     string `json:"subscription"`
-	SubscriptionType string `json:"subscriptionType"`
-	KeySharedPolicy  string `json:"keySharedPolicy"`
-	InitialPosition  string `json:"initialPosition"`
+	TopicFullName    string   `json:"topicFullName"`
+	TopicsPattern    string   `json:"topicsPattern"`
+	Topics           []string `json:"topics"`
+	PulsarURL        string   `json:"pulsarURL"`
+	Token            string   `json:"token"`
+	Tenant           string   `json:"tenant"`
+	Key              string   `json:"key"`
+	Subscription     string   `json:"subscription"`
+	SubscriptionType string   `json:"subscriptionType"`
+	KeySharedPolicy  string   `json:"keySharedPolicy"`
+	InitialPosition  string   `json:"initialPosition"`
+	AckMode          string   `json:"ackMode"`
+	SchemaType       string   `json:"schemaType"`
+	SchemaDefinition string   `json:"schemaDefinition"`
 }
 
 // TopicKey represents a struct to identify a topic
@@ -100,6 +158,9 @@ const (
 	NonResumable = "NonResumable"
 )
 
+// Masked replaces the tokens and the secrets of the function configs replied to clients
+const Masked = "***"
+
 // StringToStatus converts status in string to Status type
 func StringToStatus(status string) Status {
 	switch strings.ToLower(status) {
@@ -114,13 +175,75 @@ func StringToStatus(status string) Status {
 	}
 }
 
+// ParseStatus converts status in string to Status type, unlike StringToStatus an unknown status is an error
+func ParseStatus(status string) (Status, error) {
+	for i, name := range StatusNames {
+		if strings.ToLower(status) == name {
+			return Status(i), nil
+		}
+	}
+	return Deactivated, fmt.Errorf("unknown status %s, supported statuses are %s", status, strings.Join(StatusNames, ", "))
+}
+
+// CanTransition checks the status transition is legal per the state machine.
+// Only forward transitions are allowed except a suspended state can be activated again
+// or deactivated to take the function out of service until it is activated.
+func CanTransition(from, to Status) bool {
+	if from == Deleted {
+		return false
+	}
+	return to > from || (from == Suspended && (to == Activated || to == Deactivated))
+}
+
+// Transition moves the function config to the new status if the transition is legal.
+// Transition to the same status is a no-op.
+func Transition(cfg *FunctionConfig, to Status) error {
+	if cfg.FunctionStatus == to {
+		return nil
+	}
+	if !CanTransition(cfg.FunctionStatus, to) {
+		return fmt.Errorf("illegal function status transition from %s to %s", cfg.FunctionStatus, to)
+	}
+	cfg.FunctionStatus = to
+	cfg.UpdatedAt = time.Now()
+	return nil
+}
+
+// StatusNames are the string representation of Status in the order of the state machine
+var StatusNames = []string{"deactivated", "activated", "suspended", "deleted"}
+
+// String returns the string representation of the status
+func (s Status) String() string {
+	if s < 0 || int(s) >= len(StatusNames) {
+		return StatusNames[Deactivated]
+	}
+	return StatusNames[s]
+}
+
+// UnmarshalJSON unmarshals status from either a string or the integer value.
+// Status is persisted as an integer so that replicas on an older release can still read the documents,
+// only the API replies render it as a string
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var str string
+	if err := json.Unmarshal(data, &str); err == nil {
+		*s = StringToStatus(str)
+		return nil
+	}
+	var i int
+	if err := json.Unmarshal(data, &i); err != nil {
+		return fmt.Errorf("invalid status %s", string(data))
+	}
+	*s = Status(i)
+	return nil
+}
+
 // NewTopicConfig creates a topic configuration struct.
 func NewTopicConfig(topicFullName, pulsarURL, token string) (TopicConfig, error) {
 	cfg := TopicConfig{}
 	cfg.TopicFullName = topicFullName
 	cfg.PulsarURL = pulsarURL
 	cfg.Token = token
-	cfg.Webhooks = make([]WebhookConfig, 0, 10) //Good to have a limit to budget threads
+	cfg.Webhooks = make([]WebhookConfig, 0, DefaultMaxWebhooksPerFunction) //Good to have a limit to budget threads
 
 	var err error
 	cfg.Key, err = GetKeyFromNames(topicFullName, pulsarURL)
@@ -136,7 +259,7 @@ func NewTopicConfig(topicFullName, pulsarURL, token string) (TopicConfig, error)
 func NewWebhookConfig(URL string) WebhookConfig {
 	cfg := WebhookConfig{}
 	cfg.URL = URL
-	cfg.Subscription = fmt.Sprintf("%s%s%d", NonResumable, icrypto.GenTopicKey(), time.Now().UnixNano())
+	cfg.Subscription = GenSubscriptionName()
 	cfg.WebhookStatus = Activated
 	cfg.SubscriptionType = "exclusive"
 	cfg.InitialPosition = "latest"
@@ -145,11 +268,55 @@ func NewWebhookConfig(URL string) WebhookConfig {
 	return cfg
 }
 
+// GenSubscriptionName generates a collision resistant non-resumable subscription name
+func GenSubscriptionName() string {
+	return NonResumable + icrypto.GenUUID()
+}
+
+// webhook subscription modes
+const (
+	// SubscriptionNonResumable subscribes with a random name so it never resumes after restart
+	SubscriptionNonResumable = "nonresumable"
+	// SubscriptionResumable subscribes with a name derived from the function and webhook
+	// so a restart resumes from the last acknowledged message
+	SubscriptionResumable = "resumable"
+)
+
+// GetSubscriptionMode validates and normalizes the webhook subscription mode
+func GetSubscriptionMode(mode string) (string, error) {
+	switch strings.ToLower(mode) {
+	case SubscriptionNonResumable, "":
+		return SubscriptionNonResumable, nil
+	case SubscriptionResumable:
+		return SubscriptionResumable, nil
+	default:
+		return "", fmt.Errorf("unsupported subscription mode %s", mode)
+	}
+}
+
+// GenWebhookSubscriptionName generates the subscription name of a function's webhook by the subscription mode
+func GenWebhookSubscriptionName(mode, functionID string, index int) (string, error) {
+	m, err := GetSubscriptionMode(mode)
+	if err != nil {
+		return "", err
+	}
+	if m == SubscriptionResumable {
+		return fmt.Sprintf("%s-webhook-%d", functionID, index), nil
+	}
+	return GenSubscriptionName(), nil
+}
+
 // GetKeyFromNames generate topic key based on topic full name and pulsar url
 func GetKeyFromNames(tenant, functionName string) (string, error) {
 	return GenKey(tenant, functionName), nil
 }
 
+// FunctionKey returns the document key and ID of the function. The key is the tenant
+// followed by the function name, which is the only key scheme of the function documents.
+func FunctionKey(tenant, functionName string) string {
+	return tenant + functionName
+}
+
 // GenKey generates a unique key based on pulsar url and topic full name
 func GenKey(tenant, functionName string) string {
 	h := sha1.New()
@@ -157,6 +324,17 @@ func GenKey(tenant, functionName string) string {
 	return hex.EncodeToString(h.Sum(nil))
 }
 
+// GenETag generates an entity tag of the function config based on its content hash
+func GenETag(cfg FunctionConfig) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	h.Write(data)
+	return "\"" + hex.EncodeToString(h.Sum(nil)) + "\"", nil
+}
+
 // GetInitialPosition returns the initial position for subscription
 func GetInitialPosition(pos string) (pulsar.SubscriptionInitialPosition, error) {
 	switch strings.ToLower(pos) {
@@ -185,36 +363,342 @@ func GetSubscriptionType(subType string) (pulsar.SubscriptionType, error) {
 	}
 }
 
-// ValidateWebhookConfig validates WebhookConfig object
+// topic schema types
+const (
+	SchemaBytes  = "bytes"
+	SchemaString = "string"
+	SchemaJSON   = "json"
+	SchemaAvro   = "avro"
+)
+
+// GetSchemaType validates the schema type and definition and returns the normalized schema type.
+// The Pulsar client in use does not support schemas, so messages are always consumed as bytes
+// and the schema describes how the function decodes the payload.
+func GetSchemaType(schemaType, definition string) (string, error) {
+	t := strings.ToLower(schemaType)
+	switch t {
+	case "":
+		t = SchemaBytes
+	case SchemaBytes, SchemaString, SchemaJSON, SchemaAvro:
+	default:
+		return "", fmt.Errorf("unsupported schema type %s", schemaType)
+	}
+	if definition == "" {
+		if t == SchemaAvro {
+			return "", fmt.Errorf("avro schema requires a schema definition")
+		}
+		return t, nil
+	}
+	if t == SchemaBytes || t == SchemaString {
+		return "", fmt.Errorf("%s schema does not take a schema definition", t)
+	}
+	if !json.Valid([]byte(definition)) {
+		return "", fmt.Errorf("%s schema definition must be a JSON document", t)
+	}
+	return t, nil
+}
+
+// acknowledgment modes
+const (
+	// AckIndividual acknowledges every message individually
+	AckIndividual = "individual"
+	// AckCumulative acknowledges all messages up to and including the message
+	AckCumulative = "cumulative"
+)
+
+// GetAckMode validates and normalizes the acknowledgment mode of the subscription type.
+// Cumulative acknowledgment is invalid with shared and key shared subscriptions.
+func GetAckMode(mode, subType string) (string, error) {
+	switch strings.ToLower(mode) {
+	case AckIndividual, "":
+		return AckIndividual, nil
+	case AckCumulative:
+		t, err := GetSubscriptionType(subType)
+		if err != nil {
+			return "", err
+		}
+		if t == pulsar.Shared || t == pulsar.KeyShared {
+			return "", fmt.Errorf("cumulative acknowledgment is not supported by %s subscription", subType)
+		}
+		return AckCumulative, nil
+	default:
+		return "", fmt.Errorf("unsupported acknowledgment mode %s", mode)
+	}
+}
+
+// output message key modes, any other value is the name of the input message property used as the key
+const (
+	// OutputKeyInherit reuses the input message key
+	OutputKeyInherit = "inherit"
+	// OutputKeyNone produces the output message without a key
+	OutputKeyNone = "none"
+)
+
+// ValidateOutputKeyMode validates the output message key mode
+func ValidateOutputKeyMode(mode string) error {
+	if strings.TrimSpace(mode) != mode || strings.ContainsAny(mode, " \t\n") {
+		return fmt.Errorf("invalid output key mode %s", mode)
+	}
+	return nil
+}
+
+// OutputKey returns the output message key by the key mode from the input message key and properties
+func OutputKey(mode, inputKey string, properties map[string]string) string {
+	switch mode {
+	case OutputKeyInherit:
+		return inputKey
+	case OutputKeyNone, "":
+		return ""
+	default:
+		return properties[mode]
+	}
+}
+
+// output message sequence id strategies of the deduplication
+const (
+	// SequenceIDMessageID derives the sequence id from the input message ledger and entry id
+	SequenceIDMessageID = "messageid"
+	// SequenceIDPublishTime derives the sequence id from the input message publish time in milliseconds
+	SequenceIDPublishTime = "publishtime"
+)
+
+// GetSequenceIDStrategy validates and normalizes the output sequence id strategy, the default is the message id
+func GetSequenceIDStrategy(strategy string) (string, error) {
+	switch strings.ToLower(strategy) {
+	case SequenceIDMessageID, "":
+		return SequenceIDMessageID, nil
+	case SequenceIDPublishTime:
+		return SequenceIDPublishTime, nil
+	default:
+		return "", fmt.Errorf("unsupported sequence id strategy %s", strategy)
+	}
+}
+
+// MessageRoutingMode is the partition routing of the output messages, keyed messages are always routed by the key hash
+type MessageRoutingMode int
+
+const (
+	// RoundRobinPartition spreads the messages without a key across all partitions
+	RoundRobinPartition MessageRoutingMode = iota
+	// SinglePartition sends the messages without a key to one randomly chosen partition
+	SinglePartition
+	// CustomPartition routes every message by the output key chosen by the output key mode,
+	// messages without a key are sent to the first partition
+	CustomPartition
+)
+
+// GetMessageRoutingMode converts string based message routing mode to MessageRoutingMode, the default is round robin
+func GetMessageRoutingMode(mode string) (MessageRoutingMode, error) {
+	switch strings.ToLower(mode) {
+	case "roundrobin", "":
+		return RoundRobinPartition, nil
+	case "singlepartition":
+		return SinglePartition, nil
+	case "custompartition":
+		return CustomPartition, nil
+	default:
+		return -1, fmt.Errorf("unsupported message routing mode %s", mode)
+	}
+}
+
+// GetCompressionType converts string based compression type to Pulsar compression type, the default is LZ4
+func GetCompressionType(compression string) (pulsar.CompressionType, error) {
+	switch strings.ToLower(compression) {
+	case "lz4", "":
+		return pulsar.LZ4, nil
+	case "none":
+		return pulsar.NoCompression, nil
+	case "zlib":
+		return pulsar.ZLib, nil
+	case "zstd":
+		return pulsar.ZSTD, nil
+	default:
+		return -1, fmt.Errorf("unsupported compression type %s", compression)
+	}
+}
+
+// webhook payload modes
+const (
+	// PayloadRaw delivers the message payload as is
+	PayloadRaw = "raw"
+	// PayloadEnvelope wraps the message payload with the message metadata
+	PayloadEnvelope = "envelope"
+)
+
+// GetPayloadMode validates and normalizes the webhook payload mode
+func GetPayloadMode(mode string) (string, error) {
+	switch strings.ToLower(mode) {
+	case PayloadRaw, "":
+		return PayloadRaw, nil
+	case PayloadEnvelope:
+		return PayloadEnvelope, nil
+	default:
+		return "", fmt.Errorf("unsupported payload mode %s", mode)
+	}
+}
+
+// webhook delivery guarantees
+const (
+	// AtLeastOnce acknowledges a message only after a successful delivery
+	AtLeastOnce = "at-least-once"
+	// AtMostOnce acknowledges a message on receive regardless of the delivery result
+	AtMostOnce = "at-most-once"
+)
+
+// GetDeliveryGuarantee validates and normalizes the webhook delivery guarantee
+func GetDeliveryGuarantee(guarantee string) (string, error) {
+	switch strings.ToLower(guarantee) {
+	case AtLeastOnce, "":
+		return AtLeastOnce, nil
+	case AtMostOnce:
+		return AtMostOnce, nil
+	default:
+		return "", fmt.Errorf("unsupported delivery guarantee %s", guarantee)
+	}
+}
+
+// MaxWebhookBatchSize is the maximum number of messages in a webhook batch delivery
+const MaxWebhookBatchSize = 1000
+
+// DefaultMaxWebhooksPerFunction is the default maximum number of webhooks of a function
+const DefaultMaxWebhooksPerFunction = 10
+
+// ValidateWebhookConfig validates WebhookConfig object, all the problems are reported in a ValidationError
 // I'd write explicit validation code rather than any off the shelf library,
 // which are just DSL and sometime these library just like fit square peg in a round hole.
 // Explicit validation has no dependency and very specific.
 func ValidateWebhookConfig(whs []WebhookConfig) error {
+	verr := &ValidationError{}
 	// keeps track of exclusive subscription name
 	exclusiveSubs := make(map[string]bool)
-	for _, wh := range whs {
+	maxWebhooks := util.ConfigInt(util.GetConfig().MaxWebhooksPerFunction, DefaultMaxWebhooksPerFunction)
+	count := 0
+	for i, wh := range whs {
+		if wh.WebhookStatus == Deleted {
+			// soft deleted webhooks are retained for record only
+			continue
+		}
+		if count++; maxWebhooks > 0 && count == maxWebhooks+1 {
+			verr.Addf("webhooks", "a function can have at most %d webhooks", maxWebhooks)
+		}
+		field := func(name string) string { return fmt.Sprintf("webhooks[%d].%s", i, name) }
 		if !IsURL(wh.URL) {
-			return fmt.Errorf("not a URL %s", wh.URL)
+			verr.Addf(field("url"), "not a URL %s", wh.URL)
 		}
 		if strings.TrimSpace(wh.Subscription) == "" {
-			return fmt.Errorf("subscription name is missing")
+			verr.Addf(field("subscription"), "subscription name is missing")
 		}
 		if subType, err := GetSubscriptionType(wh.SubscriptionType); err == nil {
 			if subType == pulsar.Exclusive {
 				if exclusiveSubs[wh.Subscription] {
-					return fmt.Errorf("exclusive subscription %s cannot be shared between multiple webhooks", wh.Subscription)
+					verr.Addf(field("subscription"), "exclusive subscription %s cannot be shared between multiple webhooks", wh.Subscription)
 				}
 				exclusiveSubs[wh.Subscription] = true
 			}
 		} else {
-			return err
+			verr.Add(field("subscriptionType"), err)
 		}
 		if _, err := GetInitialPosition(wh.InitialPosition); err != nil {
-			return err
+			verr.Add(field("initialPosition"), err)
+		}
+		if wh.Signed && strings.TrimSpace(wh.Secret) == "" {
+			verr.Addf(field("secret"), "secret is required to sign webhook %s payload", wh.URL)
+		}
+		if wh.TimeoutMs < 0 {
+			verr.Addf(field("timeoutMs"), "webhook timeout must not be negative")
+		}
+		for _, v := range append([]string{wh.BasicAuthPass}, wh.Headers...) {
+			for _, name := range util.SecretRefNames(v) {
+				if !util.IsSecretEnvName(name) {
+					verr.Addf(field("headers"), "variable %s is not prefixed with %s", name, util.SecretEnvPrefix)
+				}
+			}
+		}
+		if (wh.BasicAuthUser == "") != (wh.BasicAuthPass == "") {
+			verr.Addf(field("basicAuthUser"), "basic auth user and password of webhook %s must be specified together", wh.URL)
+		}
+		if (wh.ClientCertPath == "") != (wh.ClientKeyPath == "") {
+			verr.Addf(field("clientCertPath"), "client certificate and key of webhook %s must be specified together", wh.URL)
+		} else if wh.ClientCertPath != "" {
+			if _, err := tls.LoadX509KeyPair(wh.ClientCertPath, wh.ClientKeyPath); err != nil {
+				verr.Addf(field("clientCertPath"), "failed to load client certificate of webhook %s %v", wh.URL, err)
+			}
+		}
+		if wh.MaxPayloadBytes < 0 {
+			verr.Addf(field("maxPayloadBytes"), "webhook max payload bytes must be non-negative, 0 is unlimited")
+		}
+		if _, err := GetSubscriptionMode(wh.SubscriptionMode); err != nil {
+			verr.Add(field("subscriptionMode"), err)
+		}
+		if _, err := GetPayloadMode(wh.PayloadMode); err != nil {
+			verr.Add(field("payloadMode"), err)
+		}
+		if _, err := GetDeliveryGuarantee(wh.DeliveryGuarantee); err != nil {
+			verr.Add(field("deliveryGuarantee"), err)
+		}
+		if wh.Filter.PropertyKey == "" && wh.Filter.PropertyValue != "" {
+			verr.Addf(field("filter.propertyKey"), "webhook filter property key is missing")
+		}
+		if wh.BatchSize < 0 || wh.BatchSize > MaxWebhookBatchSize {
+			verr.Addf(field("batchSize"), "webhook batch size must be between 0 and %d", MaxWebhookBatchSize)
+		}
+		if wh.BatchTimeoutMs < 0 || (wh.BatchSize > 1 && wh.BatchTimeoutMs == 0) {
+			verr.Addf(field("batchTimeoutMs"), "webhook batch timeout must be positive when batching is enabled")
 		}
 	}
-	return nil
+	return verr.Err()
+}
+
+// MaxDeliveries returns the maximum number of concurrent webhook deliveries of the function,
+// it defaults to the parallelism
+func MaxDeliveries(cfg *FunctionConfig) int {
+	if cfg.MaxConcurrentDeliveries > 0 {
+		return cfg.MaxConcurrentDeliveries
+	}
+	if cfg.Parallelism > 0 {
+		return cfg.Parallelism
+	}
+	return 1
+}
 
+// ActiveWebhooks returns the webhooks eligible for delivery
+func ActiveWebhooks(whs []WebhookConfig) []WebhookConfig {
+	active := []WebhookConfig{}
+	for _, wh := range whs {
+		if wh.WebhookStatus == Activated {
+			active = append(active, wh)
+		}
+	}
+	return active
+}
+
+// MigrateWebhookURLs converts legacy webhook URLs into structured webhooks.
+// The converted webhooks share the function input topic subscription so that
+// the migration is deterministic across loads. It returns true if any webhook is added.
+func MigrateWebhookURLs(cfg *FunctionConfig) bool {
+	existing := make(map[string]bool)
+	for _, wh := range cfg.Webhooks {
+		existing[wh.URL] = true
+	}
+
+	migrated := false
+	for _, url := range cfg.WebhookURLs {
+		if existing[url] {
+			continue
+		}
+		existing[url] = true
+		cfg.Webhooks = append(cfg.Webhooks, WebhookConfig{
+			URL:              url,
+			Subscription:     util.AssignString(cfg.InputTopic.Subscription, cfg.ID+"-webhook"),
+			SubscriptionType: cfg.InputTopic.SubscriptionType,
+			InitialPosition:  cfg.InputTopic.InitialPosition,
+			WebhookStatus:    Activated,
+			CreatedAt:        cfg.CreatedAt,
+			UpdatedAt:        cfg.UpdatedAt,
+		})
+		migrated = true
+	}
+	return migrated
 }
 
 // ValidateTopicConfig validates the TopicConfig and returns the key to identify this topic
@@ -226,6 +710,24 @@ func ValidateTopicConfig(top TopicConfig) (string, error) {
 	return GetKeyFromNames(top.TopicFullName, top.PulsarURL)
 }
 
+// ValidateTopicName validates a topic full name in the form of {persistent|non-persistent}://tenant/namespace/topic
+func ValidateTopicName(name string) error {
+	parts := strings.SplitN(name, "://", 2)
+	if len(parts) != 2 || (parts[0] != "persistent" && parts[0] != "non-persistent") {
+		return fmt.Errorf("topic name %s must start with persistent:// or non-persistent://", name)
+	}
+	names := strings.Split(parts[1], "/")
+	if len(names) != 3 {
+		return fmt.Errorf("topic name %s must be in the form of tenant/namespace/topic", name)
+	}
+	for _, n := range names {
+		if strings.TrimSpace(n) == "" || strings.ContainsAny(n, " \t\n") {
+			return fmt.Errorf("malformed topic name %s", name)
+		}
+	}
+	return nil
+}
+
 // IsURL evaluates if this is PulsarURL
 func IsURL(str string) bool {
 	u, err := url.Parse(str)
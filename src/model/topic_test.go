@@ -0,0 +1,400 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func validWebhook() WebhookConfig {
+	return WebhookConfig{
+		URL:           "https://example.com/hook",
+		Subscription:  "sub",
+		WebhookStatus: Activated,
+	}
+}
+
+func TestValidateWebhookSecretRefs(t *testing.T) {
+	wh := validWebhook()
+	wh.Headers = []string{"Authorization: Bearer ${PUBSUBFN_SECRET_TOKEN}", "X-Price: $${DbPassword}"}
+	if err := ValidateWebhookConfig([]WebhookConfig{wh}); err != nil {
+		t.Fatalf("secret references must be accepted %v", err)
+	}
+
+	wh.Headers = []string{"X-Leak: ${DbPassword}"}
+	wh.BasicAuthUser, wh.BasicAuthPass = "user", "${PulsarPrivateKey}"
+	err := ValidateWebhookConfig([]WebhookConfig{wh})
+	verr, ok := err.(*ValidationError)
+	if !ok || len(verr.Errors) != 2 {
+		t.Fatalf("expected two validation errors, got %v", err)
+	}
+	for _, e := range verr.Errors {
+		if e.Field != "webhooks[0].headers" || !strings.Contains(e.Message, "PUBSUBFN_SECRET_") {
+			t.Errorf("unexpected validation error %+v", e)
+		}
+	}
+}
+
+// validationFields returns the invalid fields of a validation error
+func validationFields(err error) []string {
+	fields := []string{}
+	if verr, ok := err.(*ValidationError); ok {
+		for _, e := range verr.Errors {
+			fields = append(fields, e.Field)
+		}
+	}
+	return fields
+}
+
+func TestValidateWebhookConfig(t *testing.T) {
+	cases := []struct {
+		name   string
+		update func(wh *WebhookConfig)
+		fields []string
+	}{
+		{"valid", func(wh *WebhookConfig) {}, []string{}},
+		{"unlimited payload", func(wh *WebhookConfig) { wh.MaxPayloadBytes = 0 }, []string{}},
+		{"max payload bytes", func(wh *WebhookConfig) { wh.MaxPayloadBytes = 1024 }, []string{}},
+		{"default timeout", func(wh *WebhookConfig) { wh.TimeoutMs = 0 }, []string{}},
+		{"timeout", func(wh *WebhookConfig) { wh.TimeoutMs = 1 }, []string{}},
+		{"negative timeout", func(wh *WebhookConfig) { wh.TimeoutMs = -1 }, []string{"webhooks[0].timeoutMs"}},
+		{"negative max payload bytes", func(wh *WebhookConfig) { wh.MaxPayloadBytes = -1 }, []string{"webhooks[0].maxPayloadBytes"}},
+		{"basic auth", func(wh *WebhookConfig) { wh.BasicAuthUser, wh.BasicAuthPass = "alice", "${PUBSUBFN_SECRET_PASS}" }, []string{}},
+		{"basic auth user only", func(wh *WebhookConfig) { wh.BasicAuthUser = "alice" }, []string{"webhooks[0].basicAuthUser"}},
+		{"basic auth password only", func(wh *WebhookConfig) { wh.BasicAuthPass = "secret" }, []string{"webhooks[0].basicAuthUser"}},
+		{"client certificate without key", func(wh *WebhookConfig) { wh.ClientCertPath = "/certs/client.crt" }, []string{"webhooks[0].clientCertPath"}},
+		{"client key without certificate", func(wh *WebhookConfig) { wh.ClientKeyPath = "/certs/client.key" }, []string{"webhooks[0].clientCertPath"}},
+		{"unloadable client certificate", func(wh *WebhookConfig) { wh.ClientCertPath, wh.ClientKeyPath = "/nonexistent.crt", "/nonexistent.key" },
+			[]string{"webhooks[0].clientCertPath"}},
+	}
+	for _, c := range cases {
+		wh := validWebhook()
+		c.update(&wh)
+		err := ValidateWebhookConfig([]WebhookConfig{wh})
+		if fields := validationFields(err); strings.Join(fields, ",") != strings.Join(c.fields, ",") {
+			t.Errorf("%s: expected invalid fields %v, got %v", c.name, c.fields, err)
+		}
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	for i, name := range StatusNames {
+		if status, err := ParseStatus(strings.ToUpper(name)); err != nil || status != Status(i) {
+			t.Errorf("%s: expected %d, got %d error %v", name, i, status, err)
+		}
+	}
+	if _, err := ParseStatus("bogus"); err == nil {
+		t.Error("an unknown status must be rejected")
+	}
+}
+
+func TestStatusJSON(t *testing.T) {
+	data, err := json.Marshal(FunctionConfig{FunctionStatus: Suspended})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"functionStatus":2`) {
+		t.Errorf("status must be persisted as an integer, got %s", data)
+	}
+
+	cases := []struct {
+		input   string
+		status  Status
+		invalid bool
+	}{
+		{`2`, Suspended, false},
+		{`"suspended"`, Suspended, false},
+		{`"Activated"`, Activated, false},
+		{`true`, Deactivated, true},
+		{`{}`, Deactivated, true},
+	}
+	for _, c := range cases {
+		var status Status
+		err := json.Unmarshal([]byte(c.input), &status)
+		if c.invalid != (err != nil) {
+			t.Errorf("%s: unexpected error %v", c.input, err)
+		}
+		if !c.invalid && status != c.status {
+			t.Errorf("%s: expected %s, got %s", c.input, c.status, status)
+		}
+	}
+}
+
+func TestFunctionConfigSchema(t *testing.T) {
+	schema := FunctionConfigSchema()
+	properties := schema["properties"].(map[string]interface{})
+	cases := []struct {
+		property   string
+		schemaType string
+	}{
+		{"name", "string"},
+		{"tenant", "string"},
+		{"functionStatus", "string"},
+		{"inputTopics", "object"},
+		{"webhooks", "array"},
+	}
+	for _, c := range cases {
+		property, ok := properties[c.property].(map[string]interface{})
+		if !ok {
+			t.Errorf("missing property %s", c.property)
+			continue
+		}
+		if property["type"] != c.schemaType {
+			t.Errorf("%s: expected type %s, got %v", c.property, c.schemaType, property["type"])
+		}
+	}
+	status := properties["functionStatus"].(map[string]interface{})
+	if enum := status["enum"].([]interface{}); len(enum) != len(StatusNames) {
+		t.Errorf("expected the status names as enum, got %v", enum)
+	}
+	if required := schema["required"].([]string); len(required) != 2 {
+		t.Errorf("unexpected required properties %v", required)
+	}
+}
+
+func TestMigrateWebhookURLs(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      FunctionConfig
+		migrated bool
+		webhooks []string
+	}{
+		{"no legacy urls", FunctionConfig{ID: "t1f1"}, false, []string{}},
+		{
+			"legacy urls",
+			FunctionConfig{ID: "t1f1", WebhookURLs: []string{"http://a", "http://b", "http://a"}},
+			true, []string{"http://a", "http://b"},
+		},
+		{
+			"already migrated",
+			FunctionConfig{ID: "t1f1", WebhookURLs: []string{"http://a"}, Webhooks: []WebhookConfig{{URL: "http://a"}}},
+			false, []string{"http://a"},
+		},
+	}
+	for _, c := range cases {
+		if migrated := MigrateWebhookURLs(&c.cfg); migrated != c.migrated {
+			t.Errorf("%s: expected migrated %v", c.name, c.migrated)
+		}
+		if len(c.cfg.Webhooks) != len(c.webhooks) {
+			t.Errorf("%s: expected webhooks %v, got %+v", c.name, c.webhooks, c.cfg.Webhooks)
+			continue
+		}
+		for i, url := range c.webhooks {
+			wh := c.cfg.Webhooks[i]
+			if wh.URL != url {
+				t.Errorf("%s: expected webhook %s, got %s", c.name, url, wh.URL)
+			}
+			if c.migrated && (wh.Subscription != "t1f1-webhook" || wh.WebhookStatus != Activated) {
+				t.Errorf("%s: unexpected migrated webhook %+v", c.name, wh)
+			}
+		}
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	// legal[from][to] lists every pair of the state machine
+	legal := [][]bool{
+		Deactivated: {Deactivated: false, Activated: true, Suspended: true, Deleted: true},
+		Activated:   {Deactivated: false, Activated: false, Suspended: true, Deleted: true},
+		Suspended:   {Deactivated: true, Activated: true, Suspended: false, Deleted: true},
+		Deleted:     {Deactivated: false, Activated: false, Suspended: false, Deleted: false},
+	}
+	for from, tos := range legal {
+		for to, want := range tos {
+			if got := CanTransition(Status(from), Status(to)); got != want {
+				t.Errorf("%s to %s: expected %v, got %v", Status(from), Status(to), want, got)
+			}
+			cfg := FunctionConfig{FunctionStatus: Status(from)}
+			err := Transition(&cfg, Status(to))
+			if from == to || want {
+				if err != nil || cfg.FunctionStatus != Status(to) {
+					t.Errorf("transition %s to %s: unexpected error %v", Status(from), Status(to), err)
+				}
+			} else if err == nil || cfg.FunctionStatus != Status(from) {
+				t.Errorf("transition %s to %s must be rejected", Status(from), Status(to))
+			}
+		}
+	}
+}
+
+func TestMaxWebhooksPerFunction(t *testing.T) {
+	defer func(max string) { util.Config.MaxWebhooksPerFunction = max }(util.Config.MaxWebhooksPerFunction)
+	webhooks := func(active, deleted int) []WebhookConfig {
+		whs := []WebhookConfig{}
+		for i := 0; i < active+deleted; i++ {
+			wh := validWebhook()
+			wh.Subscription = fmt.Sprintf("sub-%d", i)
+			if i >= active {
+				wh.WebhookStatus = Deleted
+			}
+			whs = append(whs, wh)
+		}
+		return whs
+	}
+	cases := []struct {
+		name    string
+		max     string
+		whs     []WebhookConfig
+		invalid bool
+	}{
+		{"default limit", "", webhooks(DefaultMaxWebhooksPerFunction, 0), false},
+		{"over the default limit", "", webhooks(DefaultMaxWebhooksPerFunction+1, 0), true},
+		{"configured limit", "2", webhooks(2, 0), false},
+		{"over the configured limit", "2", webhooks(3, 0), true},
+		{"deleted webhooks are not counted", "2", webhooks(2, 3), false},
+		{"unlimited", "0", webhooks(DefaultMaxWebhooksPerFunction+5, 0), false},
+	}
+	for _, c := range cases {
+		util.Config.MaxWebhooksPerFunction = c.max
+		err := ValidateWebhookConfig(c.whs)
+		if c.invalid != (err != nil) {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+}
+
+func TestGenSubscriptionName(t *testing.T) {
+	seen := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		name := GenSubscriptionName()
+		if !strings.HasPrefix(name, NonResumable) || seen[name] {
+			t.Fatalf("unexpected or duplicated subscription name %s", name)
+		}
+		seen[name] = true
+	}
+}
+
+func TestGenWebhookSubscriptionName(t *testing.T) {
+	cases := []struct {
+		mode    string
+		prefix  string
+		invalid bool
+	}{
+		{"", NonResumable, false},
+		{"NonResumable", NonResumable, false},
+		{"resumable", "t1f1-webhook-2", false},
+		{"durable", "", true},
+	}
+	for _, c := range cases {
+		name, err := GenWebhookSubscriptionName(c.mode, "t1f1", 2)
+		if c.invalid != (err != nil) || !strings.HasPrefix(name, c.prefix) {
+			t.Errorf("%q: unexpected name %s error %v", c.mode, name, err)
+		}
+	}
+	first, _ := GenWebhookSubscriptionName(SubscriptionResumable, "t1f1", 0)
+	second, _ := GenWebhookSubscriptionName(SubscriptionResumable, "t1f1", 0)
+	if first != second {
+		t.Errorf("a resumable subscription name must be stable, got %s and %s", first, second)
+	}
+}
+
+func TestGetAckMode(t *testing.T) {
+	cases := []struct {
+		mode    string
+		subType string
+		want    string
+		invalid bool
+	}{
+		{"", "shared", AckIndividual, false},
+		{"individual", "keyshared", AckIndividual, false},
+		{"cumulative", "exclusive", AckCumulative, false},
+		{"Cumulative", "failover", AckCumulative, false},
+		{"cumulative", "shared", "", true},
+		{"cumulative", "keyshared", "", true},
+		{"cumulative", "bogus", "", true},
+		{"batch", "exclusive", "", true},
+	}
+	for _, c := range cases {
+		mode, err := GetAckMode(c.mode, c.subType)
+		if c.invalid != (err != nil) || mode != c.want {
+			t.Errorf("%q on %s: expected %q, got %q error %v", c.mode, c.subType, c.want, mode, err)
+		}
+	}
+}
+
+func TestGetSchemaType(t *testing.T) {
+	cases := []struct {
+		schemaType string
+		definition string
+		want       string
+		valid      bool
+	}{
+		{"", "", SchemaBytes, true},
+		{"String", "", SchemaString, true},
+		{"json", "", SchemaJSON, true},
+		{"json", `{"type":"record"}`, SchemaJSON, true},
+		{"AVRO", `{"type":"record","name":"e","fields":[]}`, SchemaAvro, true},
+		{"avro", "", "", false},
+		{"avro", "not json", "", false},
+		{"bytes", `{"type":"record"}`, "", false},
+		{"string", `{}`, "", false},
+		{"protobuf", "", "", false},
+	}
+	for _, c := range cases {
+		got, err := GetSchemaType(c.schemaType, c.definition)
+		if (err == nil) != c.valid || got != c.want {
+			t.Errorf("%q %q: expected %q valid %v, got %q %v", c.schemaType, c.definition, c.want, c.valid, got, err)
+		}
+	}
+}
+
+func TestGetCompressionType(t *testing.T) {
+	cases := []struct {
+		compression string
+		want        pulsar.CompressionType
+		valid       bool
+	}{
+		{"", pulsar.LZ4, true},
+		{"LZ4", pulsar.LZ4, true},
+		{"none", pulsar.NoCompression, true},
+		{"zlib", pulsar.ZLib, true},
+		{"Zstd", pulsar.ZSTD, true},
+		{"snappy", -1, false},
+	}
+	for _, c := range cases {
+		got, err := GetCompressionType(c.compression)
+		if (err == nil) != c.valid || got != c.want {
+			t.Errorf("%q: expected %v valid %v, got %v %v", c.compression, c.want, c.valid, got, err)
+		}
+	}
+}
+
+func TestGetMessageRoutingMode(t *testing.T) {
+	cases := []struct {
+		mode  string
+		want  MessageRoutingMode
+		valid bool
+	}{
+		{"", RoundRobinPartition, true},
+		{"RoundRobin", RoundRobinPartition, true},
+		{"singlepartition", SinglePartition, true},
+		{"CustomPartition", CustomPartition, true},
+		{"round-robin", -1, false},
+	}
+	for _, c := range cases {
+		got, err := GetMessageRoutingMode(c.mode)
+		if (err == nil) != c.valid || got != c.want {
+			t.Errorf("%q: expected %v valid %v, got %v %v", c.mode, c.want, c.valid, got, err)
+		}
+	}
+}
+
+func TestFunctionKey(t *testing.T) {
+	cases := []struct {
+		tenant, name string
+		key          string
+	}{
+		{"t1", "f1", "t1f1"},
+		{"tenant", "", "tenant"},
+		{"", "f1", "f1"},
+	}
+	for _, c := range cases {
+		if key := FunctionKey(c.tenant, c.name); key != c.key {
+			t.Errorf("%s %s: expected key %s, got %s", c.tenant, c.name, c.key, key)
+		}
+	}
+}
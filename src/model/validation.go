@@ -0,0 +1,63 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// FieldError is a validation problem of a config field
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists all the validation problems of a config
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, fe := range e.Errors {
+		msgs = append(msgs, fe.Field+": "+fe.Message)
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add records a validation problem of the field, a nested ValidationError is flattened under the field
+func (e *ValidationError) Add(field string, err error) {
+	if err == nil {
+		return
+	}
+	if nested, ok := err.(*ValidationError); ok {
+		for _, fe := range nested.Errors {
+			e.Errors = append(e.Errors, FieldError{Field: joinField(field, fe.Field), Message: fe.Message})
+		}
+		return
+	}
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: err.Error()})
+}
+
+// Addf records a formatted validation problem of the field
+func (e *ValidationError) Addf(field, format string, args ...interface{}) {
+	e.Errors = append(e.Errors, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns the validation error, or nil if there is no problem
+func (e *ValidationError) Err() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
+
+func joinField(parent, field string) string {
+	if parent == "" {
+		return field
+	}
+	if field == "" {
+		return parent
+	}
+	return parent + "." + field
+}
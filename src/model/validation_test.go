@@ -0,0 +1,45 @@
+package model
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestValidationError(t *testing.T) {
+	verr := &ValidationError{}
+	if verr.Err() != nil {
+		t.Fatal("expected no error without problems")
+	}
+	nested := &ValidationError{}
+	nested.Addf("url", "not a URL %s", "x")
+	nested.Add("", errors.New("missing"))
+
+	verr.Add("name", nil)
+	verr.Add("name", errors.New("missing"))
+	verr.Addf("", "top level %d", 1)
+	verr.Add("webhooks[0]", nested)
+	verr.Add("", nested)
+
+	expected := []FieldError{
+		{"name", "missing"},
+		{"", "top level 1"},
+		{"webhooks[0].url", "not a URL x"},
+		{"webhooks[0]", "missing"},
+		{"url", "not a URL x"},
+		{"", "missing"},
+	}
+	if len(verr.Errors) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), verr.Errors)
+	}
+	for i, fe := range expected {
+		if verr.Errors[i] != fe {
+			t.Errorf("problem %d: expected %v, got %v", i, fe, verr.Errors[i])
+		}
+	}
+	if verr.Err() != verr {
+		t.Error("expected the validation error itself")
+	}
+	if msg := verr.Error(); msg != "name: missing; : top level 1; webhooks[0].url: not a URL x; webhooks[0]: missing; url: not a URL x; : missing" {
+		t.Errorf("unexpected message %s", msg)
+	}
+}
@@ -0,0 +1,91 @@
+package pulsardriver
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// function log levels
+const (
+	LogLevelInfo  = "info"
+	LogLevelWarn  = "warn"
+	LogLevelError = "error"
+)
+
+// LogEvent is a structured function log event published to the log topic
+type LogEvent struct {
+	FunctionID string           `json:"functionId"`
+	Level      string           `json:"level"`
+	Message    string           `json:"message"`
+	Timestamp  time.Time        `json:"timestamp"`
+	Response   *WebhookResponse `json:"response,omitempty"`
+}
+
+// WebhookResponse is the captured reply of a webhook delivery attempt
+type WebhookResponse struct {
+	URL        string            `json:"url"`
+	StatusCode int               `json:"statusCode"`
+	Headers    map[string]string `json:"headers,omitempty"`
+	Body       string            `json:"body"`
+	Truncated  bool              `json:"truncated"`
+	LatencyMs  int64             `json:"latencyMs"`
+	Error      string            `json:"error,omitempty"`
+}
+
+// LogPublisher publishes function log events to the function's log topic
+type LogPublisher struct {
+	functionID string
+	topic      model.FunctionTopic
+}
+
+// NewLogPublisher creates a log publisher for the function
+func NewLogPublisher(cfg model.FunctionConfig) *LogPublisher {
+	return &LogPublisher{
+		functionID: cfg.ID,
+		topic:      cfg.LogTopic,
+	}
+}
+
+// Publish sends a log event keyed by the function ID asynchronously.
+// It is a no-op if the function has no log topic configured.
+func (l *LogPublisher) Publish(level, message string) error {
+	return l.publish(LogEvent{Level: level, Message: message})
+}
+
+// PublishResponse sends a log event of the webhook reply
+func (l *LogPublisher) PublishResponse(level, message string, res WebhookResponse) error {
+	return l.publish(LogEvent{Level: level, Message: message, Response: &res})
+}
+
+func (l *LogPublisher) publish(event LogEvent) error {
+	if l == nil || l.topic.TopicFullName == "" {
+		return nil
+	}
+
+	p, err := GetPulsarProducer(TopicClusterURL(l.topic), l.topic.Token, l.topic.TopicFullName)
+	if err != nil {
+		return err
+	}
+	event.FunctionID = l.functionID
+	event.Timestamp = time.Now()
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	p.SendAsync(context.Background(), &pulsar.ProducerMessage{
+		Payload:   data,
+		Key:       l.functionID,
+		EventTime: time.Now(),
+	}, func(messageID pulsar.MessageID, msg *pulsar.ProducerMessage, err error) {
+		if err != nil {
+			log.Warnf("send function %s log to topic %s err %v", l.functionID, l.topic.TopicFullName, err)
+		}
+	})
+	return nil
+}
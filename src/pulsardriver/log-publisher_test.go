@@ -0,0 +1,25 @@
+package pulsardriver
+
+import (
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestLogPublisherWithoutLogTopic(t *testing.T) {
+	cases := []struct {
+		name      string
+		publisher *LogPublisher
+	}{
+		{"nil publisher", nil},
+		{"no log topic", NewLogPublisher(model.FunctionConfig{ID: "t1f1"})},
+	}
+	for _, c := range cases {
+		if err := c.publisher.Publish(LogLevelInfo, "message"); err != nil {
+			t.Errorf("%s: publish must be a no-op, got %v", c.name, err)
+		}
+		if err := c.publisher.PublishResponse(LogLevelWarn, "message", WebhookResponse{}); err != nil {
+			t.Errorf("%s: publish must be a no-op, got %v", c.name, err)
+		}
+	}
+}
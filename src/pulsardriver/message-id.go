@@ -0,0 +1,57 @@
+package pulsardriver
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// MessageIDData is the position of a message in the topic
+type MessageIDData struct {
+	LedgerID   int64
+	EntryID    int64
+	Partition  int32
+	BatchIndex int32
+}
+
+// entryBits is the number of bits of the entry id in the message offset, the same as Pulsar Functions
+const entryBits = 28
+
+// Offset returns a monotonic offset of the message in the partition
+func (id MessageIDData) Offset() int64 {
+	return id.LedgerID<<entryBits | id.EntryID
+}
+
+// ParseMessageID decodes the message id since the client does not expose its ledger and entry id.
+// The serialized message id is the MessageIdData protobuf whose fields are all varints.
+func ParseMessageID(msgID pulsar.MessageID) (MessageIDData, error) {
+	data := msgID.Serialize()
+	id := MessageIDData{Partition: -1, BatchIndex: -1}
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return id, fmt.Errorf("malformed message id")
+		}
+		data = data[n:]
+		if tag&0x7 != 0 {
+			return id, fmt.Errorf("unexpected wire type %d of message id field %d", tag&0x7, tag>>3)
+		}
+		v, n := binary.Uvarint(data)
+		if n <= 0 {
+			return id, fmt.Errorf("malformed message id")
+		}
+		data = data[n:]
+		switch tag >> 3 {
+		case 1:
+			id.LedgerID = int64(v)
+		case 2:
+			id.EntryID = int64(v)
+		case 3:
+			id.Partition = int32(v)
+		case 4:
+			id.BatchIndex = int32(v)
+		}
+	}
+	return id, nil
+}
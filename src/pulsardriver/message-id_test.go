@@ -0,0 +1,60 @@
+package pulsardriver
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// rawMessageID is a message id of serialized protobuf fields
+type rawMessageID []byte
+
+func (id rawMessageID) Serialize() []byte { return id }
+
+// encodeMessageID serializes the varint fields numbered from 1 in order
+func encodeMessageID(values ...uint64) rawMessageID {
+	data := []byte{}
+	buf := make([]byte, binary.MaxVarintLen64)
+	for i, v := range values {
+		data = append(data, buf[:binary.PutUvarint(buf, uint64(i+1)<<3)]...)
+		data = append(data, buf[:binary.PutUvarint(buf, v)]...)
+	}
+	return data
+}
+
+func TestParseMessageID(t *testing.T) {
+	cases := []struct {
+		name     string
+		id       pulsar.MessageID
+		expected MessageIDData
+		fails    bool
+	}{
+		{"ledger and entry", encodeMessageID(12, 34), MessageIDData{LedgerID: 12, EntryID: 34, Partition: -1, BatchIndex: -1}, false},
+		{"partitioned batch", encodeMessageID(12, 34, 2, 5), MessageIDData{LedgerID: 12, EntryID: 34, Partition: 2, BatchIndex: 5}, false},
+		{"earliest", pulsar.EarliestMessageID(), MessageIDData{LedgerID: -1, EntryID: -1, Partition: -1, BatchIndex: -1}, false},
+		{"latest", pulsar.LatestMessageID(), MessageIDData{LedgerID: math.MaxInt64, EntryID: math.MaxInt64, Partition: -1, BatchIndex: -1}, false},
+		{"truncated", encodeMessageID(300)[:2], MessageIDData{}, true},
+		{"length delimited field", rawMessageID{0x0a, 0x01, 0x00}, MessageIDData{}, true},
+	}
+	for _, c := range cases {
+		id, err := ParseMessageID(c.id)
+		if (err != nil) != c.fails {
+			t.Errorf("%s: expected failure %v, got %v", c.name, c.fails, err)
+			continue
+		}
+		if !c.fails && id != c.expected {
+			t.Errorf("%s: expected %+v, got %+v", c.name, c.expected, id)
+		}
+	}
+}
+
+func TestMessageIDOffset(t *testing.T) {
+	ids := []MessageIDData{{LedgerID: 1, EntryID: 0}, {LedgerID: 1, EntryID: 1}, {LedgerID: 1, EntryID: 1<<entryBits - 1}, {LedgerID: 2, EntryID: 0}}
+	for i := 1; i < len(ids); i++ {
+		if ids[i].Offset() <= ids[i-1].Offset() {
+			t.Errorf("expected the offset of %+v after %+v", ids[i], ids[i-1])
+		}
+	}
+}
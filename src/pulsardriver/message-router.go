@@ -0,0 +1,49 @@
+package pulsardriver
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// NewMessageRouter returns the partition router of the routing mode. Keyed messages are routed by
+// the key hash so the messages of a key stay in order on one partition.
+func NewMessageRouter(mode model.MessageRoutingMode) func(*pulsar.ProducerMessage, pulsar.TopicMetadata) int {
+	single := rand.Uint32()
+	var next uint32
+	return func(msg *pulsar.ProducerMessage, md pulsar.TopicMetadata) int {
+		n := md.NumPartitions()
+		if n <= 1 {
+			return 0
+		}
+		if msg.Key != "" {
+			return int(javaStringHash(msg.Key) % n)
+		}
+		switch mode {
+		case model.SinglePartition:
+			return int(single % n)
+		case model.CustomPartition:
+			return 0
+		default:
+			return int((atomic.AddUint32(&next, 1) - 1) % n)
+		}
+	}
+}
+
+// javaStringHash is the Java String.hashCode() so the keys are routed the same as the Java clients
+func javaStringHash(s string) uint32 {
+	var h int32
+	for _, c := range []rune(s) {
+		if c > 0xFFFF {
+			// supplementary characters are a UTF-16 surrogate pair in Java
+			c -= 0x10000
+			h = 31*h + 0xD800 + (c >> 10)
+			h = 31*h + 0xDC00 + (c & 0x3FF)
+			continue
+		}
+		h = 31*h + c
+	}
+	return uint32(h)
+}
@@ -0,0 +1,70 @@
+package pulsardriver
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+type partitions uint32
+
+func (p partitions) NumPartitions() uint32 { return uint32(p) }
+
+func TestJavaStringHash(t *testing.T) {
+	cases := []struct {
+		key  string
+		hash int32
+	}{
+		{"", 0},
+		{"abc", 96354},
+		{"hello", 99162322},
+		{"customer-42", -1772111155},
+		{"\U0001F600", 1772899},
+	}
+	for _, c := range cases {
+		if hash := javaStringHash(c.key); hash != uint32(c.hash) {
+			t.Errorf("%q: expected %d, got %d", c.key, c.hash, int32(hash))
+		}
+	}
+}
+
+func TestNewMessageRouter(t *testing.T) {
+	keyed := &pulsar.ProducerMessage{Key: "customer-42"}
+	keyPartition := int(javaStringHash(keyed.Key) % 4)
+	cases := []struct {
+		mode       model.MessageRoutingMode
+		partitions partitions
+		unkeyed    []int
+	}{
+		{model.RoundRobinPartition, 4, []int{0, 1, 2, 3, 0}},
+		{model.CustomPartition, 4, []int{0, 0, 0}},
+		{model.RoundRobinPartition, 1, []int{0, 0}},
+		{model.SinglePartition, 0, []int{0, 0}},
+	}
+	for _, c := range cases {
+		route := NewMessageRouter(c.mode)
+		for i, want := range c.unkeyed {
+			if got := route(&pulsar.ProducerMessage{}, c.partitions); got != want {
+				t.Errorf("mode %d partitions %d message %d: expected partition %d, got %d", c.mode, c.partitions, i, want, got)
+			}
+		}
+		// keyed messages are routed by the key hash in all modes
+		if c.partitions == 4 {
+			if got := route(keyed, c.partitions); got != keyPartition {
+				t.Errorf("mode %d: expected the keyed message on partition %d, got %d", c.mode, keyPartition, got)
+			}
+		}
+	}
+
+	route := NewMessageRouter(model.SinglePartition)
+	first := route(&pulsar.ProducerMessage{}, partitions(8))
+	for i := 0; i < 5; i++ {
+		if got := route(&pulsar.ProducerMessage{}, partitions(8)); got != first {
+			t.Errorf("expected all messages on partition %d, got %d", first, got)
+		}
+	}
+	if got := route(keyed, partitions(8)); got != int(javaStringHash(keyed.Key)%8) {
+		t.Errorf("expected the keyed message routed by its key, got %d", got)
+	}
+}
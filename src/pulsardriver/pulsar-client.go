@@ -8,11 +8,13 @@ import (
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
 	"github.com/kafkaesque-io/pubsub-function/src/util"
 	log "github.com/sirupsen/logrus"
 )
 
-// ClientCache caches a list Pulsar clients
+// ClientCache is the client registry caching the Pulsar clients shared by the URL and token
+// across the database and the function topics
 var ClientCache = make(map[string]*PulsarClient)
 
 // clientSync protects the ClientCache access
@@ -23,9 +25,43 @@ var (
 	clientConnectTimeout = util.GetEnvInt("PulsarClientConnectionTimeout", 30)
 )
 
+// clientKey identifies a client by the cluster URL and the token
+func clientKey(pulsarURL, pulsarToken string) string {
+	return pulsarURL + "|" + pulsarToken
+}
+
+// TopicClusterURL returns the pulsar URL of the function topic cluster. A topic without a pulsar URL
+// is on the data plane cluster PulsarBrokerURL, which can differ from the database cluster.
+func TopicClusterURL(ft model.FunctionTopic) string {
+	return util.AssignString(ft.PulsarURL, util.GetConfig().PulsarBrokerURL)
+}
+
+// GetTopicClient gets the shared Pulsar client of the function topic cluster
+func GetTopicClient(ft model.FunctionTopic) (pulsar.Client, error) {
+	return GetPulsarClient(TopicClusterURL(ft), ft.Token, false)
+}
+
 // GetPulsarClient gets a Pulsar client object
 func GetPulsarClient(pulsarURL, pulsarToken string, reset bool) (pulsar.Client, error) {
-	key := pulsarURL + pulsarToken
+	return getPulsarClient(pulsarURL, pulsarToken, reset,
+		time.Duration(clientConnectTimeout)*time.Second, time.Duration(clientOpsTimeout)*time.Second)
+}
+
+// GetPulsarClientWithTimeouts gets the Pulsar client shared by the URL and token from the client registry.
+// The timeouts only apply if the client is created by this call.
+func GetPulsarClientWithTimeouts(pulsarURL, pulsarToken string, connectTimeout, operationTimeout time.Duration) (pulsar.Client, error) {
+	return getPulsarClient(pulsarURL, pulsarToken, false, connectTimeout, operationTimeout)
+}
+
+// ClientCount returns the number of clients in the client registry
+func ClientCount() int {
+	clientSync.RLock()
+	defer clientSync.RUnlock()
+	return len(ClientCache)
+}
+
+func getPulsarClient(pulsarURL, pulsarToken string, reset bool, connectTimeout, operationTimeout time.Duration) (pulsar.Client, error) {
+	key := clientKey(pulsarURL, pulsarToken)
 	clientSync.Lock()
 	driver, ok := ClientCache[key]
 	if !ok {
@@ -33,6 +69,8 @@ func GetPulsarClient(pulsarURL, pulsarToken string, reset bool) (pulsar.Client,
 		driver.createdAt = time.Now()
 		driver.pulsarURL = pulsarURL
 		driver.token = pulsarToken
+		driver.connectTimeout = connectTimeout
+		driver.operationTimeout = operationTimeout
 		ClientCache[key] = driver
 	}
 	clientSync.Unlock()
@@ -45,11 +83,13 @@ func GetPulsarClient(pulsarURL, pulsarToken string, reset bool) (pulsar.Client,
 
 // PulsarClient encapsulates the Pulsar Client object
 type PulsarClient struct {
-	client    pulsar.Client
-	pulsarURL string
-	token     string
-	createdAt time.Time
-	lastUsed  time.Time
+	client           pulsar.Client
+	pulsarURL        string
+	token            string
+	connectTimeout   time.Duration
+	operationTimeout time.Duration
+	createdAt        time.Time
+	lastUsed         time.Time
 	sync.Mutex
 }
 
@@ -62,13 +102,17 @@ func (c *PulsarClient) GetClient(url, tokenStr string) (pulsar.Client, error) {
 		return c.client, nil
 	}
 
-	driver, err := NewPulsarClient(url, tokenStr)
-	if err != nil {
-		log.Errorf("failed instantiate pulsar client %v", err)
-		return nil, fmt.Errorf("Could not instantiate Pulsar client: %v", err)
+	connectTimeout := c.connectTimeout
+	if connectTimeout <= 0 {
+		connectTimeout = time.Duration(clientConnectTimeout) * time.Second
+	}
+	operationTimeout := c.operationTimeout
+	if operationTimeout <= 0 {
+		operationTimeout = time.Duration(clientOpsTimeout) * time.Second
 	}
-	if log.GetLevel() == log.DebugLevel {
-		log.Debugf("pulsar client url %s\n token %s", url, tokenStr)
+	driver, err := NewPulsarClientWithTimeouts(url, tokenStr, connectTimeout, operationTimeout)
+	if err != nil {
+		return nil, err
 	}
 
 	c.client = driver
@@ -98,10 +142,36 @@ func (c *PulsarClient) Reconnect() (pulsar.Client, error) {
 
 // NewPulsarClient always creates a new pulsar.Client connection
 func NewPulsarClient(url, tokenStr string) (pulsar.Client, error) {
+	return NewPulsarClientWithTimeouts(url, tokenStr,
+		time.Duration(clientConnectTimeout)*time.Second, time.Duration(clientOpsTimeout)*time.Second)
+}
+
+// NewPulsarClientWithTimeouts creates a new pulsar.Client connection with the connection and operation timeouts
+func NewPulsarClientWithTimeouts(url, tokenStr string, connectTimeout, operationTimeout time.Duration) (pulsar.Client, error) {
+	clientOpt, err := ClientOptions(url, tokenStr, connectTimeout, operationTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	driver, err := pulsar.NewClient(clientOpt)
+
+	if err != nil {
+		log.Errorf("failed instantiate pulsar client %v", err)
+		return nil, fmt.Errorf("Could not instantiate Pulsar client: %v", err)
+	}
+	// the token is never logged
+	log.Debugf("pulsar client url %s token authentication %v connection timeout %v operation timeout %v",
+		url, tokenStr != "", connectTimeout, operationTimeout)
+
+	return driver, nil
+}
+
+// ClientOptions builds the pulsar client options
+func ClientOptions(url, tokenStr string, connectTimeout, operationTimeout time.Duration) (pulsar.ClientOptions, error) {
 	clientOpt := pulsar.ClientOptions{
 		URL:               url,
-		OperationTimeout:  time.Duration(clientOpsTimeout) * time.Second,
-		ConnectionTimeout: time.Duration(clientConnectTimeout) * time.Second,
+		OperationTimeout:  operationTimeout,
+		ConnectionTimeout: connectTimeout,
 	}
 
 	if tokenStr != "" {
@@ -111,7 +181,7 @@ func NewPulsarClient(url, tokenStr string) (pulsar.Client, error) {
 	if strings.HasPrefix(url, "pulsar+ssl://") {
 		trustStore := os.Getenv("TrustStore") //"/etc/ssl/certs/ca-bundle.crt" all Config is also written back to OS ENV
 		if trustStore == "" {
-			return nil, fmt.Errorf("this is fatal that we are missing trustStore while pulsar+ssl is required")
+			return clientOpt, fmt.Errorf("this is fatal that we are missing trustStore while pulsar+ssl is required")
 		}
 		clientOpt.TLSTrustCertsFilePath = trustStore
 	}
@@ -119,17 +189,5 @@ func NewPulsarClient(url, tokenStr string) (pulsar.Client, error) {
 	// default is false for these two configuration parameters
 	clientOpt.TLSAllowInsecureConnection = util.StringToBool(os.Getenv("PulsarTLSAllowInsecureConnection"))
 	clientOpt.TLSValidateHostname = util.StringToBool(os.Getenv("PulsarTLSValidateHostname"))
-
-	fmt.Printf("pulsar client options %v", clientOpt)
-	driver, err := pulsar.NewClient(clientOpt)
-
-	if err != nil {
-		log.Errorf("failed instantiate pulsar client %v", err)
-		return nil, fmt.Errorf("Could not instantiate Pulsar client: %v", err)
-	}
-	if log.GetLevel() == log.DebugLevel {
-		log.Debugf("pulsar client url %s\n token %s", url, tokenStr)
-	}
-
-	return driver, nil
+	return clientOpt, nil
 }
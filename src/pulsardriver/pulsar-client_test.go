@@ -0,0 +1,118 @@
+package pulsardriver
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func TestClientOptions(t *testing.T) {
+	cases := []struct {
+		url        string
+		token      string
+		trustStore string
+		authed     bool
+		fails      bool
+	}{
+		{"pulsar://localhost:6650", "", "", false, false},
+		{"pulsar://localhost:6650", "jwt", "", true, false},
+		{"pulsar+ssl://localhost:6651", "jwt", "/etc/ssl/certs/ca-bundle.crt", true, false},
+		// a tls connection requires a trust store
+		{"pulsar+ssl://localhost:6651", "jwt", "", true, true},
+	}
+	defer os.Setenv("TrustStore", os.Getenv("TrustStore"))
+	for _, c := range cases {
+		os.Setenv("TrustStore", c.trustStore)
+		opts, err := ClientOptions(c.url, c.token, 2*time.Second, 5*time.Second)
+		if (err != nil) != c.fails {
+			t.Errorf("%s: expected failure %v, got %v", c.url, c.fails, err)
+		}
+		if c.fails {
+			continue
+		}
+		if opts.URL != c.url || opts.ConnectionTimeout != 2*time.Second || opts.OperationTimeout != 5*time.Second {
+			t.Errorf("%s: unexpected options %+v", c.url, opts)
+		}
+		if (opts.Authentication != nil) != c.authed || opts.TLSTrustCertsFilePath != c.trustStore {
+			t.Errorf("%s: expected authentication %v trust store %q, got %+v", c.url, c.authed, c.trustStore, opts)
+		}
+	}
+}
+
+func TestTopicClusterURL(t *testing.T) {
+	defer func(url string) { util.Config.PulsarBrokerURL = url }(util.Config.PulsarBrokerURL)
+	util.Config.PulsarBrokerURL = "pulsar://data-plane:6650"
+
+	cases := []struct {
+		topic model.FunctionTopic
+		url   string
+	}{
+		{model.FunctionTopic{PulsarURL: "pulsar://other-cluster:6650"}, "pulsar://other-cluster:6650"},
+		// a topic without a pulsar URL is on the data plane cluster
+		{model.FunctionTopic{}, "pulsar://data-plane:6650"},
+	}
+	for _, c := range cases {
+		if url := TopicClusterURL(c.topic); url != c.url {
+			t.Errorf("%q: expected cluster %s, got %s", c.topic.PulsarURL, c.url, url)
+		}
+	}
+}
+
+func TestClientRegistry(t *testing.T) {
+	// the clients are created without connecting to the brokers
+	defer func() {
+		clientSync.Lock()
+		for key, driver := range ClientCache {
+			if strings.HasPrefix(key, "pulsar://registry-") {
+				driver.Close()
+				delete(ClientCache, key)
+			}
+		}
+		clientSync.Unlock()
+	}()
+	count := ClientCount()
+
+	cases := []struct {
+		name    string
+		url     string
+		token   string
+		shared  bool
+		clients int
+	}{
+		{"first client", "pulsar://registry-a:6650", "t1", false, 1},
+		{"same cluster and token", "pulsar://registry-a:6650", "t1", true, 1},
+		{"other token", "pulsar://registry-a:6650", "t2", false, 2},
+		{"other cluster", "pulsar://registry-b:6650", "t1", false, 3},
+		// the key separates the URL from the token
+		{"ambiguous concatenation", "pulsar://registry-a:665", "0t1", false, 4},
+	}
+	var previous pulsar.Client
+	for _, c := range cases {
+		client, err := GetPulsarClient(c.url, c.token, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (client == previous) != c.shared {
+			t.Errorf("%s: expected shared client %v", c.name, c.shared)
+		}
+		if n := ClientCount() - count; n != c.clients {
+			t.Errorf("%s: expected %d clients, got %d", c.name, c.clients, n)
+		}
+		previous = client
+	}
+
+	// the database and the function topics on the same cluster share the client
+	db, err := GetPulsarClientWithTimeouts("pulsar://registry-a:6650", "t1", time.Second, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	topic, err := GetTopicClient(model.FunctionTopic{PulsarURL: "pulsar://registry-a:6650", Token: "t1"})
+	if err != nil || db != topic {
+		t.Errorf("expected the database client shared with the function topic, got %v", err)
+	}
+}
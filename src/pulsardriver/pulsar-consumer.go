@@ -139,3 +139,71 @@ func (c *PulsarConsumer) Reconnect() (pulsar.Consumer, error) {
 	c.Close()
 	return c.GetConsumer()
 }
+
+// NewConsumer always creates a new consumer subscribing to the function topic
+func NewConsumer(client pulsar.Client, ft model.FunctionTopic) (pulsar.Consumer, error) {
+	opts, err := NewConsumerOptions(ft)
+	if err != nil {
+		return nil, err
+	}
+	return client.Subscribe(opts)
+}
+
+// NewConsumerOptions builds Pulsar consumer options from a function topic configuration
+func NewConsumerOptions(ft model.FunctionTopic) (pulsar.ConsumerOptions, error) {
+	subType, err := model.GetSubscriptionType(ft.SubscriptionType)
+	if err != nil {
+		return pulsar.ConsumerOptions{}, err
+	}
+	initPos, err := model.GetInitialPosition(ft.InitialPosition)
+	if err != nil {
+		return pulsar.ConsumerOptions{}, err
+	}
+	if subType == pulsar.KeyShared {
+		// only auto split hash range is supported by the current Pulsar client
+		if err := model.ValidateKeySharedPolicy(ft.KeySharedPolicy); err != nil {
+			return pulsar.ConsumerOptions{}, err
+		}
+	}
+
+	opts := pulsar.ConsumerOptions{
+		SubscriptionName:            ft.Subscription,
+		SubscriptionInitialPosition: initPos,
+		Type:                        subType,
+	}
+	SetConsumerTopics(&opts, ft)
+	return opts, nil
+}
+
+// SetConsumerTopics subscribes the consumer options to either the topics pattern, the list of topics,
+// or the topic of the function topic
+func SetConsumerTopics(opts *pulsar.ConsumerOptions, ft model.FunctionTopic) {
+	if ft.TopicsPattern != "" {
+		opts.TopicsPattern = ft.TopicsPattern
+		return
+	}
+	if len(ft.Topics) > 0 {
+		opts.Topics = ft.Topics
+		return
+	}
+	opts.Topic = ft.TopicFullName
+}
+
+// cumulativeAcker is implemented by consumers supporting cumulative acknowledgment
+type cumulativeAcker interface {
+	AckCumulative(pulsar.Message) error
+}
+
+// Acknowledge acknowledges the message by the acknowledgment mode.
+// It falls back to individual acknowledgment if the consumer does not support cumulative acknowledgment.
+func Acknowledge(consumer pulsar.Consumer, msg pulsar.Message, ackMode string) {
+	if strings.ToLower(ackMode) == model.AckCumulative {
+		if c, ok := consumer.(cumulativeAcker); ok {
+			if err := c.AckCumulative(msg); err != nil {
+				log.Errorf("cumulative acknowledgment of message %v error %v", msg.ID(), err)
+			}
+			return
+		}
+	}
+	consumer.Ack(msg)
+}
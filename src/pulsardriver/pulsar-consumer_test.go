@@ -0,0 +1,129 @@
+package pulsardriver
+
+import (
+	"testing"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// fakeClient records the consumer options it is subscribed with
+type fakeClient struct {
+	pulsar.Client
+	opts []pulsar.ConsumerOptions
+}
+
+func (c *fakeClient) Subscribe(opts pulsar.ConsumerOptions) (pulsar.Consumer, error) {
+	c.opts = append(c.opts, opts)
+	return nil, nil
+}
+
+func TestNewConsumerOptions(t *testing.T) {
+	ft := model.FunctionTopic{
+		TopicFullName:    "persistent://public/default/input",
+		Subscription:     "sub",
+		SubscriptionType: "KeyShared",
+		KeySharedPolicy:  "autosplit",
+		InitialPosition:  "earliest",
+	}
+	client := &fakeClient{}
+	if _, err := NewConsumer(client, ft); err != nil {
+		t.Fatal(err)
+	}
+	if len(client.opts) != 1 {
+		t.Fatalf("expected one subscription, got %d", len(client.opts))
+	}
+	opts := client.opts[0]
+	if opts.Topic != ft.TopicFullName || opts.SubscriptionName != "sub" || opts.Type != pulsar.KeyShared ||
+		opts.SubscriptionInitialPosition != pulsar.SubscriptionPositionEarliest {
+		t.Errorf("options are not derived from the function topic %+v", opts)
+	}
+}
+
+func TestNewConsumerOptionsDefaults(t *testing.T) {
+	opts, err := NewConsumerOptions(model.FunctionTopic{TopicFullName: "persistent://public/default/input", Subscription: "sub"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if opts.Type != pulsar.Exclusive || opts.SubscriptionInitialPosition != pulsar.SubscriptionPositionLatest {
+		t.Errorf("expected exclusive subscription from the latest message, got %+v", opts)
+	}
+}
+
+func TestNewConsumerOptionsInvalid(t *testing.T) {
+	cases := []model.FunctionTopic{
+		{SubscriptionType: "bogus"},
+		{InitialPosition: "middle"},
+		{SubscriptionType: "keyshared", KeySharedPolicy: "bogus"},
+		{SubscriptionType: "keyshared", KeySharedPolicy: "sticky:0-100"},
+	}
+	for _, ft := range cases {
+		if _, err := NewConsumerOptions(ft); err == nil {
+			t.Errorf("expected an error for %+v", ft)
+		}
+	}
+}
+
+func TestSetConsumerTopics(t *testing.T) {
+	cases := []struct {
+		ft      model.FunctionTopic
+		topic   string
+		topics  int
+		pattern string
+	}{
+		{model.FunctionTopic{TopicFullName: "a", Topics: []string{"b", "c"}, TopicsPattern: "d.*"}, "", 0, "d.*"},
+		{model.FunctionTopic{TopicFullName: "a", Topics: []string{"b", "c"}}, "", 2, ""},
+		{model.FunctionTopic{TopicFullName: "a"}, "a", 0, ""},
+	}
+	for _, c := range cases {
+		opts := pulsar.ConsumerOptions{}
+		SetConsumerTopics(&opts, c.ft)
+		if opts.Topic != c.topic || len(opts.Topics) != c.topics || opts.TopicsPattern != c.pattern {
+			t.Errorf("unexpected topics %+v for %+v", opts, c.ft)
+		}
+	}
+}
+
+// ackConsumer records the acknowledgments
+type ackConsumer struct {
+	pulsar.Consumer
+	acks []string
+}
+
+func (c *ackConsumer) Ack(pulsar.Message) { c.acks = append(c.acks, model.AckIndividual) }
+
+// cumulativeConsumer supports cumulative acknowledgment
+type cumulativeConsumer struct {
+	ackConsumer
+}
+
+func (c *cumulativeConsumer) AckCumulative(pulsar.Message) error {
+	c.acks = append(c.acks, model.AckCumulative)
+	return nil
+}
+
+func TestAcknowledge(t *testing.T) {
+	cases := []struct {
+		name     string
+		consumer pulsar.Consumer
+		mode     string
+		want     string
+	}{
+		{"individual", &cumulativeConsumer{}, "", model.AckIndividual},
+		{"cumulative", &cumulativeConsumer{}, "Cumulative", model.AckCumulative},
+		{"cumulative falls back to individual", &ackConsumer{}, model.AckCumulative, model.AckIndividual},
+	}
+	for _, c := range cases {
+		Acknowledge(c.consumer, nil, c.mode)
+		var acks []string
+		switch consumer := c.consumer.(type) {
+		case *cumulativeConsumer:
+			acks = consumer.acks
+		case *ackConsumer:
+			acks = consumer.acks
+		}
+		if len(acks) != 1 || acks[0] != c.want {
+			t.Errorf("%s: expected a %s acknowledgment, got %v", c.name, c.want, acks)
+		}
+	}
+}
@@ -3,10 +3,13 @@ package pulsardriver
 import (
 	"context"
 	"errors"
+	"fmt"
+	"strconv"
 	"sync"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
 	"github.com/kafkaesque-io/pubsub-function/src/util"
 	log "github.com/sirupsen/logrus"
 )
@@ -28,7 +31,18 @@ var ProducerCache = util.NewCache(util.CacheOption{
 
 // GetPulsarProducer gets a Pulsar producer object
 func GetPulsarProducer(pulsarURL, pulsarToken, topic string) (pulsar.Producer, error) {
-	key := pulsarURL + pulsarToken + topic
+	return getPulsarProducer(pulsarURL, pulsarToken, topic, "", model.RoundRobinPartition)
+}
+
+// GetOutputProducer gets a Pulsar producer of a function output topic with the message routing mode.
+// A named producer enables the broker deduplication, its messages are not batched
+// so every message is deduplicated by its own sequence id.
+func GetOutputProducer(pulsarURL, pulsarToken, topic, name string, routing model.MessageRoutingMode) (pulsar.Producer, error) {
+	return getPulsarProducer(pulsarURL, pulsarToken, topic, name, routing)
+}
+
+func getPulsarProducer(pulsarURL, pulsarToken, topic, name string, routing model.MessageRoutingMode) (pulsar.Producer, error) {
+	key := fmt.Sprintf("%s|%s|%s|%d", clientKey(pulsarURL, pulsarToken), topic, name, routing)
 	obj, exists := ProducerCache.Get(key)
 	if exists {
 		if driver, ok := obj.(*PulsarProducer); ok {
@@ -40,6 +54,8 @@ func GetPulsarProducer(pulsarURL, pulsarToken, topic string) (pulsar.Producer, e
 		pulsarURL: pulsarURL,
 		token:     pulsarToken,
 		topic:     topic,
+		name:      name,
+		routing:   routing,
 	}
 	p, err := prod.GetProducer()
 	if err != nil {
@@ -61,6 +77,8 @@ type PulsarProducer struct {
 	pulsarURL string
 	token     string
 	topic     string
+	name      string
+	routing   model.MessageRoutingMode
 	createdAt time.Time
 	lastUsed  time.Time
 	sync.Mutex
@@ -80,7 +98,7 @@ func SendToPulsar(url, token, topic string, data []byte, async bool) error {
 	if err != nil {
 		// this is very bad if happens
 		log.Warnf("NewUUID generation error %v", err)
-		id = string(time.Now().Unix())
+		id = strconv.FormatInt(time.Now().Unix(), 10)
 	}
 	prop := map[string]string{"PulsarBeamId": id}
 	//TODO: add cluster origin and maybe other properties
@@ -117,9 +135,16 @@ func (c *PulsarProducer) GetProducer() (pulsar.Producer, error) {
 	if err != nil {
 		return nil, err
 	}
-	p, err := driver.CreateProducer(pulsar.ProducerOptions{
-		Topic: c.topic,
-	})
+	opts := pulsar.ProducerOptions{
+		Topic:           c.topic,
+		Name:            c.name,
+		DisableBatching: c.name != "",
+	}
+	// the client default router routes keyed messages by the key hash and the others round robin
+	if c.routing != model.RoundRobinPartition {
+		opts.MessageRouter = NewMessageRouter(c.routing)
+	}
+	p, err := driver.CreateProducer(opts)
 	if err != nil {
 		return nil, err
 	}
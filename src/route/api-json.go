@@ -0,0 +1,51 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// statusFields are the json fields holding a model.Status, rendered by name in the API replies
+var statusFields = map[string]bool{
+	"functionStatus": true,
+	"webhookStatus":  true,
+	"status":         true,
+}
+
+// marshalAPI marshals a reply with the status fields as strings,
+// the persisted documents keep the integer form for compatibility with older replicas
+func marshalAPI(v interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var tree interface{}
+	if err := decoder.Decode(&tree); err != nil {
+		return nil, err
+	}
+	return json.Marshal(statusNames(tree))
+}
+
+func statusNames(node interface{}) interface{} {
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for key, value := range v {
+			if number, ok := value.(json.Number); ok && statusFields[key] {
+				if i, err := number.Int64(); err == nil {
+					v[key] = model.Status(i).String()
+					continue
+				}
+			}
+			v[key] = statusNames(value)
+		}
+	case []interface{}:
+		for i, value := range v {
+			v[i] = statusNames(value)
+		}
+	}
+	return node
+}
@@ -2,7 +2,117 @@ package route
 
 //This is a model for HTTP response
 
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
 // ResponseErr - Error struct for Http response
 type ResponseErr struct {
 	Error string `json:"error"`
 }
+
+// ErrorEnvelope is the error response with a stable machine readable code
+// validation failures list every problem of the request in errors
+type ErrorEnvelope struct {
+	Code    string             `json:"code"`
+	Message string             `json:"message"`
+	Errors  []model.FieldError `json:"errors,omitempty"`
+}
+
+// error codes
+const (
+	ErrCodeInvalidRequest     = "invalid_request"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeForbidden          = "forbidden"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeAlreadyExists      = "already_exists"
+	ErrCodeConflict           = "conflict"
+	ErrCodePreconditionFailed = "precondition_failed"
+	ErrCodeQuotaExceeded      = "quota_exceeded"
+	ErrCodeUnavailable        = "unavailable"
+	ErrCodeInternal           = "internal_error"
+)
+
+// dbErrors maps the database errors to the http status and error code
+var dbErrors = []struct {
+	prefix string
+	status int
+	code   string
+}{
+	{db.DocConflict, http.StatusConflict, ErrCodeConflict},
+	{db.DocIdempotencyConflict, http.StatusConflict, ErrCodeConflict},
+	{db.DocImmutableField, http.StatusConflict, ErrCodeConflict},
+	{db.DocQuotaExceeded, http.StatusForbidden, ErrCodeQuotaExceeded},
+	{db.DocReloadInProgress, http.StatusServiceUnavailable, ErrCodeUnavailable},
+}
+
+// statusCode returns the default error code of the http status
+func statusCode(status int) string {
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrCodeInvalidRequest
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusForbidden:
+		return ErrCodeForbidden
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusPreconditionFailed:
+		return ErrCodePreconditionFailed
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	default:
+		return ErrCodeInternal
+	}
+}
+
+// errorStatus maps the error to the http status and error code,
+// database errors take precedence over the status suggested by the handler
+func errorStatus(err error, status int) (int, string) {
+	switch {
+	case errors.Is(err, db.ErrDocNotFound):
+		return http.StatusNotFound, ErrCodeNotFound
+	case errors.Is(err, db.ErrDocAlreadyExisted):
+		return http.StatusConflict, ErrCodeAlreadyExists
+	}
+	var verr *model.ValidationError
+	if errors.As(err, &verr) {
+		return http.StatusBadRequest, ErrCodeInvalidRequest
+	}
+	for _, e := range dbErrors {
+		if strings.HasPrefix(err.Error(), e.prefix) {
+			return e.status, e.code
+		}
+	}
+	return status, statusCode(status)
+}
+
+// replyError replies the error in the error envelope
+func replyError(err error, w http.ResponseWriter, status int) {
+	status, code := errorStatus(err, status)
+	envelope := ErrorEnvelope{Code: code, Message: err.Error()}
+	var verr *model.ValidationError
+	if errors.As(err, &verr) {
+		envelope.Errors = verr.Errors
+	}
+	writeEnvelope(w, status, envelope)
+}
+
+func writeEnvelope(w http.ResponseWriter, status int, envelope ErrorEnvelope) {
+	data, err := json.Marshal(envelope)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
@@ -0,0 +1,55 @@
+package route
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestReplyError(t *testing.T) {
+	verr := &model.ValidationError{}
+	verr.Add("name", errors.New("missing"))
+
+	cases := []struct {
+		err    error
+		status int
+		want   int
+		code   string
+		fields int
+	}{
+		{fmt.Errorf("%w key abc", db.ErrDocNotFound), http.StatusInternalServerError, http.StatusNotFound, ErrCodeNotFound, 0},
+		{fmt.Errorf("%w key abc", db.ErrDocAlreadyExisted), http.StatusInternalServerError, http.StatusConflict, ErrCodeAlreadyExists, 0},
+		{verr, http.StatusUnprocessableEntity, http.StatusBadRequest, ErrCodeInvalidRequest, 1},
+		{errors.New(db.DocConflict + " revision 2"), http.StatusInternalServerError, http.StatusConflict, ErrCodeConflict, 0},
+		{errors.New(db.DocIdempotencyConflict), http.StatusInternalServerError, http.StatusConflict, ErrCodeConflict, 0},
+		{errors.New(db.DocImmutableField + " name"), http.StatusBadRequest, http.StatusConflict, ErrCodeConflict, 0},
+		{errors.New(db.DocQuotaExceeded), http.StatusInternalServerError, http.StatusForbidden, ErrCodeQuotaExceeded, 0},
+		{errors.New(db.DocReloadInProgress), http.StatusInternalServerError, http.StatusServiceUnavailable, ErrCodeUnavailable, 0},
+		{errors.New("bad json"), http.StatusUnprocessableEntity, http.StatusUnprocessableEntity, ErrCodeInvalidRequest, 0},
+		{errors.New("denied"), http.StatusUnauthorized, http.StatusUnauthorized, ErrCodeUnauthorized, 0},
+		{errors.New("boom"), http.StatusInternalServerError, http.StatusInternalServerError, ErrCodeInternal, 0},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		replyError(c.err, rr, c.status)
+		if rr.Code != c.want {
+			t.Errorf("%v: expected status %d, got %d", c.err, c.want, rr.Code)
+		}
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("%v: expected json content type, got %s", c.err, ct)
+		}
+		var envelope ErrorEnvelope
+		if err := json.Unmarshal(rr.Body.Bytes(), &envelope); err != nil {
+			t.Fatalf("%v: %v", c.err, err)
+		}
+		if envelope.Code != c.code || envelope.Message != c.err.Error() || len(envelope.Errors) != c.fields {
+			t.Errorf("%v: unexpected envelope %+v", c.err, envelope)
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package route
 
 import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -8,27 +11,53 @@ import (
 	"io/ioutil"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/ghodss/yaml"
 	"github.com/gorilla/mux"
 	"github.com/kafkaesque-io/pubsub-function/src/db"
 	"github.com/kafkaesque-io/pubsub-function/src/lambda"
 	"github.com/kafkaesque-io/pubsub-function/src/model"
 	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
 	"github.com/kafkaesque-io/pubsub-function/src/util"
+	"github.com/kafkaesque-io/pubsub-function/src/webhook"
 
 	log "github.com/sirupsen/logrus"
 )
 
 var singleDb db.Db
 
+// RuntimeSource reports the live state of the functions run by this server
+type RuntimeSource interface {
+	RuntimeStatus(cfg *model.FunctionConfig) lambda.RuntimeStatus
+}
+
+// functionRuntime is nil unless the server runs the functions
+var functionRuntime RuntimeSource
+
+// startNodeInstance starts a function instance and returns its URL
+var startNodeInstance = lambda.StartNodeInstance
+
+// SetRuntime sets the source of the function runtime status
+func SetRuntime(runtime RuntimeSource) {
+	functionRuntime = runtime
+}
+
 const subDelimiter = "-"
 
 // Init initializes database
 func Init() {
 	singleDb = db.NewDbWithPanic(util.GetConfig().PbDbType)
+
+	if adminURL := util.GetConfig().PulsarAdminURL; adminURL != "" {
+		if interval := util.ConfigInt(util.GetConfig().BacklogCollectionInterval, 60); interval > 0 {
+			lambda.StartBacklogMonitor(singleDb, db.NewRestAdmin(adminURL, util.GetConfig().DbPassword),
+				time.Duration(interval)*time.Second)
+		}
+	}
 }
 
 // TokenServerResponse is the json object for token server response
@@ -49,14 +78,14 @@ func TokenSubjectHandler(w http.ResponseWriter, r *http.Request) {
 	if util.StrContains(util.SuperRoles, util.AssignString(r.Header.Get("injectedSubs"), "BOGUSROLE")) {
 		tokenString, err := util.JWTAuth.GenerateToken(subject)
 		if err != nil {
-			util.ResponseErrorJSON(errors.New("failed to generate token"), w, http.StatusInternalServerError)
+			replyError(errors.New("failed to generate token"), w, http.StatusInternalServerError)
 		} else {
 			respJSON, err := json.Marshal(&TokenServerResponse{
 				Subject: subject,
 				Token:   tokenString,
 			})
 			if err != nil {
-				util.ResponseErrorJSON(errors.New("failed to marshal token response json object"), w, http.StatusInternalServerError)
+				replyError(errors.New("failed to marshal token response json object"), w, http.StatusInternalServerError)
 				return
 			}
 			w.Write(respJSON)
@@ -64,7 +93,7 @@ func TokenSubjectHandler(w http.ResponseWriter, r *http.Request) {
 		}
 		return
 	}
-	util.ResponseErrorJSON(errors.New("incorrect subject"), w, http.StatusUnauthorized)
+	replyError(errors.New("incorrect subject"), w, http.StatusUnauthorized)
 	return
 }
 
@@ -74,24 +103,293 @@ func StatusPage(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// LivenessHandler replies OK as long as the process serves requests
+func LivenessHandler(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// ReadinessHandler replies OK only when the database cache is warm and the database producer works
+func ReadinessHandler(w http.ResponseWriter, r *http.Request) {
+	if singleDb == nil || !singleDb.Ready() {
+		replyError(errors.New("database is not ready"), w, http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write([]byte("ok"))
+}
+
+// HealthSummaryHandler replies with a JSON summary of the database health
+func HealthSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	report := singleDb.HealthReport()
+	resJSON, err := json.Marshal(report)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if !report.Healthy {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	w.Write(resJSON)
+}
+
+// DebugConfigHandler replies with the effective configuration with secrets redacted
+func DebugConfigHandler(w http.ResponseWriter, r *http.Request) {
+	if !isSuperRole(r.Header.Get("injectedSubs")) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	resJSON, err := json.Marshal(util.RedactedConfig())
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// ReloadHandler rebuilds the database cache from the database topic
+func ReloadHandler(w http.ResponseWriter, r *http.Request) {
+	if !isSuperRole(r.Header.Get("injectedSubs")) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	if err := singleDb.Reload(); err != nil {
+		if err.Error() == db.DocReloadInProgress {
+			replyError(err, w, http.StatusConflict)
+			return
+		}
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// VerifyReport is the reply of the database cache verification
+type VerifyReport struct {
+	DivergentKeys []string `json:"divergentKeys"`
+}
+
+// VerifyHandler replies with the keys of the cached documents diverging from the database
+func VerifyHandler(w http.ResponseWriter, r *http.Request) {
+	if !isSuperRole(r.Header.Get("injectedSubs")) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	keys, err := singleDb.Verify()
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	resJSON, err := json.Marshal(VerifyReport{DivergentKeys: keys})
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// DeletePreviewHandler replies with the functions affected by deleting the function
+func DeletePreviewHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	impact, err := singleDb.DeletePreview(tenant, functionName)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	resJSON, err := json.Marshal(impact)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// TopicSubscriptionsHandler replies with the subscription names and types configured on the topic
+func TopicSubscriptionsHandler(w http.ResponseWriter, r *http.Request) {
+	if !isSuperRole(r.Header.Get("injectedSubs")) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	topicFullName := r.URL.Query().Get("topic")
+	if err := model.ValidateTopicName(topicFullName); err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	subs, err := singleDb.SubscriptionsOnTopic(topicFullName)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	resJSON, err := json.Marshal(subs)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// WebhookTestRequest is the request body to test a webhook URL
+type WebhookTestRequest struct {
+	URL       string   `json:"url"`
+	Headers   []string `json:"headers"`
+	TimeoutMs int      `json:"timeoutMs"`
+}
+
+// WebhookTestHandler sends a test payload to the webhook URL and replies with the result without persisting anything
+func WebhookTestHandler(w http.ResponseWriter, r *http.Request) {
+	if !isSuperRole(r.Header.Get("injectedSubs")) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	var req WebhookTestRequest
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&req); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	if err := webhook.ValidateProbeURL(req.URL); err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	result := webhook.Probe(req.URL, req.Headers, time.Duration(req.TimeoutMs)*time.Millisecond)
+	resJSON, err := json.Marshal(result)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// ReplayRequest is the request body to replay messages to webhooks
+type ReplayRequest struct {
+	From           time.Time `json:"from"`
+	To             time.Time `json:"to"`
+	StartMessageID string    `json:"startMessageId"`
+	EndMessageID   string    `json:"endMessageId"`
+	MaxMessages    int       `json:"maxMessages"`
+	WebhookURL     string    `json:"webhookURL"`
+}
+
+// ReplayHandler re-delivers messages on the function input topic to its webhooks
+func ReplayHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+
+	var req ReplayRequest
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&req); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	replayRange := webhook.ReplayRange{From: req.From, To: req.To, MaxMessages: req.MaxMessages}
+	if replayRange.StartMessageID, err = decodeMessageID(req.StartMessageID); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	if replayRange.EndMessageID, err = decodeMessageID(req.EndMessageID); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	if err := replayRange.Validate(); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+
+	doc, err := singleDb.GetByKey(model.FunctionKey(tenant, functionName))
+	if err != nil {
+		replyError(err, w, http.StatusNotFound)
+		return
+	}
+	client, err := pulsardriver.GetTopicClient(doc.InputTopic)
+	if err != nil {
+		replyError(err, w, http.StatusServiceUnavailable)
+		return
+	}
+
+	sender := webhook.NewWebhookSender(webhook.NewDbStatusReporter(singleDb, doc.ID))
+	delivered := map[string]int{}
+	for i := range doc.Webhooks {
+		wh := &doc.Webhooks[i]
+		if wh.WebhookStatus != model.Activated || (req.WebhookURL != "" && req.WebhookURL != wh.URL) {
+			continue
+		}
+		// a separate reader per webhook so the live subscription is not affected
+		reader, err := client.CreateReader(replayRange.ReaderOptions(doc.InputTopic.TopicFullName))
+		if err != nil {
+			replyError(err, w, http.StatusServiceUnavailable)
+			return
+		}
+		count, err := sender.Replay(reader, wh, replayRange)
+		reader.Close()
+		if err != nil {
+			replyError(fmt.Errorf("replay to webhook %s failed after %d messages: %v", wh.URL, count, err), w, http.StatusBadGateway)
+			return
+		}
+		delivered[wh.URL] = count
+	}
+
+	resJSON, err := json.Marshal(delivered)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// decodeMessageID decodes a base64 encoded serialized message ID
+func decodeMessageID(str string) (pulsar.MessageID, error) {
+	if str == "" {
+		return nil, nil
+	}
+	data, err := base64.StdEncoding.DecodeString(str)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message id %s", str)
+	}
+	return pulsar.DeserializeMessageID(data)
+}
+
 // ReceiveHandler - the message receiver handler
 func ReceiveHandler(w http.ResponseWriter, r *http.Request) {
 	b, err := ioutil.ReadAll(r.Body)
 	defer r.Body.Close()
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusInternalServerError)
+		replyError(err, w, http.StatusInternalServerError)
 		return
 	}
 	token, topic, pulsarURL, err := util.ReceiverHeader(util.AllowedPulsarURLs, &r.Header)
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusUnauthorized)
+		replyError(err, w, http.StatusUnauthorized)
 		return
 	}
 
 	topicFN, err2 := GetTopicFnFromRoute(mux.Vars(r))
 	if topic == "" && err2 != nil {
 		// only read topic from routes
-		util.ResponseErrorJSON(err2, w, http.StatusUnprocessableEntity)
+		replyError(err2, w, http.StatusUnprocessableEntity)
 		return
 	}
 	topicFN = util.AssignString(topic, topicFN) // header topicFn overwrites topic specified in the routes
@@ -100,7 +398,7 @@ func ReceiveHandler(w http.ResponseWriter, r *http.Request) {
 	pulsarAsync := r.URL.Query().Get("mode") == "async"
 	err = pulsardriver.SendToPulsar(pulsarURL, token, topicFN, b, pulsarAsync)
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusServiceUnavailable)
+		replyError(err, w, http.StatusServiceUnavailable)
 		return
 	}
 	w.WriteHeader(http.StatusOK)
@@ -245,22 +543,232 @@ func ConsumerConfigFromHTTPParts(allowedClusters []string, h *http.Header, vars
 
 // GetFunctionHandler gets a function
 func GetFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+
+	doc, err := singleDb.GetByKey(model.FunctionKey(tenant, functionName))
+	if err != nil {
+		if errors.Is(err, db.ErrDocNotFound) {
+			replyError(err, w, http.StatusNotFound)
+			return
+		}
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	etag, err := model.GenETag(*doc)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+
+	maskSecrets(doc)
+	resJSON, err := marshalAPI(doc)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", etag)
+	w.Write(resJSON)
+}
+
+// FunctionList is the paginated response of function configs.
+// NextCursor is the cursor of the next page when the list is paged by cursor.
+type FunctionList struct {
+	Items      []*model.FunctionConfig `json:"items"`
+	Total      int                     `json:"total"`
+	NextCursor string                  `json:"nextCursor,omitempty"`
+}
+
+// ListFunctionsHandler lists function configs filtered by tenant and status with pagination.
+// The list is paged by the cursor query parameter if present, otherwise by the offset.
+func ListFunctionsHandler(w http.ResponseWriter, r *http.Request) {
+	params := r.URL.Query()
+	offset, err := nonNegativeQueryParam(params, "offset")
+	if err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	limit, err := nonNegativeQueryParam(params, "limit")
+	if err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+
+	subjects := r.Header.Get("injectedSubs")
+	tenant := util.QueryParamString(params, "tenant", "")
+	var cfgs []*model.FunctionConfig
+	if tenant == "" {
+		if !isSuperRole(subjects) {
+			replyError(errors.New("tenant is required"), w, http.StatusForbidden)
+			return
+		}
+		cfgs, err = singleDb.Load()
+	} else {
+		if !VerifySubject(tenant, subjects, ExtractEvalTenant) {
+			replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+			return
+		}
+		cfgs, err = singleDb.LoadByTenant(tenant)
+	}
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	if str, ok := params["status"]; ok {
+		status, err := model.ParseStatus(str[0])
+		if err != nil {
+			replyError(err, w, http.StatusBadRequest)
+			return
+		}
+		cfgs = db.FilterByStatus(cfgs, status)
+	}
+
+	list := FunctionList{Total: len(cfgs)}
+	if _, ok := params["cursor"]; ok {
+		list.Items, list.NextCursor, err = db.PaginateAfter(cfgs, params.Get("cursor"), limit)
+		if err != nil {
+			replyError(err, w, http.StatusBadRequest)
+			return
+		}
+	} else {
+		list.Items, list.Total = db.Paginate(cfgs, offset, limit)
+	}
+	for _, v := range list.Items {
+		maskSecrets(v)
+	}
+	resJSON, err := marshalAPI(list)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// isSuperRole checks if any of the token subjects is a super role
+func isSuperRole(tokenSubjects string) bool {
+	for _, v := range strings.Split(tokenSubjects, ",") {
+		if util.StrContains(util.SuperRoles, v) {
+			return true
+		}
+	}
+	return false
+}
+
+func nonNegativeQueryParam(params url.Values, name string) (int, error) {
+	str := util.QueryParamString(params, name, "0")
+	i, err := strconv.Atoi(str)
+	if err != nil || i < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative integer", name)
+	}
+	return i, nil
+}
+
+// FunctionSchemaHandler replies with JSON Schema of the function config
+func FunctionSchemaHandler(w http.ResponseWriter, r *http.Request) {
+	resJSON, err := json.Marshal(model.FunctionConfigSchema())
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(resJSON)
+}
+
+// ifMatch evaluates the If-Match precondition against the current function config
+func ifMatch(r *http.Request, key string) (bool, error) {
+	match := r.Header.Get("If-Match")
+	if match == "" {
+		return true, nil
+	}
+
+	doc, err := singleDb.GetByKey(key)
+	if err != nil {
+		if errors.Is(err, db.ErrDocNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if match == "*" {
+		return true, nil
+	}
+	etag, err := model.GenETag(*doc)
+	if err != nil {
+		return false, err
+	}
+	for _, v := range strings.Split(match, ",") {
+		if strings.TrimSpace(v) == etag {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// maskSecrets masks the topic tokens and the webhook credentials of the function config replied to clients.
+// The webhooks and the secret refs are copied since they are shared with the cached document.
+func maskSecrets(doc *model.FunctionConfig) {
+	doc.InputTopic.Token = model.Masked
+	doc.OutputTopic.Token = model.Masked
+	doc.LogTopic.Token = model.Masked
+	if len(doc.Webhooks) > 0 {
+		doc.Webhooks = append([]model.WebhookConfig{}, doc.Webhooks...)
+	}
+	for i := range doc.Webhooks {
+		wh := &doc.Webhooks[i]
+		if wh.Secret != "" {
+			wh.Secret = model.Masked
+		}
+		if wh.BasicAuthPass != "" {
+			wh.BasicAuthPass = model.Masked
+		}
+		headers := make([]string, len(wh.Headers))
+		for j, h := range wh.Headers {
+			headers[j] = strings.TrimSpace(strings.SplitN(h, ":", 2)[0]) + ": " + model.Masked
+		}
+		wh.Headers = headers
+	}
+	if len(doc.SecretRefs) > 0 {
+		refs := make(map[string]string, len(doc.SecretRefs))
+		for k := range doc.SecretRefs {
+			refs[k] = model.Masked
+		}
+		doc.SecretRefs = refs
+	}
 }
 
 // UpdateFunctionHandler creates or updates a function
 func UpdateFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	if err := r.ParseMultipartForm(10 << 10); err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusUnprocessableEntity)
+		replyError(err, w, http.StatusUnprocessableEntity)
 		return
 	}
 	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
 	if tenant == "" || functionName == "" || err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusUnprocessableEntity)
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
 		return
 	}
 	tokenStr, _, pulsarURL, err := util.ReceiverHeader(util.AllowedPulsarURLs, &r.Header)
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusUnauthorized)
+		replyError(err, w, http.StatusUnauthorized)
+		return
+	}
+	if ok, err := ifMatch(r, model.FunctionKey(tenant, functionName)); err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	} else if !ok {
+		replyError(errors.New("function config has been modified"), w, http.StatusPreconditionFailed)
 		return
 	}
 
@@ -268,7 +776,7 @@ func UpdateFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	doc := model.FunctionConfig{
 		Name:           functionName,
 		Tenant:         tenant,
-		ID:             tenant + functionName,
+		ID:             model.FunctionKey(tenant, functionName),
 		LanguagePack:   util.AssignString(r.FormValue("language-pack"), "javascript"),
 		Parallelism:    util.GetEnvInt(r.FormValue("parallelism"), 1),
 		TriggerType:    util.AssignString(r.FormValue("trigger-type"), "pulsar-topic"),
@@ -276,12 +784,26 @@ func UpdateFunctionHandler(w http.ResponseWriter, r *http.Request) {
 		CreatedAt:      now,
 		UpdatedAt:      now,
 	}
+	// a re-deployment without the status keeps the status of the existing function
+	if _, ok := r.MultipartForm.Value["function-status"]; !ok {
+		existing, err := singleDb.GetByKey(doc.ID)
+		if err == nil {
+			doc.FunctionStatus = existing.FunctionStatus
+		} else if !errors.Is(err, db.ErrDocNotFound) {
+			replyError(err, w, http.StatusInternalServerError)
+			return
+		}
+	}
+	if _, err := lambda.GetLanguagePack(doc.LanguagePack); err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
 	file, fileReader, err := r.FormFile("source")
 	if file != nil {
 		defer file.Close()
 	}
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusUnprocessableEntity)
+		replyError(err, w, http.StatusUnprocessableEntity)
 		return
 	}
 
@@ -298,6 +820,10 @@ func UpdateFunctionHandler(w http.ResponseWriter, r *http.Request) {
 			InitialPosition:  r.FormValue("subscription-initial-position"),
 			KeySharedPolicy:  r.FormValue("key-shared-policy"),
 		}
+		if err := lambda.ValidateParallelism(doc.Parallelism, doc.InputTopic.SubscriptionType); err != nil {
+			replyError(err, w, http.StatusUnprocessableEntity)
+			return
+		}
 	}
 	if r.FormValue("output-topic") != "" {
 		doc.OutputTopic = model.FunctionTopic{
@@ -311,22 +837,40 @@ func UpdateFunctionHandler(w http.ResponseWriter, r *http.Request) {
 	// read all of the contents of our uploaded file into a byte array
 	fileBytes, err := ioutil.ReadAll(file)
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusInternalServerError)
+		replyError(err, w, http.StatusInternalServerError)
 		return
 	}
+
+	// a retried creation with the same idempotency key replies with the original result
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	var fingerprint string
+	if idempotencyKey != "" {
+		fingerprint, err = requestFingerprint(r.MultipartForm.Value, fileBytes)
+		if err != nil {
+			replyError(err, w, http.StatusInternalServerError)
+			return
+		}
+		if replayed, err := db.LookupIdempotencyKey(singleDb, tenant, doc.ID, idempotencyKey, fingerprint); err != nil {
+			replyError(err, w, http.StatusConflict)
+			return
+		} else if replayed {
+			replyFunction(w, doc.ID, http.StatusOK)
+			return
+		}
+	}
 	doc.FunctionFilePath = lambda.GetSourceFilePath(doc.Tenant) + "/" + functionName + ".js"
 	// write this byte array to our temporary file
 	if err = ioutil.WriteFile(doc.FunctionFilePath, fileBytes, 0644); err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusInternalServerError)
+		replyError(err, w, http.StatusInternalServerError)
 		return
 	}
 
 	functionURLs := []string{}
 	for i := 0; i < doc.Parallelism; i++ {
-		url, err := lambda.StartNodeInstance(doc)
+		url, err := startNodeInstance(doc)
 		if err != nil {
 			log.Errorf("start function node failure %v", err)
-			util.ResponseErrorJSON(err, w, http.StatusInternalServerError)
+			replyError(err, w, http.StatusInternalServerError)
 			return
 		}
 		// return that we have successfully uploaded our file!
@@ -337,30 +881,294 @@ func UpdateFunctionHandler(w http.ResponseWriter, r *http.Request) {
 
 	log.Infof("function metadata %v", doc)
 
-	id, err := singleDb.Update(&doc)
+	auditedDb := db.WithAuditActor(singleDb, r.Header.Get("injectedSubs"))
+	var id string
+	if idempotencyKey != "" {
+		var replayed bool
+		id, replayed, err = db.IdempotentCreate(auditedDb, idempotencyKey, fingerprint, &doc)
+		if err == nil && replayed {
+			replyFunction(w, id, http.StatusOK)
+			return
+		}
+	} else {
+		id, err = auditedDb.Update(&doc)
+	}
 	if err != nil {
-		util.ResponseErrorJSON(err, w, http.StatusConflict)
+		replyError(err, w, http.StatusConflict)
 		return
 	}
 	if len(id) > 1 {
-		savedDoc, err := singleDb.GetByKey(id)
-		if err != nil {
-			util.ResponseErrorJSON(err, w, http.StatusInternalServerError)
+		replyFunction(w, id, http.StatusCreated)
+		return
+	}
+	replyError(fmt.Errorf("failed to update"), w, http.StatusInternalServerError)
+}
+
+// replyFunction replies with the saved function config
+func replyFunction(w http.ResponseWriter, key string, statusCode int) {
+	savedDoc, err := singleDb.GetByKey(key)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	if etag, err := model.GenETag(*savedDoc); err == nil {
+		w.Header().Set("ETag", etag)
+	}
+	maskSecrets(savedDoc)
+	resJSON, err := marshalAPI(savedDoc)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(resJSON)
+}
+
+// requestFingerprint is the content hash of the form values and the uploaded source file
+func requestFingerprint(values map[string][]string, source []byte) (string, error) {
+	data, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	h := sha1.New()
+	h.Write(data)
+	h.Write(source)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// DryRunFunctionHandler validates a function config without persisting it
+func DryRunFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	tokenStr, _, pulsarURL, err := util.ReceiverHeader(util.AllowedPulsarURLs, &r.Header)
+	if err != nil {
+		replyError(err, w, http.StatusUnauthorized)
+		return
+	}
+
+	var doc model.FunctionConfig
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&doc); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+
+	doc.Name = functionName
+	doc.Tenant = tenant
+	doc.ID = model.FunctionKey(tenant, functionName)
+	doc.TriggerType = util.AssignString(doc.TriggerType, lambda.PulsarTrigger)
+	if doc.Parallelism == 0 {
+		doc.Parallelism = 1
+	}
+	if doc.TriggerType == lambda.PulsarTrigger {
+		doc.InputTopic.PulsarURL = util.AssignString(doc.InputTopic.PulsarURL, pulsarURL)
+		doc.InputTopic.Token = util.AssignString(doc.InputTopic.Token, tokenStr)
+		doc.InputTopic.Tenant = tenant
+	}
+	if err := lambda.ValidateFunctionConfig(&doc); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	if err := singleDb.Validate(&doc); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+
+	maskSecrets(&doc)
+	resJSON, err := marshalAPI(doc)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// StatusTransitionHandler returns a handler to transition the function status
+func StatusTransitionHandler(to model.Status) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+		if tenant == "" || functionName == "" || err != nil {
+			replyError(err, w, http.StatusUnprocessableEntity)
+			return
+		}
+		if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+			replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
 			return
 		}
-		w.WriteHeader(http.StatusCreated)
-		savedDoc.InputTopic.Token = "***"
-		savedDoc.OutputTopic.Token = "***"
-		savedDoc.LogTopic.Token = "***"
-		resJSON, err := json.Marshal(savedDoc)
+		doc, err := singleDb.GetByKey(model.FunctionKey(tenant, functionName))
 		if err != nil {
-			util.ResponseErrorJSON(err, w, http.StatusInternalServerError)
+			replyError(err, w, http.StatusNotFound)
+			return
+		}
+
+		if doc.FunctionStatus != to {
+			if err := model.Transition(doc, to); err != nil {
+				replyError(err, w, http.StatusConflict)
+				return
+			}
+			if _, err := db.WithAuditActor(singleDb, r.Header.Get("injectedSubs")).Update(doc); err != nil {
+				replyError(err, w, http.StatusInternalServerError)
+				return
+			}
+		}
+		replyFunction(w, doc.ID, http.StatusOK)
+	}
+}
+
+// TouchFunctionHandler refreshes the function UpdatedAt without other changes
+func TouchFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	key, err := db.WithAuditActor(singleDb, r.Header.Get("injectedSubs")).Touch(tenant, functionName)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	replyFunction(w, key, http.StatusOK)
+}
+
+// FunctionRuntimeHandler replies with the live runtime state of the function
+func FunctionRuntimeHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	if functionRuntime == nil {
+		replyError(errors.New("functions are not run by this server"), w, http.StatusServiceUnavailable)
+		return
+	}
+	doc, err := singleDb.GetByKey(model.FunctionKey(tenant, functionName))
+	if err != nil {
+		replyError(err, w, http.StatusNotFound)
+		return
+	}
+	resJSON, err := marshalAPI(functionRuntime.RuntimeStatus(doc))
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(resJSON)
+}
+
+// CloneFunctionRequest is the request body to clone a function
+type CloneFunctionRequest struct {
+	Name string `json:"name"`
+}
+
+// CloneFunctionHandler creates a copy of the function under the new name in the same tenant
+func CloneFunctionHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	var req CloneFunctionRequest
+	decoder := json.NewDecoder(r.Body)
+	defer r.Body.Close()
+	if err := decoder.Decode(&req); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	key, err := db.WithAuditActor(singleDb, r.Header.Get("injectedSubs")).Clone(tenant, functionName, req.Name)
+	if err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	replyFunction(w, key, http.StatusCreated)
+}
+
+// ExportManifestHandler replies with the function manifest in YAML, or in JSON with the format=json query parameter
+func ExportManifestHandler(w http.ResponseWriter, r *http.Request) {
+	tenant, functionName, err := tenantFunctionName(mux.Vars(r))
+	if tenant == "" || functionName == "" || err != nil {
+		replyError(err, w, http.StatusUnprocessableEntity)
+		return
+	}
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	manifest, err := db.ExportManifest(singleDb, tenant, functionName)
+	if err != nil {
+		replyError(err, w, http.StatusInternalServerError)
+		return
+	}
+	contentType := "application/x-yaml"
+	if r.URL.Query().Get("format") == "json" {
+		if manifest, err = yaml.YAMLToJSON(manifest); err != nil {
+			replyError(err, w, http.StatusInternalServerError)
 			return
 		}
-		w.Write(resJSON)
+		contentType = "application/json"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(manifest)
+}
+
+// ImportManifestHandler creates or updates a function of the tenant from a YAML or JSON manifest
+func ImportManifestHandler(w http.ResponseWriter, r *http.Request) {
+	tenant := mux.Vars(r)["tenant"]
+	if !VerifySubject(tenant, r.Header.Get("injectedSubs"), ExtractEvalTenant) {
+		replyError(errors.New("incorrect subject"), w, http.StatusForbidden)
+		return
+	}
+	tokenStr, _, pulsarURL, err := util.ReceiverHeader(util.AllowedPulsarURLs, &r.Header)
+	if err != nil {
+		replyError(err, w, http.StatusUnauthorized)
+		return
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	defer r.Body.Close()
+	if err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	doc, err := db.ParseManifest(body)
+	if err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	// the topics without a cluster default to the cluster and the token of the request
+	for _, topic := range []*model.FunctionTopic{&doc.InputTopic, &doc.OutputTopic} {
+		if topic.TopicFullName != "" || topic.TopicsPattern != "" || len(topic.Topics) > 0 {
+			topic.PulsarURL = util.AssignString(topic.PulsarURL, pulsarURL)
+			topic.Token = util.AssignString(topic.Token, tokenStr)
+			topic.Tenant = tenant
+		}
+	}
+	doc.TriggerType = util.AssignString(doc.TriggerType, lambda.PulsarTrigger)
+	if err := lambda.ValidateFunctionConfig(doc); err != nil {
+		replyError(err, w, http.StatusBadRequest)
+		return
+	}
+	key, err := db.ImportManifest(db.WithAuditActor(singleDb, r.Header.Get("injectedSubs")), tenant, doc)
+	if err != nil {
+		replyError(err, w, http.StatusConflict)
 		return
 	}
-	util.ResponseErrorJSON(fmt.Errorf("failed to update"), w, http.StatusInternalServerError)
+	replyFunction(w, key, http.StatusOK)
 }
 
 // DeleteFunctionHandler deletes a function
@@ -0,0 +1,700 @@
+package route
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/lambda"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// healthDb reports a fixed health
+type healthDb struct {
+	db.Db
+	report db.HealthReport
+}
+
+func (h *healthDb) HealthReport() db.HealthReport { return h.report }
+func (h *healthDb) Ready() bool                   { return h.report.Ready }
+
+// fakeRuntime reports every function running with a fixed number of consumers
+type fakeRuntime struct {
+	consumers int
+}
+
+func (f *fakeRuntime) RuntimeStatus(cfg *model.FunctionConfig) lambda.RuntimeStatus {
+	return lambda.RuntimeStatus{ID: cfg.ID, Status: cfg.FunctionStatus, Connected: true, Consumers: f.consumers}
+}
+
+// useDb replaces the database of the handlers and returns a function restoring the previous one
+func useDb(database db.Db) func() {
+	previous := singleDb
+	singleDb = database
+	return func() { singleDb = previous }
+}
+
+func newInMemoryDb(t *testing.T) db.Db {
+	database, err := db.NewInMemoryHandler()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database
+}
+
+// functionRequest builds a request of the function route with the route variables and the subject
+func functionRequest(method, tenant, function, subject, body string) *http.Request {
+	r := httptest.NewRequest(method, "/v2/function/"+tenant+"/"+function, strings.NewReader(body))
+	r.Header.Set("injectedSubs", subject)
+	r.Header.Set("PulsarUrl", "pulsar://localhost:6650")
+	r.Header.Set("Content-Type", "application/json")
+	return mux.SetURLVars(r, map[string]string{"tenant": tenant, "function": function})
+}
+
+// deployRequest is a function deployment form of the fields and a source file
+func deployRequest(t *testing.T, method, tenant, function, subject string, fields map[string]string) *http.Request {
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for k, v := range fields {
+		form.WriteField(k, v)
+	}
+	source, err := form.CreateFormFile("source", function+".js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	source.Write([]byte("module.exports = (msg) => msg"))
+	form.Close()
+	r := functionRequest(method, tenant, function, subject, "")
+	r.Body = ioutil.NopCloser(&body)
+	r.Header.Set("Content-Type", form.FormDataContentType())
+	return r
+}
+
+// useDeployment writes the function sources to a temporary directory and fakes the function instances
+func useDeployment(t *testing.T) func() {
+	dir, err := ioutil.TempDir("", "functions")
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("FunctionBaseDir", dir)
+	previous := startNodeInstance
+	startNodeInstance = func(cfg model.FunctionConfig) (string, error) { return "http://localhost:8000", nil }
+	return func() {
+		startNodeInstance = previous
+		os.Unsetenv("FunctionBaseDir")
+		os.RemoveAll(dir)
+	}
+}
+
+func TestHealthSummaryHandler(t *testing.T) {
+	lastReadAt := time.Now().Add(-time.Second).UTC().Truncate(time.Second)
+	cases := []struct {
+		report db.HealthReport
+		status int
+	}{
+		{db.HealthReport{Healthy: true, Ready: true, CacheSize: 3, LastReadAt: lastReadAt, ReaderLag: "5ms", ProducerConnected: true}, http.StatusOK},
+		{db.HealthReport{Healthy: false, CacheSize: 1, ReaderLag: "0s"}, http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		restore := useDb(&healthDb{report: c.report})
+		rr := httptest.NewRecorder()
+		HealthSummaryHandler(rr, httptest.NewRequest(http.MethodGet, "/health", nil))
+		restore()
+
+		if rr.Code != c.status {
+			t.Errorf("expected status %d, got %d", c.status, rr.Code)
+		}
+		var report db.HealthReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if report.CacheSize != c.report.CacheSize || report.ReaderLag != c.report.ReaderLag ||
+			!report.LastReadAt.Equal(c.report.LastReadAt) || report.ProducerConnected != c.report.ProducerConnected {
+			t.Errorf("expected report %+v, got %+v", c.report, report)
+		}
+	}
+}
+
+func TestDryRunFunctionHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	_, err := database.Create(&model.FunctionConfig{Tenant: "t1", Name: "owner", InputTopic: model.FunctionTopic{
+		TopicFullName: "persistent://t1/ns/claimed", PulsarURL: "pulsar://localhost:6650", Subscription: "sub",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name   string
+		body   string
+		status int
+		errors int
+	}{
+		{"valid", `{"inputTopics":{"topicFullName":"persistent://t1/ns/in","subscription":"sub"}}`, http.StatusOK, 0},
+		{"malformed json", `{"inputTopics":`, http.StatusBadRequest, 0},
+		{"missing subscription", `{"inputTopics":{"topicFullName":"persistent://t1/ns/in"}}`, http.StatusBadRequest, 1},
+		{"several problems", `{"inputTopics":{"topicFullName":"persistent://t1/ns/in","subscription":"sub",` +
+			`"subscriptionType":"bogus"},"outputTopics":{"topicFullName":"persistent://t1/ns/in"},"languagePack":"cobol"}`,
+			http.StatusBadRequest, 4},
+		{"claimed exclusive subscription", `{"inputTopics":{"topicFullName":"persistent://t1/ns/claimed","subscription":"sub"}}`,
+			http.StatusConflict, 0},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		DryRunFunctionHandler(rr, functionRequest(http.MethodPost, "t1", "f1", "t1-admin", c.body))
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d %s", c.name, c.status, rr.Code, rr.Body.String())
+			continue
+		}
+		var envelope ErrorEnvelope
+		json.Unmarshal(rr.Body.Bytes(), &envelope)
+		if len(envelope.Errors) != c.errors {
+			t.Errorf("%s: expected %d field errors, got %+v", c.name, c.errors, envelope.Errors)
+		}
+	}
+	if cfgs, _ := database.Load(); len(cfgs) != 1 {
+		t.Fatalf("a dry run must not persist the function, got %d functions", len(cfgs))
+	}
+}
+
+func TestGetFunctionHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	_, err := database.Create(&model.FunctionConfig{
+		Tenant:     "t1",
+		Name:       "f1",
+		InputTopic: model.FunctionTopic{Token: "input-token"},
+		SecretRefs: map[string]string{"API_KEY": "PUBSUBFN_SECRET_API_KEY"},
+		Webhooks: []model.WebhookConfig{{
+			URL:           "https://example.com/hook",
+			Headers:       []string{"Authorization: Bearer abc", "X-Tenant:t1"},
+			Secret:        "hmac-secret",
+			BasicAuthUser: "user",
+			BasicAuthPass: "password",
+			WebhookStatus: model.Activated,
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rr := httptest.NewRecorder()
+	GetFunctionHandler(rr, functionRequest(http.MethodGet, "t1", "f1", "t2-admin", ""))
+	if rr.Code != http.StatusForbidden {
+		t.Fatalf("another tenant must not read the function, got status %d", rr.Code)
+	}
+
+	rr = httptest.NewRecorder()
+	GetFunctionHandler(rr, functionRequest(http.MethodGet, "t1", "f1", "t1-admin", ""))
+	if rr.Code != http.StatusOK || rr.Header().Get("ETag") == "" {
+		t.Fatalf("expected the function with an ETag, got status %d", rr.Code)
+	}
+	body := rr.Body.String()
+	for _, secret := range []string{"input-token", "hmac-secret", "password", "Bearer abc", "PUBSUBFN_SECRET_API_KEY"} {
+		if strings.Contains(body, secret) {
+			t.Errorf("the reply leaks %s", secret)
+		}
+	}
+	var doc model.FunctionConfig
+	json.Unmarshal(rr.Body.Bytes(), &doc)
+	wh := doc.Webhooks[0]
+	if wh.Headers[0] != "Authorization: ***" || wh.Headers[1] != "X-Tenant: ***" || wh.BasicAuthUser != "user" {
+		t.Errorf("unexpected masked webhook %+v", wh)
+	}
+
+	stored, _ := database.GetByKey("t1f1")
+	if stored.Webhooks[0].Secret != "hmac-secret" || stored.Webhooks[0].Headers[0] != "Authorization: Bearer abc" ||
+		stored.SecretRefs["API_KEY"] != "PUBSUBFN_SECRET_API_KEY" {
+		t.Fatalf("masking must not change the stored function %+v", stored)
+	}
+}
+
+func TestIfMatch(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	key, _ := database.Create(&model.FunctionConfig{Tenant: "t1", Name: "f1"})
+	doc, _ := database.GetByKey(key)
+	etag, _ := model.GenETag(*doc)
+
+	cases := []struct {
+		match string
+		ok    bool
+	}{
+		{"", true},
+		{"*", true},
+		{etag, true},
+		{`"stale", ` + etag, true},
+		{`"stale"`, false},
+	}
+	for _, c := range cases {
+		r := functionRequest(http.MethodPut, "t1", "f1", "t1-admin", "")
+		r.Header.Set("If-Match", c.match)
+		if ok, err := ifMatch(r, key); err != nil || ok != c.ok {
+			t.Errorf("If-Match %s: expected %v, got %v error %v", c.match, c.ok, ok, err)
+		}
+	}
+}
+
+func TestListFunctionsHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	for _, f := range []struct {
+		tenant, name string
+		status       model.Status
+	}{
+		{"t1", "a", model.Activated}, {"t1", "b", model.Suspended}, {"t1", "c", model.Activated},
+		{"t1", "d", model.Activated}, {"t2", "e", model.Activated},
+	} {
+		if _, err := database.Create(&model.FunctionConfig{Tenant: f.tenant, Name: f.name, FunctionStatus: f.status}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cases := []struct {
+		query   string
+		subject string
+		status  int
+		total   int
+		ids     []string
+	}{
+		{"tenant=t1&status=activated&offset=1&limit=1", "t1-admin", http.StatusOK, 3, []string{"t1c"}},
+		{"tenant=t1&status=Suspended", "t1-admin", http.StatusOK, 1, []string{"t1b"}},
+		{"tenant=t1&status=activated&offset=5", "t1-admin", http.StatusOK, 3, []string{}},
+		{"tenant=t1&cursor=&limit=2", "t1-admin", http.StatusOK, 4, []string{"t1a", "t1b"}},
+		{"tenant=t1&cursor=" + db.EncodeCursor("t1b") + "&limit=5", "t1-admin", http.StatusOK, 4, []string{"t1c", "t1d"}},
+		{"tenant=t1&cursor=%21%21", "t1-admin", http.StatusBadRequest, 0, nil},
+		{"tenant=t1&status=bogus", "t1-admin", http.StatusBadRequest, 0, nil},
+		{"tenant=t1&limit=-1", "t1-admin", http.StatusBadRequest, 0, nil},
+		{"tenant=t1&offset=x", "t1-admin", http.StatusBadRequest, 0, nil},
+		{"tenant=t1", "t2-admin", http.StatusForbidden, 0, nil},
+		{"status=activated", "t1-admin", http.StatusForbidden, 0, nil},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/v2/functions?"+c.query, nil)
+		r.Header.Set("injectedSubs", c.subject)
+		rr := httptest.NewRecorder()
+		ListFunctionsHandler(rr, r)
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.query, c.status, rr.Code)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var list FunctionList
+		json.Unmarshal(rr.Body.Bytes(), &list)
+		ids := []string{}
+		for _, v := range list.Items {
+			ids = append(ids, v.ID)
+		}
+		if list.Total != c.total || strings.Join(ids, ",") != strings.Join(c.ids, ",") {
+			t.Errorf("%s: expected total %d items %v, got total %d items %v", c.query, c.total, c.ids, list.Total, ids)
+		}
+	}
+}
+
+func TestMarshalAPI(t *testing.T) {
+	cases := []struct {
+		name  string
+		reply interface{}
+		want  []string
+	}{
+		{
+			"function with webhooks",
+			model.FunctionConfig{FunctionStatus: model.Suspended, Webhooks: []model.WebhookConfig{{WebhookStatus: model.Activated}}},
+			[]string{`"functionStatus":"suspended"`, `"webhookStatus":"activated"`},
+		},
+		{
+			"function list",
+			struct {
+				Items []model.FunctionConfig `json:"items"`
+			}{[]model.FunctionConfig{{FunctionStatus: model.Deleted}}},
+			[]string{`"functionStatus":"deleted"`},
+		},
+		{
+			"unrelated fields",
+			map[string]interface{}{"retries": 3, "name": "status"},
+			[]string{`"retries":3`, `"name":"status"`},
+		},
+	}
+	for _, c := range cases {
+		data, err := marshalAPI(c.reply)
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		for _, want := range c.want {
+			if !strings.Contains(string(data), want) {
+				t.Errorf("%s: expected %s in %s", c.name, want, data)
+			}
+		}
+	}
+}
+
+func TestStatusTransitionHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	if _, err := database.Create(&model.FunctionConfig{Tenant: "t1", Name: "f1"}); err != nil {
+		t.Fatal(err)
+	}
+	handlers := map[string]http.HandlerFunc{}
+	for _, route := range RestRoutes {
+		if route.Method == http.MethodPatch && strings.HasPrefix(route.Pattern, "/v2/function/{tenant}/{function}/") {
+			handlers[route.Pattern[strings.LastIndex(route.Pattern, "/")+1:]] = route.HandlerFunc
+		}
+	}
+
+	cases := []struct {
+		action  string
+		subject string
+		code    int
+		status  model.Status
+	}{
+		{"activate", "t2-admin", http.StatusForbidden, model.Deactivated},
+		{"activate", "t1-admin", http.StatusOK, model.Activated},
+		{"activate", "t1-admin", http.StatusOK, model.Activated},
+		{"deactivate", "t1-admin", http.StatusConflict, model.Activated},
+		{"suspend", "t1-admin", http.StatusOK, model.Suspended},
+		{"deactivate", "t1-admin", http.StatusOK, model.Deactivated},
+		{"deactivate", "t1-admin", http.StatusOK, model.Deactivated},
+		{"activate", "t1-admin", http.StatusOK, model.Activated},
+	}
+	for i, c := range cases {
+		// the transition routes take no request body so no content type is required
+		r := functionRequest(http.MethodPatch, "t1", "f1", c.subject, "")
+		r.Header.Del("Content-Type")
+		rr := httptest.NewRecorder()
+		handlers[c.action](rr, r)
+		if rr.Code != c.code {
+			t.Errorf("%d %s: expected status %d, got %d %s", i, c.action, c.code, rr.Code, rr.Body.String())
+		}
+		if doc, _ := database.GetByKey("t1f1"); doc.FunctionStatus != c.status {
+			t.Errorf("%d %s: expected function status %s, got %s", i, c.action, c.status, doc.FunctionStatus)
+		}
+	}
+}
+
+func TestUntimedRoutesExist(t *testing.T) {
+	patterns := map[string]bool{}
+	for _, route := range RestRoutes {
+		patterns[route.Pattern] = true
+	}
+	for pattern := range untimedRoutes {
+		if !patterns[pattern] {
+			t.Errorf("untimed route %s is not a REST route", pattern)
+		}
+	}
+}
+
+func TestDebugConfigHandler(t *testing.T) {
+	defer func(roles []string, password string) {
+		util.SuperRoles, util.Config.DbPassword = roles, password
+	}(util.SuperRoles, util.Config.DbPassword)
+	util.SuperRoles = []string{"superuser"}
+	util.Config.DbPassword = "secret-token"
+
+	cases := []struct {
+		subject string
+		status  int
+	}{
+		{"superuser", http.StatusOK},
+		{"tenant1", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/debug/config", nil)
+		r.Header.Set("injectedSubs", c.subject)
+		rr := httptest.NewRecorder()
+		DebugConfigHandler(rr, r)
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d", c.subject, c.status, rr.Code)
+		}
+		if strings.Contains(rr.Body.String(), "secret-token") {
+			t.Errorf("%s: expected the password to be redacted, got %s", c.subject, rr.Body.String())
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var cfg map[string]string
+		if err := json.Unmarshal(rr.Body.Bytes(), &cfg); err != nil {
+			t.Fatal(err)
+		}
+		if cfg["DbPassword"] != "***" || cfg["DbName"] != util.Config.DbName {
+			t.Errorf("unexpected configuration %v", cfg)
+		}
+	}
+}
+
+// verifyDb reports fixed divergent keys
+type verifyDb struct {
+	db.Db
+	keys []string
+	err  error
+}
+
+func (v *verifyDb) Verify() ([]string, error) { return v.keys, v.err }
+
+func TestVerifyHandler(t *testing.T) {
+	defer func(roles []string) { util.SuperRoles = roles }(util.SuperRoles)
+	util.SuperRoles = []string{"superuser"}
+
+	cases := []struct {
+		subject string
+		db      *verifyDb
+		status  int
+		keys    []string
+	}{
+		{"superuser", &verifyDb{keys: []string{}}, http.StatusOK, []string{}},
+		{"superuser", &verifyDb{keys: []string{"t1f1", "t1f2"}}, http.StatusOK, []string{"t1f1", "t1f2"}},
+		{"superuser", &verifyDb{err: errors.New("reader failure")}, http.StatusInternalServerError, nil},
+		{"tenant1", &verifyDb{keys: []string{}}, http.StatusForbidden, nil},
+	}
+	for i, c := range cases {
+		restore := useDb(c.db)
+		r := httptest.NewRequest(http.MethodPost, "/v2/admin/verify", nil)
+		r.Header.Set("injectedSubs", c.subject)
+		rr := httptest.NewRecorder()
+		VerifyHandler(rr, r)
+		restore()
+
+		if rr.Code != c.status {
+			t.Errorf("%d: expected status %d, got %d", i, c.status, rr.Code)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var report VerifyReport
+		if err := json.Unmarshal(rr.Body.Bytes(), &report); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Join(report.DivergentKeys, ",") != strings.Join(c.keys, ",") {
+			t.Errorf("%d: expected divergent keys %v, got %v", i, c.keys, report.DivergentKeys)
+		}
+	}
+}
+
+func TestTopicSubscriptionsHandler(t *testing.T) {
+	defer func(roles []string) { util.SuperRoles = roles }(util.SuperRoles)
+	util.SuperRoles = []string{"superuser"}
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	cfg := &model.FunctionConfig{Tenant: "t1", Name: "f1", InputTopic: model.FunctionTopic{
+		TopicFullName:    "persistent://public/default/input",
+		PulsarURL:        "pulsar://localhost:6650",
+		Subscription:     "sub",
+		SubscriptionType: "shared",
+	}}
+	if _, err := database.Create(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		subject string
+		topic   string
+		status  int
+		subs    string
+	}{
+		{"superuser", "persistent://public/default/input", http.StatusOK, "sub"},
+		{"superuser", "persistent://public/default/other", http.StatusOK, ""},
+		{"superuser", "input", http.StatusUnprocessableEntity, ""},
+		{"t1-admin", "persistent://public/default/input", http.StatusForbidden, ""},
+	}
+	for _, c := range cases {
+		r := httptest.NewRequest(http.MethodGet, "/v2/admin/subscriptions?topic="+c.topic, nil)
+		r.Header.Set("injectedSubs", c.subject)
+		rr := httptest.NewRecorder()
+		TopicSubscriptionsHandler(rr, r)
+		if rr.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d", c.subject, c.topic, c.status, rr.Code)
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var subs []db.SubscriptionInfo
+		if err := json.Unmarshal(rr.Body.Bytes(), &subs); err != nil {
+			t.Fatal(err)
+		}
+		names := []string{}
+		for _, s := range subs {
+			names = append(names, s.Subscription)
+		}
+		if strings.Join(names, ",") != c.subs {
+			t.Errorf("%s: expected subscriptions %s, got %v", c.topic, c.subs, names)
+		}
+	}
+}
+
+func TestCloneFunctionHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	if _, err := database.Create(&model.FunctionConfig{Tenant: "t1", Name: "f1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		subject string
+		body    string
+		status  int
+	}{
+		{"t1-admin", `{"name":"f2"}`, http.StatusCreated},
+		{"t1-admin", `{"name":"f2"}`, http.StatusConflict},
+		{"t1-admin", `{"name":`, http.StatusBadRequest},
+		{"t2-admin", `{"name":"f3"}`, http.StatusForbidden},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		CloneFunctionHandler(rr, functionRequest(http.MethodPost, "t1", "f1", c.subject, c.body))
+		if rr.Code != c.status {
+			t.Errorf("%s %s: expected status %d, got %d %s", c.subject, c.body, c.status, rr.Code, rr.Body.String())
+		}
+	}
+	if _, err := database.GetByTopic("t1", "f2"); err != nil {
+		t.Errorf("expected the clone found by its names, got %v", err)
+	}
+}
+
+func TestProbeHandlers(t *testing.T) {
+	cases := []struct {
+		name      string
+		database  db.Db
+		readiness int
+	}{
+		{"ready", &healthDb{report: db.HealthReport{Healthy: true, Ready: true}}, http.StatusOK},
+		{"broker outage", &healthDb{report: db.HealthReport{Healthy: false, Ready: false}}, http.StatusServiceUnavailable},
+		{"no database", nil, http.StatusServiceUnavailable},
+	}
+	for _, c := range cases {
+		restore := useDb(c.database)
+		live := httptest.NewRecorder()
+		LivenessHandler(live, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+		ready := httptest.NewRecorder()
+		ReadinessHandler(ready, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		restore()
+
+		if live.Code != http.StatusOK {
+			t.Errorf("%s: expected liveness status 200, got %d", c.name, live.Code)
+		}
+		if ready.Code != c.readiness {
+			t.Errorf("%s: expected readiness status %d, got %d", c.name, c.readiness, ready.Code)
+		}
+	}
+}
+
+func TestFunctionRuntimeHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	if _, err := database.Create(&model.FunctionConfig{Tenant: "t1", Name: "f1", FunctionStatus: model.Activated}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetRuntime(functionRuntime)
+
+	cases := []struct {
+		name     string
+		runtime  RuntimeSource
+		function string
+		subject  string
+		status   int
+	}{
+		{"running", &fakeRuntime{consumers: 2}, "f1", "t1-admin", http.StatusOK},
+		{"not run by this server", nil, "f1", "t1-admin", http.StatusServiceUnavailable},
+		{"unknown function", &fakeRuntime{consumers: 2}, "f2", "t1-admin", http.StatusNotFound},
+		{"other tenant", &fakeRuntime{consumers: 2}, "f1", "t2-admin", http.StatusForbidden},
+	}
+	for _, c := range cases {
+		SetRuntime(c.runtime)
+		rr := httptest.NewRecorder()
+		FunctionRuntimeHandler(rr, functionRequest(http.MethodGet, "t1", c.function, c.subject, ""))
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d %s", c.name, c.status, rr.Code, rr.Body.String())
+			continue
+		}
+		if c.status != http.StatusOK {
+			continue
+		}
+		var status lambda.RuntimeStatus
+		if err := json.Unmarshal(rr.Body.Bytes(), &status); err != nil {
+			t.Fatal(err)
+		}
+		if status.ID != "t1f1" || status.Status != model.Activated || !status.Connected || status.Consumers != 2 {
+			t.Errorf("%s: unexpected runtime status %+v", c.name, status)
+		}
+	}
+}
+
+func TestUpdateFunctionHandler(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	defer useDeployment(t)()
+	fields := map[string]string{"input-topic": "persistent://t1/ns/input", "subscription-type": "shared"}
+
+	cases := []struct {
+		name    string
+		method  string
+		subject string
+		status  int
+	}{
+		{"create", http.MethodPost, "t1-admin", http.StatusCreated},
+		{"other tenant create", http.MethodPost, "t2-admin", http.StatusForbidden},
+		{"other tenant update", http.MethodPut, "t2-admin", http.StatusForbidden},
+		{"update", http.MethodPut, "t1-admin", http.StatusCreated},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		UpdateFunctionHandler(rr, deployRequest(t, c.method, "t1", "f1", c.subject, fields))
+		if rr.Code != c.status {
+			t.Errorf("%s: expected status %d, got %d %s", c.name, c.status, rr.Code, rr.Body.String())
+		}
+	}
+	if cfgs, _ := database.LoadByTenant("t1"); len(cfgs) != 1 {
+		t.Errorf("expected a single function of the tenant, got %d", len(cfgs))
+	}
+}
+
+func TestRedeployKeepsStatus(t *testing.T) {
+	database := newInMemoryDb(t)
+	defer useDb(database)()
+	defer useDeployment(t)()
+	fields := func(status string) map[string]string {
+		f := map[string]string{"input-topic": "persistent://t1/ns/input", "subscription-type": "shared"}
+		if status != "" {
+			f["function-status"] = status
+		}
+		return f
+	}
+
+	cases := []struct {
+		name   string
+		form   map[string]string
+		code   int
+		status model.Status
+	}{
+		{"create", fields(""), http.StatusCreated, model.Deactivated},
+		{"activate", fields("activated"), http.StatusCreated, model.Activated},
+		{"redeploy", fields(""), http.StatusCreated, model.Activated},
+		{"illegal status", fields("deactivated"), http.StatusConflict, model.Activated},
+		{"suspend", fields("suspended"), http.StatusCreated, model.Suspended},
+		{"redeploy suspended", fields(""), http.StatusCreated, model.Suspended},
+	}
+	for _, c := range cases {
+		rr := httptest.NewRecorder()
+		UpdateFunctionHandler(rr, deployRequest(t, http.MethodPut, "t1", "f1", "t1-admin", c.form))
+		if rr.Code != c.code {
+			t.Errorf("%s: expected status %d, got %d %s", c.name, c.code, rr.Code, rr.Body.String())
+		}
+		if doc, _ := database.GetByKey("t1f1"); doc == nil || doc.FunctionStatus != c.status {
+			t.Errorf("%s: expected function status %s, got %+v", c.name, c.status, doc)
+		}
+	}
+}
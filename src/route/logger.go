@@ -1,19 +1,30 @@
 package route
 
 import (
-	"log"
 	"net/http"
 	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/icrypto"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
 )
 
+// RequestIDHeader carries the request id, it is generated if the client does not specify one
+const RequestIDHeader = "X-Request-Id"
+
 // Logger logs http traffic.
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		requestID := r.Header.Get(RequestIDHeader)
+		if requestID == "" {
+			requestID = icrypto.GenUUID()
+			r.Header.Set(RequestIDHeader, requestID)
+		}
+		w.Header().Set(RequestIDHeader, requestID)
 
 		inner.ServeHTTP(w, r)
 
-		log.Printf(
+		util.LogEntry("http", requestID).Infof(
 			"%s\t%s\t%s\t%s",
 			r.Method,
 			r.RequestURI,
@@ -0,0 +1,49 @@
+package route
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+	log "github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+)
+
+func TestLoggerRequestID(t *testing.T) {
+	hook := test.NewGlobal()
+	defer log.StandardLogger().ReplaceHooks(make(log.LevelHooks))
+
+	cases := []struct {
+		requestID string
+	}{
+		{"client-request-id"},
+		// the request id is generated if the client does not specify one
+		{""},
+	}
+	for _, c := range cases {
+		hook.Reset()
+		var handled string
+		handler := Logger(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handled = r.Header.Get(RequestIDHeader)
+		}), "test route")
+		r := httptest.NewRequest(http.MethodGet, "/status", nil)
+		if c.requestID != "" {
+			r.Header.Set(RequestIDHeader, c.requestID)
+		}
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, r)
+
+		requestID := rr.Header().Get(RequestIDHeader)
+		if requestID == "" || (c.requestID != "" && requestID != c.requestID) || handled != requestID {
+			t.Errorf("%q: unexpected request id %q handled as %q", c.requestID, requestID, handled)
+		}
+		entry := hook.LastEntry()
+		if entry == nil {
+			t.Fatalf("%q: expected the request to be logged", c.requestID)
+		}
+		if entry.Data[util.LogKeyRequestID] != requestID || entry.Data[util.LogKeyComponent] != "http" || entry.Data[util.LogKeyApp] != util.AppName {
+			t.Errorf("%q: unexpected log fields %v", c.requestID, entry.Data)
+		}
+	}
+}
@@ -1,7 +1,9 @@
 package route
 
 import (
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/gorilla/mux"
 
@@ -11,43 +13,58 @@ import (
 )
 
 // NewRouter - create new router for HTTP routing
-func NewRouter(mode *string) *mux.Router {
+func NewRouter(mode *string) (*mux.Router, error) {
+	routes, err := GetEffectiveRoutes(mode)
+	if err != nil {
+		return nil, err
+	}
 
 	router := mux.NewRouter().StrictSlash(true)
-	for _, route := range GetEffectiveRoutes(mode) {
+	for _, route := range routes {
 		var handler http.Handler
 
 		handler = route.HandlerFunc
 		handler = Logger(handler, route.Name)
+		handler = route.AuthFunc(middleware.LimitTenantRate(handler))
+		if !untimedRoutes[route.Pattern] {
+			// the timeout is applied per route since the router middlewares are built on every request
+			handler = middleware.ConfigTimeout(handler)
+		}
 
 		router.
 			Methods(route.Method).
 			Path(route.Pattern).
 			Name(route.Name).
-			Handler(route.AuthFunc(handler))
+			Handler(handler)
 
 	}
 	// TODO rate limit can be added per route basis
 	router.Use(middleware.LimitRate)
 
 	log.Infof("router added")
-	return router
+	return router, nil
 }
 
-// GetEffectiveRoutes gets effective routes
-func GetEffectiveRoutes(mode *string) Routes {
-	return append(PrometheusRoute, getRoutes(mode)...)
+// GetEffectiveRoutes gets effective routes, an unknown mode is rejected
+func GetEffectiveRoutes(mode *string) (Routes, error) {
+	routes, err := getRoutes(mode)
+	if err != nil {
+		return nil, err
+	}
+	return append(append(PrometheusRoute, ProbeRoutes...), routes...), nil
 }
 
-func getRoutes(mode *string) Routes {
+func getRoutes(mode *string) (Routes, error) {
 	switch *mode {
 	case util.Hybrid:
-		return append(ReceiverRoutes, RestRoutes...)
+		return append(ReceiverRoutes, RestRoutes...), nil
 	case util.Receiver:
-		return ReceiverRoutes
+		return ReceiverRoutes, nil
 	case util.HTTPOnly:
-		return append(ReceiverRoutes, RestRoutes...)
+		return append(ReceiverRoutes, RestRoutes...), nil
+	case util.Rest, util.TokenServer, util.HTTPWithNoRest, util.Writer:
+		return RestRoutes, nil
 	default:
-		return RestRoutes
+		return nil, fmt.Errorf("unsupported server mode %s, supported modes are %s", *mode, strings.Join(util.HTTPRouterModes, ", "))
 	}
 }
@@ -0,0 +1,54 @@
+package route
+
+import (
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func TestGetEffectiveRoutes(t *testing.T) {
+	cases := []struct {
+		mode     string
+		receiver bool
+		rest     bool
+		fails    bool
+	}{
+		{util.Hybrid, true, true, false},
+		{util.HTTPOnly, true, true, false},
+		{util.Receiver, true, false, false},
+		{util.Rest, false, true, false},
+		{util.TokenServer, false, true, false},
+		{util.HTTPWithNoRest, false, true, false},
+		{util.Writer, false, true, false},
+		// the broker mode runs no http router
+		{util.Broker, false, false, true},
+		{"hybird", false, false, true},
+		{"", false, false, true},
+	}
+	for _, c := range cases {
+		mode := c.mode
+		routes, err := GetEffectiveRoutes(&mode)
+		if (err != nil) != c.fails {
+			t.Errorf("%q: expected failure %v, got %v", c.mode, c.fails, err)
+		}
+		if c.fails {
+			if routes != nil {
+				t.Errorf("%q: expected no routes, got %d", c.mode, len(routes))
+			}
+			continue
+		}
+		if !util.IsHTTPRouterRequired(&mode) || !util.IsValidMode(&mode) {
+			t.Errorf("%q: expected a valid http router mode", c.mode)
+		}
+		patterns := map[string]bool{}
+		for _, route := range routes {
+			patterns[route.Pattern] = true
+		}
+		if !patterns["/metrics"] || !patterns["/healthz"] {
+			t.Errorf("%q: expected the metrics and probe routes", c.mode)
+		}
+		if patterns["/v1/firehose"] != c.receiver || patterns["/v2/functions"] != c.rest {
+			t.Errorf("%q: expected receiver routes %v rest routes %v", c.mode, c.receiver, c.rest)
+		}
+	}
+}
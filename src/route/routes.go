@@ -5,6 +5,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/kafkaesque-io/pubsub-function/src/middleware"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
@@ -17,9 +18,27 @@ type Route struct {
 	AuthFunc    mux.MiddlewareFunc
 }
 
+// jsonBody requires the JSON content type on the route with a JSON request body
+func jsonBody(handler http.HandlerFunc) http.HandlerFunc {
+	return middleware.RequireJSON(handler).ServeHTTP
+}
+
+// ipFiltered filters the route requests by the client IP
+func ipFiltered(handler http.HandlerFunc) http.HandlerFunc {
+	return middleware.ReceiverIPFilter(handler).ServeHTTP
+}
+
 // Routes list of HTTP Routes
 type Routes []Route
 
+// untimedRoutes are the long running admin routes exempted from the HTTPRequestTimeout,
+// the replay is bounded by ReplayTimeoutSeconds and the database replay by DbReloadTimeout
+var untimedRoutes = map[string]bool{
+	"/v2/admin/reload":                     true,
+	"/v2/admin/verify":                     true,
+	"/v2/admin/replay/{tenant}/{function}": true,
+}
+
 // PrometheusRoute definition
 var PrometheusRoute = Routes{
 	Route{
@@ -31,26 +50,114 @@ var PrometheusRoute = Routes{
 	},
 }
 
+// ProbeRoutes are the liveness and readiness probes served in all modes
+var ProbeRoutes = Routes{
+	Route{
+		"Liveness probe",
+		http.MethodGet,
+		"/healthz",
+		LivenessHandler,
+		middleware.NoAuth,
+	},
+	Route{
+		"Readiness probe",
+		http.MethodGet,
+		"/readyz",
+		ReadinessHandler,
+		middleware.NoAuth,
+	},
+}
+
 // ReceiverRoutes definition
 var ReceiverRoutes = Routes{
 	Route{
 		"status",
 		"GET",
 		"/status",
-		StatusPage,
+		ipFiltered(StatusPage),
 		middleware.AuthHeaderRequired,
 	},
 	Route{
 		"Receive",
 		"POST",
 		"/v1/firehose",
-		ReceiveHandler,
+		ipFiltered(ReceiveHandler),
 		middleware.NoAuth,
 	},
 }
 
 // RestRoutes definition
 var RestRoutes = Routes{
+	Route{
+		"Health summary",
+		http.MethodGet,
+		"/metrics/health",
+		HealthSummaryHandler,
+		middleware.NoAuth,
+	},
+	Route{
+		"Effective configuration",
+		http.MethodGet,
+		"/debug/config",
+		DebugConfigHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Reload database cache",
+		http.MethodPost,
+		"/v2/admin/reload",
+		ReloadHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Verify database cache",
+		http.MethodGet,
+		"/v2/admin/verify",
+		VerifyHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Preview function delete",
+		http.MethodGet,
+		"/v2/admin/delete-preview/{tenant}/{function}",
+		DeletePreviewHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"List subscriptions on a topic",
+		http.MethodGet,
+		"/v2/admin/subscriptions",
+		TopicSubscriptionsHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Test a webhook URL",
+		http.MethodPost,
+		"/v2/admin/webhook-test",
+		jsonBody(WebhookTestHandler),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Replay messages to webhooks",
+		http.MethodPost,
+		"/v2/admin/replay/{tenant}/{function}",
+		jsonBody(ReplayHandler),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Function config schema",
+		http.MethodGet,
+		"/v2/schema/function",
+		FunctionSchemaHandler,
+		middleware.NoAuth,
+	},
+	Route{
+		"List functions",
+		"GET",
+		"/v2/functions",
+		ListFunctionsHandler,
+		middleware.AuthVerifyJWT,
+	},
 	Route{
 		"Get a function",
 		"GET",
@@ -65,6 +172,76 @@ var RestRoutes = Routes{
 		UpdateFunctionHandler,
 		middleware.AuthVerifyJWT,
 	},
+	Route{
+		"Update a function",
+		"PUT",
+		"/v2/function/{tenant}/{function}",
+		UpdateFunctionHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Validate a function",
+		"POST",
+		"/v2/function/{tenant}/{function}/dryrun",
+		jsonBody(DryRunFunctionHandler),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Activate a function",
+		http.MethodPatch,
+		"/v2/function/{tenant}/{function}/activate",
+		StatusTransitionHandler(model.Activated),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Suspend a function",
+		http.MethodPatch,
+		"/v2/function/{tenant}/{function}/suspend",
+		StatusTransitionHandler(model.Suspended),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Deactivate a function",
+		http.MethodPatch,
+		"/v2/function/{tenant}/{function}/deactivate",
+		StatusTransitionHandler(model.Deactivated),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Touch a function",
+		http.MethodPatch,
+		"/v2/function/{tenant}/{function}/touch",
+		TouchFunctionHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Function runtime status",
+		http.MethodGet,
+		"/v2/function/{tenant}/{function}/status",
+		FunctionRuntimeHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Clone a function",
+		http.MethodPost,
+		"/v2/function/{tenant}/{function}/clone",
+		jsonBody(CloneFunctionHandler),
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Export a function manifest",
+		http.MethodGet,
+		"/v2/function/{tenant}/{function}/manifest",
+		ExportManifestHandler,
+		middleware.AuthVerifyJWT,
+	},
+	Route{
+		"Import a function manifest",
+		http.MethodPut,
+		"/v2/manifest/{tenant}",
+		ImportManifestHandler,
+		middleware.AuthVerifyJWT,
+	},
 	Route{
 		"Delete a function",
 		"DELETE",
@@ -76,7 +253,7 @@ var RestRoutes = Routes{
 		"Trigger a function",
 		"PUT",
 		"/v2/function",
-		TriggerFunctionHandler,
+		jsonBody(TriggerFunctionHandler),
 		middleware.AuthVerifyJWT,
 	},
 }
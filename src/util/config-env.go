@@ -0,0 +1,40 @@
+package util
+
+import (
+	"os"
+	"reflect"
+	"strings"
+)
+
+// ConfigEnvPrefix is the prefix of the env variables overriding the configuration fields
+const ConfigEnvPrefix = "PUBSUBFN_"
+
+// ConfigEnvName returns the env variable name overriding the configuration field,
+// i.e. PUBSUBFN_DBNAME overrides DbName
+func ConfigEnvName(field string) string {
+	return ConfigEnvPrefix + strings.ToUpper(field)
+}
+
+// ConfigEnvMapping returns the env variable name of every configuration field
+func ConfigEnvMapping() map[string]string {
+	mapping := make(map[string]string)
+	fields := reflect.TypeOf(Configuration{})
+	for i := 0; i < fields.NumField(); i++ {
+		if fields.Field(i).Type.Kind() == reflect.String {
+			mapping[fields.Field(i).Name] = ConfigEnvName(fields.Field(i).Name)
+		}
+	}
+	return mapping
+}
+
+// lookupConfigEnv looks up the env variable override of the configuration field.
+// The prefixed env variable takes precedence over the legacy one named after the field.
+func lookupConfigEnv(field string) (string, bool) {
+	if envV := os.Getenv(ConfigEnvName(field)); len(envV) > 0 {
+		return envV, true
+	}
+	if envV := os.Getenv(field); len(envV) > 0 {
+		return envV, true
+	}
+	return "", false
+}
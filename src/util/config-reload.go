@@ -0,0 +1,173 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/ghodss/yaml"
+	log "github.com/sirupsen/logrus"
+)
+
+// ReloadableFields are the configuration fields that take effect at runtime on a reload,
+// a change to any other field requires a restart
+var ReloadableFields = map[string]bool{
+	"LogLevel":                 true,
+	"LogFormat":                true,
+	"TenantRateLimit":          true,
+	"TenantRateLimitOverrides": true,
+	"MaxFunctionsPerTenant":    true,
+	"MaxWebhooksPerFunction":   true,
+	"HTTPRequestTimeout":       true,
+	"ReceiverAllowedCIDRs":     true,
+	"ReceiverDeniedCIDRs":      true,
+	"TrustedProxy":             true,
+}
+
+var (
+	// currentConfig holds the *Configuration swapped in by a reload
+	currentConfig atomic.Value
+
+	// startupEnv is the env variable overrides captured before the config file values are exported
+	startupEnv     map[string]string
+	startupEnvOnce sync.Once
+
+	reloadHooks     []func()
+	reloadHooksLock sync.Mutex
+	reloadLock      sync.Mutex
+)
+
+// OnConfigReload registers a function called after the configuration is reloaded
+func OnConfigReload(fn func()) {
+	reloadHooksLock.Lock()
+	defer reloadHooksLock.Unlock()
+	reloadHooks = append(reloadHooks, fn)
+}
+
+// WatchConfigReload reloads the configuration file on SIGHUP
+func WatchConfigReload(configFile string) {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGHUP)
+	go func() {
+		for range sig {
+			log.Warnf("reload configuration file %s on SIGHUP", configFile)
+			if _, err := ReloadConfig(configFile); err != nil {
+				log.Errorf("failed to reload configuration file %s error %v", configFile, err)
+			}
+		}
+	}()
+}
+
+// ReloadConfig re-reads the configuration file and swaps in the values safe to change at runtime.
+// It returns the names of the changed fields that require a restart to take effect.
+func ReloadConfig(configFile string) ([]string, error) {
+	reloadLock.Lock()
+	defer reloadLock.Unlock()
+
+	parsed, err := parseConfigFile(configFile)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := *GetConfig()
+	restart := []string{}
+	current := reflect.ValueOf(&cfg).Elem()
+	updated := reflect.ValueOf(parsed)
+	reloaded := []string{}
+	for _, field := range changedConfigFields(&parsed, &cfg) {
+		if !ReloadableFields[field] {
+			restart = append(restart, field)
+			continue
+		}
+		current.FieldByName(field).SetString(updated.FieldByName(field).String())
+		reloaded = append(reloaded, field)
+	}
+	// an invalid configuration is rejected as a whole and the current one stays in effect
+	if err := validateConfig(&cfg); err != nil {
+		return nil, err
+	}
+	for _, field := range reloaded {
+		log.Infof("configuration %s reloaded", field)
+	}
+	for _, field := range restart {
+		log.Warnf("configuration %s changed but requires a restart to take effect", field)
+	}
+	setConfigEnv(&cfg)
+	currentConfig.Store(&cfg)
+	log.SetLevel(logLevel(cfg.LogLevel))
+	log.SetFormatter(logFormatter(cfg.LogFormat))
+
+	reloadHooksLock.Lock()
+	hooks := append([]func(){}, reloadHooks...)
+	reloadHooksLock.Unlock()
+	for _, fn := range hooks {
+		fn()
+	}
+	return restart, nil
+}
+
+// parseConfigFile reads the configuration file with the env variable overrides applied
+func parseConfigFile(configFile string) (Configuration, error) {
+	cfg := Configuration{}
+	fileBytes, err := ioutil.ReadFile(configFile)
+	if err != nil {
+		return cfg, err
+	}
+
+	if hasJSONPrefix(fileBytes) {
+		err = json.Unmarshal(fileBytes, &cfg)
+	} else {
+		err = yaml.Unmarshal(fileBytes, &cfg)
+	}
+	if err != nil {
+		return cfg, err
+	}
+
+	// env variables overwrite the config file values, they are captured once
+	// since the effective values are exported to env afterwards
+	fields := reflect.TypeOf(cfg)
+	startupEnvOnce.Do(func() {
+		startupEnv = make(map[string]string)
+		for i := 0; i < fields.NumField(); i++ {
+			if envV, ok := lookupConfigEnv(fields.Field(i).Name); ok {
+				startupEnv[fields.Field(i).Name] = envV
+			}
+		}
+	})
+	st := reflect.ValueOf(&cfg).Elem()
+	for i := 0; i < fields.NumField(); i++ {
+		f := st.Field(i)
+		envV, ok := startupEnv[fields.Field(i).Name]
+		if ok && f.Kind() == reflect.String && f.CanSet() {
+			f.SetString(strings.TrimSuffix(envV, "\n")) // ensure no \n at the end of line that was introduced by loading k8s secrete file
+		}
+	}
+	return cfg, nil
+}
+
+// setConfigEnv exports the configuration string fields to env variables
+func setConfigEnv(cfg *Configuration) {
+	for field, value := range configValues(cfg) {
+		os.Setenv(field, value)
+	}
+}
+
+// changedConfigFields returns the names of the configuration string fields changed from the previous configuration
+func changedConfigFields(cfg, previous *Configuration) []string {
+	changed := []string{}
+	fields := reflect.TypeOf(*cfg)
+	values := reflect.ValueOf(*cfg)
+	for i := 0; i < fields.NumField(); i++ {
+		f := values.Field(i)
+		if f.Kind() == reflect.String && reflect.ValueOf(*previous).Field(i).String() != f.String() {
+			changed = append(changed, fields.Field(i).Name)
+		}
+	}
+	return changed
+}
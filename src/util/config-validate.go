@@ -0,0 +1,133 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// SupportedDbTypes are the supported PbDbType values
+var SupportedDbTypes = []string{"pulsarAsDb", "inmemory"}
+
+// configLogLevels are the supported LogLevel values, empty defaults to info
+var configLogLevels = []string{"", "debug", "info", "warn", "error", "fatal"}
+
+// configCompressions are the supported DbCompression values, empty defaults to lz4
+var configCompressions = []string{"", "none", "lz4", "zlib", "zstd"}
+
+// configNumericFields are the configuration fields that must be non-negative integers if specified
+var configNumericFields = []string{
+	"MaxFunctionsPerTenant", "MaxWebhooksPerFunction", "HTTPRequestTimeout", "TenantRateLimit",
+	"DbCompactionInterval", "DbCacheSoftLimit", "DbHeartbeatInterval", "DbReadyTimeout", "DbVerifyInterval", "BacklogCollectionInterval",
+	"WebhookRetries", "WebhookRetryBaseDelayMs", "WebhookMaxConsecutiveFailures", "WebhookMaxIdleConns", "WebhookMaxIdleConnsPerHost",
+}
+
+// configPositiveFields are the configuration fields that must be positive integers if specified
+var configPositiveFields = []string{"DbSendTimeoutMs", "DbConnectTimeoutMs", "DbOperationTimeoutMs", "ReconcileInterval", "OutputSendTimeoutMs", "IdempotencyKeyTTL", "WebhookTimeoutMs"}
+
+// ValidateConfig validates the effective configuration before any connection is established.
+// All the invalid fields are reported in the returned error.
+func ValidateConfig() error {
+	return validateConfig(GetConfig())
+}
+
+func validateConfig(cfg *Configuration) error {
+	problems := []string{}
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if !StrContains(SupportedDbTypes, cfg.PbDbType) {
+		add("PbDbType %q must be one of %s", cfg.PbDbType, strings.Join(SupportedDbTypes, ", "))
+	}
+	if cfg.PbDbType == "pulsarAsDb" {
+		if cfg.DbName == "" {
+			add("DbName is required for the pulsarAsDb database")
+		}
+		if cfg.PulsarBrokerURL == "" && !strings.HasPrefix(cfg.DbConnectionStr, "pulsar") {
+			add("PulsarBrokerURL or a pulsar DbConnectionStr is required for the pulsarAsDb database")
+		}
+	}
+
+	if err := validateURL(cfg.PulsarBrokerURL, "pulsar", "pulsar+ssl"); err != nil {
+		add("PulsarBrokerURL %v", err)
+	}
+	if strings.HasPrefix(cfg.DbConnectionStr, "pulsar") {
+		if err := validateURL(cfg.DbConnectionStr, "pulsar", "pulsar+ssl"); err != nil {
+			add("DbConnectionStr %v", err)
+		}
+	}
+	for _, cluster := range strings.Split(cfg.PulsarClusters, ",") {
+		if err := validateURL(strings.TrimSpace(cluster), "pulsar", "pulsar+ssl"); err != nil {
+			add("PulsarClusters %v", err)
+		}
+	}
+	if err := validateURL(cfg.PulsarAdminURL, "http", "https"); err != nil {
+		add("PulsarAdminURL %v", err)
+	}
+
+	if !StrContains(configLogLevels, strings.TrimSpace(strings.ToLower(cfg.LogLevel))) {
+		add("LogLevel %q must be one of debug, info, warn, error, fatal", cfg.LogLevel)
+	}
+	if !StrContains([]string{"", "text", "json"}, strings.TrimSpace(strings.ToLower(cfg.LogFormat))) {
+		add("LogFormat %q must be text or json", cfg.LogFormat)
+	}
+	if !StrContains(configCompressions, strings.TrimSpace(strings.ToLower(cfg.DbCompression))) {
+		add("DbCompression %q must be one of none, lz4, zlib, zstd", cfg.DbCompression)
+	}
+
+	values := configValues(cfg)
+	for _, field := range configNumericFields {
+		if n, err := parseConfigInt(values[field]); err != nil || n < 0 {
+			add("%s %q must be a non-negative integer", field, values[field])
+		}
+	}
+	for _, field := range configPositiveFields {
+		if n, err := parseConfigInt(values[field]); err != nil || (values[field] != "" && n <= 0) {
+			add("%s %q must be a positive integer", field, values[field])
+		}
+	}
+
+	for _, field := range []string{"ReceiverAllowedCIDRs", "ReceiverDeniedCIDRs"} {
+		for _, cidr := range strings.Split(values[field], ",") {
+			cidr = strings.TrimSpace(cidr)
+			if cidr == "" {
+				continue
+			}
+			if _, _, err := net.ParseCIDR(cidr); err != nil && net.ParseIP(cidr) == nil {
+				add("%s %q is not a CIDR or an IP address", field, cidr)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		return errors.New("invalid configuration: " + strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// validateURL validates an optional URL with one of the schemes
+func validateURL(str string, schemes ...string) error {
+	if str == "" {
+		return nil
+	}
+	u, err := url.Parse(str)
+	if err != nil {
+		return fmt.Errorf("%q is not a valid URL", str)
+	}
+	if !StrContains(schemes, u.Scheme) || u.Host == "" {
+		return fmt.Errorf("%q must be a %s URL", str, strings.Join(schemes, " or "))
+	}
+	return nil
+}
+
+// parseConfigInt parses an optional integer configuration value, empty is 0
+func parseConfigInt(str string) (int, error) {
+	if strings.TrimSpace(str) == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(strings.TrimSpace(str))
+}
@@ -2,16 +2,13 @@ package util
 
 import (
 	"bytes"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"reflect"
 	"strings"
 
 	"unicode"
 
-	"github.com/ghodss/yaml"
 	"github.com/kafkaesque-io/pubsub-function/src/icrypto"
 	log "github.com/sirupsen/logrus"
 )
@@ -21,7 +18,8 @@ import (
 const DefaultConfigFile = "../config/app_config.yml"
 
 // Configuration has a set of parameters to configure the beam server.
-// The same name can be used in environment variable to override yml or json values.
+// The same name can be used in environment variable to override yml or json values,
+// so can the name upper cased with the PUBSUBFN_ prefix, i.e. PUBSUBFN_DBNAME for DbName.
 type Configuration struct {
 	// PORT is the http port
 	PORT string `json:"PORT"`
@@ -32,6 +30,9 @@ type Configuration struct {
 	// LogLevel is used to set the application log level
 	LogLevel string `json:"LogLevel"`
 
+	// LogFormat is the log format, text or json (default: text)
+	LogFormat string `json:"LogFormat"`
+
 	// DbName is the database name in mongo or topic name when Pulsar is used as database
 	DbName string `json:"DbName"`
 
@@ -81,6 +82,136 @@ type Configuration struct {
 
 	// HTTPAuthImpl specifies the jwt authen and authorization algorithm, `noauth` to skip JWT authentication
 	HTTPAuthImpl string `json:"HTTPAuthImpl"`
+
+	// FunctionFileCheck verifies the function file exists on the local disk when a function is validated
+	// It should be enabled only when the function files are co-located with this server
+	FunctionFileCheck string `json:"FunctionFileCheck"`
+
+	// MaxFunctionsPerTenant is the maximum number of functions a tenant can create, 0 or empty means unlimited
+	MaxFunctionsPerTenant string `json:"MaxFunctionsPerTenant"`
+
+	// MaxWebhooksPerFunction is the maximum number of webhooks of a function, each webhook requires a subscription
+	// and a delivery goroutine. The default is 10, 0 means unlimited
+	MaxWebhooksPerFunction string `json:"MaxWebhooksPerFunction"`
+
+	// ImmutableFields are comma separated function config field paths that an update cannot change,
+	// the default is Tenant,InputTopic.TopicFullName
+	ImmutableFields string `json:"ImmutableFields"`
+
+	// HTTPRequestTimeout is the maximum time in seconds to serve a http request, the default is 60 seconds
+	HTTPRequestTimeout string `json:"HTTPRequestTimeout"`
+
+	// ReceiverAllowedCIDRs and ReceiverDeniedCIDRs are comma separated CIDRs or IP addresses to filter the receiver requests
+	// An empty allow list allows all addresses not in the deny list
+	ReceiverAllowedCIDRs string `json:"ReceiverAllowedCIDRs"`
+	ReceiverDeniedCIDRs  string `json:"ReceiverDeniedCIDRs"`
+
+	// TrustedProxy trusts the client IP in X-Forwarded-For header set by a proxy (default: false)
+	TrustedProxy string `json:"TrustedProxy"`
+
+	// TenantRateLimit is the default requests per second of each tenant, 0 or empty means unlimited
+	TenantRateLimit string `json:"TenantRateLimit"`
+
+	// TenantRateLimitOverrides are comma separated tenant:rate pairs to override the default tenant rate limit
+	TenantRateLimitOverrides string `json:"TenantRateLimitOverrides"`
+
+	// WebhookRedactedHeaders are comma separated webhook response headers redacted in the captured responses
+	// published to the function log topic, the default is Set-Cookie,WWW-Authenticate,Proxy-Authenticate,Authorization
+	WebhookRedactedHeaders string `json:"WebhookRedactedHeaders"`
+
+	// WebhookProbeAllowPrivate allows the webhook test endpoint to reach loopback and private network addresses (default: false)
+	WebhookProbeAllowPrivate string `json:"WebhookProbeAllowPrivate"`
+
+	// WebhookRetries is the number of retries of a failed webhook delivery, the default is 3
+	WebhookRetries string `json:"WebhookRetries"`
+
+	// WebhookRetryBaseDelayMs is the base delay in milliseconds of the exponential backoff between the retries,
+	// the default is 100
+	WebhookRetryBaseDelayMs string `json:"WebhookRetryBaseDelayMs"`
+
+	// WebhookMaxConsecutiveFailures is the number of consecutive failed deliveries before a webhook is suspended,
+	// the default is 10, 0 never suspends a webhook
+	WebhookMaxConsecutiveFailures string `json:"WebhookMaxConsecutiveFailures"`
+
+	// WebhookTimeoutMs is the delivery timeout in milliseconds of the webhooks without their own timeout,
+	// the default is 30000
+	WebhookTimeoutMs string `json:"WebhookTimeoutMs"`
+
+	// WebhookMaxIdleConns is the maximum number of idle keep-alive connections to all the webhook endpoints,
+	// the default is 100
+	WebhookMaxIdleConns string `json:"WebhookMaxIdleConns"`
+
+	// WebhookMaxIdleConnsPerHost is the maximum number of idle keep-alive connections per webhook host, the default is 10
+	WebhookMaxIdleConnsPerHost string `json:"WebhookMaxIdleConnsPerHost"`
+
+	// DbReadCompacted reads the compacted database topic (default: true)
+	// It requires compaction to be enabled on the database topic
+	DbReadCompacted string `json:"DbReadCompacted"`
+
+	// DbCompactOnStartup triggers the database topic compaction on startup (default: false)
+	DbCompactOnStartup string `json:"DbCompactOnStartup"`
+
+	// PulsarAdminURL is the Pulsar admin REST API URL to trigger and verify the database topic compaction
+	PulsarAdminURL string `json:"PulsarAdminURL"`
+
+	// DbCompactionInterval is the interval in seconds to trigger the database topic compaction, 0 or empty disables it
+	DbCompactionInterval string `json:"DbCompactionInterval"`
+
+	// DbCacheSoftLimit is the number of cached function configs beyond which a memory pressure warning is logged
+	// The default is 10000, 0 disables the warning
+	DbCacheSoftLimit string `json:"DbCacheSoftLimit"`
+
+	// DbConnectTimeoutMs and DbOperationTimeoutMs are the database Pulsar client connection and operation timeouts
+	// in milliseconds so that a wrong broker URL fails the startup fast, the defaults are 10000 and 30000
+	DbConnectTimeoutMs   string `json:"DbConnectTimeoutMs"`
+	DbOperationTimeoutMs string `json:"DbOperationTimeoutMs"`
+
+	// DbReloadTimeout is the maximum time in seconds to replay the database topic on reload, the default is 300
+	DbReloadTimeout string `json:"DbReloadTimeout"`
+
+	// DbFlushTimeout is the maximum time in seconds to flush the buffered documents to the database topic, the default is 10
+	DbFlushTimeout string `json:"DbFlushTimeout"`
+
+	// DbSendTimeoutMs is the maximum time in milliseconds to send a document to the database topic, the default is 30000
+	DbSendTimeoutMs string `json:"DbSendTimeoutMs"`
+
+	// DbCompression is the compression type of the database topic producer, none, lz4, zlib, or zstd (default: lz4)
+	DbCompression string `json:"DbCompression"`
+
+	// DbSubscriptionName runs the database listener as a durable consumer with the subscription name
+	// so the backlog can be monitored by the broker, it must be unique per server instance.
+	// The database listener is a reader if it is not specified.
+	DbSubscriptionName string `json:"DbSubscriptionName"`
+
+	// DbHeartbeatInterval is the interval in seconds to probe the database producer with a heartbeat message
+	// The default is 60 seconds, 0 disables the probe
+	DbHeartbeatInterval string `json:"DbHeartbeatInterval"`
+
+	// DbVerifyInterval is the interval in seconds to verify the database cache against the database topic
+	// and log the divergent documents, the default 0 disables the verification
+	DbVerifyInterval string `json:"DbVerifyInterval"`
+
+	// DbQuarantineTopic is the topic the malformed database documents are forwarded to, empty only logs and counts them
+	DbQuarantineTopic string `json:"DbQuarantineTopic"`
+
+	// DbReadyTimeout is the maximum time in seconds to wait for the database cache to catch up on startup
+	// The default 0 does not wait
+	DbReadyTimeout string `json:"DbReadyTimeout"`
+
+	// BacklogCollectionInterval is the interval in seconds to collect the function subscription backlog
+	// with the Pulsar admin API, the default is 60 seconds, 0 disables the collection
+	BacklogCollectionInterval string `json:"BacklogCollectionInterval"`
+
+	// ReconcileInterval is the interval in seconds to reconcile the function runners with the function configs
+	// besides every database change, the default is 60 seconds
+	ReconcileInterval string `json:"ReconcileInterval"`
+
+	// OutputSendTimeoutMs is the maximum time in milliseconds to produce a function reply to the output topic,
+	// the default is 30000
+	OutputSendTimeoutMs string `json:"OutputSendTimeoutMs"`
+
+	// IdempotencyKeyTTL is the time in seconds a function creation idempotency key is honoured, the default is 3600
+	IdempotencyKeyTTL string `json:"IdempotencyKeyTTL"`
 }
 
 var (
@@ -106,49 +237,24 @@ func Init() {
 	ReadConfigFile(configFile)
 
 	log.SetLevel(logLevel(Config.LogLevel))
+	log.SetFormatter(logFormatter(Config.LogFormat))
 
 	log.Warnf("Configuration built from file - %s", configFile)
+	WatchConfigReload(configFile)
 	JWTAuth = icrypto.NewRSAKeyPair(Config.PulsarPrivateKey, Config.PulsarPublicKey)
 }
 
 // ReadConfigFile reads configuration file.
 func ReadConfigFile(configFile string) {
-	fileBytes, err := ioutil.ReadFile(configFile)
+	cfg, err := parseConfigFile(configFile)
 	if err != nil {
 		fmt.Printf("failed to load configuration file %s", configFile)
 		panic(err)
 	}
+	Config = cfg
 
-	if hasJSONPrefix(fileBytes) {
-		err = json.Unmarshal(fileBytes, &Config)
-		if err != nil {
-			panic(err)
-		}
-	} else {
-		err = yaml.Unmarshal(fileBytes, &Config)
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	// Next section allows env variable overwrites config file value
-	fields := reflect.TypeOf(Config)
-	// pointer to struct
-	values := reflect.ValueOf(&Config)
-	// struct
-	st := values.Elem()
-	for i := 0; i < fields.NumField(); i++ {
-		field := fields.Field(i).Name
-		f := st.FieldByName(field)
-
-		if f.Kind() == reflect.String {
-			envV := os.Getenv(field)
-			if len(envV) > 0 && f.IsValid() && f.CanSet() {
-				f.SetString(strings.TrimSuffix(envV, "\n")) // ensure no \n at the end of line that was introduced by loading k8s secrete file
-			}
-			os.Setenv(field, f.String())
-		}
-	}
+	// expose the effective values to the env variable lookups
+	setConfigEnv(&Config)
 
 	clusterStr := AssignString(Config.PulsarClusters, "")
 	AllowedPulsarURLs = strings.Split(clusterStr, ",")
@@ -167,8 +273,42 @@ func ReadConfigFile(configFile string) {
 		Config.PulsarBrokerURL, AllowedPulsarURLs, Config.PulsarTLSAllowInsecureConnection, Config.PulsarTLSValidateHostname)
 }
 
+// secretConfigFields are the name fragments of the configuration fields redacted from RedactedConfig
+var secretConfigFields = []string{"password", "token", "secret", "privatekey"}
+
+// RedactedConfig returns the effective configuration with the secrets redacted
+func RedactedConfig() map[string]string {
+	redacted := configValues(GetConfig())
+	for name, value := range redacted {
+		lower := strings.ToLower(name)
+		for _, secret := range secretConfigFields {
+			if strings.Contains(lower, secret) && value != "" {
+				redacted[name] = "***"
+				break
+			}
+		}
+	}
+	return redacted
+}
+
+// configValues returns the configuration string fields by name
+func configValues(cfg *Configuration) map[string]string {
+	values := make(map[string]string)
+	fields := reflect.TypeOf(*cfg)
+	st := reflect.ValueOf(*cfg)
+	for i := 0; i < fields.NumField(); i++ {
+		if st.Field(i).Kind() == reflect.String {
+			values[fields.Field(i).Name] = st.Field(i).String()
+		}
+	}
+	return values
+}
+
 //GetConfig returns a reference to the Configuration
 func GetConfig() *Configuration {
+	if cfg, ok := currentConfig.Load().(*Configuration); ok {
+		return cfg
+	}
 	return &Config
 }
 
@@ -0,0 +1,204 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// useConfig swaps in the configuration and returns a function restoring the previous one
+func useConfig(cfg Configuration) func() {
+	previous := GetConfig()
+	currentConfig.Store(&cfg)
+	return func() { currentConfig.Store(previous) }
+}
+
+func TestRedactedConfig(t *testing.T) {
+	defer useConfig(Configuration{
+		DbName:           "functions",
+		DbPassword:       "eyJhbGciOiJSUzI1NiJ9.token",
+		PulsarPrivateKey: "/keys/private.key",
+		PulsarPublicKey:  "/keys/public.key",
+		PulsarBrokerURL:  "pulsar://localhost:6650",
+	})()
+
+	cases := []struct {
+		field string
+		value string
+	}{
+		{"DbPassword", "***"},
+		{"PulsarPrivateKey", "***"},
+		{"DbName", "functions"},
+		{"PulsarPublicKey", "/keys/public.key"},
+		{"PulsarBrokerURL", "pulsar://localhost:6650"},
+	}
+	redacted := RedactedConfig()
+	for _, c := range cases {
+		value, ok := redacted[c.field]
+		if !ok || value != c.value {
+			t.Errorf("%s: expected %q, got %q", c.field, c.value, value)
+		}
+	}
+}
+
+func TestReloadConfig(t *testing.T) {
+	defer useConfig(Configuration{PbDbType: "inmemory", DbName: "functions", TenantRateLimit: "5"})()
+	reloads := 0
+	OnConfigReload(func() { reloads++ })
+
+	cases := []struct {
+		name      string
+		file      string
+		rateLimit string
+		restart   []string
+		fails     bool
+	}{
+		{"reloadable change", `{"PbDbType":"inmemory","DbName":"functions","TenantRateLimit":"10"}`, "10", []string{}, false},
+		{"change requiring a restart", `{"PbDbType":"inmemory","DbName":"other","TenantRateLimit":"10"}`, "10", []string{"DbName"}, false},
+		{"invalid value is rejected", `{"PbDbType":"inmemory","DbName":"functions","TenantRateLimit":"-1"}`, "10", nil, true},
+		{"malformed file", `{"PbDbType":`, "10", nil, true},
+		{"yaml file", "PbDbType: inmemory\nDbName: functions\nTenantRateLimit: \"20\"\n", "20", []string{}, false},
+	}
+	expectedReloads := 0
+	for _, c := range cases {
+		file, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(c.file)
+		file.Close()
+		restart, err := ReloadConfig(file.Name())
+		os.Remove(file.Name())
+
+		if (err != nil) != c.fails {
+			t.Errorf("%s: expected failure %v, got %v", c.name, c.fails, err)
+		}
+		if !c.fails {
+			expectedReloads++
+		}
+		if !reflect.DeepEqual(restart, c.restart) {
+			t.Errorf("%s: expected restart fields %v, got %v", c.name, c.restart, restart)
+		}
+		if rateLimit := GetConfig().TenantRateLimit; rateLimit != c.rateLimit {
+			t.Errorf("%s: expected rate limit %s, got %s", c.name, c.rateLimit, rateLimit)
+		}
+		if dbName := GetConfig().DbName; dbName != "functions" {
+			t.Errorf("%s: expected DbName to require a restart, got %s", c.name, dbName)
+		}
+		if reloads != expectedReloads {
+			t.Errorf("%s: expected %d reload hooks calls, got %d", c.name, expectedReloads, reloads)
+		}
+	}
+}
+
+func TestConfigEnvName(t *testing.T) {
+	cases := []struct {
+		field string
+		env   string
+	}{
+		{"DbName", "PUBSUBFN_DBNAME"},
+		{"PulsarBrokerURL", "PUBSUBFN_PULSARBROKERURL"},
+		{"PORT", "PUBSUBFN_PORT"},
+	}
+	mapping := ConfigEnvMapping()
+	for _, c := range cases {
+		if env := ConfigEnvName(c.field); env != c.env {
+			t.Errorf("%s: expected %s, got %s", c.field, c.env, env)
+		}
+		if mapping[c.field] != c.env {
+			t.Errorf("%s: expected mapping to %s, got %s", c.field, c.env, mapping[c.field])
+		}
+	}
+}
+
+func TestConfigEnvOverride(t *testing.T) {
+	// the env variables are captured again for the test
+	startupEnvOnce = sync.Once{}
+	defer func() { startupEnvOnce = sync.Once{} }()
+	for env, value := range map[string]string{"PUBSUBFN_DBNAME": "env-db", "LogLevel": "debug", "PUBSUBFN_LOGLEVEL": "warn", "DbPassword": "legacy\n"} {
+		os.Setenv(env, value)
+		defer os.Unsetenv(env)
+	}
+
+	file, err := ioutil.TempFile("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+	file.WriteString(`{"DbName":"file-db","LogLevel":"info","PbDbType":"inmemory"}`)
+	file.Close()
+	cfg, err := parseConfigFile(file.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		value    string
+		expected string
+	}{
+		{"prefixed env overrides the file", cfg.DbName, "env-db"},
+		{"prefixed env takes precedence over the legacy env", cfg.LogLevel, "warn"},
+		{"legacy env without the trailing new line", cfg.DbPassword, "legacy"},
+		{"file value without env", cfg.PbDbType, "inmemory"},
+	}
+	for _, c := range cases {
+		if c.value != c.expected {
+			t.Errorf("%s: expected %q, got %q", c.name, c.expected, c.value)
+		}
+	}
+}
+
+func TestValidateConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		cfg      Configuration
+		problems []string
+	}{
+		{"valid in memory", Configuration{PbDbType: "inmemory"}, nil},
+		{"valid pulsar database", Configuration{PbDbType: "pulsarAsDb", DbName: "functions", PulsarBrokerURL: "pulsar+ssl://broker:6651",
+			PulsarClusters: "pulsar://a:6650, pulsar://b:6650", ReceiverAllowedCIDRs: "10.0.0.0/8,127.0.0.1", DbSendTimeoutMs: "500"}, nil},
+		{"connection string as broker", Configuration{PbDbType: "pulsarAsDb", DbName: "functions", DbConnectionStr: "pulsar://broker:6650"}, nil},
+		{"unknown database type", Configuration{PbDbType: "mongo"}, []string{"PbDbType"}},
+		{"missing pulsar database fields", Configuration{PbDbType: "pulsarAsDb"}, []string{"DbName", "PulsarBrokerURL or a pulsar DbConnectionStr"}},
+		{"malformed urls", Configuration{PbDbType: "inmemory", PulsarBrokerURL: "http://broker", PulsarAdminURL: "broker:8080", PulsarClusters: "pulsar://"},
+			[]string{"PulsarBrokerURL", "PulsarAdminURL", "PulsarClusters"}},
+		{"unknown modes", Configuration{PbDbType: "inmemory", LogLevel: "verbose", LogFormat: "xml", DbCompression: "gzip"},
+			[]string{"LogLevel", "LogFormat", "DbCompression"}},
+		{"invalid numbers", Configuration{PbDbType: "inmemory", TenantRateLimit: "-1", HTTPRequestTimeout: "30s", DbSendTimeoutMs: "0"},
+			[]string{"TenantRateLimit", "HTTPRequestTimeout", "DbSendTimeoutMs"}},
+		{"invalid cidr", Configuration{PbDbType: "inmemory", ReceiverDeniedCIDRs: "10.0.0.0/33"}, []string{"ReceiverDeniedCIDRs"}},
+		{"zero reconcile interval", Configuration{PbDbType: "inmemory", ReconcileInterval: "0"}, []string{"ReconcileInterval"}},
+		{"zero output send timeout", Configuration{PbDbType: "inmemory", OutputSendTimeoutMs: "0"}, []string{"OutputSendTimeoutMs"}},
+		{"zero idempotency key ttl", Configuration{PbDbType: "inmemory", IdempotencyKeyTTL: "0"}, []string{"IdempotencyKeyTTL"}},
+		{"negative webhook retries", Configuration{PbDbType: "inmemory", WebhookRetries: "-1", WebhookRetryBaseDelayMs: "0", WebhookMaxConsecutiveFailures: "ten"},
+			[]string{"WebhookRetries", "WebhookMaxConsecutiveFailures"}},
+		{"invalid webhook client settings", Configuration{PbDbType: "inmemory", WebhookTimeoutMs: "0", WebhookMaxIdleConns: "-1", WebhookMaxIdleConnsPerHost: "0"},
+			[]string{"WebhookMaxIdleConns", "WebhookTimeoutMs"}},
+	}
+	for _, c := range cases {
+		err := validateConfig(&c.cfg)
+		if len(c.problems) == 0 {
+			if err != nil {
+				t.Errorf("%s: unexpected error %v", c.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", c.name)
+			continue
+		}
+		// all the problems are reported at once
+		if n := len(strings.Split(err.Error(), "; ")); n != len(c.problems) {
+			t.Errorf("%s: expected %d problems, got %v", c.name, len(c.problems), err)
+		}
+		for _, problem := range c.problems {
+			if !strings.Contains(err.Error(), problem) {
+				t.Errorf("%s: expected %s in %v", c.name, problem, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package util
+
+import (
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// AppName is the app field of the structured log entries
+const AppName = "pubsub-function"
+
+// The keys carried by the structured log entries
+const (
+	LogKeyApp       = "app"
+	LogKeyComponent = "component"
+	LogKeyRequestID = "request_id"
+)
+
+// LogEntry returns a log entry with the app, component, and request id keys,
+// the request id is empty if the entry is not logged for a http request
+func LogEntry(component, requestID string) *log.Entry {
+	return log.WithFields(log.Fields{
+		LogKeyApp:       AppName,
+		LogKeyComponent: component,
+		LogKeyRequestID: requestID,
+	})
+}
+
+// logFormatter returns the JSON formatter for the json log format, or the default text formatter
+func logFormatter(format string) log.Formatter {
+	switch strings.TrimSpace(strings.ToLower(format)) {
+	case "json":
+		return &log.JSONFormatter{}
+	default:
+		return &log.TextFormatter{}
+	}
+}
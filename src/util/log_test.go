@@ -0,0 +1,76 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func TestLogFormatter(t *testing.T) {
+	cases := []struct {
+		format string
+		json   bool
+	}{
+		{"json", true},
+		{" JSON ", true},
+		{"text", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		_, isJSON := logFormatter(c.format).(*log.JSONFormatter)
+		if isJSON != c.json {
+			t.Errorf("%q: expected json formatter %v, got %v", c.format, c.json, isJSON)
+		}
+	}
+}
+
+func TestLogFormatReload(t *testing.T) {
+	defer useConfig(Configuration{PbDbType: "inmemory"})()
+	defer func(formatter log.Formatter, level log.Level) {
+		log.SetFormatter(formatter)
+		log.SetLevel(level)
+	}(log.StandardLogger().Formatter, log.GetLevel())
+
+	cases := []struct {
+		file  string
+		json  bool
+		level log.Level
+	}{
+		{`{"PbDbType":"inmemory","LogFormat":"json","LogLevel":"debug"}`, true, log.DebugLevel},
+		{`{"PbDbType":"inmemory","LogFormat":"text","LogLevel":"warn"}`, false, log.WarnLevel},
+	}
+	for _, c := range cases {
+		file, err := ioutil.TempFile("", "config")
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(c.file)
+		file.Close()
+		_, err = ReloadConfig(file.Name())
+		os.Remove(file.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, isJSON := log.StandardLogger().Formatter.(*log.JSONFormatter); isJSON != c.json || log.GetLevel() != c.level {
+			t.Errorf("%s: expected json formatter %v level %v, got %T %v", c.file, c.json, c.level, log.StandardLogger().Formatter, log.GetLevel())
+		}
+	}
+}
+
+func TestLogEntry(t *testing.T) {
+	cases := []struct {
+		component string
+		requestID string
+	}{
+		{"pulsardb", ""},
+		{"http", "req-1"},
+	}
+	for _, c := range cases {
+		fields := LogEntry(c.component, c.requestID).Data
+		if fields[LogKeyApp] != AppName || fields[LogKeyComponent] != c.component || fields[LogKeyRequestID] != c.requestID {
+			t.Errorf("%s: unexpected fields %v", c.component, fields)
+		}
+	}
+}
@@ -23,15 +23,23 @@ const HTTPWithNoRest = "http2"
 // Rest mode provides a Rest API for webhook management
 const Rest = "rest"
 
+// Writer mode is the single writer of the function database serving the rest api only
+const Writer = "writer"
+
 // IsBrokerRequired check if the broker is required
 func IsBrokerRequired(mode *string) bool {
 	return *mode == Broker || *mode == Hybrid
 }
 
+// HTTPRouterModes are the modes running the http router
+var HTTPRouterModes = []string{Hybrid, Receiver, Rest, TokenServer, HTTPOnly, HTTPWithNoRest, Writer}
+
+// Modes are all the supported modes
+var Modes = append([]string{Broker}, HTTPRouterModes...)
+
 // IsHTTPRouterRequired check whether to initialize http router
 func IsHTTPRouterRequired(mode *string) bool {
-	modes := []string{Hybrid, Receiver, Rest, TokenServer, HTTPOnly, HTTPWithNoRest}
-	return StrContains(modes, *mode)
+	return StrContains(HTTPRouterModes, *mode)
 }
 
 // IsBroker check if the mode is broker
@@ -41,6 +49,5 @@ func IsBroker(mode *string) bool {
 
 // IsValidMode checks if the mode is supported
 func IsValidMode(mode *string) bool {
-	modes := []string{Broker, Hybrid, Receiver, Rest, TokenServer, HTTPOnly, HTTPWithNoRest}
-	return StrContains(modes, *mode)
+	return StrContains(Modes, *mode)
 }
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"os"
+	"strings"
+)
+
+// SecretEnvPrefix is the prefix of the env variables a function config can reference as a secret.
+// The other env variables, including the exported configuration, are never resolved for a function.
+const SecretEnvPrefix = "PUBSUBFN_SECRET_"
+
+// IsSecretEnvName checks whether the env variable can be referenced as a secret
+func IsSecretEnvName(name string) bool {
+	return len(name) > len(SecretEnvPrefix) && strings.HasPrefix(name, SecretEnvPrefix)
+}
+
+// LookupSecretEnv looks up a secret env variable, an env variable without the secret prefix is never found
+func LookupSecretEnv(name string) (string, bool) {
+	if !IsSecretEnvName(name) {
+		return "", false
+	}
+	return os.LookupEnv(name)
+}
+
+// SecretRefNames returns the variable names referenced as ${VAR} in the value, `$$` escapes a literal `$`
+func SecretRefNames(value string) []string {
+	names := []string{}
+	for i := 0; i+1 < len(value); i++ {
+		if value[i] != '$' {
+			continue
+		}
+		switch value[i+1] {
+		case '$':
+			i++
+		case '{':
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				return names
+			}
+			names = append(names, value[i+2:i+2+end])
+			i += end + 2
+		}
+	}
+	return names
+}
@@ -0,0 +1,39 @@
+package util
+
+import (
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestLookupSecretEnv(t *testing.T) {
+	os.Setenv("PUBSUBFN_SECRET_KEY", "v")
+	defer os.Unsetenv("PUBSUBFN_SECRET_KEY")
+	os.Setenv("DbPassword", "server-secret")
+	defer os.Unsetenv("DbPassword")
+
+	if v, ok := LookupSecretEnv("PUBSUBFN_SECRET_KEY"); !ok || v != "v" {
+		t.Errorf("expected the secret env variable, got %s %v", v, ok)
+	}
+	for _, name := range []string{"DbPassword", "PUBSUBFN_SECRET_", "PUBSUBFN_DBPASSWORD"} {
+		if _, ok := LookupSecretEnv(name); ok {
+			t.Errorf("%s must not be resolved as a secret", name)
+		}
+	}
+}
+
+func TestSecretRefNames(t *testing.T) {
+	cases := map[string][]string{
+		"plain":               {},
+		"${A} and ${B}":       {"A", "B"},
+		"$${A} ${B}":          {"B"},
+		"$$$${A}":             {},
+		"${A":                 {},
+		"Bearer ${A}${B} $$x": {"A", "B"},
+	}
+	for value, expected := range cases {
+		if names := SecretRefNames(value); !reflect.DeepEqual(names, expected) {
+			t.Errorf("%s: expected %v, got %v", value, expected, names)
+		}
+	}
+}
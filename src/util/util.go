@@ -122,6 +122,14 @@ func GetEnvInt(env string, defaultNum int) int {
 	return defaultNum
 }
 
+// ConfigInt parses an integer configuration value with a default if the value is empty or improper
+func ConfigInt(value string, defaultNum int) int {
+	if i, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+		return i
+	}
+	return defaultNum
+}
+
 // StringToBool format various strings to boolean
 // strconv.ParseBool only covers `true` and `false` cases
 func StringToBool(str string) bool {
@@ -0,0 +1,24 @@
+package util
+
+import "testing"
+
+func TestConfigInt(t *testing.T) {
+	cases := []struct {
+		value string
+		def   int
+		want  int
+	}{
+		{"", 60, 60},
+		{"30", 60, 30},
+		{" 30 ", 60, 30},
+		{"0", 60, 0},
+		{"-1", 60, -1},
+		{"30s", 60, 60},
+		{"abc", 10, 10},
+	}
+	for _, c := range cases {
+		if got := ConfigInt(c.value, c.def); got != c.want {
+			t.Errorf("ConfigInt(%q, %d) expected %d, got %d", c.value, c.def, c.want, got)
+		}
+	}
+}
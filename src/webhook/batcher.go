@@ -0,0 +1,86 @@
+package webhook
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Batcher accumulates messages for a webhook and delivers them as a JSON array
+// when either the batch size is reached or the batch timeout expires.
+type Batcher struct {
+	sender   *WebhookSender
+	wh       *model.WebhookConfig
+	size     int
+	timeout  time.Duration
+	payloads []json.RawMessage
+	timer    *time.Timer
+	sync.Mutex
+}
+
+// NewBatcher creates a batcher for the webhook
+func NewBatcher(sender *WebhookSender, wh *model.WebhookConfig) *Batcher {
+	return &Batcher{
+		sender:  sender,
+		wh:      wh,
+		size:    wh.BatchSize,
+		timeout: time.Duration(wh.BatchTimeoutMs) * time.Millisecond,
+	}
+}
+
+// Add adds a message payload to the batch. The batch is delivered once it is full.
+func (b *Batcher) Add(payload []byte) error {
+	b.Lock()
+	defer b.Unlock()
+
+	data, err := jsonPayload(payload)
+	if err != nil {
+		return err
+	}
+	b.payloads = append(b.payloads, data)
+
+	if len(b.payloads) >= b.size {
+		return b.flush()
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.timeout, func() {
+			if err := b.Flush(); err != nil {
+				log.Errorf("webhook %s batch flush error %v", b.wh.URL, err)
+			}
+		})
+	}
+	return nil
+}
+
+// Flush delivers the pending partial batch
+func (b *Batcher) Flush() error {
+	b.Lock()
+	defer b.Unlock()
+	return b.flush()
+}
+
+// Close flushes the pending messages on shutdown
+func (b *Batcher) Close() error {
+	return b.Flush()
+}
+
+func (b *Batcher) flush() error {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	if len(b.payloads) == 0 {
+		return nil
+	}
+
+	data, err := json.Marshal(b.payloads)
+	b.payloads = nil
+	if err != nil {
+		return err
+	}
+	return b.sender.Send(b.wh, data)
+}
@@ -0,0 +1,75 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newRecordingServer records the delivered request bodies
+func newRecordingServer() (*httptest.Server, func() []string) {
+	var lock sync.Mutex
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		lock.Lock()
+		bodies = append(bodies, string(body))
+		lock.Unlock()
+	}))
+	return server, func() []string {
+		lock.Lock()
+		defer lock.Unlock()
+		return append([]string{}, bodies...)
+	}
+}
+
+func TestBatcher(t *testing.T) {
+	cases := []struct {
+		name      string
+		size      int
+		timeoutMs int
+		payloads  []string
+		wait      time.Duration
+		batches   [][]string
+	}{
+		{"full batch", 2, 1000, []string{`{"a":1}`, `{"b":2}`}, 0, [][]string{{`{"a":1}`, `{"b":2}`}}},
+		{"partial batch on timeout", 3, 10, []string{`{"a":1}`}, 50 * time.Millisecond, [][]string{{`{"a":1}`}}},
+		{"pending batch before timeout", 3, 1000, []string{`{"a":1}`}, 0, [][]string{}},
+		{"non json payload", 1, 1000, []string{`plain`}, 0, [][]string{{`"plain"`}}},
+	}
+	for _, c := range cases {
+		server, bodies := newRecordingServer()
+		wh := activeWebhook(server.URL)
+		wh.BatchSize, wh.BatchTimeoutMs = c.size, c.timeoutMs
+		b := NewBatcher(newTestSender(nil), wh)
+		for _, p := range c.payloads {
+			if err := b.Add([]byte(p)); err != nil {
+				t.Fatalf("%s: %v", c.name, err)
+			}
+		}
+		time.Sleep(c.wait)
+		got := bodies()
+		server.Close()
+
+		if len(got) != len(c.batches) {
+			t.Errorf("%s: expected %d batches, got %v", c.name, len(c.batches), got)
+			continue
+		}
+		for i, batch := range c.batches {
+			var items []json.RawMessage
+			if err := json.Unmarshal([]byte(got[i]), &items); err != nil || len(items) != len(batch) {
+				t.Errorf("%s: unexpected batch %s", c.name, got[i])
+				continue
+			}
+			for j, item := range items {
+				if string(item) != batch[j] {
+					t.Errorf("%s: expected %s, got %s", c.name, batch[j], item)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package webhook
+
+import (
+	"sync"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// BreakerState is the state of a circuit breaker
+type BreakerState int
+
+// state machine of circuit breaker
+const (
+	// BreakerClosed allows all deliveries
+	BreakerClosed BreakerState = iota
+	// BreakerOpen short-circuits all deliveries
+	BreakerOpen
+	// BreakerHalfOpen allows a single probe delivery to test recovery
+	BreakerHalfOpen
+)
+
+var breakerStateNames = []string{"closed", "open", "half-open"}
+
+// String returns the string representation of the breaker state
+func (s BreakerState) String() string {
+	return breakerStateNames[s]
+}
+
+var (
+	breakerFailureRate = util.GetEnvInt("WebhookBreakerFailureRate", 50)
+	breakerWindow      = util.GetEnvInt("WebhookBreakerWindow", 20)
+	breakerOpenTimeout = util.GetEnvInt("WebhookBreakerOpenSeconds", 30)
+)
+
+// CircuitBreaker opens when the failure rate in a window of deliveries reaches the threshold,
+// and half-opens after the open timeout to probe whether the endpoint has recovered.
+type CircuitBreaker struct {
+	// FailureRate is the failure percentage within a window to open the breaker
+	FailureRate int
+	// Window is the number of deliveries to evaluate the failure rate
+	Window      int
+	OpenTimeout time.Duration
+
+	state    BreakerState
+	requests int
+	failures int
+	openedAt time.Time
+	probing  bool
+	sync.Mutex
+}
+
+// NewCircuitBreaker creates a circuit breaker with the default settings
+func NewCircuitBreaker() *CircuitBreaker {
+	return &CircuitBreaker{
+		FailureRate: breakerFailureRate,
+		Window:      breakerWindow,
+		OpenTimeout: time.Duration(breakerOpenTimeout) * time.Second,
+	}
+}
+
+// Allow reports whether a delivery can be attempted
+func (b *CircuitBreaker) Allow() bool {
+	b.Lock()
+	defer b.Unlock()
+	switch b.state {
+	case BreakerOpen:
+		if time.Since(b.openedAt) < b.OpenTimeout {
+			return false
+		}
+		b.state = BreakerHalfOpen
+		b.probing = true
+		return true
+	case BreakerHalfOpen:
+		// only one probe at a time
+		if b.probing {
+			return false
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+}
+
+// Record records the outcome of a delivery
+func (b *CircuitBreaker) Record(success bool) {
+	b.Lock()
+	defer b.Unlock()
+	switch b.state {
+	case BreakerHalfOpen:
+		b.probing = false
+		if success {
+			b.reset(BreakerClosed)
+		} else {
+			b.trip()
+		}
+	case BreakerClosed:
+		b.requests++
+		if !success {
+			b.failures++
+		}
+		if b.requests >= b.Window {
+			if b.failures*100 >= b.FailureRate*b.requests {
+				b.trip()
+			} else {
+				b.reset(BreakerClosed)
+			}
+		}
+	}
+}
+
+// State returns the current breaker state
+func (b *CircuitBreaker) State() BreakerState {
+	b.Lock()
+	defer b.Unlock()
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.OpenTimeout {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+func (b *CircuitBreaker) trip() {
+	b.reset(BreakerOpen)
+	b.openedAt = time.Now()
+}
+
+func (b *CircuitBreaker) reset(state BreakerState) {
+	b.state = state
+	b.requests = 0
+	b.failures = 0
+}
@@ -0,0 +1,37 @@
+package webhook
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerTransitions(t *testing.T) {
+	b := &CircuitBreaker{FailureRate: 50, Window: 4, OpenTimeout: 20 * time.Millisecond}
+	steps := []struct {
+		name    string
+		wait    time.Duration
+		record  []bool
+		allowed bool
+		state   BreakerState
+	}{
+		{"failures below the rate keep it closed", 0, []bool{true, true, true, false}, true, BreakerClosed},
+		{"failures at the rate open it", 0, []bool{false, true, false, true}, false, BreakerOpen},
+		{"it half-opens after the open timeout", 30 * time.Millisecond, nil, true, BreakerHalfOpen},
+		{"a failed probe opens it again", 0, []bool{false}, false, BreakerOpen},
+		{"a second probe after the timeout", 30 * time.Millisecond, nil, true, BreakerHalfOpen},
+		{"only one probe at a time", 0, nil, false, BreakerHalfOpen},
+		{"a successful probe closes it", 0, []bool{true}, true, BreakerClosed},
+	}
+	for _, step := range steps {
+		time.Sleep(step.wait)
+		for _, success := range step.record {
+			b.Record(success)
+		}
+		if allowed := b.Allow(); allowed != step.allowed {
+			t.Fatalf("%s: expected allowed %v", step.name, step.allowed)
+		}
+		if state := b.State(); state != step.state {
+			t.Fatalf("%s: expected state %s, got %s", step.name, step.state, state)
+		}
+	}
+}
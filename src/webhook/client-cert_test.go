@@ -0,0 +1,146 @@
+package webhook
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// certFiles issues a client certificate signed by the CA and writes its PEM cert and key files
+func certFiles(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certPath, keyPath := filepath.Join(dir, name+".crt"), filepath.Join(dir, name+".key")
+	if err := ioutil.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return certPath, keyPath
+}
+
+// newCA creates a self-signed CA
+func newCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ca, key
+}
+
+func TestSendClientCertificate(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	defer func() {
+		tlsClientsLock.Lock()
+		tlsClients = make(map[string]*http.Client)
+		tlsClientsLock.Unlock()
+	}()
+
+	ca, caKey := newCA(t, "webhook-ca")
+	other, otherKey := newCA(t, "other-ca")
+	certPath, keyPath := certFiles(t, dir, "trusted", ca, caKey)
+	untrustedCert, untrustedKey := certFiles(t, dir, "untrusted", other, otherKey)
+
+	var subject string
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		subject = r.TLS.PeerCertificates[0].Subject.CommonName
+	}))
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	server.StartTLS()
+	defer server.Close()
+	serverCAs := x509.NewCertPool()
+	serverCAs.AddCert(server.Certificate())
+
+	cases := []struct {
+		name     string
+		certPath string
+		keyPath  string
+		subject  string
+		fails    bool
+	}{
+		{"trusted client certificate", certPath, keyPath, "trusted", false},
+		{"untrusted client certificate", untrustedCert, untrustedKey, "", true},
+		{"mismatched key", certPath, untrustedKey, "", true},
+		{"missing certificate", filepath.Join(dir, "missing.crt"), keyPath, "", true},
+	}
+	for _, c := range cases {
+		subject = ""
+		wh := activeWebhook(server.URL)
+		wh.ClientCertPath, wh.ClientKeyPath = c.certPath, c.keyPath
+		s := newTestSender(nil)
+		s.MaxRetries = 0
+		// the client of the webhook certificate trusts the test server
+		if client, err := s.clientFor(wh); err == nil {
+			client.Transport.(*http.Transport).TLSClientConfig.RootCAs = serverCAs
+		}
+
+		err := s.Send(wh, []byte(`{}`))
+		if (err != nil) != c.fails || subject != c.subject {
+			t.Errorf("%s: expected failure %v subject %q, got %v %q", c.name, c.fails, c.subject, err, subject)
+		}
+	}
+
+	// the client is shared by the webhooks with the same certificate
+	wh := activeWebhook(server.URL)
+	wh.ClientCertPath, wh.ClientKeyPath = certPath, keyPath
+	first, _ := newTestSender(nil).clientFor(wh)
+	second, _ := newTestSender(nil).clientFor(wh)
+	if first == nil || first != second {
+		t.Errorf("expected a shared client of the certificate")
+	}
+}
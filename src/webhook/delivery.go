@@ -0,0 +1,135 @@
+package webhook
+
+import (
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FunctionDelivery delivers the messages consumed from the function input topic to the activated webhooks
+// of the function. The concurrent deliveries are bounded by the function maximum concurrent deliveries.
+type FunctionDelivery struct {
+	sender   *WebhookSender
+	webhooks []*model.WebhookConfig
+	dispatch []Dispatcher
+	batchers []*Batcher
+	// ackMode is the acknowledgment mode of the function input topic
+	ackMode   string
+	closeOnce sync.Once
+}
+
+// NewFunctionDelivery creates the delivery of the function webhooks. A webhook with a batch size
+// is delivered in batches, otherwise every message is processed by the webhook dispatcher.
+func NewFunctionDelivery(cfg *model.FunctionConfig, reporter StatusReporter) *FunctionDelivery {
+	d := &FunctionDelivery{sender: NewFunctionSender(cfg, reporter), ackMode: cfg.InputTopic.AckMode}
+	for _, wh := range model.ActiveWebhooks(cfg.Webhooks) {
+		wh := wh
+		d.webhooks = append(d.webhooks, &wh)
+		if wh.BatchSize > 1 {
+			d.dispatch = append(d.dispatch, nil)
+			d.batchers = append(d.batchers, NewBatcher(d.sender, &wh))
+			continue
+		}
+		d.dispatch = append(d.dispatch, NewDispatcher(&wh, model.MaxDeliveries(cfg)))
+		d.batchers = append(d.batchers, nil)
+	}
+	return d
+}
+
+// Handle delivers the message to every webhook. The message is acknowledged once all webhooks
+// acknowledged it, or negatively acknowledged if any webhook did for redelivery.
+// Batched webhooks acknowledge the message once it is added to the batch.
+func (d *FunctionDelivery) Handle(consumer pulsar.Consumer, msg pulsar.Message) {
+	if len(d.webhooks) == 0 {
+		pulsardriver.Acknowledge(consumer, msg, d.ackMode)
+		return
+	}
+	acks := newAckGroup(consumer, len(d.webhooks), d.ackMode)
+	for i, wh := range d.webhooks {
+		if d.batchers[i] != nil {
+			d.batch(acks, msg, wh, d.batchers[i])
+			continue
+		}
+		d.sender.Dispatch(d.dispatch[i], acks, msg, wh)
+	}
+}
+
+func (d *FunctionDelivery) batch(acks pulsar.Consumer, msg pulsar.Message, wh *model.WebhookConfig, b *Batcher) {
+	if !FilterMatch(msg, wh.Filter) {
+		acks.Ack(msg)
+		return
+	}
+	payload, err := Transform(msg, wh.PayloadMode)
+	if err == nil {
+		err = b.Add(payload)
+	}
+	if err != nil {
+		log.Errorf("webhook %s batch of message %v error %v", wh.URL, msg.ID(), err)
+	}
+	acks.Ack(msg)
+}
+
+// SetOutput sets the output of the webhook replies, it must be set before the first message is handled.
+// The batched deliveries have no input message so their replies are not produced.
+func (d *FunctionDelivery) SetOutput(output OutputFunc) {
+	d.sender.Output = output
+}
+
+// Close waits for the pending deliveries and flushes the partial batches
+func (d *FunctionDelivery) Close() {
+	d.closeOnce.Do(func() {
+		for i := range d.webhooks {
+			if d.dispatch[i] != nil {
+				d.dispatch[i].Close()
+			}
+			if d.batchers[i] != nil {
+				if err := d.batchers[i].Close(); err != nil {
+					log.Errorf("webhook %s batch flush on close error %v", d.webhooks[i].URL, err)
+				}
+			}
+		}
+	})
+}
+
+// ackGroup acknowledges a message on the consumer by the acknowledgment mode once every webhook has acknowledged it
+type ackGroup struct {
+	pulsar.Consumer
+	pending int
+	nacked  bool
+	ackMode string
+	sync.Mutex
+}
+
+func newAckGroup(consumer pulsar.Consumer, webhooks int, ackMode string) *ackGroup {
+	return &ackGroup{Consumer: consumer, pending: webhooks, ackMode: ackMode}
+}
+
+// Ack acknowledges the message of one webhook
+func (g *ackGroup) Ack(msg pulsar.Message) {
+	g.done(msg, false)
+}
+
+// Nack negatively acknowledges the message of one webhook
+func (g *ackGroup) Nack(msg pulsar.Message) {
+	g.done(msg, true)
+}
+
+func (g *ackGroup) done(msg pulsar.Message, nack bool) {
+	g.Lock()
+	g.pending--
+	g.nacked = g.nacked || nack
+	last, nacked := g.pending == 0, g.nacked
+	g.Unlock()
+	if !last {
+		return
+	}
+	if nacked {
+		g.Consumer.Nack(msg)
+		return
+	}
+	pulsardriver.Acknowledge(g.Consumer, msg, g.ackMode)
+}
@@ -0,0 +1,172 @@
+package webhook
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// countingConsumer counts the acknowledgments of concurrent deliveries
+type countingConsumer struct {
+	pulsar.Consumer
+	acks, nacks int32
+}
+
+func (c *countingConsumer) Ack(pulsar.Message)  { atomic.AddInt32(&c.acks, 1) }
+func (c *countingConsumer) Nack(pulsar.Message) { atomic.AddInt32(&c.nacks, 1) }
+
+func TestFunctionDelivery(t *testing.T) {
+	cases := []struct {
+		name        string
+		statusCodes []int
+		acks, nacks int32
+	}{
+		{"no webhook", nil, 1, 0},
+		{"one webhook", []int{http.StatusOK}, 1, 0},
+		{"all webhooks delivered", []int{http.StatusOK, http.StatusOK}, 1, 0},
+		{"one webhook failed", []int{http.StatusOK, http.StatusBadRequest}, 0, 1},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{ID: "t1f1", Parallelism: 1}
+		calls := []*int32{}
+		for i, code := range c.statusCodes {
+			server, n := newTestServer(code)
+			defer server.Close()
+			wh := activeWebhook(server.URL)
+			wh.Subscription = fmt.Sprintf("sub-%d", i)
+			cfg.Webhooks = append(cfg.Webhooks, *wh)
+			calls = append(calls, n)
+		}
+		// a suspended webhook is not delivered
+		cfg.Webhooks = append(cfg.Webhooks, model.WebhookConfig{URL: "http://localhost:1", WebhookStatus: model.Suspended})
+
+		consumer := &countingConsumer{}
+		d := NewFunctionDelivery(cfg, nil)
+		d.Handle(consumer, &fakeMessage{payload: []byte(`{}`)})
+		d.Close()
+		if consumer.acks != c.acks || consumer.nacks != c.nacks {
+			t.Errorf("%s: expected %d acks %d nacks, got %d %d", c.name, c.acks, c.nacks, consumer.acks, consumer.nacks)
+		}
+		for i, n := range calls {
+			if atomic.LoadInt32(n) != 1 {
+				t.Errorf("%s: expected webhook %d to be delivered once, got %d", c.name, i, *n)
+			}
+		}
+	}
+}
+
+func TestFunctionDeliveryBatches(t *testing.T) {
+	server, bodies := newRecordingServer()
+	defer server.Close()
+	wh := activeWebhook(server.URL)
+	wh.BatchSize, wh.BatchTimeoutMs = 2, 60000
+	cfg := &model.FunctionConfig{ID: "t1f1", Parallelism: 1, Webhooks: []model.WebhookConfig{*wh}}
+
+	consumer := &countingConsumer{}
+	d := NewFunctionDelivery(cfg, nil)
+	for _, p := range []string{`{"a":1}`, `{"b":2}`, `{"c":3}`} {
+		d.Handle(consumer, &fakeMessage{payload: []byte(p)})
+	}
+	// the partial batch is flushed on close
+	d.Close()
+	got := bodies()
+	if len(got) != 2 || got[0] != `[{"a":1},{"b":2}]` || got[1] != `[{"c":3}]` {
+		t.Errorf("unexpected batches %v", got)
+	}
+	if consumer.acks != 3 {
+		t.Errorf("expected the batched messages to be acknowledged, got %d", consumer.acks)
+	}
+}
+
+func TestFunctionDeliveryConcurrency(t *testing.T) {
+	var inflight, peak int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inflight, 1)
+		defer atomic.AddInt32(&inflight, -1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	var lock sync.Mutex
+	reported := 0
+	wh := activeWebhook(server.URL)
+	wh.SubscriptionType = "shared"
+	cfg := &model.FunctionConfig{ID: "t1f1", Parallelism: 4, MaxConcurrentDeliveries: 3, Webhooks: []model.WebhookConfig{*wh}}
+	d := NewFunctionDelivery(cfg, func(wh *model.WebhookConfig) {
+		lock.Lock()
+		reported++
+		lock.Unlock()
+	})
+	d.sender.MaxFailures = 100
+
+	consumer := &countingConsumer{}
+	for i := 0; i < 12; i++ {
+		d.Handle(consumer, &fakeMessage{payload: []byte(`{}`)})
+	}
+	d.Close()
+	if peak > 3 {
+		t.Errorf("expected at most 3 concurrent deliveries, got %d", peak)
+	}
+	// the state of the webhook shared by the concurrent deliveries is consistent
+	if d.webhooks[0].Failures != 12 || reported != 12 || consumer.nacks != 12 {
+		t.Errorf("expected 12 failures reported and nacked, got %d %d %d", d.webhooks[0].Failures, reported, consumer.nacks)
+	}
+}
+
+// cumulativeConsumer records the cumulative acknowledgments besides the individual ones
+type cumulativeConsumer struct {
+	countingConsumer
+	cumulative int32
+}
+
+func (c *cumulativeConsumer) AckCumulative(pulsar.Message) error {
+	atomic.AddInt32(&c.cumulative, 1)
+	return nil
+}
+
+func TestFunctionDeliveryAckMode(t *testing.T) {
+	cases := []struct {
+		name       string
+		ackMode    string
+		webhooks   int
+		acks       int32
+		cumulative int32
+	}{
+		{"individual without webhook", "", 0, 1, 0},
+		{"cumulative without webhook", model.AckCumulative, 0, 0, 1},
+		{"individual", model.AckIndividual, 2, 1, 0},
+		{"cumulative", model.AckCumulative, 2, 0, 1},
+	}
+	for _, c := range cases {
+		cfg := &model.FunctionConfig{ID: "t1f1", Parallelism: 1, InputTopic: model.FunctionTopic{AckMode: c.ackMode}}
+		for i := 0; i < c.webhooks; i++ {
+			server, _ := newTestServer(http.StatusOK)
+			defer server.Close()
+			wh := activeWebhook(server.URL)
+			wh.Subscription = fmt.Sprintf("sub-%d", i)
+			cfg.Webhooks = append(cfg.Webhooks, *wh)
+		}
+		consumer := &cumulativeConsumer{}
+		d := NewFunctionDelivery(cfg, nil)
+		d.Handle(consumer, &fakeMessage{payload: []byte(`{}`)})
+		d.Close()
+		if consumer.acks != c.acks || consumer.cumulative != c.cumulative || consumer.nacks != 0 {
+			t.Errorf("%s: expected %d acks %d cumulative acks, got %d %d %d nacks",
+				c.name, c.acks, c.cumulative, consumer.acks, consumer.cumulative, consumer.nacks)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package webhook
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// Dispatcher runs message deliveries
+type Dispatcher interface {
+	Dispatch(key string, deliver func())
+	Close()
+}
+
+// NewDispatcher creates an ordered dispatcher for a key-shared webhook subscription so that the
+// deliveries of the same key stay in order, otherwise the deliveries run concurrently.
+// The workers are the number of keys delivered in parallel by the ordered dispatcher.
+func NewDispatcher(wh *model.WebhookConfig, workers int) Dispatcher {
+	if subType, err := model.GetSubscriptionType(wh.SubscriptionType); err == nil && subType == pulsar.KeyShared {
+		return NewOrderedDispatcher(workers)
+	}
+	return &concurrentDispatcher{}
+}
+
+// concurrentDispatcher runs every delivery in its own goroutine
+type concurrentDispatcher struct {
+	wg sync.WaitGroup
+}
+
+// Dispatch is a Dispatcher interface method
+func (d *concurrentDispatcher) Dispatch(key string, deliver func()) {
+	d.wg.Add(1)
+	go func() {
+		defer d.wg.Done()
+		deliver()
+	}()
+}
+
+// Close waits for the pending deliveries
+func (d *concurrentDispatcher) Close() {
+	d.wg.Wait()
+}
+
+// OrderedDispatcher serializes the deliveries of the same message key on a worker
+// while the deliveries of the keys hashed to different workers run in parallel
+type OrderedDispatcher struct {
+	workers []chan func()
+	wg      sync.WaitGroup
+}
+
+// NewOrderedDispatcher creates an ordered dispatcher with the number of workers
+func NewOrderedDispatcher(workers int) *OrderedDispatcher {
+	if workers < 1 {
+		workers = 1
+	}
+	d := &OrderedDispatcher{workers: make([]chan func(), workers)}
+	for i := range d.workers {
+		d.workers[i] = make(chan func(), 100)
+		d.wg.Add(1)
+		go func(queue chan func()) {
+			defer d.wg.Done()
+			for deliver := range queue {
+				deliver()
+			}
+		}(d.workers[i])
+	}
+	return d
+}
+
+// Dispatch queues the delivery on the worker of the key, it blocks if the worker queue is full
+func (d *OrderedDispatcher) Dispatch(key string, deliver func()) {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	d.workers[h.Sum32()%uint32(len(d.workers))] <- deliver
+}
+
+// Close waits for the queued deliveries to complete, no delivery can be dispatched afterwards
+func (d *OrderedDispatcher) Close() {
+	for _, queue := range d.workers {
+		close(queue)
+	}
+	d.wg.Wait()
+}
+
+// Dispatch processes the consumed message with the dispatcher keyed by the message key
+func (s *WebhookSender) Dispatch(d Dispatcher, consumer pulsar.Consumer, msg pulsar.Message, wh *model.WebhookConfig) {
+	d.Dispatch(msg.Key(), func() {
+		s.Process(consumer, msg, wh)
+	})
+}
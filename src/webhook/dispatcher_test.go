@@ -0,0 +1,82 @@
+package webhook
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestNewDispatcher(t *testing.T) {
+	cases := []struct {
+		subscriptionType string
+		ordered          bool
+	}{
+		{"keyshared", true},
+		{"KeyShared", true},
+		{"failover", false},
+		{"shared", false},
+		{"exclusive", false},
+		{"", false},
+	}
+	for _, c := range cases {
+		d := NewDispatcher(&model.WebhookConfig{SubscriptionType: c.subscriptionType}, 2)
+		if _, ordered := d.(*OrderedDispatcher); ordered != c.ordered {
+			t.Errorf("%q: expected ordered dispatcher %v, got %T", c.subscriptionType, c.ordered, d)
+		}
+		d.Close()
+	}
+}
+
+// workerOf returns the worker the ordered dispatcher assigns to the key
+func workerOf(key string, workers int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32() % uint32(workers)
+}
+
+func TestOrderedDispatcher(t *testing.T) {
+	keys := []string{"order-1", "order-2"}
+	if workerOf(keys[0], 2) == workerOf(keys[1], 2) {
+		t.Fatalf("expected the keys on different workers")
+	}
+
+	var lock sync.Mutex
+	delivered := map[string][]int{}
+	var inflight, peak int32
+	d := NewOrderedDispatcher(2)
+	// the deliveries of the two keys are interleaved
+	for i := 0; i < 20; i++ {
+		for _, key := range keys {
+			key, seq := key, i
+			d.Dispatch(key, func() {
+				n := atomic.AddInt32(&inflight, 1)
+				defer atomic.AddInt32(&inflight, -1)
+				for {
+					p := atomic.LoadInt32(&peak)
+					if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				lock.Lock()
+				delivered[key] = append(delivered[key], seq)
+				lock.Unlock()
+			})
+		}
+	}
+	d.Close()
+
+	for _, key := range keys {
+		if fmt.Sprint(delivered[key]) != fmt.Sprint([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17, 18, 19}) {
+			t.Errorf("expected the deliveries of %s in order, got %v", key, delivered[key])
+		}
+	}
+	if peak != 2 {
+		t.Errorf("expected the two keys delivered in parallel, got %d concurrent deliveries", peak)
+	}
+}
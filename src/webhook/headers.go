@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// SecretLookup resolves a secret variable referenced in webhook headers, the secret env variables by default
+var SecretLookup = util.LookupSecretEnv
+
+// ResolveHeaders substitutes ${VAR} in header values at delivery time so tokens can be rotated
+// without editing webhook configurations. `$$` escapes a literal `$`, any other text is left intact.
+// Only the variables prefixed with util.SecretEnvPrefix can be referenced.
+func ResolveHeaders(headers []string) ([]string, error) {
+	resolved := make([]string, 0, len(headers))
+	for _, h := range headers {
+		value, err := resolveHeader(h)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, value)
+	}
+	return resolved, nil
+}
+
+func resolveHeader(h string) (string, error) {
+	var sb strings.Builder
+	for i := 0; i < len(h); i++ {
+		if h[i] != '$' || i+1 >= len(h) {
+			sb.WriteByte(h[i])
+			continue
+		}
+		switch h[i+1] {
+		case '$':
+			sb.WriteByte('$')
+			i++
+		case '{':
+			end := strings.IndexByte(h[i+2:], '}')
+			if end < 0 {
+				sb.WriteByte(h[i])
+				continue
+			}
+			name := h[i+2 : i+2+end]
+			if !util.IsSecretEnvName(name) {
+				return "", fmt.Errorf("variable %s referenced in webhook header is not prefixed with %s", name, util.SecretEnvPrefix)
+			}
+			value, ok := SecretLookup(name)
+			if !ok {
+				return "", fmt.Errorf("missing variable %s referenced in webhook header", name)
+			}
+			sb.WriteString(value)
+			i += end + 2
+		default:
+			sb.WriteByte(h[i])
+		}
+	}
+	return sb.String(), nil
+}
@@ -0,0 +1,39 @@
+package webhook
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveHeaders(t *testing.T) {
+	os.Setenv("PUBSUBFN_SECRET_TOKEN", "abc")
+	defer os.Unsetenv("PUBSUBFN_SECRET_TOKEN")
+	os.Setenv("DbPassword", "server-secret")
+	defer os.Unsetenv("DbPassword")
+
+	cases := []struct {
+		header   string
+		resolved string
+		fails    bool
+	}{
+		{"Authorization: Bearer ${PUBSUBFN_SECRET_TOKEN}", "Authorization: Bearer abc", false},
+		{"X-Price: $$5 ${PUBSUBFN_SECRET_TOKEN}", "X-Price: $5 abc", false},
+		{"X-Literal: $${PUBSUBFN_SECRET_TOKEN}", "X-Literal: ${PUBSUBFN_SECRET_TOKEN}", false},
+		{"X-Open: ${unterminated", "X-Open: ${unterminated", false},
+		{"X-Missing: ${PUBSUBFN_SECRET_MISSING}", "", true},
+		{"X-Leak: ${DbPassword}", "", true},
+		{"X-Empty: ${}", "", true},
+	}
+	for _, c := range cases {
+		resolved, err := ResolveHeaders([]string{c.header})
+		if c.fails {
+			if err == nil {
+				t.Errorf("%s: expected an error, got %v", c.header, resolved)
+			}
+			continue
+		}
+		if err != nil || len(resolved) != 1 || resolved[0] != c.resolved {
+			t.Errorf("%s: expected %s, got %v error %v", c.header, c.resolved, resolved, err)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package webhook
+
+import (
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	deliveryLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "pubsub_function_webhook_delivery_seconds",
+		Help:    "The http round trip time of webhook delivery attempts",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"function", "host"})
+
+	deliveryStatus = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pubsub_function_webhook_deliveries_total",
+		Help: "The number of webhook delivery attempts by the http status class",
+	}, []string{"function", "host", "class"})
+
+	oversizedSkips = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "pubsub_function_webhook_oversized_skips_total",
+		Help: "The number of messages skipped for exceeding the webhook maximum payload size",
+	}, []string{"function", "host"})
+)
+
+func init() {
+	prometheus.MustRegister(deliveryLatency, deliveryStatus, oversizedSkips)
+}
+
+// statusClass returns the http status class such as 2xx, or error if there is no response
+func statusClass(statusCode int, err error) string {
+	if err != nil || statusCode < 100 {
+		return "error"
+	}
+	return strconv.Itoa(statusCode/100) + "xx"
+}
+
+// webhookHost returns the host of the webhook URL as the metrics label
+func webhookHost(webhookURL string) string {
+	if u, err := url.Parse(webhookURL); err == nil {
+		return u.Host
+	}
+	return ""
+}
+
+// observeOversized counts a message skipped for exceeding the webhook maximum payload size
+func observeOversized(functionID, webhookURL string) {
+	oversizedSkips.WithLabelValues(functionID, webhookHost(webhookURL)).Inc()
+}
+
+// observeDelivery records the latency and the status class of a delivery attempt
+func observeDelivery(functionID, webhookURL string, start time.Time, statusCode int, err error) {
+	host := webhookHost(webhookURL)
+	deliveryLatency.WithLabelValues(functionID, host).Observe(time.Since(start).Seconds())
+	deliveryStatus.WithLabelValues(functionID, host, statusClass(statusCode, err)).Inc()
+}
@@ -0,0 +1,103 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestStatusClass(t *testing.T) {
+	cases := []struct {
+		statusCode int
+		err        error
+		class      string
+	}{
+		{http.StatusOK, nil, "2xx"},
+		{http.StatusNoContent, nil, "2xx"},
+		{http.StatusMovedPermanently, nil, "3xx"},
+		{http.StatusNotFound, nil, "4xx"},
+		{http.StatusBadGateway, nil, "5xx"},
+		{0, errors.New("connection refused"), "error"},
+		{http.StatusOK, errors.New("timeout"), "error"},
+	}
+	for _, c := range cases {
+		if class := statusClass(c.statusCode, c.err); class != c.class {
+			t.Errorf("%d %v: expected %s, got %s", c.statusCode, c.err, c.class, class)
+		}
+	}
+}
+
+// latencyOf returns the sample count and sum of the delivery latency of the function to the host
+func latencyOf(t *testing.T, functionID, host string) (uint64, float64) {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "pubsub_function_webhook_delivery_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			labels := map[string]string{}
+			for _, label := range m.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			if labels["function"] == functionID && labels["host"] == host {
+				return m.GetHistogram().GetSampleCount(), m.GetHistogram().GetSampleSum()
+			}
+		}
+	}
+	return 0, 0
+}
+
+func TestDeliveryMetrics(t *testing.T) {
+	delay := 20 * time.Millisecond
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		switch {
+		case strings.HasSuffix(r.URL.Path, "/missing"):
+			w.WriteHeader(http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/broken"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+	host := strings.TrimPrefix(server.URL, "http://")
+
+	s := newTestSender(nil)
+	s.FunctionID = "metrics-test"
+	s.MaxRetries = 0
+	for _, path := range []string{"/ok", "/ok", "/missing", "/broken"} {
+		s.Send(activeWebhook(server.URL+path), []byte(`{}`))
+	}
+	// a refused connection has no status class
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+	s.Send(activeWebhook(refused.URL), []byte(`{}`))
+
+	cases := []struct {
+		host  string
+		class string
+		count float64
+	}{
+		{host, "2xx", 2},
+		{host, "4xx", 1},
+		{host, "5xx", 1},
+		{strings.TrimPrefix(refused.URL, "http://"), "error", 1},
+	}
+	for _, c := range cases {
+		if count := testutil.ToFloat64(deliveryStatus.WithLabelValues("metrics-test", c.host, c.class)); count != c.count {
+			t.Errorf("%s: expected %v deliveries, got %v", c.class, c.count, count)
+		}
+	}
+	count, sum := latencyOf(t, "metrics-test", host)
+	if count != 4 || sum < 4*delay.Seconds() {
+		t.Errorf("expected the latency of the delayed deliveries, got %d samples of %v seconds", count, sum)
+	}
+}
@@ -0,0 +1,160 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// ProbePayload is the synthetic payload sent to test a webhook
+const ProbePayload = `{"test":true,"message":"pubsub function webhook test"}`
+
+// ProbeResult is the outcome of a webhook test delivery
+type ProbeResult struct {
+	StatusCode int    `json:"statusCode"`
+	LatencyMs  int64  `json:"latencyMs"`
+	Error      string `json:"error,omitempty"`
+}
+
+var (
+	// probeHTTPClient does not follow redirects so a public URL cannot redirect the probe to a private address
+	probeHTTPClient *http.Client
+	probeClientOnce sync.Once
+)
+
+func probeClient() *http.Client {
+	probeClientOnce.Do(func() {
+		probeHTTPClient = &http.Client{
+			Transport: newProbeTransport(),
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+	})
+	return probeHTTPClient
+}
+
+// newProbeTransport checks the address of every connection when it is dialed, so a host resolving
+// to a public address on validation cannot rebind to a disallowed address on delivery.
+// The probe is never sent through a proxy since the proxy would dial the webhook host instead.
+func newProbeTransport() *http.Transport {
+	transport := newTransport(nil)
+	transport.Proxy = nil
+	transport.DialContext = (&net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   probeDialControl,
+	}).DialContext
+	return transport
+}
+
+// probeDialControl rejects a connection to a disallowed address unless WebhookProbeAllowPrivate is enabled
+func probeDialControl(network, address string, c syscall.RawConn) error {
+	if util.StringToBool(util.GetConfig().WebhookProbeAllowPrivate) {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || disallowedIP(ip) {
+		return fmt.Errorf("webhook connection to a disallowed address %s", host)
+	}
+	return nil
+}
+
+// ValidateProbeURL accepts http and https URLs whose host does not resolve to a loopback, private,
+// link-local, or unspecified address, unless WebhookProbeAllowPrivate is enabled
+func ValidateProbeURL(str string) error {
+	u, err := url.Parse(str)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("not a http or https URL %s", str)
+	}
+	if util.StringToBool(util.GetConfig().WebhookProbeAllowPrivate) {
+		return nil
+	}
+	ips, err := net.LookupIP(u.Hostname())
+	if err != nil {
+		return fmt.Errorf("failed to resolve webhook host %s %v", u.Hostname(), err)
+	}
+	for _, ip := range ips {
+		if disallowedIP(ip) {
+			return fmt.Errorf("webhook host %s resolves to a disallowed address %v", u.Hostname(), ip)
+		}
+	}
+	return nil
+}
+
+// disallowedIP checks whether the address is a loopback, private, link-local, or unspecified address
+func disallowedIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() || isPrivateIP(ip)
+}
+
+var privateCIDRs = []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "100.64.0.0/10", "fc00::/7"}
+
+func isPrivateIP(ip net.IP) bool {
+	for _, cidr := range privateCIDRs {
+		if _, network, err := net.ParseCIDR(cidr); err == nil && network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// Probe sends the synthetic payload to the webhook URL once without retry and reports the reply.
+// The headers can reference secret variables as the webhook headers.
+func Probe(webhookURL string, headers []string, timeout time.Duration) ProbeResult {
+	if err := ValidateProbeURL(webhookURL); err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	resolved, err := ResolveHeaders(headers)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	if timeout <= 0 {
+		timeout = webhookTimeout()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, webhookURL, bytes.NewReader([]byte(ProbePayload)))
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	for _, h := range resolved {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return ProbeResult{Error: fmt.Sprintf("malformed webhook header %s", h)}
+		}
+		req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+
+	start := time.Now()
+	res, err := probeClient().Do(req)
+	result := ProbeResult{LatencyMs: time.Since(start).Milliseconds()}
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+	result.StatusCode = res.StatusCode
+	if res.StatusCode < http.StatusOK || res.StatusCode >= http.StatusMultipleChoices {
+		result.Error = fmt.Sprintf("webhook replied with status code %d", res.StatusCode)
+	}
+	return result
+}
@@ -0,0 +1,118 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+func TestProbeDialControl(t *testing.T) {
+	defer func(allow string) { util.Config.WebhookProbeAllowPrivate = allow }(util.Config.WebhookProbeAllowPrivate)
+	cases := []struct {
+		address string
+		allow   string
+		fails   bool
+	}{
+		{"93.184.216.34:443", "", false},
+		{"[2606:2800:220:1::]:443", "", false},
+		{"127.0.0.1:8080", "", true},
+		{"[::1]:80", "", true},
+		{"10.1.2.3:80", "", true},
+		{"172.16.0.1:80", "", true},
+		{"192.168.1.1:80", "", true},
+		{"169.254.169.254:80", "", true},
+		{"0.0.0.0:80", "", true},
+		{"[fd00::1]:80", "", true},
+		{"127.0.0.1:8080", "true", false},
+	}
+	for _, c := range cases {
+		util.Config.WebhookProbeAllowPrivate = c.allow
+		if err := probeDialControl("tcp", c.address, nil); (err != nil) != c.fails {
+			t.Errorf("%s allow %q: expected failure %v, got %v", c.address, c.allow, c.fails, err)
+		}
+	}
+}
+
+func TestProbe(t *testing.T) {
+	defer func(allow string) { util.Config.WebhookProbeAllowPrivate = allow }(util.Config.WebhookProbeAllowPrivate)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/fail":
+			w.WriteHeader(http.StatusInternalServerError)
+		case "/redirect":
+			http.Redirect(w, r, "/ok", http.StatusFound)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+		}
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name       string
+		url        string
+		allow      string
+		statusCode int
+		err        string
+	}{
+		{"success", server.URL + "/ok", "true", http.StatusOK, ""},
+		{"non-2xx reply", server.URL + "/fail", "true", http.StatusInternalServerError, "status code 500"},
+		{"redirect is not followed", server.URL + "/redirect", "true", http.StatusFound, "status code 302"},
+		{"timeout", server.URL + "/slow", "true", 0, "deadline exceeded"},
+		{"private address", server.URL + "/ok", "", 0, "disallowed address"},
+		{"not a http URL", "ftp://example.com", "true", 0, "not a http or https URL"},
+	}
+	for _, c := range cases {
+		util.Config.WebhookProbeAllowPrivate = c.allow
+		result := Probe(c.url, nil, 50*time.Millisecond)
+		if result.StatusCode != c.statusCode || !strings.Contains(result.Error, c.err) || (c.err == "") != (result.Error == "") {
+			t.Errorf("%s: expected status %d error %q, got %+v", c.name, c.statusCode, c.err, result)
+		}
+	}
+}
+
+func TestProbeRejectsRebindingOnDial(t *testing.T) {
+	defer func(allow string) { util.Config.WebhookProbeAllowPrivate = allow }(util.Config.WebhookProbeAllowPrivate)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	// the host passed validation but connects to a loopback address when delivered
+	util.Config.WebhookProbeAllowPrivate = ""
+	req, err := http.NewRequest(http.MethodPost, server.URL, strings.NewReader(ProbePayload))
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := probeClient().Do(req)
+	if err == nil {
+		res.Body.Close()
+		t.Fatalf("expected the connection to the loopback address rejected, got status %d", res.StatusCode)
+	}
+	if !strings.Contains(err.Error(), "disallowed address") {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestValidateProbeURL(t *testing.T) {
+	defer func(allow string) { util.Config.WebhookProbeAllowPrivate = allow }(util.Config.WebhookProbeAllowPrivate)
+	util.Config.WebhookProbeAllowPrivate = ""
+	cases := []struct {
+		url string
+		err string
+	}{
+		{"https://93.184.216.34/hook", ""},
+		{"ftp://93.184.216.34/hook", "not a http or https URL"},
+		{"https:///hook", "not a http or https URL"},
+		{"http://127.0.0.1:8080/hook", "disallowed address"},
+		{"http://[::1]/hook", "disallowed address"},
+		{"http://169.254.169.254/latest/meta-data", "disallowed address"},
+	}
+	for _, c := range cases {
+		err := ValidateProbeURL(c.url)
+		if (c.err == "") != (err == nil) || (err != nil && !strings.Contains(err.Error(), c.err)) {
+			t.Errorf("%s: expected error %q, got %v", c.url, c.err, err)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package webhook
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// FilterMatch evaluates whether a message matches the webhook filter
+func FilterMatch(msg pulsar.Message, filter model.Filter) bool {
+	if filter.KeyPrefix != "" && !strings.HasPrefix(msg.Key(), filter.KeyPrefix) {
+		return false
+	}
+	if filter.PropertyKey != "" {
+		value, ok := msg.Properties()[filter.PropertyKey]
+		if !ok || (filter.PropertyValue != "" && value != filter.PropertyValue) {
+			return false
+		}
+	}
+	return true
+}
+
+// Process delivers a consumed message to the webhook.
+// Messages not matching the webhook filter or exceeding the webhook maximum payload size
+// are acknowledged without delivery.
+// Under at-least-once delivery the message is acknowledged only after a successful delivery,
+// otherwise it is negatively acknowledged for redelivery. Under at-most-once delivery
+// the message is acknowledged on receive regardless of the delivery result.
+// The acknowledgment follows the sender acknowledgment mode.
+func (s *WebhookSender) Process(consumer pulsar.Consumer, msg pulsar.Message, wh *model.WebhookConfig) {
+	if guarantee, _ := model.GetDeliveryGuarantee(wh.DeliveryGuarantee); guarantee == model.AtMostOnce {
+		pulsardriver.Acknowledge(consumer, msg, s.AckMode)
+		if _, err := s.Deliver(msg, wh); err != nil {
+			log.Errorf("webhook %s delivery of message %v error %v", wh.URL, msg.ID(), err)
+		}
+		return
+	}
+	if _, err := s.Deliver(msg, wh); err != nil {
+		log.Errorf("webhook %s delivery of message %v error %v", wh.URL, msg.ID(), err)
+		consumer.Nack(msg)
+		return
+	}
+	pulsardriver.Acknowledge(consumer, msg, s.AckMode)
+}
+
+// Deliver filters, transforms, and sends a message to the webhook, and produces the reply to the sender output.
+// It returns false without error if the message does not match the webhook filter
+// or the payload is skipped for exceeding the webhook maximum payload size.
+func (s *WebhookSender) Deliver(msg pulsar.Message, wh *model.WebhookConfig) (bool, error) {
+	if !FilterMatch(msg, wh.Filter) {
+		return false, nil
+	}
+	payload, err := Transform(msg, wh.PayloadMode)
+	if err != nil {
+		return false, err
+	}
+	if wh.MaxPayloadBytes > 0 && len(payload) > wh.MaxPayloadBytes {
+		s.skipOversized(msg, wh, len(payload))
+		return false, nil
+	}
+	var headers []string
+	if wh.PropagateProperties {
+		headers = PropertyHeaders(msg.Properties())
+	}
+	reply, err := s.send(wh, payload, headers)
+	if err != nil {
+		return true, err
+	}
+	// an empty reply produces no output message
+	if s.Output != nil && len(reply) > 0 {
+		if err := s.Output(msg, reply); err != nil {
+			return true, fmt.Errorf("webhook %s reply output of message %v error %v", wh.URL, msg.ID(), err)
+		}
+	}
+	return true, nil
+}
+
+// skipOversized logs and counts a message skipped for exceeding the webhook maximum payload size
+func (s *WebhookSender) skipOversized(msg pulsar.Message, wh *model.WebhookConfig, size int) {
+	message := fmt.Sprintf("skip message %v of %d bytes exceeding webhook %s max payload %d bytes",
+		msg.ID(), size, wh.URL, wh.MaxPayloadBytes)
+	log.Warn(message)
+	s.log(pulsardriver.LogLevelWarn, message)
+	observeOversized(s.FunctionID, wh.URL)
+}
+
+// PropertyHeaderPrefix prefixes the message property names propagated as webhook headers
+const PropertyHeaderPrefix = "X-Msg-Prop-"
+
+// PropertyHeaders converts message properties to webhook headers.
+// Properties whose names are not valid header names are skipped.
+func PropertyHeaders(properties map[string]string) []string {
+	headers := []string{}
+	for k, v := range properties {
+		if !validHeaderName(k) || strings.ContainsAny(v, "\r\n") {
+			log.Warnf("skip propagating message property %s as webhook header", k)
+			continue
+		}
+		headers = append(headers, PropertyHeaderPrefix+k+": "+v)
+	}
+	return headers
+}
+
+// validHeaderName checks the name consists of http token characters
+func validHeaderName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, c := range name {
+		if c < 33 || c > 126 || strings.ContainsRune("\"(),/:;<=>?@[\\]{}", c) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// fakeMessage is a message with a topic, key, properties, and payload
+type fakeMessage struct {
+	pulsar.Message
+	topic      string
+	key        string
+	payload    []byte
+	properties map[string]string
+	published  time.Time
+}
+
+func (m *fakeMessage) Key() string                   { return m.key }
+func (m *fakeMessage) Payload() []byte               { return m.payload }
+func (m *fakeMessage) Properties() map[string]string { return m.properties }
+func (m *fakeMessage) Topic() string                 { return m.topic }
+func (m *fakeMessage) ID() pulsar.MessageID          { return pulsar.EarliestMessageID() }
+func (m *fakeMessage) PublishTime() time.Time        { return m.published }
+func (m *fakeMessage) EventTime() time.Time          { return time.Time{} }
+
+func TestFilterMatch(t *testing.T) {
+	msg := &fakeMessage{key: "order-1", properties: map[string]string{"region": "eu"}}
+	cases := []struct {
+		name   string
+		filter model.Filter
+		match  bool
+	}{
+		{"no filter", model.Filter{}, true},
+		{"matching key prefix", model.Filter{KeyPrefix: "order-"}, true},
+		{"other key prefix", model.Filter{KeyPrefix: "invoice-"}, false},
+		{"property present", model.Filter{PropertyKey: "region"}, true},
+		{"property absent", model.Filter{PropertyKey: "tier"}, false},
+		{"matching property value", model.Filter{PropertyKey: "region", PropertyValue: "eu"}, true},
+		{"other property value", model.Filter{PropertyKey: "region", PropertyValue: "us"}, false},
+		{"key and property must both match", model.Filter{KeyPrefix: "invoice-", PropertyKey: "region"}, false},
+	}
+	for _, c := range cases {
+		if match := FilterMatch(msg, c.filter); match != c.match {
+			t.Errorf("%s: expected match %v", c.name, c.match)
+		}
+	}
+}
+
+// ackConsumer records the acknowledgments and negative acknowledgments
+type ackConsumer struct {
+	pulsar.Consumer
+	acks, nacks int
+}
+
+func (c *ackConsumer) Ack(pulsar.Message)  { c.acks++ }
+func (c *ackConsumer) Nack(pulsar.Message) { c.nacks++ }
+
+func TestProcessDeliveryGuarantee(t *testing.T) {
+	cases := []struct {
+		name       string
+		guarantee  string
+		statusCode int
+		acks       int
+		nacks      int
+	}{
+		{"at least once delivered", model.AtLeastOnce, http.StatusOK, 1, 0},
+		{"at least once failed", model.AtLeastOnce, http.StatusBadRequest, 0, 1},
+		{"at most once delivered", model.AtMostOnce, http.StatusOK, 1, 0},
+		{"at most once failed", model.AtMostOnce, http.StatusBadRequest, 1, 0},
+	}
+	for _, c := range cases {
+		server, _ := newTestServer(c.statusCode)
+		wh := activeWebhook(server.URL)
+		wh.DeliveryGuarantee = c.guarantee
+		consumer := &ackConsumer{}
+		newTestSender(nil).Process(consumer, &fakeMessage{payload: []byte(`{}`)}, wh)
+		server.Close()
+		if consumer.acks != c.acks || consumer.nacks != c.nacks {
+			t.Errorf("%s: expected %d acks %d nacks, got %d and %d", c.name, c.acks, c.nacks, consumer.acks, consumer.nacks)
+		}
+	}
+}
+
+func TestProcessAckMode(t *testing.T) {
+	cases := []struct {
+		name       string
+		ackMode    string
+		guarantee  string
+		acks       int32
+		cumulative int32
+	}{
+		{"individual", model.AckIndividual, model.AtLeastOnce, 1, 0},
+		{"cumulative", model.AckCumulative, model.AtLeastOnce, 0, 1},
+		{"cumulative at most once", model.AckCumulative, model.AtMostOnce, 0, 1},
+	}
+	for _, c := range cases {
+		server, _ := newTestServer(http.StatusOK)
+		wh := activeWebhook(server.URL)
+		wh.DeliveryGuarantee = c.guarantee
+		consumer := &cumulativeConsumer{}
+		sender := newTestSender(nil)
+		sender.AckMode = c.ackMode
+		sender.Process(consumer, &fakeMessage{payload: []byte(`{}`)}, wh)
+		server.Close()
+		if consumer.acks != c.acks || consumer.cumulative != c.cumulative {
+			t.Errorf("%s: expected %d acks %d cumulative acks, got %d and %d", c.name, c.acks, c.cumulative, consumer.acks, consumer.cumulative)
+		}
+	}
+}
+
+func TestDeliverOutput(t *testing.T) {
+	cases := []struct {
+		name      string
+		reply     string
+		output    bool
+		outputErr error
+		produced  string
+		acks      int
+		nacks     int
+	}{
+		{"reply produced", `{"result":1}`, true, nil, `{"result":1}`, 1, 0},
+		{"empty reply", "", true, nil, "", 1, 0},
+		{"output failure is redelivered", `{"result":1}`, true, errors.New("producer closed"), `{"result":1}`, 0, 1},
+		{"no output", `{"result":1}`, false, nil, "", 1, 0},
+	}
+	for _, c := range cases {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(c.reply))
+		}))
+		produced := ""
+		sender := newTestSender(nil)
+		if c.output {
+			sender.Output = func(input pulsar.Message, reply []byte) error {
+				produced = string(reply)
+				return c.outputErr
+			}
+		}
+		consumer := &ackConsumer{}
+		sender.Process(consumer, &fakeMessage{payload: []byte(`{}`)}, activeWebhook(server.URL))
+		server.Close()
+		if produced != c.produced || consumer.acks != c.acks || consumer.nacks != c.nacks {
+			t.Errorf("%s: expected %q produced with %d acks %d nacks, got %q %d %d",
+				c.name, c.produced, c.acks, c.nacks, produced, consumer.acks, consumer.nacks)
+		}
+	}
+}
+
+func TestPropertyHeaders(t *testing.T) {
+	cases := []struct {
+		properties map[string]string
+		headers    []string
+	}{
+		{nil, []string{}},
+		{map[string]string{"trace": "t1"}, []string{"X-Msg-Prop-trace: t1"}},
+		{map[string]string{"bad name": "v"}, []string{}},
+		{map[string]string{"a/b": "v"}, []string{}},
+		{map[string]string{"split": "v\r\nX-Injected: 1"}, []string{}},
+	}
+	for _, c := range cases {
+		if headers := PropertyHeaders(c.properties); strings.Join(headers, "|") != strings.Join(c.headers, "|") {
+			t.Errorf("%v: expected headers %v, got %v", c.properties, c.headers, headers)
+		}
+	}
+}
+
+func TestDeliverPropagatesProperties(t *testing.T) {
+	cases := []struct {
+		propagate bool
+		header    string
+	}{
+		{true, "t1"},
+		{false, ""},
+	}
+	for _, c := range cases {
+		var received http.Header
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			received = r.Header.Clone()
+		}))
+		wh := activeWebhook(server.URL)
+		wh.PropagateProperties = c.propagate
+		msg := &fakeMessage{payload: []byte(`{}`), properties: map[string]string{"trace": "t1"}}
+		if _, err := newTestSender(nil).Deliver(msg, wh); err != nil {
+			t.Fatal(err)
+		}
+		server.Close()
+		if got := received.Get("X-Msg-Prop-trace"); got != c.header {
+			t.Errorf("propagate %v: expected header %q, got %q", c.propagate, c.header, got)
+		}
+	}
+}
+
+func TestDeliverMaxPayloadBytes(t *testing.T) {
+	cases := []struct {
+		name     string
+		limit    int
+		payload  string
+		delivers bool
+	}{
+		{"unlimited", 0, `{"data":"0123456789"}`, true},
+		{"under the limit", 64, `{"data":"0123456789"}`, true},
+		{"at the limit", 21, `{"data":"0123456789"}`, true},
+		{"over the limit", 20, `{"data":"0123456789"}`, false},
+	}
+	for _, c := range cases {
+		server, calls := newTestServer(http.StatusOK)
+		wh := activeWebhook(server.URL)
+		wh.MaxPayloadBytes = c.limit
+		s := newTestSender(nil)
+		s.FunctionID = "oversized-test"
+		host := strings.TrimPrefix(server.URL, "http://")
+
+		consumer := &ackConsumer{}
+		s.Process(consumer, &fakeMessage{payload: []byte(c.payload)}, wh)
+		server.Close()
+
+		// an oversized message is skipped and acknowledged
+		if consumer.acks != 1 || consumer.nacks != 0 {
+			t.Errorf("%s: expected the message acknowledged, got %d acks %d nacks", c.name, consumer.acks, consumer.nacks)
+		}
+		if delivered := *calls == 1; delivered != c.delivers {
+			t.Errorf("%s: expected delivery %v, got %d calls", c.name, c.delivers, *calls)
+		}
+		skipped := testutil.ToFloat64(oversizedSkips.WithLabelValues("oversized-test", host))
+		if (skipped == 1) == c.delivers {
+			t.Errorf("%s: expected the oversized skips counted %v, got %v", c.name, !c.delivers, skipped)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+var (
+	// the maximum number of messages can be re-delivered by a single replay
+	maxReplayMessages = util.GetEnvInt("MaxReplayMessages", 1000)
+
+	// the maximum number of messages a replay scans to locate the range
+	maxReplayScan = util.GetEnvInt("MaxReplayScan", 100000)
+
+	replayTimeout = util.GetEnvInt("ReplayTimeoutSeconds", 300)
+)
+
+// ReplayRange selects the messages to replay by publish time and/or message ID
+type ReplayRange struct {
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	StartMessageID pulsar.MessageID `json:"-"`
+	EndMessageID   pulsar.MessageID `json:"-"`
+	MaxMessages    int              `json:"maxMessages"`
+}
+
+// Validate validates and bounds the replay range
+func (r *ReplayRange) Validate() error {
+	if !r.From.IsZero() && !r.To.IsZero() && r.To.Before(r.From) {
+		return fmt.Errorf("replay range ends before it starts")
+	}
+	if r.MaxMessages < 0 {
+		return fmt.Errorf("max messages must be positive")
+	}
+	if r.MaxMessages == 0 || r.MaxMessages > maxReplayMessages {
+		r.MaxMessages = maxReplayMessages
+	}
+	return nil
+}
+
+// ReaderOptions returns the options of a temporary reader on the input topic.
+// The reader does not interfere with the live subscription.
+func (r *ReplayRange) ReaderOptions(topic string) pulsar.ReaderOptions {
+	opts := pulsar.ReaderOptions{
+		Topic:          topic,
+		StartMessageID: pulsar.EarliestMessageID(),
+	}
+	if r.StartMessageID != nil {
+		opts.StartMessageID = r.StartMessageID
+		opts.StartMessageIDInclusive = true
+	}
+	return opts
+}
+
+// Replay re-delivers messages in the range read by the reader to the webhook.
+// It returns the number of messages delivered.
+func (s *WebhookSender) Replay(reader pulsar.Reader, wh *model.WebhookConfig, r ReplayRange) (int, error) {
+	if err := r.Validate(); err != nil {
+		return 0, err
+	}
+	var end []byte
+	if r.EndMessageID != nil {
+		end = r.EndMessageID.Serialize()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(replayTimeout)*time.Second)
+	defer cancel()
+	delivered := 0
+	for scanned := 0; scanned < maxReplayScan && delivered < r.MaxMessages && reader.HasNext(); scanned++ {
+		msg, err := reader.Next(ctx)
+		if err != nil {
+			return delivered, err
+		}
+		if !r.To.IsZero() && msg.PublishTime().After(r.To) {
+			break
+		}
+		if r.From.IsZero() || !msg.PublishTime().Before(r.From) {
+			ok, err := s.Deliver(msg, wh)
+			if err != nil {
+				return delivered, err
+			}
+			if ok {
+				delivered++
+			}
+		}
+		if end != nil && bytes.Equal(msg.ID().Serialize(), end) {
+			break
+		}
+	}
+	return delivered, nil
+}
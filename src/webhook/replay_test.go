@@ -0,0 +1,78 @@
+package webhook
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+)
+
+// fakeReader reads the messages in turn
+type fakeReader struct {
+	pulsar.Reader
+	messages []pulsar.Message
+}
+
+func (r *fakeReader) HasNext() bool { return len(r.messages) > 0 }
+
+func (r *fakeReader) Next(context.Context) (pulsar.Message, error) {
+	msg := r.messages[0]
+	r.messages = r.messages[1:]
+	return msg, nil
+}
+
+func TestReplayRangeValidate(t *testing.T) {
+	now := time.Now()
+	cases := []struct {
+		name        string
+		r           ReplayRange
+		maxMessages int
+		invalid     bool
+	}{
+		{"default max messages", ReplayRange{}, maxReplayMessages, false},
+		{"bounded max messages", ReplayRange{MaxMessages: maxReplayMessages + 1}, maxReplayMessages, false},
+		{"requested max messages", ReplayRange{MaxMessages: 5}, 5, false},
+		{"negative max messages", ReplayRange{MaxMessages: -1}, 0, true},
+		{"ends before it starts", ReplayRange{From: now, To: now.Add(-time.Minute)}, 0, true},
+	}
+	for _, c := range cases {
+		err := c.r.Validate()
+		if c.invalid != (err != nil) {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if !c.invalid && c.r.MaxMessages != c.maxMessages {
+			t.Errorf("%s: expected max messages %d, got %d", c.name, c.maxMessages, c.r.MaxMessages)
+		}
+	}
+}
+
+func TestReplay(t *testing.T) {
+	start := time.Now()
+	messages := func() []pulsar.Message {
+		msgs := []pulsar.Message{}
+		for i := 0; i < 5; i++ {
+			msgs = append(msgs, &fakeMessage{payload: []byte(`{}`), published: start.Add(time.Duration(i) * time.Minute)})
+		}
+		return msgs
+	}
+	cases := []struct {
+		name      string
+		r         ReplayRange
+		delivered int
+	}{
+		{"all messages", ReplayRange{}, 5},
+		{"from a publish time", ReplayRange{From: start.Add(2 * time.Minute)}, 3},
+		{"to a publish time", ReplayRange{To: start.Add(time.Minute)}, 2},
+		{"bounded by max messages", ReplayRange{MaxMessages: 2}, 2},
+	}
+	for _, c := range cases {
+		server, bodies := newRecordingServer()
+		delivered, err := newTestSender(nil).Replay(&fakeReader{messages: messages()}, activeWebhook(server.URL), c.r)
+		calls := len(bodies())
+		server.Close()
+		if err != nil || delivered != c.delivered || calls != c.delivered {
+			t.Errorf("%s: expected %d deliveries, got %d and %d calls error %v", c.name, c.delivered, delivered, calls, err)
+		}
+	}
+}
@@ -0,0 +1,38 @@
+package webhook
+
+import (
+	"fmt"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// NewDbStatusReporter persists webhook status transitions of a function through the database Update.
+// Only status changes are written so that every delivery does not produce a database update.
+func NewDbStatusReporter(database db.Crud, functionKey string) StatusReporter {
+	return func(wh *model.WebhookConfig) {
+		if err := persistWebhookStatus(database, functionKey, wh); err != nil {
+			log.Errorf("failed to persist webhook %s status for function %s error %v", wh.URL, functionKey, err)
+		}
+	}
+}
+
+func persistWebhookStatus(database db.Crud, functionKey string, wh *model.WebhookConfig) error {
+	cfg, err := database.GetByKey(functionKey)
+	if err != nil {
+		return err
+	}
+	for i, v := range cfg.Webhooks {
+		if v.URL == wh.URL && v.Subscription == wh.Subscription {
+			if v.WebhookStatus == wh.WebhookStatus {
+				return nil
+			}
+			cfg.Webhooks[i] = *wh
+			_, err = database.Update(cfg)
+			return err
+		}
+	}
+	return fmt.Errorf("webhook %s is not configured", wh.URL)
+}
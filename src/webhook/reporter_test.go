@@ -0,0 +1,60 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/db"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+func TestSuspendAfterConsecutiveFailures(t *testing.T) {
+	server, calls := newTestServer(http.StatusBadRequest)
+	defer server.Close()
+
+	database, _ := db.NewInMemoryHandler()
+	key, err := database.Create(&model.FunctionConfig{
+		Tenant:   "t1",
+		Name:     "f1",
+		Webhooks: []model.WebhookConfig{*activeWebhook(server.URL)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, _ := database.GetByKey(key)
+	wh := cfg.Webhooks[0]
+
+	sender := newTestSender(NewDbStatusReporter(database, key))
+	sender.MaxFailures = 2
+	for i := 0; i < 2; i++ {
+		if err := sender.Send(&wh, []byte(`{}`)); err == nil {
+			t.Fatal("expected a delivery failure")
+		}
+	}
+	if wh.WebhookStatus != model.Suspended || wh.Failures != 2 {
+		t.Fatalf("expected the webhook suspended after 2 failures, got %s failures %d", wh.WebhookStatus, wh.Failures)
+	}
+	stored, _ := database.GetByKey(key)
+	if stored.Webhooks[0].WebhookStatus != model.Suspended {
+		t.Fatalf("the suspension is not persisted, got %s", stored.Webhooks[0].WebhookStatus)
+	}
+
+	if err := sender.Send(&wh, []byte(`{}`)); err == nil || *calls != 2 {
+		t.Fatalf("no delivery is attempted after the suspension, error %v calls %d", err, *calls)
+	}
+}
+
+func TestSuccessResetsFailures(t *testing.T) {
+	server, _ := newTestServer(http.StatusBadRequest, http.StatusOK, http.StatusBadRequest)
+	defer server.Close()
+
+	wh := activeWebhook(server.URL)
+	sender := newTestSender(nil)
+	sender.MaxFailures = 2
+	for i := 0; i < 3; i++ {
+		sender.Send(wh, []byte(`{}`))
+	}
+	if wh.WebhookStatus != model.Activated || wh.Failures != 1 {
+		t.Fatalf("a success must reset the consecutive failures, got %s failures %d", wh.WebhookStatus, wh.Failures)
+	}
+}
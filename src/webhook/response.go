@@ -0,0 +1,71 @@
+package webhook
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// the maximum number of response body bytes captured into the log topic
+var responseCaptureBytes = util.GetEnvInt("WebhookResponseCaptureBytes", 1024)
+
+// defaultRedactedHeaders are the response headers redacted unless WebhookRedactedHeaders is configured
+const defaultRedactedHeaders = "Set-Cookie,WWW-Authenticate,Proxy-Authenticate,Authorization"
+
+// ResponseSink receives the captured webhook replies
+type ResponseSink interface {
+	PublishResponse(level, message string, res pulsardriver.WebhookResponse) error
+}
+
+// captureResponse reads the bounded response body and publishes the reply to the response sink.
+// The rest of the body is drained so the connection can be reused.
+func (s *WebhookSender) captureResponse(url string, res *http.Response, latency time.Duration) {
+	sink, ok := s.Logs.(ResponseSink)
+	if !ok || responseCaptureBytes < 0 {
+		io.Copy(ioutil.Discard, res.Body)
+		return
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(res.Body, int64(responseCaptureBytes)))
+	extra, _ := io.Copy(ioutil.Discard, res.Body)
+
+	captured := pulsardriver.WebhookResponse{
+		URL:        url,
+		StatusCode: res.StatusCode,
+		Headers:    redactHeaders(res.Header),
+		Body:       string(body),
+		Truncated:  extra > 0,
+		LatencyMs:  latency.Milliseconds(),
+	}
+	level := pulsardriver.LogLevelInfo
+	if res.StatusCode >= http.StatusBadRequest {
+		level = pulsardriver.LogLevelWarn
+	}
+	message := fmt.Sprintf("webhook %s replied with status code %d", url, res.StatusCode)
+	if err := sink.PublishResponse(level, message, captured); err != nil {
+		log.Errorf("failed to publish webhook %s response error %v", url, err)
+	}
+}
+
+// redactHeaders flattens the response headers with the values of the redacted headers masked
+func redactHeaders(header http.Header) map[string]string {
+	redactedHeaders := strings.Split(util.AssignString(util.GetConfig().WebhookRedactedHeaders, defaultRedactedHeaders), ",")
+	headers := make(map[string]string)
+	for name, values := range header {
+		headers[name] = strings.Join(values, ", ")
+		for _, redacted := range redactedHeaders {
+			if strings.EqualFold(strings.TrimSpace(redacted), name) {
+				headers[name] = "***"
+				break
+			}
+		}
+	}
+	return headers
+}
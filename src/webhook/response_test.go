@@ -0,0 +1,81 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// responseLogs records the published webhook responses
+type responseLogs struct {
+	recordingLogs
+	levels    []string
+	responses []pulsardriver.WebhookResponse
+}
+
+func (l *responseLogs) PublishResponse(level, message string, res pulsardriver.WebhookResponse) error {
+	l.levels = append(l.levels, level)
+	l.responses = append(l.responses, res)
+	return nil
+}
+
+func TestCaptureResponse(t *testing.T) {
+	defer func(n int, redacted string) {
+		responseCaptureBytes, util.Config.WebhookRedactedHeaders = n, redacted
+	}(responseCaptureBytes, util.Config.WebhookRedactedHeaders)
+	responseCaptureBytes = 16
+
+	cases := []struct {
+		name       string
+		statusCode int
+		body       string
+		redacted   string
+		level      string
+		captured   string
+		truncated  bool
+		headers    map[string]string
+	}{
+		{"success", http.StatusOK, `{"ok":true}`, "", pulsardriver.LogLevelInfo, `{"ok":true}`, false,
+			map[string]string{"Set-Cookie": "***", "X-Trace": "t1"}},
+		{"server error", http.StatusInternalServerError, `{"error":"database unavailable"}`, "", pulsardriver.LogLevelWarn, `{"error":"databa`, true,
+			map[string]string{"Set-Cookie": "***", "X-Trace": "t1"}},
+		{"configured redacted headers", http.StatusOK, "", "X-Trace", pulsardriver.LogLevelInfo, "", false,
+			map[string]string{"Set-Cookie": "session=1", "X-Trace": "***"}},
+	}
+	for _, c := range cases {
+		util.Config.WebhookRedactedHeaders = c.redacted
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Set-Cookie", "session=1")
+			w.Header().Set("X-Trace", "t1")
+			w.WriteHeader(c.statusCode)
+			w.Write([]byte(c.body))
+		}))
+		logs := &responseLogs{}
+		s := newTestSender(nil)
+		s.MaxRetries, s.Logs = 0, logs
+		s.Send(activeWebhook(server.URL), []byte(`{}`))
+		server.Close()
+
+		if len(logs.responses) != 1 {
+			t.Fatalf("%s: expected one captured response, got %d", c.name, len(logs.responses))
+		}
+		res := logs.responses[0]
+		if logs.levels[0] != c.level || res.StatusCode != c.statusCode || res.URL != server.URL {
+			t.Errorf("%s: unexpected response event %s %+v", c.name, logs.levels[0], res)
+		}
+		if res.Body != c.captured || res.Truncated != c.truncated {
+			t.Errorf("%s: expected body %q truncated %v, got %q %v", c.name, c.captured, c.truncated, res.Body, res.Truncated)
+		}
+		for name, value := range c.headers {
+			if res.Headers[name] != value {
+				t.Errorf("%s: expected header %s %q, got %q", c.name, name, value, res.Headers[name])
+			}
+		}
+		if res.LatencyMs < 0 {
+			t.Errorf("%s: unexpected latency %d", c.name, res.LatencyMs)
+		}
+	}
+}
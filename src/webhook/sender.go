@@ -0,0 +1,345 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/icrypto"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+
+	log "github.com/sirupsen/logrus"
+)
+
+func webhookRetries() int { return util.ConfigInt(util.GetConfig().WebhookRetries, 3) }
+
+func webhookBaseDelay() time.Duration {
+	return time.Duration(util.ConfigInt(util.GetConfig().WebhookRetryBaseDelayMs, 100)) * time.Millisecond
+}
+
+// webhookMaxFailures is the number of consecutive failed deliveries before a webhook is suspended
+func webhookMaxFailures() int {
+	return util.ConfigInt(util.GetConfig().WebhookMaxConsecutiveFailures, 10)
+}
+
+// webhookTimeout is the default delivery timeout if the webhook does not specify one
+func webhookTimeout() time.Duration {
+	return time.Duration(util.ConfigInt(util.GetConfig().WebhookTimeoutMs, 30000)) * time.Millisecond
+}
+
+var (
+	// webhookClient is shared across all deliveries to reuse connections to webhook endpoints,
+	// it is created on the first use so the configured keep-alive settings apply
+	webhookClient     *http.Client
+	webhookClientOnce sync.Once
+)
+
+func sharedClient() *http.Client {
+	webhookClientOnce.Do(func() {
+		webhookClient = &http.Client{Transport: newTransport(nil)}
+	})
+	return webhookClient
+}
+
+func newTransport(tlsConfig *tls.Config) *http.Transport {
+	return &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        util.ConfigInt(util.GetConfig().WebhookMaxIdleConns, 100),
+		MaxIdleConnsPerHost: util.ConfigInt(util.GetConfig().WebhookMaxIdleConnsPerHost, 10),
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+		TLSClientConfig:     tlsConfig,
+	}
+}
+
+var (
+	// tlsClients are the clients with a client certificate keyed by the certificate and key paths
+	tlsClients     = make(map[string]*http.Client)
+	tlsClientsLock sync.Mutex
+)
+
+// clientFor returns the http client of the webhook, a client with the webhook client certificate
+// is created once and shared by the webhooks with the same certificate
+func (s *WebhookSender) clientFor(wh *model.WebhookConfig) (*http.Client, error) {
+	if wh.ClientCertPath == "" {
+		return s.Client, nil
+	}
+	key := wh.ClientCertPath + ":" + wh.ClientKeyPath
+	tlsClientsLock.Lock()
+	defer tlsClientsLock.Unlock()
+	if client, ok := tlsClients[key]; ok {
+		return client, nil
+	}
+	cert, err := tls.LoadX509KeyPair(wh.ClientCertPath, wh.ClientKeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load webhook %s client certificate %v", wh.URL, err)
+	}
+	client := &http.Client{
+		Transport: newTransport(&tls.Config{Certificates: []tls.Certificate{cert}}),
+	}
+	tlsClients[key] = client
+	return client, nil
+}
+
+// SignatureHeader carries the HMAC-SHA256 signature of the payload for signed webhooks
+const SignatureHeader = "X-Signature"
+
+// StatusReporter is called after every delivery attempt so the webhook state can be persisted
+type StatusReporter func(wh *model.WebhookConfig)
+
+// LogSink receives function log events such as failed deliveries
+type LogSink interface {
+	Publish(level, message string) error
+}
+
+// WebhookSender delivers message payloads to webhooks
+type WebhookSender struct {
+	// FunctionID labels the delivery metrics
+	FunctionID  string
+	Client      *http.Client
+	MaxRetries  int
+	BaseDelay   time.Duration
+	MaxFailures int
+	Reporter    StatusReporter
+	Logs        LogSink
+	// AckMode is the acknowledgment mode of the consumed messages, individual by default
+	AckMode string
+	// Output produces the replies of the delivered messages, the replies are discarded if nil
+	Output OutputFunc
+
+	// circuit breakers per webhook URL
+	breakers     map[string]*CircuitBreaker
+	breakersLock sync.Mutex
+
+	// inflight bounds the concurrent deliveries, it is unbounded if nil
+	inflight chan struct{}
+
+	// stateLock guards the delivery state of the webhooks shared by concurrent deliveries
+	stateLock sync.Mutex
+}
+
+// NewWebhookSender creates a webhook sender with the default retry settings
+func NewWebhookSender(reporter StatusReporter) *WebhookSender {
+	return &WebhookSender{
+		Client:      sharedClient(),
+		MaxRetries:  webhookRetries(),
+		BaseDelay:   webhookBaseDelay(),
+		MaxFailures: webhookMaxFailures(),
+		Reporter:    reporter,
+		breakers:    make(map[string]*CircuitBreaker),
+	}
+}
+
+// NewFunctionSender creates a webhook sender of the function whose concurrent deliveries are bounded
+// by the function maximum concurrent deliveries
+func NewFunctionSender(cfg *model.FunctionConfig, reporter StatusReporter) *WebhookSender {
+	s := NewWebhookSender(reporter)
+	s.FunctionID = cfg.ID
+	s.AckMode = cfg.InputTopic.AckMode
+	s.inflight = make(chan struct{}, model.MaxDeliveries(cfg))
+	return s
+}
+
+// Breaker returns the circuit breaker of the webhook URL
+func (s *WebhookSender) Breaker(url string) *CircuitBreaker {
+	s.breakersLock.Lock()
+	defer s.breakersLock.Unlock()
+	if s.breakers == nil {
+		s.breakers = make(map[string]*CircuitBreaker)
+	}
+	b, ok := s.breakers[url]
+	if !ok {
+		b = NewCircuitBreaker()
+		s.breakers[url] = b
+	}
+	return b
+}
+
+// Send posts the payload to the webhook URL with the configured headers.
+// Server errors and connection failures are retried with exponential backoff,
+// client errors are returned immediately since a retry would not change the outcome.
+// A webhook is suspended after MaxFailures consecutive failed deliveries
+// and no further delivery is attempted until it is activated again.
+func (s *WebhookSender) Send(wh *model.WebhookConfig, payload []byte) error {
+	_, err := s.send(wh, payload, nil)
+	return err
+}
+
+// OutputFunc produces the webhook reply of the input message
+type OutputFunc func(input pulsar.Message, reply []byte) error
+
+// maxReplyBytes is the maximum reply read for the output, the Pulsar default maximum message size
+const maxReplyBytes = 5 << 20
+
+// send delivers the payload with the extra headers in addition to the webhook headers.
+// The reply body is returned only if the sender has an output.
+func (s *WebhookSender) send(wh *model.WebhookConfig, payload []byte, extraHeaders []string) ([]byte, error) {
+	if s.inflight != nil {
+		// excess deliveries block until an in-flight delivery completes
+		s.inflight <- struct{}{}
+		defer func() { <-s.inflight }()
+	}
+	if !s.activated(wh) {
+		return nil, fmt.Errorf("webhook %s is not activated", wh.URL)
+	}
+
+	var statusCode, attempts int
+	var reply []byte
+	headers, err := ResolveHeaders(wh.Headers)
+	if err != nil {
+		s.report(wh, statusCode, attempts, err)
+		return nil, err
+	}
+	headers = append(headers, extraHeaders...)
+	password, err := resolveHeader(wh.BasicAuthPass)
+	if err != nil {
+		s.report(wh, statusCode, attempts, err)
+		return nil, err
+	}
+	client, err := s.clientFor(wh)
+	if err != nil {
+		s.report(wh, statusCode, attempts, err)
+		return nil, err
+	}
+
+	breaker := s.Breaker(wh.URL)
+	if !breaker.Allow() {
+		return nil, fmt.Errorf("webhook %s circuit breaker is %s", wh.URL, breaker.State())
+	}
+	for attempts < s.MaxRetries+1 {
+		if attempts > 0 {
+			num := int64(math.Pow(2, float64(attempts-1)))
+			time.Sleep(time.Duration(num) * s.BaseDelay)
+		}
+		attempts++
+		statusCode, reply, err = s.post(client, wh, headers, password, payload)
+		if err == nil && statusCode < http.StatusInternalServerError {
+			break
+		}
+		log.Warnf("webhook %s delivery attempt %d status code %d error %v", wh.URL, attempts, statusCode, err)
+	}
+	// client errors mean the endpoint is up so only server errors trip the breaker
+	breaker.Record(err == nil && statusCode < http.StatusInternalServerError)
+
+	if err == nil && (statusCode < http.StatusOK || statusCode >= http.StatusMultipleChoices) {
+		err = fmt.Errorf("webhook %s replied with status code %d", wh.URL, statusCode)
+	}
+	s.report(wh, statusCode, attempts, err)
+	return reply, err
+}
+
+// post sends the payload once, the password is the resolved basic auth password.
+// The reply body is read for the output before the response is captured.
+func (s *WebhookSender) post(client *http.Client, wh *model.WebhookConfig, headers []string, password string, payload []byte) (int, []byte, error) {
+	timeout := webhookTimeout()
+	if wh.TimeoutMs > 0 {
+		timeout = time.Duration(wh.TimeoutMs) * time.Millisecond
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req, err := http.NewRequest(http.MethodPost, wh.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	if wh.Signed {
+		req.Header.Set(SignatureHeader, "sha256="+icrypto.SignHMACSHA256([]byte(wh.Secret), payload))
+	}
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return 0, nil, fmt.Errorf("malformed webhook header %s", h)
+		}
+		req.Header.Set(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+	if wh.BasicAuthUser != "" {
+		req.SetBasicAuth(wh.BasicAuthUser, password)
+	}
+
+	start := time.Now()
+	res, err := client.Do(req)
+	if err != nil {
+		observeDelivery(s.FunctionID, wh.URL, start, 0, err)
+		return 0, nil, err
+	}
+	observeDelivery(s.FunctionID, wh.URL, start, res.StatusCode, nil)
+	defer res.Body.Close()
+	var reply []byte
+	if s.Output != nil {
+		if reply, err = ioutil.ReadAll(io.LimitReader(res.Body, maxReplyBytes+1)); err != nil {
+			return 0, nil, err
+		}
+		if len(reply) > maxReplyBytes {
+			return 0, nil, fmt.Errorf("webhook %s reply exceeds %d bytes", wh.URL, maxReplyBytes)
+		}
+		res.Body = ioutil.NopCloser(bytes.NewReader(reply))
+	}
+	s.captureResponse(wh.URL, res, time.Since(start))
+	return res.StatusCode, reply, nil
+}
+
+// activated returns whether the webhook is activated, a webhook can be suspended by a concurrent delivery
+func (s *WebhookSender) activated(wh *model.WebhookConfig) bool {
+	s.stateLock.Lock()
+	defer s.stateLock.Unlock()
+	return wh.WebhookStatus == model.Activated
+}
+
+// report records the delivery result on the webhook and reports a snapshot of the webhook state
+func (s *WebhookSender) report(wh *model.WebhookConfig, statusCode, attempts int, err error) {
+	breaker := s.Breaker(wh.URL).State().String()
+	s.stateLock.Lock()
+	wh.LastReply = model.WebhookReply{
+		StatusCode: statusCode,
+		Attempts:   attempts,
+		RepliedAt:  time.Now(),
+		Breaker:    breaker,
+	}
+	suspended := false
+	if err != nil {
+		wh.LastReply.Error = err.Error()
+		wh.Failures++
+		if s.MaxFailures > 0 && wh.Failures >= s.MaxFailures && wh.WebhookStatus != model.Suspended {
+			wh.WebhookStatus = model.Suspended
+			wh.UpdatedAt = time.Now()
+			suspended = true
+		}
+	} else {
+		wh.Failures = 0
+	}
+	snapshot := *wh
+	s.stateLock.Unlock()
+
+	if err != nil {
+		s.log(pulsardriver.LogLevelWarn, fmt.Sprintf("webhook %s delivery failed after %d attempts error %v", wh.URL, attempts, err))
+	}
+	if suspended {
+		log.Errorf("suspend webhook %s after %d consecutive failures, last error %v", wh.URL, snapshot.Failures, err)
+		s.log(pulsardriver.LogLevelError, fmt.Sprintf("webhook %s suspended after %d consecutive failures", wh.URL, snapshot.Failures))
+	}
+	if s.Reporter != nil {
+		s.Reporter(&snapshot)
+	}
+}
+
+func (s *WebhookSender) log(level, message string) {
+	if s.Logs == nil {
+		return
+	}
+	if err := s.Logs.Publish(level, message); err != nil {
+		log.Errorf("failed to publish function log error %v", err)
+	}
+}
@@ -0,0 +1,245 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+	"github.com/kafkaesque-io/pubsub-function/src/pulsardriver"
+	"github.com/kafkaesque-io/pubsub-function/src/util"
+)
+
+// newTestServer replies with the status codes in turn, the last status code is repeated
+func newTestServer(statusCodes ...int) (*httptest.Server, *int32) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		i := int(atomic.AddInt32(&calls, 1)) - 1
+		if i >= len(statusCodes) {
+			i = len(statusCodes) - 1
+		}
+		w.WriteHeader(statusCodes[i])
+	}))
+	return server, &calls
+}
+
+func newTestSender(reporter StatusReporter) *WebhookSender {
+	s := NewWebhookSender(reporter)
+	s.MaxRetries = 2
+	s.BaseDelay = time.Millisecond
+	return s
+}
+
+func activeWebhook(url string) *model.WebhookConfig {
+	return &model.WebhookConfig{URL: url, WebhookStatus: model.Activated}
+}
+
+func TestSendRetries(t *testing.T) {
+	cases := []struct {
+		name        string
+		statusCodes []int
+		calls       int32
+		fails       bool
+	}{
+		{"success", []int{http.StatusOK}, 1, false},
+		{"client error is not retried", []int{http.StatusBadRequest}, 1, true},
+		{"server error is retried until success", []int{http.StatusBadGateway, http.StatusOK}, 2, false},
+		{"server error is retried then given up", []int{http.StatusInternalServerError}, 3, true},
+	}
+	for _, c := range cases {
+		server, calls := newTestServer(c.statusCodes...)
+		reports := 0
+		wh := activeWebhook(server.URL)
+		err := newTestSender(func(*model.WebhookConfig) { reports++ }).Send(wh, []byte(`{}`))
+		server.Close()
+
+		if (err != nil) != c.fails {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+		if *calls != c.calls || wh.LastReply.Attempts != int(c.calls) {
+			t.Errorf("%s: expected %d attempts, got %d calls and %d attempts", c.name, c.calls, *calls, wh.LastReply.Attempts)
+		}
+		if wh.LastReply.StatusCode != c.statusCodes[len(c.statusCodes)-1] || reports != 1 {
+			t.Errorf("%s: the webhook status is not tracked %+v reports %d", c.name, wh.LastReply, reports)
+		}
+	}
+}
+
+func TestSendInactiveWebhook(t *testing.T) {
+	server, calls := newTestServer(http.StatusOK)
+	defer server.Close()
+	wh := activeWebhook(server.URL)
+	wh.WebhookStatus = model.Suspended
+	if err := newTestSender(nil).Send(wh, []byte(`{}`)); err == nil || *calls != 0 {
+		t.Fatalf("a suspended webhook must not be delivered, error %v calls %d", err, *calls)
+	}
+}
+
+func TestSendSignedPayload(t *testing.T) {
+	var signature string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature = r.Header.Get(SignatureHeader)
+	}))
+	defer server.Close()
+
+	wh := activeWebhook(server.URL)
+	wh.Signed, wh.Secret = true, "Jefe"
+	if err := newTestSender(nil).Send(wh, []byte("what do ya want for nothing?")); err != nil {
+		t.Fatal(err)
+	}
+	if signature != "sha256=5bdcc146bf60754e6a042426089575c75a003f089d2739839dec58b964ec3843" {
+		t.Fatalf("unexpected signature header %s", signature)
+	}
+
+	wh.Signed = false
+	if err := newTestSender(nil).Send(wh, []byte("{}")); err != nil || signature != "" {
+		t.Fatalf("an unsigned webhook must not carry a signature, got %s error %v", signature, err)
+	}
+}
+
+func TestSendBasicAuth(t *testing.T) {
+	os.Setenv("PUBSUBFN_SECRET_HOOK_PASS", "s3cret")
+	defer os.Unsetenv("PUBSUBFN_SECRET_HOOK_PASS")
+	os.Setenv("DbPassword", "leaked")
+	defer os.Unsetenv("DbPassword")
+
+	cases := []struct {
+		name     string
+		user     string
+		pass     string
+		auth     bool
+		password string
+		fails    bool
+	}{
+		{"no basic auth", "", "", false, "", false},
+		{"plain password", "alice", "plain", true, "plain", false},
+		{"secret reference", "alice", "${PUBSUBFN_SECRET_HOOK_PASS}", true, "s3cret", false},
+		{"unprefixed reference is not resolved", "alice", "${DbPassword}", false, "", true},
+	}
+	for _, c := range cases {
+		var user, password string
+		var auth, called bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			user, password, auth = r.BasicAuth()
+		}))
+		wh := activeWebhook(server.URL)
+		wh.BasicAuthUser, wh.BasicAuthPass = c.user, c.pass
+		err := newTestSender(nil).Send(wh, []byte(`{}`))
+		server.Close()
+
+		if (err != nil) != c.fails || called == c.fails {
+			t.Errorf("%s: expected failure %v, got %v called %v", c.name, c.fails, err, called)
+		}
+		if auth != c.auth || (c.auth && user != c.user) || password != c.password {
+			t.Errorf("%s: expected basic auth %v %s:%s, got %v %s:%s", c.name, c.auth, c.user, c.password, auth, user, password)
+		}
+	}
+}
+
+// recordingLogs records the published function log levels
+type recordingLogs struct {
+	levels []string
+}
+
+func (l *recordingLogs) Publish(level, message string) error {
+	l.levels = append(l.levels, level)
+	return nil
+}
+
+func TestSendPublishesFunctionLogs(t *testing.T) {
+	server, _ := newTestServer(http.StatusOK, http.StatusBadRequest)
+	defer server.Close()
+	logs := &recordingLogs{}
+	s := newTestSender(nil)
+	s.MaxFailures = 2
+	s.Logs = logs
+	wh := activeWebhook(server.URL)
+
+	cases := []struct {
+		name   string
+		levels []string
+	}{
+		{"delivered", []string{}},
+		{"failed", []string{pulsardriver.LogLevelWarn}},
+		{"suspended", []string{pulsardriver.LogLevelWarn, pulsardriver.LogLevelWarn, pulsardriver.LogLevelError}},
+	}
+	for _, c := range cases {
+		s.Send(wh, []byte(`{}`))
+		if strings.Join(logs.levels, ",") != strings.Join(c.levels, ",") {
+			t.Errorf("%s: expected log levels %v, got %v", c.name, c.levels, logs.levels)
+		}
+	}
+	if wh.WebhookStatus != model.Suspended {
+		t.Fatalf("the webhook must be suspended after the consecutive failures, got %s", wh.WebhookStatus)
+	}
+}
+
+func TestSendTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	cases := []struct {
+		name      string
+		timeoutMs int
+		fails     bool
+	}{
+		{"webhook timeout shorter than the reply", 10, true},
+		{"webhook timeout longer than the reply", 1000, false},
+		{"default timeout", 0, false},
+	}
+	for _, c := range cases {
+		wh := activeWebhook(server.URL)
+		wh.TimeoutMs = c.timeoutMs
+		s := newTestSender(nil)
+		s.MaxRetries = 0
+		if err := s.Send(wh, []byte(`{}`)); (err != nil) != c.fails {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+		}
+	}
+	if NewWebhookSender(nil).Client != NewWebhookSender(nil).Client {
+		t.Error("the senders must share the http client to reuse connections")
+	}
+}
+
+func TestNewWebhookSenderConfig(t *testing.T) {
+	defer func(retries, delay, failures string) {
+		util.Config.WebhookRetries, util.Config.WebhookRetryBaseDelayMs, util.Config.WebhookMaxConsecutiveFailures = retries, delay, failures
+	}(util.Config.WebhookRetries, util.Config.WebhookRetryBaseDelayMs, util.Config.WebhookMaxConsecutiveFailures)
+
+	util.Config.WebhookRetries, util.Config.WebhookRetryBaseDelayMs, util.Config.WebhookMaxConsecutiveFailures = "", "", ""
+	s := NewWebhookSender(nil)
+	if s.MaxRetries != 3 || s.BaseDelay != 100*time.Millisecond || s.MaxFailures != 10 {
+		t.Errorf("unexpected default settings %d %v %d", s.MaxRetries, s.BaseDelay, s.MaxFailures)
+	}
+
+	util.Config.WebhookRetries, util.Config.WebhookRetryBaseDelayMs, util.Config.WebhookMaxConsecutiveFailures = "5", "250", "0"
+	s = NewWebhookSender(nil)
+	if s.MaxRetries != 5 || s.BaseDelay != 250*time.Millisecond || s.MaxFailures != 0 {
+		t.Errorf("the configured settings are not applied %d %v %d", s.MaxRetries, s.BaseDelay, s.MaxFailures)
+	}
+}
+
+func TestWebhookClientConfig(t *testing.T) {
+	defer func(timeout, idle, idlePerHost string) {
+		util.Config.WebhookTimeoutMs, util.Config.WebhookMaxIdleConns, util.Config.WebhookMaxIdleConnsPerHost = timeout, idle, idlePerHost
+	}(util.Config.WebhookTimeoutMs, util.Config.WebhookMaxIdleConns, util.Config.WebhookMaxIdleConnsPerHost)
+
+	util.Config.WebhookTimeoutMs, util.Config.WebhookMaxIdleConns, util.Config.WebhookMaxIdleConnsPerHost = "", "", ""
+	transport := newTransport(nil)
+	if webhookTimeout() != 30*time.Second || transport.MaxIdleConns != 100 || transport.MaxIdleConnsPerHost != 10 {
+		t.Errorf("unexpected default settings %v %d %d", webhookTimeout(), transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+
+	util.Config.WebhookTimeoutMs, util.Config.WebhookMaxIdleConns, util.Config.WebhookMaxIdleConnsPerHost = "500", "20", "4"
+	transport = newTransport(nil)
+	if webhookTimeout() != 500*time.Millisecond || transport.MaxIdleConns != 20 || transport.MaxIdleConnsPerHost != 4 {
+		t.Errorf("the configured settings are not applied %v %d %d", webhookTimeout(), transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+}
@@ -0,0 +1,55 @@
+package webhook
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/apache/pulsar-client-go/pulsar"
+	"github.com/kafkaesque-io/pubsub-function/src/model"
+)
+
+// Envelope wraps a message payload with the message metadata
+type Envelope struct {
+	Topic       string            `json:"topic"`
+	Key         string            `json:"key"`
+	PublishTime time.Time         `json:"publishTime"`
+	EventTime   time.Time         `json:"eventTime"`
+	Properties  map[string]string `json:"properties"`
+	Payload     json.RawMessage   `json:"payload"`
+}
+
+// Transform rewrites the message payload for delivery according to the payload mode
+func Transform(msg pulsar.Message, mode string) ([]byte, error) {
+	payloadMode, err := model.GetPayloadMode(mode)
+	if err != nil {
+		return nil, err
+	}
+	if payloadMode == model.PayloadRaw {
+		return msg.Payload(), nil
+	}
+
+	payload, err := jsonPayload(msg.Payload())
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(Envelope{
+		Topic:       msg.Topic(),
+		Key:         msg.Key(),
+		PublishTime: msg.PublishTime(),
+		EventTime:   msg.EventTime(),
+		Properties:  msg.Properties(),
+		Payload:     payload,
+	})
+}
+
+// jsonPayload embeds a payload in JSON, a non JSON payload becomes a JSON string
+func jsonPayload(payload []byte) (json.RawMessage, error) {
+	if json.Valid(payload) {
+		return json.RawMessage(payload), nil
+	}
+	data, err := json.Marshal(string(payload))
+	if err != nil {
+		return nil, err
+	}
+	return json.RawMessage(data), nil
+}
@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestTransform(t *testing.T) {
+	cases := []struct {
+		name    string
+		mode    string
+		payload string
+		want    string
+		invalid bool
+	}{
+		{"default is raw", "", `{"a":1}`, `{"a":1}`, false},
+		{"raw keeps a non json payload", "raw", `plain`, `plain`, false},
+		{"envelope embeds a json payload", "envelope", `{"a":1}`, `{"a":1}`, false},
+		{"envelope quotes a non json payload", "ENVELOPE", `plain`, `"plain"`, false},
+		{"unknown mode", "xml", `{}`, "", true},
+	}
+	for _, c := range cases {
+		msg := &fakeMessage{topic: "persistent://t1/ns/in", key: "k1", payload: []byte(c.payload)}
+		data, err := Transform(msg, c.mode)
+		if c.invalid != (err != nil) {
+			t.Errorf("%s: unexpected error %v", c.name, err)
+			continue
+		}
+		if c.invalid {
+			continue
+		}
+		if c.mode == "" || c.mode == "raw" {
+			if string(data) != c.want {
+				t.Errorf("%s: expected %s, got %s", c.name, c.want, data)
+			}
+			continue
+		}
+		var envelope Envelope
+		if err := json.Unmarshal(data, &envelope); err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		if string(envelope.Payload) != c.want || envelope.Topic != msg.topic || envelope.Key != msg.key {
+			t.Errorf("%s: unexpected envelope %s", c.name, data)
+		}
+	}
+}